@@ -0,0 +1,228 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/iotexproject/phoenix/db"
+	"github.com/iotexproject/phoenix/json"
+)
+
+const (
+	accessRequestNamespace = "accessrequest"
+	accessSecretNamespace  = "accesssecret"
+)
+
+// states of access request
+const (
+	AccessPending  = "pending"
+	AccessApproved = "approved"
+	AccessDenied   = "denied"
+	AccessRedeemed = "redeemed"
+)
+
+var (
+	// ErrAccessRequestNotFound is returned if the access request doesn't exist
+	ErrAccessRequestNotFound = errors.New("access request not found")
+
+	// ErrAccessRequestState is returned if the access request can't move from its state to the requested one
+	ErrAccessRequestState = errors.New("invalid state of access request")
+
+	// ErrAccessSecret is returned if the secret doesn't match the one of the access request
+	ErrAccessSecret = errors.New("invalid secret of access request")
+)
+
+// accessTransitions maps each state to the states an access request must be in to move to it, a redeemed
+// request moves back to approved if its token fails to be minted
+var accessTransitions = map[string][]string{
+	AccessApproved: {AccessPending, AccessRedeemed},
+	AccessDenied:   {AccessPending},
+	AccessRedeemed: {AccessApproved},
+}
+
+type (
+	// AccessRequest is a trustee's request for access to owner's data, which the owner approves or denies.
+	// An approved request is backed by a grant, whose token the trustee redeems with the secret of the request
+	AccessRequest struct {
+		ID            string `json:"id"`
+		Owner         string `json:"owner"`
+		Trustee       string `json:"trustee"`
+		Subject       string `json:"subject"`
+		Scope         string `json:"scope"`
+		Duration      int64  `json:"duration"` // second, the granted token is valid for since approval
+		Justification string `json:"justification"`
+		State         string `json:"state"`
+		Reason        string `json:"reason,omitempty"`
+		GrantID       string `json:"grantId,omitempty"`
+		CreatedAt     int64  `json:"createdAt"`
+		UpdatedAt     int64  `json:"updatedAt"`
+	}
+
+	AccessRequests interface {
+		// FileAccessRequest puts trustee's pending request into db, and returns the secret to redeem it with
+		FileAccessRequest(*AccessRequest) (string, error)
+
+		// GetAccessRequest returns owner's access request according to request ID
+		GetAccessRequest(string, string) (*AccessRequest, error)
+
+		// ListAccessRequests returns owner's access requests in the state, all requests if the state is empty
+		ListAccessRequests(string, string) ([]*AccessRequest, error)
+
+		// MoveAccessRequest moves the access request to the state and rewrites it, ErrAccessRequestState is
+		// returned if the request has moved from the state it was read in since
+		MoveAccessRequest(*AccessRequest, string) error
+
+		// VerifySecret returns ErrAccessSecret if the secret isn't the one of owner's access request
+		VerifySecret(string, string, string) error
+	}
+
+	accessRequests struct {
+		db.KVStore
+	}
+)
+
+func NewAccessRequests(kv db.KVStore) AccessRequests {
+	return &accessRequests{
+		KVStore: kv,
+	}
+}
+
+// Store returns the name of the registered store the access request refers to
+func (req *AccessRequest) Store() string {
+	return strings.SplitN(req.Subject, "/", 2)[0]
+}
+
+func accessRequestKey(namespace, id string) []byte {
+	return []byte(namespace + "/" + id)
+}
+
+func (a *accessRequests) FileAccessRequest(req *AccessRequest) (string, error) {
+	if req.Owner == "" || req.Trustee == "" || req.Subject == "" || req.Scope == "" || req.Duration <= 0 {
+		return "", errors.New("owner, trustee, subject, scope and duration are required")
+	}
+	id, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+	req.ID, req.State, req.Reason, req.GrantID = id, AccessPending, "", ""
+	req.CreatedAt = time.Now().Unix()
+	req.UpdatedAt = req.CreatedAt
+	if err := a.put(req); err != nil {
+		return "", err
+	}
+	h := sha256.Sum256([]byte(secret))
+	if err := a.Put(accessSecretNamespace, accessRequestKey(req.Owner, id), h[:]); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+func (a *accessRequests) GetAccessRequest(namespace, id string) (*AccessRequest, error) {
+	v, err := a.Get(accessRequestNamespace, accessRequestKey(namespace, id))
+	switch errors.Cause(err) {
+	case nil:
+		break
+	case db.ErrBucketNotExist, db.ErrNotExist:
+		return nil, errors.Wrapf(ErrAccessRequestNotFound, "access request %s", id)
+	default:
+		return nil, err
+	}
+	req := &AccessRequest{}
+	if err := json.Unmarshal(v, req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func (a *accessRequests) ListAccessRequests(namespace, state string) ([]*AccessRequest, error) {
+	_, values, err := a.List(accessRequestNamespace, []byte(namespace+"/"))
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*AccessRequest, 0, len(values))
+	for _, v := range values {
+		req := &AccessRequest{}
+		if err := json.Unmarshal(v, req); err != nil {
+			return nil, err
+		}
+		if state == "" || req.State == state {
+			list = append(list, req)
+		}
+	}
+	return list, nil
+}
+
+func (a *accessRequests) MoveAccessRequest(req *AccessRequest, state string) error {
+	if !canMove(req.State, state) {
+		return errors.Wrapf(ErrAccessRequestState, "access request is %s, can't be %s", req.State, state)
+	}
+	moved := *req
+	moved.State, moved.UpdatedAt = state, time.Now().Unix()
+	err := a.Update(accessRequestNamespace, accessRequestKey(req.Owner, req.ID), func(v []byte) ([]byte, error) {
+		if v == nil {
+			return nil, errors.Wrapf(ErrAccessRequestNotFound, "access request %s", req.ID)
+		}
+		stored := &AccessRequest{}
+		if err := json.Unmarshal(v, stored); err != nil {
+			return nil, err
+		}
+		// the request is moved once, by whoever reads it in the state first
+		if stored.State != req.State {
+			return nil, errors.Wrapf(ErrAccessRequestState, "access request is %s, can't be %s", stored.State, state)
+		}
+		return json.Marshal(&moved)
+	})
+	if err != nil {
+		return err
+	}
+	*req = moved
+	return nil
+}
+
+func canMove(from, to string) bool {
+	for _, state := range accessTransitions[to] {
+		if state == from {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *accessRequests) VerifySecret(namespace, id, secret string) error {
+	v, err := a.Get(accessSecretNamespace, accessRequestKey(namespace, id))
+	switch errors.Cause(err) {
+	case nil:
+		break
+	case db.ErrBucketNotExist, db.ErrNotExist:
+		return errors.Wrapf(ErrAccessRequestNotFound, "access request %s", id)
+	default:
+		return err
+	}
+	h := sha256.Sum256([]byte(secret))
+	if subtle.ConstantTimeCompare(v, h[:]) != 1 {
+		return ErrAccessSecret
+	}
+	return nil
+}
+
+func (a *accessRequests) put(req *AccessRequest) error {
+	v, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	return a.Put(accessRequestNamespace, accessRequestKey(req.Owner, req.ID), v)
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/iotexproject/iotex-antenna-go/v2/jwt"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iotexproject/phoenix/db"
+)
+
+func TestAccessRequests(t *testing.T) {
+	r := require.New(t)
+
+	testFile, err := ioutil.TempFile(os.TempDir(), "test-bolt")
+	path := testFile.Name()
+	r.NoError(err)
+	testFile.Close()
+	defer func() {
+		r.NoError(os.Remove(path))
+	}()
+
+	d := db.NewBoltDB(path)
+	ctx := context.Background()
+	r.NoError(d.Start(ctx))
+	defer func() {
+		r.NoError(d.Stop(ctx))
+	}()
+
+	a := NewAccessRequests(d)
+	_, err = a.FileAccessRequest(&AccessRequest{Owner: "owner", Trustee: "alice", Subject: "s3/reports", Scope: jwt.READ})
+	r.Error(err)
+
+	req := &AccessRequest{Owner: "owner", Trustee: "alice", Subject: "s3/reports", Scope: jwt.READ, Duration: 3600, Justification: "Q1 audit"}
+	secret, err := a.FileAccessRequest(req)
+	r.NoError(err)
+	r.NotEmpty(req.ID)
+	r.Equal(AccessPending, req.State)
+	r.Equal("s3", req.Store())
+	other := &AccessRequest{Owner: "owner", Trustee: "bob", Subject: "s3", Scope: jwt.DELETE, Duration: 60}
+	_, err = a.FileAccessRequest(other)
+	r.NoError(err)
+
+	r.NoError(a.VerifySecret("owner", req.ID, secret))
+	r.Equal(ErrAccessSecret, errors.Cause(a.VerifySecret("owner", req.ID, "wrong")))
+	r.Equal(ErrAccessRequestNotFound, errors.Cause(a.VerifySecret("other", req.ID, secret)))
+
+	// pending -> approved -> redeemed
+	r.Equal(ErrAccessRequestState, errors.Cause(a.MoveAccessRequest(req, AccessRedeemed)))
+	r.NoError(a.MoveAccessRequest(req, AccessApproved))
+	r.Equal(ErrAccessRequestState, errors.Cause(a.MoveAccessRequest(req, AccessDenied)))
+	// a request read before it moved can't be moved again
+	stale, err := a.GetAccessRequest("owner", req.ID)
+	r.NoError(err)
+	r.NoError(a.MoveAccessRequest(req, AccessRedeemed))
+	r.Equal(ErrAccessRequestState, errors.Cause(a.MoveAccessRequest(req, AccessRedeemed)))
+	r.Equal(ErrAccessRequestState, errors.Cause(a.MoveAccessRequest(stale, AccessRedeemed)))
+	r.Equal(AccessApproved, stale.State)
+	got, err := a.GetAccessRequest("owner", req.ID)
+	r.NoError(err)
+	r.Equal(AccessRedeemed, got.State)
+	// redeemed -> approved if the token failed to be minted
+	r.NoError(a.MoveAccessRequest(got, AccessApproved))
+	r.NoError(a.MoveAccessRequest(got, AccessRedeemed))
+	r.Equal(ErrAccessRequestNotFound, errors.Cause(a.MoveAccessRequest(&AccessRequest{Owner: "owner", ID: "unknown", State: AccessPending}, AccessDenied)))
+
+	// pending -> denied
+	r.NoError(a.MoveAccessRequest(other, AccessDenied))
+	r.Equal(ErrAccessRequestState, errors.Cause(a.MoveAccessRequest(other, AccessApproved)))
+
+	list, err := a.ListAccessRequests("owner", "")
+	r.NoError(err)
+	r.Len(list, 2)
+	list, err = a.ListAccessRequests("owner", AccessDenied)
+	r.NoError(err)
+	r.Len(list, 1)
+	r.Equal("bob", list[0].Trustee)
+	list, err = a.ListAccessRequests("other", "")
+	r.NoError(err)
+	r.Empty(list)
+
+	_, err = a.GetAccessRequest("owner", "unknown")
+	r.Equal(ErrAccessRequestNotFound, errors.Cause(err))
+}
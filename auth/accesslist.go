@@ -7,9 +7,16 @@
 package auth
 
 import (
+	"net"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/iotexproject/iotex-antenna-go/v2/jwt"
+	"github.com/pkg/errors"
+
+	"github.com/iotexproject/phoenix/db"
+	"github.com/iotexproject/phoenix/json"
 )
 
 // const
@@ -22,6 +29,8 @@ const (
 var (
 	TokenCtxKey = &contextKey{"Token"}
 	ErrorCtxKey = &contextKey{"Error"}
+	AuditCtxKey = &contextKey{"Audit"}
+	ShareCtxKey = &contextKey{"Share"}
 )
 
 type contextKey struct {
@@ -34,6 +43,27 @@ func (k *contextKey) String() string {
 
 type Claims struct {
 	*jwt.JWT
+	Extension
+
+	// Token is the raw jwt string the claims are parsed from
+	Token string
+
+	// Namespace is the owner namespace resolved from the issuer
+	Namespace string
+
+	// Parent is the claims of the token this one is delegated from, nil for a token issued by the owner
+	Parent *Claims
+
+	// Owner is true if the owner authenticated itself by signing a challenge with its wallet
+	Owner bool
+}
+
+// Ops are the operations a scope can grant
+var Ops = []string{jwt.CREATE, jwt.READ, jwt.UPDATE, jwt.DELETE}
+
+// Allow returns true if the scope includes the operation
+func (c *Claims) Allow(op string) bool {
+	return strings.Contains(c.Scope, op)
 }
 
 func (c *Claims) AllowCreate() bool {
@@ -59,3 +89,259 @@ func (c *Claims) IsBucket() bool {
 func (c *Claims) IsObject() bool {
 	return strings.Contains(c.Subject, Object)
 }
+
+// Root returns the claims of the token issued by the owner at the start of the delegation chain
+func (c *Claims) Root() *Claims {
+	for c.Parent != nil {
+		c = c.Parent
+	}
+	return c
+}
+
+// Store returns the name of the registered store the subject refers to
+func (c *Claims) Store() string {
+	return strings.SplitN(c.Subject, "/", 2)[0]
+}
+
+// Resource returns the bucket/path the subject is restricted to, empty if the whole store is accessible
+func (c *Claims) Resource() string {
+	parts := strings.SplitN(c.Subject, "/", 2)
+	if len(parts) < 2 {
+		return ""
+	}
+	return strings.Trim(parts[1], "/")
+}
+
+// Covers returns true if the bucket/path is within the subject
+func (c *Claims) Covers(bucket, path string) bool {
+	return resourceWithin(strings.Trim(bucket+"/"+path, "/"), c.Resource())
+}
+
+func resourceWithin(res, parent string) bool {
+	if parent == "" {
+		return true
+	}
+	return res == parent || strings.HasPrefix(res, parent+"/")
+}
+
+const accessListNamespace = "accesslist"
+
+// lists of access list rules
+const (
+	AllowList = "allow"
+	DenyList  = "deny"
+)
+
+// kinds of access list rules
+const (
+	RuleIssuer  = "issuer"
+	RuleSubject = "subject"
+	RuleCIDR    = "cidr"
+)
+
+var (
+	// ErrAccessListed is returned if the request is denied, or not allowed, by the access lists of the operator
+	ErrAccessListed = errors.New("rejected by access list")
+
+	// ErrAccessRule is returned if the access list rule is invalid
+	ErrAccessRule = errors.New("invalid access list rule")
+
+	// ErrAccessRuleNotFound is returned if the access list rule doesn't exist
+	ErrAccessRuleNotFound = errors.New("access list rule not found")
+)
+
+type (
+	// AccessRule allows or denies the owner address issuing tokens, the subject of tokens, or the network of
+	// clients. Once a kind has allow rules, only what they match is accepted
+	AccessRule struct {
+		List      string `json:"list"`
+		Kind      string `json:"kind"`
+		Value     string `json:"value"`
+		Notes     string `json:"notes,omitempty"`
+		CreatedAt int64  `json:"createdAt"`
+	}
+
+	AccessLists interface {
+		// PutRule normalizes the rule and puts it into db, it applies to requests at once
+		PutRule(*AccessRule) error
+
+		// DelRule deletes the rule of the list, kind and value
+		DelRule(list, kind, value string) error
+
+		// Rules returns all rules of the access lists
+		Rules() ([]*AccessRule, error)
+
+		// Check returns ErrAccessListed if the issuer or subject of the claims, or the client IP is rejected
+		Check(c *Claims, ip string) error
+	}
+
+	accessLists struct {
+		db.KVStore
+
+		// rules are the rules in db, loaded on first use and reloaded once changed
+		mu     sync.RWMutex
+		rules  []*AccessRule
+		loaded bool
+	}
+)
+
+func NewAccessLists(kv db.KVStore) AccessLists {
+	return &accessLists{
+		KVStore: kv,
+	}
+}
+
+// Normalize validates the rule, and rewrites its value in the form it is matched
+func (rule *AccessRule) Normalize() error {
+	if rule.List != AllowList && rule.List != DenyList {
+		return errors.Wrapf(ErrAccessRule, "list must be %s or %s", AllowList, DenyList)
+	}
+	switch rule.Kind {
+	case RuleIssuer:
+		namespace, err := OwnerNamespace(rule.Value)
+		if err != nil {
+			return errors.Wrapf(ErrAccessRule, "invalid issuer %s: %v", rule.Value, err)
+		}
+		rule.Value = namespace
+	case RuleSubject:
+		rule.Value = strings.Trim(rule.Value, "/")
+		if rule.Value == "" {
+			return errors.Wrap(ErrAccessRule, "subject is required")
+		}
+	case RuleCIDR:
+		if ip := net.ParseIP(rule.Value); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			rule.Value = (&net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}).String()
+			break
+		}
+		_, network, err := net.ParseCIDR(rule.Value)
+		if err != nil {
+			return errors.Wrapf(ErrAccessRule, "invalid cidr %s", rule.Value)
+		}
+		rule.Value = network.String()
+	default:
+		return errors.Wrapf(ErrAccessRule, "kind must be %s, %s or %s", RuleIssuer, RuleSubject, RuleCIDR)
+	}
+	return nil
+}
+
+// matches returns true if the rule matches the value of its kind
+func (rule *AccessRule) matches(value string) bool {
+	switch rule.Kind {
+	case RuleSubject:
+		return resourceWithin(value, rule.Value)
+	case RuleCIDR:
+		_, network, err := net.ParseCIDR(rule.Value)
+		ip := net.ParseIP(value)
+		return err == nil && ip != nil && network.Contains(ip)
+	default:
+		return rule.Value == value
+	}
+}
+
+func accessRuleKey(list, kind, value string) []byte {
+	return []byte(list + "/" + kind + "/" + value)
+}
+
+func (m *accessLists) PutRule(rule *AccessRule) error {
+	if err := rule.Normalize(); err != nil {
+		return err
+	}
+	rule.CreatedAt = time.Now().Unix()
+	v, err := json.Marshal(rule)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.loaded = false
+	return m.Put(accessListNamespace, accessRuleKey(rule.List, rule.Kind, rule.Value), v)
+}
+
+func (m *accessLists) DelRule(list, kind, value string) error {
+	rule := &AccessRule{List: list, Kind: kind, Value: value}
+	if err := rule.Normalize(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := accessRuleKey(rule.List, rule.Kind, rule.Value)
+	switch _, err := m.Get(accessListNamespace, key); errors.Cause(err) {
+	case nil:
+		break
+	case db.ErrBucketNotExist, db.ErrNotExist:
+		return errors.Wrapf(ErrAccessRuleNotFound, "%s %s %s", rule.List, rule.Kind, rule.Value)
+	default:
+		return err
+	}
+	m.loaded = false
+	return m.Delete(accessListNamespace, key)
+}
+
+func (m *accessLists) Rules() ([]*AccessRule, error) {
+	m.mu.RLock()
+	if m.loaded {
+		defer m.mu.RUnlock()
+		return m.rules, nil
+	}
+	m.mu.RUnlock()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.loaded {
+		return m.rules, nil
+	}
+	_, values, err := m.List(accessListNamespace, nil)
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]*AccessRule, 0, len(values))
+	for _, v := range values {
+		rule := &AccessRule{}
+		if err := json.Unmarshal(v, rule); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	m.rules, m.loaded = rules, true
+	return rules, nil
+}
+
+func (m *accessLists) Check(c *Claims, ip string) error {
+	rules, err := m.Rules()
+	if err != nil || len(rules) == 0 {
+		return err
+	}
+	values := map[string]string{
+		RuleIssuer: c.Root().Namespace,
+		RuleCIDR:   ip,
+	}
+	if !c.Owner {
+		values[RuleSubject] = strings.Trim(c.Subject, "/")
+	}
+	for _, kind := range []string{RuleIssuer, RuleSubject, RuleCIDR} {
+		value, ok := values[kind]
+		if !ok {
+			continue
+		}
+		allowList, allowed := false, false
+		for _, rule := range rules {
+			if rule.Kind != kind {
+				continue
+			}
+			if rule.List == DenyList && rule.matches(value) {
+				return errors.Wrapf(ErrAccessListed, "%s %s is denied", kind, value)
+			}
+			if rule.List == AllowList {
+				allowList, allowed = true, allowed || rule.matches(value)
+			}
+		}
+		if allowList && !allowed {
+			return errors.Wrapf(ErrAccessListed, "%s %s is not allowed", kind, value)
+		}
+	}
+	return nil
+}
@@ -7,10 +7,17 @@
 package auth
 
 import (
+	"context"
+	"io/ioutil"
+	"os"
 	"testing"
 
+	"github.com/iotexproject/go-pkgs/crypto"
 	"github.com/iotexproject/iotex-antenna-go/v2/jwt"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
+
+	"github.com/iotexproject/phoenix/db"
 )
 
 func TestClaim(t *testing.T) {
@@ -31,3 +38,72 @@ func TestClaim(t *testing.T) {
 	c.Subject = "Object: nh74PpkJJibjA"
 	r.True(c.IsObject())
 }
+
+func TestAccessLists(t *testing.T) {
+	r := require.New(t)
+
+	testFile, err := ioutil.TempFile(os.TempDir(), "test-bolt")
+	path := testFile.Name()
+	r.NoError(err)
+	testFile.Close()
+	defer func() {
+		r.NoError(os.Remove(path))
+	}()
+
+	d := db.NewBoltDB(path)
+	ctx := context.Background()
+	r.NoError(d.Start(ctx))
+	defer func() {
+		r.NoError(d.Stop(ctx))
+	}()
+
+	owner, err := crypto.GenerateKey()
+	r.NoError(err)
+	other, err := crypto.GenerateKey()
+	r.NoError(err)
+	c := &Claims{JWT: &jwt.JWT{Subject: "s3/reports/q1.csv"}, Namespace: owner.PublicKey().Address().Hex()[2:]}
+	o := &Claims{JWT: &jwt.JWT{Subject: "ipfs"}, Namespace: other.PublicKey().Address().Hex()[2:]}
+	lists := NewAccessLists(d)
+	r.NoError(lists.Check(c, "10.1.2.3"))
+
+	// invalid rules
+	for _, rule := range []*AccessRule{
+		{List: "block", Kind: RuleCIDR, Value: "10.0.0.0/8"},
+		{List: DenyList, Kind: "ip", Value: "10.0.0.0/8"},
+		{List: DenyList, Kind: RuleCIDR, Value: "10.0.0.0/33"},
+		{List: DenyList, Kind: RuleIssuer, Value: "alice"},
+		{List: DenyList, Kind: RuleSubject, Value: "/"},
+	} {
+		r.Equal(ErrAccessRule, errors.Cause(lists.PutRule(rule)))
+	}
+
+	// denied client networks and issuers apply at once
+	rule := &AccessRule{List: DenyList, Kind: RuleCIDR, Value: "203.0.113.7"}
+	r.NoError(lists.PutRule(rule))
+	r.Equal("203.0.113.7/32", rule.Value)
+	r.Equal(ErrAccessListed, errors.Cause(lists.Check(c, "203.0.113.7")))
+	r.NoError(lists.Check(c, "203.0.113.8"))
+	r.NoError(lists.PutRule(&AccessRule{List: DenyList, Kind: RuleIssuer, Value: other.PublicKey().Address().String()}))
+	r.Equal(ErrAccessListed, errors.Cause(lists.Check(o, "10.1.2.3")))
+	r.NoError(lists.Check(c, "10.1.2.3"))
+
+	// only allowlisted subjects are accepted once there are allow rules, owner session has no subject
+	r.NoError(lists.PutRule(&AccessRule{List: AllowList, Kind: RuleSubject, Value: "s3/reports/"}))
+	r.NoError(lists.Check(c, "10.1.2.3"))
+	c.Subject = "s3/reportsx"
+	r.Equal(ErrAccessListed, errors.Cause(lists.Check(c, "10.1.2.3")))
+	c.Owner = true
+	r.NoError(lists.Check(c, "10.1.2.3"))
+
+	rules, err := lists.Rules()
+	r.NoError(err)
+	r.Len(rules, 3)
+	r.NoError(lists.DelRule(DenyList, RuleCIDR, "203.0.113.7"))
+	r.NoError(lists.Check(c, "203.0.113.7"))
+	r.Equal(ErrAccessRuleNotFound, errors.Cause(lists.DelRule(DenyList, RuleCIDR, "203.0.113.7")))
+
+	// rules persist in db
+	rules, err = NewAccessLists(d).Rules()
+	r.NoError(err)
+	r.Len(rules, 2)
+}
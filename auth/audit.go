@@ -0,0 +1,209 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/iotexproject/phoenix/db"
+	"github.com/iotexproject/phoenix/json"
+)
+
+const (
+	auditNamespace     = "audit"
+	auditHeadNamespace = "audithead"
+)
+
+// results of audited requests
+const (
+	AuditSucceeded = "succeeded"
+	AuditDenied    = "denied"
+	AuditFailed    = "failed"
+)
+
+// ErrAuditChain is returned if a record of the audit log is altered, removed or out of order
+var ErrAuditChain = errors.New("audit log is tampered")
+
+type (
+	// AuditRecord is a request to owner's data. Records of an owner are chained by the hash of the previous
+	// record, so altering or removing a record breaks the chain
+	AuditRecord struct {
+		Seq     uint64 `json:"seq"`
+		Time    int64  `json:"time"`
+		Issuer  string `json:"issuer"`
+		Token   string `json:"token"` // hash of the token
+		Subject string `json:"subject"`
+		Op      string `json:"op"`
+		Bucket  string `json:"bucket,omitempty"`
+		Path    string `json:"path,omitempty"`
+		Bytes   int64  `json:"bytes"`
+		Status  int    `json:"status"`
+		Result  string `json:"result"`
+		IP      string `json:"ip"`
+		Prev    string `json:"prev"`
+		Hash    string `json:"hash"`
+	}
+
+	// AuditFilter selects the records of a query, empty fields select all
+	AuditFilter struct {
+		From   int64
+		To     int64
+		Token  string
+		Bucket string
+		// Path selects the object and the objects under it
+		Path string
+		// Verify verifies the whole log is chained before the records are returned
+		Verify bool
+	}
+
+	Audit interface {
+		// Append chains the record to the audit log of the owner
+		Append(string, *AuditRecord) error
+
+		// Query returns the records of the audit log of the owner selected by the filter, the log is verified
+		// first if the filter asks to
+		Query(string, *AuditFilter) ([]*AuditRecord, error)
+	}
+
+	// auditHead is the last record of owner's audit log
+	auditHead struct {
+		Seq  uint64 `json:"seq"`
+		Hash string `json:"hash"`
+	}
+
+	audit struct {
+		db.KVStore
+	}
+)
+
+func NewAudit(kv db.KVStore) Audit {
+	return &audit{
+		KVStore: kv,
+	}
+}
+
+// Digest returns the hash of the record, which covers the hash of the previous record
+func (rec *AuditRecord) Digest() (string, error) {
+	unhashed := *rec
+	unhashed.Hash = ""
+	v, err := json.Marshal(&unhashed)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256(v)
+	return hex.EncodeToString(h[:]), nil
+}
+
+// Matches returns true if the record is selected by the filter
+func (f *AuditFilter) Matches(rec *AuditRecord) bool {
+	switch {
+	case f.From != 0 && rec.Time < f.From:
+		return false
+	case f.To != 0 && rec.Time > f.To:
+		return false
+	case f.Token != "" && rec.Token != f.Token:
+		return false
+	case f.Bucket != "" && rec.Bucket != f.Bucket:
+		return false
+	}
+	path := strings.Trim(f.Path, "/")
+	return path == "" || resourceWithin(rec.Path, path)
+}
+
+func auditKey(namespace string, seq uint64) []byte {
+	return []byte(fmt.Sprintf("%s/%020d", namespace, seq))
+}
+
+func (a *audit) Append(namespace string, rec *AuditRecord) error {
+	// the record and the head are written in one transaction, so records are chained in order and the head
+	// never points past the last record
+	return a.Batch(func(tx db.Tx) error {
+		head, err := getAuditHead(tx, namespace)
+		if err != nil {
+			return err
+		}
+		rec.Seq, rec.Prev = head.Seq+1, head.Hash
+		if rec.Hash, err = rec.Digest(); err != nil {
+			return err
+		}
+		v, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		if err := tx.Put(auditNamespace, auditKey(namespace, rec.Seq), v); err != nil {
+			return err
+		}
+		if v, err = json.Marshal(&auditHead{Seq: rec.Seq, Hash: rec.Hash}); err != nil {
+			return err
+		}
+		return tx.Put(auditHeadNamespace, []byte(namespace), v)
+	})
+}
+
+func (a *audit) Query(namespace string, filter *AuditFilter) ([]*AuditRecord, error) {
+	head, err := getAuditHead(a, namespace)
+	if err != nil {
+		return nil, err
+	}
+	_, values, err := a.List(auditNamespace, []byte(namespace+"/"))
+	if err != nil {
+		return nil, err
+	}
+
+	list := []*AuditRecord{}
+	prev := &AuditRecord{}
+	for _, v := range values {
+		rec := &AuditRecord{}
+		if err := json.Unmarshal(v, rec); err != nil {
+			return nil, err
+		}
+		if rec.Seq > head.Seq {
+			// appended since the head was read
+			break
+		}
+		if filter.Verify {
+			digest, err := rec.Digest()
+			if err != nil {
+				return nil, err
+			}
+			if rec.Seq != prev.Seq+1 || rec.Prev != prev.Hash || rec.Hash != digest {
+				return nil, errors.Wrapf(ErrAuditChain, "record %d doesn't follow record %d", rec.Seq, prev.Seq)
+			}
+		}
+		if filter.Matches(rec) {
+			list = append(list, rec)
+		}
+		prev = rec
+	}
+	// records removed from the end are caught by the head
+	if filter.Verify && (prev.Seq != head.Seq || prev.Hash != head.Hash) {
+		return nil, errors.Wrapf(ErrAuditChain, "log ends at record %d, head is record %d", prev.Seq, head.Seq)
+	}
+	return list, nil
+}
+
+// getAuditHead returns the head of owner's audit log, which is empty before the first record
+func getAuditHead(g getter, namespace string) (*auditHead, error) {
+	head := &auditHead{}
+	v, err := g.Get(auditHeadNamespace, []byte(namespace))
+	switch errors.Cause(err) {
+	case nil:
+		if err := json.Unmarshal(v, head); err != nil {
+			return nil, err
+		}
+	case db.ErrBucketNotExist, db.ErrNotExist:
+		break
+	default:
+		return nil, err
+	}
+	return head, nil
+}
@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/iotexproject/iotex-antenna-go/v2/jwt"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iotexproject/phoenix/db"
+	"github.com/iotexproject/phoenix/json"
+)
+
+func TestAudit(t *testing.T) {
+	r := require.New(t)
+
+	testFile, err := ioutil.TempFile(os.TempDir(), "test-bolt")
+	path := testFile.Name()
+	r.NoError(err)
+	testFile.Close()
+	defer func() {
+		r.NoError(os.Remove(path))
+	}()
+
+	d := db.NewBoltDB(path)
+	ctx := context.Background()
+	r.NoError(d.Start(ctx))
+	defer func() {
+		r.NoError(d.Stop(ctx))
+	}()
+
+	a := NewAudit(d)
+	records := []*AuditRecord{
+		{Time: 100, Token: "alice", Op: jwt.UPDATE, Bucket: "reports", Path: "q1.csv", Bytes: 10, Status: 200, Result: AuditSucceeded},
+		{Time: 200, Token: "alice", Op: jwt.READ, Bucket: "reports", Path: "q1.csv", Bytes: 10, Status: 200, Result: AuditSucceeded},
+		{Time: 300, Token: "bob", Op: jwt.READ, Bucket: "reports", Path: "2020/q2.csv", Status: 403, Result: AuditDenied},
+		{Time: 400, Token: "bob", Op: jwt.READ, Bucket: "results", Status: 200, Result: AuditSucceeded},
+	}
+	for _, rec := range records {
+		r.NoError(a.Append("owner", rec))
+	}
+	r.NoError(a.Append("other", &AuditRecord{Time: 100, Token: "carol"}))
+	r.Equal(uint64(4), records[3].Seq)
+	r.Equal(records[2].Hash, records[3].Prev)
+
+	// concurrent appends are chained in turn
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		go func() {
+			errs <- a.Append("concurrent", &AuditRecord{Time: 100, Token: "dave"})
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		r.NoError(<-errs)
+	}
+	list, err := a.Query("concurrent", &AuditFilter{Verify: true})
+	r.NoError(err)
+	r.Len(list, 10)
+
+	tests := []struct {
+		filter *AuditFilter
+		seqs   []uint64
+	}{
+		{&AuditFilter{}, []uint64{1, 2, 3, 4}},
+		{&AuditFilter{From: 200, To: 300}, []uint64{2, 3}},
+		{&AuditFilter{Token: "bob"}, []uint64{3, 4}},
+		{&AuditFilter{Bucket: "reports", Path: "q1.csv"}, []uint64{1, 2}},
+		{&AuditFilter{Bucket: "reports", Path: "/2020/"}, []uint64{3}},
+	}
+	for i, test := range tests {
+		list, err := a.Query("owner", test.filter)
+		r.NoError(err)
+		seqs := []uint64{}
+		for _, rec := range list {
+			seqs = append(seqs, rec.Seq)
+		}
+		r.Equal(test.seqs, seqs, "case %d", i)
+	}
+
+	// altering a record breaks the chain
+	altered := *records[1]
+	altered.Token = "mallory"
+	v, err := json.Marshal(&altered)
+	r.NoError(err)
+	r.NoError(d.Put(auditNamespace, auditKey("owner", 2), v))
+	_, err = a.Query("owner", &AuditFilter{Verify: true})
+	r.Equal(ErrAuditChain, errors.Cause(err))
+	// which is only verified if asked
+	list, err = a.Query("owner", &AuditFilter{Token: "mallory"})
+	r.NoError(err)
+	r.Len(list, 1)
+	v, err = json.Marshal(records[1])
+	r.NoError(err)
+	r.NoError(d.Put(auditNamespace, auditKey("owner", 2), v))
+	_, err = a.Query("owner", &AuditFilter{Verify: true})
+	r.NoError(err)
+
+	// so does removing the last one
+	r.NoError(d.Delete(auditNamespace, auditKey("owner", 4)))
+	_, err = a.Query("owner", &AuditFilter{Verify: true})
+	r.Equal(ErrAuditChain, errors.Cause(err))
+
+	list, err = a.Query("other", &AuditFilter{Verify: true})
+	r.NoError(err)
+	r.Len(list, 1)
+	r.Empty(list[0].Prev)
+}
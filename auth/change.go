@@ -0,0 +1,180 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/iotexproject/phoenix/db"
+	"github.com/iotexproject/phoenix/json"
+)
+
+const (
+	changeNamespace     = "change"
+	changeHeadNamespace = "changehead"
+	changePathNamespace = "changepath"
+
+	// changeRetention is the number of changes of a bucket kept to resume from
+	changeRetention = 1000
+)
+
+// types of changes
+const (
+	ChangeCreated = "created"
+	ChangeUpdated = "updated"
+	ChangeDeleted = "deleted"
+)
+
+type (
+	// Change is a change of an object in a bucket, or of the bucket itself if Path is empty. IDs of changes of a
+	// bucket are sequential
+	Change struct {
+		ID      uint64 `json:"id"`
+		Type    string `json:"type"`
+		Store   string `json:"store"`
+		Bucket  string `json:"bucket"`
+		Path    string `json:"path,omitempty"`
+		Size    int64  `json:"size,omitempty"`
+		Subject string `json:"subject,omitempty"`
+		Time    int64  `json:"time"`
+	}
+
+	Changes interface {
+		// Record appends the change to the log of owner's bucket. Writes are recorded as created, or as updated
+		// if the object was written through phoenix before
+		Record(string, *Change) error
+
+		// Since returns the changes of owner's bucket after the change ID, as many as are kept
+		Since(namespace, store, bucket string, after uint64) ([]*Change, error)
+	}
+
+	changes struct {
+		db.KVStore
+
+		// mu serializes recording, so changes of a bucket are numbered in order
+		mu sync.Mutex
+	}
+)
+
+func NewChanges(kv db.KVStore) Changes {
+	return &changes{
+		KVStore: kv,
+	}
+}
+
+// EventID returns the change ID as the ID of server-sent event
+func (c *Change) EventID() string {
+	return strconv.FormatUint(c.ID, 10)
+}
+
+func changeBucketKey(namespace, store, bucket string) string {
+	return namespace + "/" + store + "/" + bucket
+}
+
+func changeKey(bucket string, id uint64) []byte {
+	return []byte(fmt.Sprintf("%s/%020d", bucket, id))
+}
+
+func (m *changes) Record(namespace string, c *Change) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bucket := changeBucketKey(namespace, c.Store, c.Bucket)
+	if c.Path != "" {
+		path := []byte(bucket + "/" + c.Path)
+		_, err := m.Get(changePathNamespace, path)
+		switch errors.Cause(err) {
+		case nil:
+			if c.Type == ChangeCreated {
+				c.Type = ChangeUpdated
+			}
+		case db.ErrBucketNotExist, db.ErrNotExist:
+			break
+		default:
+			return err
+		}
+		if c.Type == ChangeDeleted {
+			err = m.Delete(changePathNamespace, path)
+		} else {
+			err = m.Put(changePathNamespace, path, []byte{1})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// objects of a bucket deleted are gone with it
+	if c.Path == "" && c.Type == ChangeDeleted {
+		paths, _, err := m.List(changePathNamespace, []byte(bucket+"/"))
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			if err := m.Delete(changePathNamespace, path); err != nil {
+				return err
+			}
+		}
+	}
+
+	head, err := m.head(bucket)
+	if err != nil {
+		return err
+	}
+	c.ID = head + 1
+	if c.Time == 0 {
+		c.Time = time.Now().Unix()
+	}
+	v, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := m.Put(changeNamespace, changeKey(bucket, c.ID), v); err != nil {
+		return err
+	}
+	if err := m.Put(changeHeadNamespace, []byte(bucket), []byte(c.EventID())); err != nil {
+		return err
+	}
+	if c.ID > changeRetention {
+		return m.Delete(changeNamespace, changeKey(bucket, c.ID-changeRetention))
+	}
+	return nil
+}
+
+func (m *changes) Since(namespace, store, bucket string, after uint64) ([]*Change, error) {
+	_, values, err := m.List(changeNamespace, []byte(changeBucketKey(namespace, store, bucket)+"/"))
+	if err != nil {
+		return nil, err
+	}
+	list := []*Change{}
+	for _, v := range values {
+		c := &Change{}
+		if err := json.Unmarshal(v, c); err != nil {
+			return nil, err
+		}
+		if c.ID > after {
+			list = append(list, c)
+		}
+	}
+	return list, nil
+}
+
+// head returns the ID of the last change of the bucket
+func (m *changes) head(bucket string) (uint64, error) {
+	v, err := m.Get(changeHeadNamespace, []byte(bucket))
+	switch errors.Cause(err) {
+	case nil:
+		return strconv.ParseUint(string(v), 10, 64)
+	case db.ErrBucketNotExist, db.ErrNotExist:
+		return 0, nil
+	default:
+		return 0, err
+	}
+}
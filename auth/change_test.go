@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/iotexproject/phoenix/db"
+)
+
+func TestChanges(t *testing.T) {
+	r := require.New(t)
+
+	testFile, err := ioutil.TempFile(os.TempDir(), "test-bolt")
+	path := testFile.Name()
+	r.NoError(err)
+	testFile.Close()
+	defer func() {
+		r.NoError(os.Remove(path))
+	}()
+
+	d := db.NewBoltDB(path)
+	ctx := context.Background()
+	r.NoError(d.Start(ctx))
+	defer func() {
+		r.NoError(d.Stop(ctx))
+	}()
+
+	m := NewChanges(d)
+	changes := []*Change{
+		{Type: ChangeCreated, Store: "s3", Bucket: "readings", Path: "a.json", Size: 10},
+		{Type: ChangeCreated, Store: "s3", Bucket: "readings", Path: "a.json", Size: 12},
+		{Type: ChangeDeleted, Store: "s3", Bucket: "readings", Path: "a.json"},
+		{Type: ChangeCreated, Store: "s3", Bucket: "readings", Path: "a.json", Size: 8},
+	}
+	for _, c := range changes {
+		r.NoError(m.Record("owner", c))
+	}
+	r.NoError(m.Record("owner", &Change{Type: ChangeCreated, Store: "s3", Bucket: "readings2", Path: "a.json"}))
+	r.NoError(m.Record("other", &Change{Type: ChangeCreated, Store: "s3", Bucket: "readings", Path: "a.json"}))
+
+	// writing an object written before is an update
+	r.Equal(uint64(4), changes[3].ID)
+	r.Equal(ChangeUpdated, changes[1].Type)
+	r.Equal(ChangeCreated, changes[3].Type)
+	r.NotZero(changes[0].Time)
+
+	list, err := m.Since("owner", "s3", "readings", 0)
+	r.NoError(err)
+	r.Equal(changes, list)
+	list, err = m.Since("owner", "s3", "readings", 2)
+	r.NoError(err)
+	r.Equal(changes[2:], list)
+	list, err = m.Since("owner", "s3", "readings2", 0)
+	r.NoError(err)
+	r.Len(list, 1)
+	r.Equal(uint64(1), list[0].ID)
+
+	// objects are created anew once their bucket is deleted
+	r.NoError(m.Record("owner", &Change{Type: ChangeDeleted, Store: "s3", Bucket: "readings"}))
+	c := &Change{Type: ChangeCreated, Store: "s3", Bucket: "readings", Path: "a.json"}
+	r.NoError(m.Record("owner", c))
+	r.Equal(ChangeCreated, c.Type)
+	r.Equal(uint64(6), c.ID)
+
+	// only the latest changes are kept
+	for i := 0; i < changeRetention; i++ {
+		r.NoError(m.Record("owner", &Change{Type: ChangeCreated, Store: "s3", Bucket: "readings", Path: "b.json"}))
+	}
+	list, err = m.Since("owner", "s3", "readings", 0)
+	r.NoError(err)
+	r.Len(list, changeRetention)
+	r.Equal(uint64(7), list[0].ID)
+}
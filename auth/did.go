@@ -0,0 +1,290 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/iotexproject/go-pkgs/hash"
+	"github.com/pkg/errors"
+
+	"github.com/iotexproject/phoenix/db"
+)
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// multicodec prefixes of public keys in did:key
+var (
+	multicodecEd25519   = []byte{0xed, 0x01}
+	multicodecSecp256k1 = []byte{0xe7, 0x01}
+	multicodecP256      = []byte{0x80, 0x24}
+)
+
+// DIDKey decodes the public key of a did:key, supports ed25519, secp256k1 and p256 keys
+func DIDKey(did string) (interface{}, error) {
+	id := strings.TrimPrefix(did, "did:key:")
+	if i := strings.Index(id, "#"); i >= 0 {
+		id = id[:i]
+	}
+	// multibase base58btc
+	if !strings.HasPrefix(id, "z") {
+		return nil, errors.Errorf("%s is not a base58btc did:key", did)
+	}
+	b, err := base58Decode(id[1:])
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(b, multicodecEd25519):
+		return decodePublicKey(KeyEd25519, b[2:])
+	case bytes.HasPrefix(b, multicodecSecp256k1):
+		return decodePublicKey(KeySecp256k1, b[2:])
+	case bytes.HasPrefix(b, multicodecP256):
+		return decodePublicKey(KeyP256, b[2:])
+	default:
+		return nil, errors.Errorf("key type of %s not supported", did)
+	}
+}
+
+// EncodeDIDKey encodes an ed25519 public key into a did:key
+func EncodeDIDKey(pub []byte) string {
+	return "did:key:z" + base58Encode(append(append([]byte{}, multicodecEd25519...), pub...))
+}
+
+// DIDNamespace returns the owner namespace of a DID which is not bound to an address
+func DIDNamespace(did string) string {
+	if i := strings.Index(did, "#"); i >= 0 {
+		did = did[:i]
+	}
+	h := hash.Hash160b([]byte(did))
+	return hex.EncodeToString(h[:])
+}
+
+func base58Decode(s string) ([]byte, error) {
+	n := new(big.Int)
+	radix := big.NewInt(58)
+	for _, c := range s {
+		i := strings.IndexRune(base58Alphabet, c)
+		if i < 0 {
+			return nil, errors.Errorf("invalid base58 character %q", c)
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(i)))
+	}
+	// leading '1's are zero bytes
+	zeros := 0
+	for zeros < len(s) && s[zeros] == base58Alphabet[0] {
+		zeros++
+	}
+	return append(make([]byte, zeros), n.Bytes()...), nil
+}
+
+func base58Encode(b []byte) string {
+	n := new(big.Int).SetBytes(b)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+	var out []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	for i := 0; i < len(b) && b[i] == 0; i++ {
+		out = append(out, base58Alphabet[0])
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
+
+// verification method types of DID document
+const (
+	Ed25519VerificationKey2018        = "Ed25519VerificationKey2018"
+	EcdsaSecp256k1VerificationKey2019 = "EcdsaSecp256k1VerificationKey2019"
+	EcdsaSecp256r1VerificationKey2019 = "EcdsaSecp256r1VerificationKey2019"
+)
+
+// ErrDIDNotFound is returned if the DID or its verification method can't be resolved
+var ErrDIDNotFound = errors.New("DID not found")
+
+var verificationKeyTypes = map[string]string{
+	Ed25519VerificationKey2018:        KeyEd25519,
+	EcdsaSecp256k1VerificationKey2019: KeySecp256k1,
+	EcdsaSecp256r1VerificationKey2019: KeyP256,
+}
+
+type (
+	// DIDDocument is the resolved document of a DID
+	DIDDocument struct {
+		ID                 string               `json:"id"`
+		VerificationMethod []VerificationMethod `json:"verificationMethod"`
+	}
+
+	// VerificationMethod is a public key in the DID document
+	VerificationMethod struct {
+		ID           string `json:"id"`
+		Type         string `json:"type"`
+		Controller   string `json:"controller"`
+		PublicKeyHex string `json:"publicKeyHex"`
+	}
+
+	DIDResolver interface {
+		// Resolve resolves the document of a DID, the DID URL can refer to a verification method by fragment
+		Resolve(string) (*DIDDocument, error)
+	}
+
+	// LocalDIDResolver resolves DID documents added to it, and did:key
+	LocalDIDResolver struct {
+		mu   sync.RWMutex
+		docs map[string]*DIDDocument
+	}
+
+	registryDIDResolver struct {
+		keys Keys
+	}
+)
+
+// PublicKeyOf decodes the public key of the verification method
+func (m *VerificationMethod) PublicKeyOf() (interface{}, error) {
+	keyType, ok := verificationKeyTypes[m.Type]
+	if !ok {
+		return nil, errors.Errorf("verification method type %s not supported", m.Type)
+	}
+	b, err := hex.DecodeString(strings.TrimPrefix(m.PublicKeyHex, "0x"))
+	if err != nil {
+		return nil, err
+	}
+	return decodePublicKey(keyType, b)
+}
+
+// Method returns the verification method with the id, which can be a DID URL or only the fragment
+func (d *DIDDocument) Method(id string) (*VerificationMethod, error) {
+	if id == "" && len(d.VerificationMethod) == 1 {
+		return &d.VerificationMethod[0], nil
+	}
+	if strings.HasPrefix(id, "#") {
+		id = d.ID + id
+	}
+	for i := range d.VerificationMethod {
+		if d.VerificationMethod[i].ID == id {
+			return &d.VerificationMethod[i], nil
+		}
+	}
+	return nil, errors.Wrapf(ErrDIDNotFound, "verification method %s", id)
+}
+
+// didKeyDocument generates the document of a did:key
+func didKeyDocument(did string) (*DIDDocument, error) {
+	if i := strings.Index(did, "#"); i >= 0 {
+		did = did[:i]
+	}
+	key, err := DIDKey(did)
+	if err != nil {
+		return nil, err
+	}
+	m := VerificationMethod{
+		ID:         did + "#" + strings.TrimPrefix(did, "did:key:"),
+		Controller: did,
+	}
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		m.Type, m.PublicKeyHex = Ed25519VerificationKey2018, hex.EncodeToString(k)
+	case *ecdsa.PublicKey:
+		m.Type = EcdsaSecp256r1VerificationKey2019
+		if k.Curve != elliptic.P256() {
+			m.Type = EcdsaSecp256k1VerificationKey2019
+		}
+		m.PublicKeyHex = hex.EncodeToString(elliptic.Marshal(k.Curve, k.X, k.Y))
+	}
+	return &DIDDocument{ID: did, VerificationMethod: []VerificationMethod{m}}, nil
+}
+
+// NewLocalDIDResolver creates a resolver of the documents, as a stand-in of the DID registry
+func NewLocalDIDResolver(docs ...*DIDDocument) *LocalDIDResolver {
+	r := &LocalDIDResolver{
+		docs: make(map[string]*DIDDocument),
+	}
+	for _, d := range docs {
+		r.Add(d)
+	}
+	return r
+}
+
+// Add adds the document to the resolver
+func (r *LocalDIDResolver) Add(doc *DIDDocument) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.docs[doc.ID] = doc
+}
+
+func (r *LocalDIDResolver) Resolve(did string) (*DIDDocument, error) {
+	if strings.HasPrefix(did, "did:key:") {
+		return didKeyDocument(did)
+	}
+	if i := strings.Index(did, "#"); i >= 0 {
+		did = did[:i]
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	doc, ok := r.docs[did]
+	if !ok {
+		return nil, errors.Wrapf(ErrDIDNotFound, "DID %s", did)
+	}
+	return doc, nil
+}
+
+// NewDIDResolver creates a resolver of did:key, and did:io whose verification methods are the issuer keys
+// registered by the owner, a did:io URL must refer to the key ID by fragment
+func NewDIDResolver(keys Keys) DIDResolver {
+	return &registryDIDResolver{
+		keys: keys,
+	}
+}
+
+func (r *registryDIDResolver) Resolve(did string) (*DIDDocument, error) {
+	if strings.HasPrefix(did, "did:key:") {
+		return didKeyDocument(did)
+	}
+	if !strings.HasPrefix(did, "did:io:") {
+		return nil, errors.Wrapf(ErrDIDNotFound, "DID method of %s not supported", did)
+	}
+	parts := strings.SplitN(did, "#", 2)
+	if len(parts) != 2 {
+		return nil, errors.Wrapf(ErrDIDNotFound, "key ID of %s is required", did)
+	}
+	addr, err := ParseAddress(strings.TrimPrefix(parts[0], "did:io:"))
+	if err != nil {
+		return nil, errors.Wrap(ErrDIDNotFound, err.Error())
+	}
+	key, err := r.keys.GetKey(addr.Hex()[2:], parts[1])
+	switch errors.Cause(err) {
+	case nil:
+		break
+	case db.ErrBucketNotExist, db.ErrNotExist:
+		return nil, errors.Wrapf(ErrDIDNotFound, "verification method %s", did)
+	default:
+		return nil, err
+	}
+	m := VerificationMethod{
+		ID:           did,
+		Controller:   parts[0],
+		PublicKeyHex: key.PublicKey,
+	}
+	for t, k := range verificationKeyTypes {
+		if k == key.Type {
+			m.Type = t
+		}
+	}
+	return &DIDDocument{ID: parts[0], VerificationMethod: []VerificationMethod{m}}, nil
+}
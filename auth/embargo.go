@@ -0,0 +1,212 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/iotexproject/phoenix/db"
+	"github.com/iotexproject/phoenix/json"
+)
+
+const embargoNamespace = "embargo"
+
+var (
+	// ErrEmbargoed is returned if the bucket or object is under embargo
+	ErrEmbargoed = errors.New("under embargo")
+
+	// ErrEmbargoNotFound is returned if the embargo doesn't exist, or is released already
+	ErrEmbargoNotFound = errors.New("embargo not found")
+)
+
+type (
+	// Embargo keeps a bucket, or an object in it if Path is given, unreadable until the release time, or until
+	// the owner signs the release message. An embargo without release time is only released by the owner
+	Embargo struct {
+		ID         string `json:"id"`
+		Store      string `json:"store"`
+		Bucket     string `json:"bucket"`
+		Path       string `json:"path,omitempty"`
+		ReleaseAt  int64  `json:"releaseAt,omitempty"`
+		CreatedAt  int64  `json:"createdAt"`
+		ReleasedAt int64  `json:"releasedAt,omitempty"`
+		Signature  string `json:"signature,omitempty"`
+	}
+
+	Embargoes interface {
+		// PutEmbargo puts owner's embargo into db, replacing the existing one of the bucket or object
+		PutEmbargo(string, *Embargo) error
+
+		// ListEmbargoes returns owner's embargoes pending release
+		ListEmbargoes(string) ([]*Embargo, error)
+
+		// Release releases owner's embargo with the owner's signature of its release message
+		Release(owner string, e *Embargo, sig []byte) (*Embargo, error)
+
+		// Check returns ErrEmbargoed if the bucket, or the object if path is given, is under owner's embargo
+		Check(namespace, store, bucket, path string) error
+
+		// Embargoed returns the paths of objects under owner's embargo in the bucket
+		Embargoed(namespace, store, bucket string) (map[string]bool, error)
+	}
+
+	embargoes struct {
+		db.KVStore
+	}
+)
+
+// Active returns true if the embargo is neither released nor past its release time
+func (e *Embargo) Active() bool {
+	return e.ReleasedAt == 0 && (e.ReleaseAt == 0 || time.Now().Unix() < e.ReleaseAt)
+}
+
+// Message returns the message the owner signs with personal_sign to release the embargo
+func (e *Embargo) Message() string {
+	return fmt.Sprintf("phoenix wants you to release %s\n\nEmbargo: %s", e.target(), e.ID)
+}
+
+func (e *Embargo) target() string {
+	return strings.TrimSuffix(e.Store+"/"+e.Bucket+"/"+e.Path, "/")
+}
+
+func NewEmbargoes(kv db.KVStore) Embargoes {
+	return &embargoes{
+		KVStore: kv,
+	}
+}
+
+func embargoKey(namespace, store, bucket, path string) []byte {
+	return []byte(strings.TrimSuffix(namespace+"/"+store+"/"+bucket+"/"+path, "/"))
+}
+
+func (m *embargoes) PutEmbargo(namespace string, e *Embargo) error {
+	if e.Store == "" || e.Bucket == "" || strings.Contains(e.Store, "/") || strings.Contains(e.Bucket, "/") {
+		return errors.New("store and bucket are required, and can't contain /")
+	}
+	id, err := randomHex(16)
+	if err != nil {
+		return err
+	}
+	e.ID, e.CreatedAt, e.ReleasedAt, e.Signature = id, time.Now().Unix(), 0, ""
+	return m.put(namespace, e)
+}
+
+func (m *embargoes) ListEmbargoes(namespace string) ([]*Embargo, error) {
+	_, values, err := m.List(embargoNamespace, []byte(namespace+"/"))
+	if err != nil {
+		return nil, err
+	}
+	list := []*Embargo{}
+	for _, v := range values {
+		e := &Embargo{}
+		if err := json.Unmarshal(v, e); err != nil {
+			return nil, err
+		}
+		if e.Active() {
+			list = append(list, e)
+		}
+	}
+	return list, nil
+}
+
+func (m *embargoes) Release(owner string, e *Embargo, sig []byte) (*Embargo, error) {
+	addr, err := ParseAddress(owner)
+	if err != nil {
+		return nil, err
+	}
+	namespace := addr.Hex()[2:]
+	released := &Embargo{}
+	err = m.Update(embargoNamespace, embargoKey(namespace, e.Store, e.Bucket, e.Path), func(v []byte) ([]byte, error) {
+		if v == nil {
+			return nil, errors.Wrapf(ErrEmbargoNotFound, "embargo of %s", e.target())
+		}
+		if err := json.Unmarshal(v, released); err != nil {
+			return nil, err
+		}
+		if !released.Active() {
+			return nil, errors.Wrapf(ErrEmbargoNotFound, "embargo of %s is released", e.target())
+		}
+		if _, err := RecoverSigner(PersonalMessageHash(released.Message()), sig, addr); err != nil {
+			return nil, err
+		}
+		released.ReleasedAt, released.Signature = time.Now().Unix(), fmt.Sprintf("%x", sig)
+		return json.Marshal(released)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return released, nil
+}
+
+func (m *embargoes) Check(namespace, store, bucket, path string) error {
+	paths := []string{""}
+	if path != "" {
+		paths = append(paths, path)
+	}
+	for _, p := range paths {
+		e, err := m.get(namespace, store, bucket, p)
+		if err != nil {
+			return err
+		}
+		if e == nil || !e.Active() {
+			continue
+		}
+		if e.ReleaseAt == 0 {
+			return errors.Wrapf(ErrEmbargoed, "%s is held until released by owner", e.target())
+		}
+		return errors.Wrapf(ErrEmbargoed, "%s is held until %s", e.target(), time.Unix(e.ReleaseAt, 0).UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+func (m *embargoes) Embargoed(namespace, store, bucket string) (map[string]bool, error) {
+	_, values, err := m.List(embargoNamespace, []byte(namespace+"/"+store+"/"+bucket+"/"))
+	if err != nil {
+		return nil, err
+	}
+	paths := map[string]bool{}
+	for _, v := range values {
+		e := &Embargo{}
+		if err := json.Unmarshal(v, e); err != nil {
+			return nil, err
+		}
+		if e.Active() {
+			paths[e.Path] = true
+		}
+	}
+	return paths, nil
+}
+
+// get returns the embargo of the bucket or object, nil if there isn't
+func (m *embargoes) get(namespace, store, bucket, path string) (*Embargo, error) {
+	v, err := m.Get(embargoNamespace, embargoKey(namespace, store, bucket, path))
+	switch errors.Cause(err) {
+	case nil:
+		break
+	case db.ErrBucketNotExist, db.ErrNotExist:
+		return nil, nil
+	default:
+		return nil, err
+	}
+	e := &Embargo{}
+	if err := json.Unmarshal(v, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (m *embargoes) put(namespace string, e *Embargo) error {
+	v, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return m.Put(embargoNamespace, embargoKey(namespace, e.Store, e.Bucket, e.Path), v)
+}
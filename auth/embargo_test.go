@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/iotexproject/go-pkgs/crypto"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iotexproject/phoenix/db"
+)
+
+func TestEmbargoes(t *testing.T) {
+	r := require.New(t)
+
+	testFile, err := ioutil.TempFile(os.TempDir(), "test-bolt")
+	path := testFile.Name()
+	r.NoError(err)
+	testFile.Close()
+	defer func() {
+		r.NoError(os.Remove(path))
+	}()
+
+	d := db.NewBoltDB(path)
+	ctx := context.Background()
+	r.NoError(d.Start(ctx))
+	defer func() {
+		r.NoError(d.Stop(ctx))
+	}()
+
+	owner, err := crypto.GenerateKey()
+	r.NoError(err)
+	namespace := owner.PublicKey().Address().Hex()[2:]
+	m := NewEmbargoes(d)
+
+	r.Error(m.PutEmbargo(namespace, &Embargo{Store: "s3"}))
+	r.NoError(m.Check(namespace, "s3", "results", "q1.csv"))
+
+	// object held until release time, bucket held until released by owner
+	object := &Embargo{Store: "s3", Bucket: "results", Path: "q1.csv", ReleaseAt: time.Now().Add(time.Hour).Unix()}
+	r.NoError(m.PutEmbargo(namespace, object))
+	r.Equal(ErrEmbargoed, errors.Cause(m.Check(namespace, "s3", "results", "q1.csv")))
+	r.NoError(m.Check(namespace, "s3", "results", "q2.csv"))
+	r.NoError(m.Check(namespace, "s3", "results", ""))
+	r.NoError(m.PutEmbargo(namespace, &Embargo{Store: "s3", Bucket: "results2", Path: "q1.csv", ReleaseAt: time.Now().Add(-time.Minute).Unix()}))
+	r.NoError(m.Check(namespace, "s3", "results2", "q1.csv"))
+	embargoed, err := m.Embargoed(namespace, "s3", "results")
+	r.NoError(err)
+	r.Equal(map[string]bool{"q1.csv": true}, embargoed)
+
+	bucket := &Embargo{Store: "s3", Bucket: "results"}
+	r.NoError(m.PutEmbargo(namespace, bucket))
+	r.Equal(ErrEmbargoed, errors.Cause(m.Check(namespace, "s3", "results", "q2.csv")))
+	list, err := m.ListEmbargoes(namespace)
+	r.NoError(err)
+	r.Len(list, 2)
+
+	// release must be signed by the owner, of the embargo's message
+	other, err := crypto.GenerateKey()
+	r.NoError(err)
+	sig, err := other.Sign(PersonalMessageHash(bucket.Message()))
+	r.NoError(err)
+	_, err = m.Release(owner.PublicKey().Address().String(), bucket, sig)
+	r.Equal(ErrChallenge, errors.Cause(err))
+	_, err = m.Release(other.PublicKey().Address().String(), bucket, sig)
+	r.Equal(ErrEmbargoNotFound, errors.Cause(err))
+	sig, err = owner.Sign(PersonalMessageHash(bucket.Message()))
+	r.NoError(err)
+	released, err := m.Release(owner.PublicKey().Address().String(), bucket, sig)
+	r.NoError(err)
+	r.NotZero(released.ReleasedAt)
+	r.NoError(m.Check(namespace, "s3", "results", "q2.csv"))
+	_, err = m.Release(owner.PublicKey().Address().String(), bucket, sig)
+	r.Equal(ErrEmbargoNotFound, errors.Cause(err))
+
+	list, err = m.ListEmbargoes(namespace)
+	r.NoError(err)
+	r.Len(list, 1)
+	r.Equal("q1.csv", list[0].Path)
+}
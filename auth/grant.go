@@ -0,0 +1,227 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+
+	"github.com/iotexproject/phoenix/db"
+	"github.com/iotexproject/phoenix/json"
+)
+
+const (
+	grantNamespace      = "grant"
+	grantTokenNamespace = "granttoken"
+	mintKeyNamespace    = "mintkey"
+)
+
+// MintKeyID is the key ID phoenix registers under the owner to mint tokens on the owner's behalf,
+// the owner removes the key to invalidate all tokens minted by phoenix
+const MintKeyID = "phoenix"
+
+var (
+	// ErrNoLiveGrant is returned if the token is not recorded in a grant, or the grant is revoked or expired
+	ErrNoLiveGrant = errors.New("no live grant for token")
+
+	// ErrGrantNotFound is returned if the grant doesn't exist
+	ErrGrantNotFound = errors.New("grant not found")
+)
+
+type (
+	// Grant is the record of access an owner handed out to a trustee
+	Grant struct {
+		ID      string `json:"id"`
+		Trustee string `json:"trustee"`
+		// Subject, Scope and ExpiresAt are the claims of the granted token
+		Subject   string `json:"subject"`
+		Scope     string `json:"scope"`
+		ExpiresAt int64  `json:"expiresAt"`
+		Notes     string `json:"notes,omitempty"`
+		// TokenHash is the hash of the granted token
+		TokenHash string `json:"tokenHash,omitempty"`
+		CreatedAt int64  `json:"createdAt"`
+		RevokedAt int64  `json:"revokedAt,omitempty"`
+	}
+
+	Grants interface {
+		// CreateGrant puts owner's new grant into db, the ID of grant is assigned
+		CreateGrant(string, *Grant) error
+
+		// GetGrant returns owner's grant according to grant ID
+		GetGrant(string, string) (*Grant, error)
+
+		// UpdateGrant rewrites owner's grant, the token of grant is indexed once it is set
+		UpdateGrant(string, *Grant) error
+
+		// ListGrants returns owner's grants of the registered store, all grants if the store is empty
+		ListGrants(string, string) ([]*Grant, error)
+
+		// Live returns the live grant the token is delegated from, ErrNoLiveGrant if there isn't
+		Live(*Claims) (*Grant, error)
+	}
+
+	grants struct {
+		db.KVStore
+	}
+)
+
+// Store returns the name of the registered store the grant refers to
+func (g *Grant) Store() string {
+	return strings.SplitN(g.Subject, "/", 2)[0]
+}
+
+// Alive returns true if the grant is neither revoked nor expired
+func (g *Grant) Alive() bool {
+	return g.RevokedAt == 0 && (g.ExpiresAt == 0 || g.ExpiresAt > time.Now().Unix())
+}
+
+func NewGrants(kv db.KVStore) Grants {
+	return &grants{
+		KVStore: kv,
+	}
+}
+
+func (g *grants) CreateGrant(namespace string, grant *Grant) error {
+	id, err := randomHex(16)
+	if err != nil {
+		return err
+	}
+	grant.ID, grant.CreatedAt = id, time.Now().Unix()
+	return g.UpdateGrant(namespace, grant)
+}
+
+func (g *grants) GetGrant(namespace, id string) (*Grant, error) {
+	v, err := g.Get(grantNamespace, []byte(namespace+"/"+id))
+	switch errors.Cause(err) {
+	case nil:
+		break
+	case db.ErrBucketNotExist, db.ErrNotExist:
+		return nil, errors.Wrapf(ErrGrantNotFound, "grant %s", id)
+	default:
+		return nil, err
+	}
+	grant := &Grant{}
+	if err := json.Unmarshal(v, grant); err != nil {
+		return nil, err
+	}
+	return grant, nil
+}
+
+func (g *grants) UpdateGrant(namespace string, grant *Grant) error {
+	if grant.ID == "" {
+		return errors.New("grant id is required")
+	}
+	v, err := json.Marshal(grant)
+	if err != nil {
+		return err
+	}
+	if err := g.Put(grantNamespace, []byte(namespace+"/"+grant.ID), v); err != nil {
+		return err
+	}
+	if grant.TokenHash == "" {
+		return nil
+	}
+	return g.Put(grantTokenNamespace, []byte(grant.TokenHash), []byte(namespace+"/"+grant.ID))
+}
+
+func (g *grants) ListGrants(namespace, store string) ([]*Grant, error) {
+	_, values, err := g.List(grantNamespace, []byte(namespace+"/"))
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*Grant, 0, len(values))
+	for _, v := range values {
+		grant := &Grant{}
+		if err := json.Unmarshal(v, grant); err != nil {
+			return nil, err
+		}
+		if store == "" || grant.Store() == store {
+			list = append(list, grant)
+		}
+	}
+	return list, nil
+}
+
+func (g *grants) Live(c *Claims) (*Grant, error) {
+	root := c.Root()
+	v, err := g.Get(grantTokenNamespace, []byte(root.Hash()))
+	switch errors.Cause(err) {
+	case nil:
+		break
+	case db.ErrBucketNotExist, db.ErrNotExist:
+		return nil, ErrNoLiveGrant
+	default:
+		return nil, err
+	}
+	parts := strings.SplitN(string(v), "/", 2)
+	if len(parts) != 2 || parts[0] != root.Namespace {
+		return nil, ErrNoLiveGrant
+	}
+	grant, err := g.GetGrant(parts[0], parts[1])
+	switch errors.Cause(err) {
+	case nil:
+		break
+	case ErrGrantNotFound:
+		return nil, ErrNoLiveGrant
+	default:
+		return nil, err
+	}
+	if !grant.Alive() {
+		return nil, errors.Wrapf(ErrNoLiveGrant, "grant %s is revoked or expired", grant.ID)
+	}
+	return grant, nil
+}
+
+// MintKey returns the key phoenix mints tokens with, which is generated on first use and kept in db
+func MintKey(kv db.KVStore) (ed25519.PrivateKey, error) {
+	var key ed25519.PrivateKey
+	err := kv.Update(mintKeyNamespace, []byte(MintKeyID), func(v []byte) ([]byte, error) {
+		if v != nil {
+			key = ed25519.NewKeyFromSeed(v)
+			return v, nil
+		}
+		_, prv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		key = prv
+		return prv.Seed(), nil
+	})
+	return key, err
+}
+
+// MintToken registers the mint key under the owner and signs the token of the grant, issued by the owner's address.
+// The token carries the grant ID as jti, so tokens of grants alike are told apart
+func MintToken(keys Keys, key ed25519.PrivateKey, namespace string, grant *Grant) (string, error) {
+	if grant.ID == "" {
+		return "", errors.New("grant id is required")
+	}
+	if err := keys.PutKey(namespace, &IssuerKey{
+		ID:        MintKeyID,
+		Type:      KeyEd25519,
+		PublicKey: hex.EncodeToString(key.Public().(ed25519.PublicKey)),
+	}); err != nil {
+		return "", err
+	}
+	return signClaims(MintKeyID, &signedClaims{
+		StandardClaims: jwtgo.StandardClaims{
+			ExpiresAt: grant.ExpiresAt,
+			Id:        grant.ID,
+			IssuedAt:  time.Now().Unix(),
+			Issuer:    "did:io:0x" + namespace,
+			Subject:   grant.Subject,
+		},
+		Scope: grant.Scope,
+	}, key)
+}
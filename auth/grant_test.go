@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/iotexproject/go-pkgs/crypto"
+	"github.com/iotexproject/iotex-antenna-go/v2/jwt"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iotexproject/phoenix/db"
+)
+
+func TestGrants(t *testing.T) {
+	r := require.New(t)
+
+	testFile, err := ioutil.TempFile(os.TempDir(), "test-bolt")
+	path := testFile.Name()
+	r.NoError(err)
+	testFile.Close()
+	defer func() {
+		r.NoError(os.Remove(path))
+	}()
+
+	d := db.NewBoltDB(path)
+	ctx := context.Background()
+	r.NoError(d.Start(ctx))
+	defer func() {
+		r.NoError(d.Stop(ctx))
+	}()
+
+	owner, err := crypto.GenerateKey()
+	r.NoError(err)
+	namespace := owner.PublicKey().Address().Hex()[2:]
+	g := NewGrants(d)
+
+	// recorded token
+	tok, err := SignToken(time.Now().Unix(), time.Now().Add(time.Hour).Unix(), "s3/reports", jwt.READ, Extension{}, owner)
+	r.NoError(err)
+	c, err := NewClaims(tok)
+	r.NoError(err)
+	_, err = g.Live(c)
+	r.Equal(ErrNoLiveGrant, errors.Cause(err))
+	recorded := &Grant{Trustee: "alice", Subject: c.Subject, Scope: c.Scope, ExpiresAt: c.ExpiresAt, TokenHash: c.Hash()}
+	r.NoError(g.CreateGrant(namespace, recorded))
+	r.NotEmpty(recorded.ID)
+	live, err := g.Live(c)
+	r.NoError(err)
+	r.Equal(recorded.ID, live.ID)
+	// a presentation is live with the grant of the credential it carries
+	vp := &Claims{JWT: &jwt.JWT{Subject: c.Subject, Scope: c.Scope}, Token: "presentation", Parent: c}
+	live, err = g.Live(vp)
+	r.NoError(err)
+	r.Equal(recorded.ID, live.ID)
+
+	// minted token, issued by owner's address with phoenix's key
+	keys := NewKeys(d)
+	key, err := MintKey(d)
+	r.NoError(err)
+	again, err := MintKey(d)
+	r.NoError(err)
+	r.Equal(key, again)
+	minted := &Grant{Trustee: "bob", Subject: "ipfs", Scope: jwt.CREATE, ExpiresAt: time.Now().Add(time.Hour).Unix()}
+	_, err = MintToken(keys, key, namespace, minted)
+	r.Error(err)
+	r.NoError(g.CreateGrant(namespace, minted))
+	tok, err = MintToken(keys, key, namespace, minted)
+	r.NoError(err)
+	c2, err := NewClaimsWithResolver(tok, NewIssuerResolver(keys))
+	r.NoError(err)
+	r.Equal(namespace, c2.Namespace)
+	r.Equal("ipfs", c2.Subject)
+	_, err = g.Live(c2)
+	r.Equal(ErrNoLiveGrant, errors.Cause(err))
+	minted.TokenHash = c2.Hash()
+	r.NoError(g.UpdateGrant(namespace, minted))
+	live, err = g.Live(c2)
+	r.NoError(err)
+	r.Equal(minted.ID, live.ID)
+
+	// grants alike are minted different tokens
+	alike := &Grant{Trustee: "bob", Subject: "ipfs", Scope: jwt.CREATE, ExpiresAt: minted.ExpiresAt}
+	r.NoError(g.CreateGrant(namespace, alike))
+	tok2, err := MintToken(keys, key, namespace, alike)
+	r.NoError(err)
+	r.NotEqual(tok, tok2)
+
+	list, err := g.ListGrants(namespace, "")
+	r.NoError(err)
+	r.Len(list, 3)
+	list, err = g.ListGrants(namespace, "s3")
+	r.NoError(err)
+	r.Len(list, 1)
+	r.Equal("alice", list[0].Trustee)
+	list, err = g.ListGrants("other", "")
+	r.NoError(err)
+	r.Empty(list)
+
+	// revoked and expired grants are not live
+	recorded.RevokedAt = time.Now().Unix()
+	r.NoError(g.UpdateGrant(namespace, recorded))
+	_, err = g.Live(c)
+	r.Equal(ErrNoLiveGrant, errors.Cause(err))
+	minted.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+	r.NoError(g.UpdateGrant(namespace, minted))
+	_, err = g.Live(c2)
+	r.Equal(ErrNoLiveGrant, errors.Cause(err))
+
+	_, err = g.GetGrant(namespace, "unknown")
+	r.Equal(ErrGrantNotFound, errors.Cause(err))
+}
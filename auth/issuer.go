@@ -0,0 +1,215 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"encoding/hex"
+	"strings"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/iotexproject/go-pkgs/crypto"
+	"github.com/pkg/errors"
+
+	"github.com/iotexproject/phoenix/db"
+	"github.com/iotexproject/phoenix/json"
+)
+
+// key types of issuer
+const (
+	KeySecp256k1 = "secp256k1"
+	KeyEd25519   = "ed25519"
+	KeyP256      = "p256"
+)
+
+const issuerKeyNamespace = "issuerkey"
+
+// ErrIssuer is returned if the issuer of a token can't be resolved
+var ErrIssuer = errors.New("unknown issuer")
+
+// KeyResolver resolves issuers identified by the key itself, a secp256k1 hex public key or a did:key
+var KeyResolver IssuerResolver = &issuerResolver{}
+
+type (
+	// Issuer is the resolved issuer of a token
+	Issuer struct {
+		// Key is the public key verifying the token, *ecdsa.PublicKey or ed25519.PublicKey
+		Key interface{}
+		// Namespace is the owner namespace, which stays the same when the owner rotates keys
+		Namespace string
+	}
+
+	IssuerResolver interface {
+		// Resolve resolves the issuer and key ID of a token
+		Resolve(iss, kid string) (*Issuer, error)
+	}
+
+	// IssuerKey is a public key an owner registers for signing tokens
+	IssuerKey struct {
+		ID        string `json:"kid"`
+		Type      string `json:"type"`
+		PublicKey string `json:"publicKey"`
+	}
+
+	Keys interface {
+		// GetKey returns owner's issuer key according to key ID
+		GetKey(string, string) (*IssuerKey, error)
+
+		// PutKey puts owner's issuer key into db
+		PutKey(string, *IssuerKey) error
+
+		// DelKey deletes owner's issuer key from db
+		DelKey(string, string) error
+	}
+
+	keys struct {
+		db.KVStore
+	}
+
+	issuerResolver struct {
+		keys Keys
+	}
+)
+
+func NewKeys(kv db.KVStore) Keys {
+	return &keys{
+		KVStore: kv,
+	}
+}
+
+func (k *keys) GetKey(namespace, kid string) (*IssuerKey, error) {
+	v, err := k.Get(issuerKeyNamespace, []byte(namespace+"/"+kid))
+	if err != nil {
+		return nil, err
+	}
+	key := &IssuerKey{}
+	if err := json.Unmarshal(v, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (k *keys) PutKey(namespace string, key *IssuerKey) error {
+	if _, err := key.PublicKeyOf(); err != nil {
+		return err
+	}
+	v, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	return k.Put(issuerKeyNamespace, []byte(namespace+"/"+key.ID), v)
+}
+
+func (k *keys) DelKey(namespace, kid string) error {
+	return k.Delete(issuerKeyNamespace, []byte(namespace+"/"+kid))
+}
+
+// PublicKeyOf decodes the hex public key according to its type
+func (k *IssuerKey) PublicKeyOf() (interface{}, error) {
+	if k.ID == "" {
+		return nil, errors.New("key id is required")
+	}
+	b, err := hex.DecodeString(strings.TrimPrefix(k.PublicKey, "0x"))
+	if err != nil {
+		return nil, err
+	}
+	return decodePublicKey(k.Type, b)
+}
+
+func decodePublicKey(keyType string, b []byte) (interface{}, error) {
+	switch keyType {
+	case KeySecp256k1:
+		if len(b) == 33 {
+			return ethcrypto.DecompressPubkey(b)
+		}
+		pk, err := crypto.BytesToPublicKey(b)
+		if err != nil {
+			return nil, err
+		}
+		return pk.EcdsaPublicKey(), nil
+	case KeyP256:
+		var x, y = elliptic.Unmarshal(elliptic.P256(), b)
+		if x == nil {
+			x, y = elliptic.UnmarshalCompressed(elliptic.P256(), b)
+		}
+		if x == nil {
+			return nil, errors.New("invalid p256 public key")
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	case KeyEd25519:
+		if len(b) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 public key")
+		}
+		return ed25519.PublicKey(b), nil
+	default:
+		return nil, errors.Errorf("key type %s not supported", keyType)
+	}
+}
+
+// NewIssuerResolver creates a resolver which resolves issuers identified by an IoTeX or Ethereum address
+// (or did:io) plus the key ID, besides the issuers KeyResolver resolves
+func NewIssuerResolver(keys Keys) IssuerResolver {
+	return &issuerResolver{
+		keys: keys,
+	}
+}
+
+func (r *issuerResolver) Resolve(iss, kid string) (*Issuer, error) {
+	if strings.HasPrefix(iss, "did:key:") {
+		key, err := DIDKey(iss)
+		if err != nil {
+			return nil, errors.Wrap(ErrIssuer, err.Error())
+		}
+		return &Issuer{Key: key, Namespace: DIDNamespace(iss)}, nil
+	}
+	iss = strings.TrimPrefix(iss, "did:io:")
+	if i := strings.Index(iss, "#"); i >= 0 {
+		iss, kid = iss[:i], iss[i+1:]
+	}
+	// secp256k1 hex public key
+	if pk, err := crypto.HexStringToPublicKey(iss); err == nil {
+		return &Issuer{Key: pk.EcdsaPublicKey(), Namespace: pk.Address().Hex()[2:]}, nil
+	}
+
+	addr, err := ParseAddress(iss)
+	if err != nil {
+		return nil, errors.Wrapf(ErrIssuer, "issuer %s", iss)
+	}
+	if r.keys == nil || kid == "" {
+		return nil, errors.Wrapf(ErrIssuer, "key id of issuer %s is required", iss)
+	}
+	namespace := addr.Hex()[2:]
+	ik, err := r.keys.GetKey(namespace, kid)
+	switch errors.Cause(err) {
+	case nil:
+		break
+	case db.ErrBucketNotExist, db.ErrNotExist:
+		return nil, errors.Wrapf(ErrIssuer, "key %s of issuer %s is not registered", kid, iss)
+	default:
+		return nil, err
+	}
+	key, err := ik.PublicKeyOf()
+	if err != nil {
+		return nil, err
+	}
+	return &Issuer{Key: key, Namespace: namespace}, nil
+}
+
+// signingMethodFor returns the signing method of the key
+func signingMethodFor(key interface{}) jwtgo.SigningMethod {
+	switch key.(type) {
+	case *ecdsa.PublicKey, *ecdsa.PrivateKey:
+		return jwtgo.SigningMethodES256
+	case ed25519.PublicKey, ed25519.PrivateKey:
+		return SigningMethodEdDSA
+	default:
+		return nil
+	}
+}
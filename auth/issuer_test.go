@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iotexproject/go-pkgs/crypto"
+	"github.com/iotexproject/iotex-antenna-go/v2/jwt"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iotexproject/phoenix/db"
+)
+
+func TestDIDKey(t *testing.T) {
+	r := require.New(t)
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	r.NoError(err)
+	did := EncodeDIDKey(pub)
+	r.True(strings.HasPrefix(did, "did:key:z6Mk"))
+	key, err := DIDKey(did)
+	r.NoError(err)
+	r.Equal(ed25519.PublicKey(pub), key)
+	r.Len(DIDNamespace(did), 40)
+
+	_, err = DIDKey("did:key:abc")
+	r.Error(err)
+}
+
+func TestIssuerResolver(t *testing.T) {
+	r := require.New(t)
+
+	testFile, err := ioutil.TempFile(os.TempDir(), "test-bolt")
+	path := testFile.Name()
+	r.NoError(err)
+	testFile.Close()
+	defer func() {
+		r.NoError(os.Remove(path))
+	}()
+
+	d := db.NewBoltDB(path)
+	ctx := context.Background()
+	r.NoError(d.Start(ctx))
+	defer func() {
+		r.NoError(d.Stop(ctx))
+	}()
+
+	owner, err := crypto.GenerateKey()
+	r.NoError(err)
+	namespace := owner.PublicKey().Address().Hex()[2:]
+	keys := NewKeys(d)
+	resolver := NewIssuerResolver(keys)
+	issue := time.Now().Unix()
+	expire := time.Now().Add(time.Hour).Unix()
+
+	// secp256k1 hex public key
+	tok, err := SignToken(issue, expire, "s3", jwt.READ, Extension{}, owner)
+	r.NoError(err)
+	c, err := NewClaimsWithResolver(tok, resolver)
+	r.NoError(err)
+	r.Equal(namespace, c.Namespace)
+
+	// ed25519 and p256 keys registered under owner's address
+	edPub, edPrv, err := ed25519.GenerateKey(rand.Reader)
+	r.NoError(err)
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	r.NoError(err)
+	r.NoError(keys.PutKey(namespace, &IssuerKey{ID: "key-1", Type: KeyEd25519, PublicKey: hex.EncodeToString(edPub)}))
+	r.NoError(keys.PutKey(namespace, &IssuerKey{
+		ID:        "key-2",
+		Type:      KeyP256,
+		PublicKey: hex.EncodeToString(elliptic.Marshal(elliptic.P256(), p256.X, p256.Y)),
+	}))
+	r.Error(keys.PutKey(namespace, &IssuerKey{ID: "key-3", Type: KeyP256, PublicKey: hex.EncodeToString(edPub)}))
+
+	tests := []struct {
+		iss, kid string
+		key      interface{}
+		err      bool
+	}{
+		{owner.PublicKey().Address().String(), "key-1", edPrv, false},
+		{owner.PublicKey().Address().Hex() + "#key-1", "", edPrv, false},
+		{"did:io:" + owner.PublicKey().Address().Hex(), "key-2", p256, false},
+		// key doesn't match kid
+		{owner.PublicKey().Address().String(), "key-2", edPrv, true},
+		// key not registered
+		{owner.PublicKey().Address().String(), "key-3", edPrv, true},
+		// key id is required
+		{owner.PublicKey().Address().String(), "", edPrv, true},
+	}
+	for _, e := range tests {
+		tok, err := SignTokenWithKey(e.iss, e.kid, issue, expire, "s3", jwt.READ, Extension{}, e.key)
+		r.NoError(err)
+		c, err := NewClaimsWithResolver(tok, resolver)
+		if e.err {
+			r.Error(err)
+			continue
+		}
+		r.NoError(err)
+		r.Equal(namespace, c.Namespace)
+		r.Equal("s3", c.Subject)
+	}
+
+	// rotated key is rejected, namespace stays
+	r.NoError(keys.DelKey(namespace, "key-1"))
+	tok, err = SignTokenWithKey(owner.PublicKey().Address().String(), "key-1", issue, expire, "s3", jwt.READ, Extension{}, edPrv)
+	r.NoError(err)
+	_, err = NewClaimsWithResolver(tok, resolver)
+	r.Error(err)
+
+	// did:key is resolved without registry
+	did := EncodeDIDKey(edPub)
+	tok, err = SignTokenWithKey(did, "", issue, expire, "s3", jwt.READ, Extension{}, edPrv)
+	r.NoError(err)
+	c, err = NewClaims(tok)
+	r.NoError(err)
+	r.Equal(DIDNamespace(did), c.Namespace)
+	r.Equal("EdDSA", c.SignMethod)
+
+	iss, err := KeyResolver.Resolve(owner.PublicKey().Address().String(), "key-2")
+	r.Equal(ErrIssuer, errors.Cause(err))
+	r.Nil(iss)
+}
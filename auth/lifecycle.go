@@ -0,0 +1,266 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/iotexproject/phoenix/db"
+	"github.com/iotexproject/phoenix/json"
+)
+
+const (
+	lifecycleNamespace = "lifecycle"
+	expiryNamespace    = "expiry"
+)
+
+// actions of lifecycle rules
+const (
+	LifecycleDelete  = "delete"
+	LifecycleArchive = "archive"
+)
+
+// DefaultStorageClass is the storage class objects are archived to if the rule doesn't name one
+const DefaultStorageClass = "GLACIER"
+
+var (
+	// ErrLifecycleRule is returned if the lifecycle rule is invalid
+	ErrLifecycleRule = errors.New("invalid lifecycle rule")
+
+	// ErrLifecycleRuleNotFound is returned if the lifecycle rule doesn't exist
+	ErrLifecycleRuleNotFound = errors.New("lifecycle rule not found")
+)
+
+type (
+	// LifecycleRule deletes, or archives to a colder storage class, the objects of a bucket in the folder Prefix
+	// once they are older than Days. Native is true if the rule is enforced by the lifecycle configuration of the
+	// backend, otherwise phoenix enforces it
+	LifecycleRule struct {
+		ID           string `json:"id"`
+		Owner        string `json:"-"`
+		Store        string `json:"store"`
+		Bucket       string `json:"bucket"`
+		Prefix       string `json:"prefix,omitempty"`
+		Action       string `json:"action"`
+		Days         int    `json:"days"`
+		StorageClass string `json:"storageClass,omitempty"`
+		Native       bool   `json:"native"`
+		CreatedAt    int64  `json:"createdAt"`
+	}
+
+	// Expiry is the time an object uploaded with a TTL is deleted
+	Expiry struct {
+		Owner     string `json:"-"`
+		Store     string `json:"store"`
+		Bucket    string `json:"bucket"`
+		Path      string `json:"path"`
+		ExpiresAt int64  `json:"expiresAt"`
+	}
+
+	Lifecycles interface {
+		// PutRule puts owner's lifecycle rule into db, a new rule is assigned an ID
+		PutRule(string, *LifecycleRule) error
+
+		// GetRule returns owner's lifecycle rule according to its ID
+		GetRule(string, string) (*LifecycleRule, error)
+
+		// DelRule removes owner's lifecycle rule
+		DelRule(string, string) error
+
+		// Rules returns owner's lifecycle rules, or those of all owners if namespace is empty
+		Rules(namespace string) ([]*LifecycleRule, error)
+
+		// SetExpiry records the time owner's object expires, an expiry of zero clears it
+		SetExpiry(string, *Expiry) error
+
+		// Expired returns owner's objects expired by the time, or those of all owners if namespace is empty
+		Expired(namespace string, t int64) ([]*Expiry, error)
+
+		// Forget removes the expiry of owner's object, or the rules and expiries of the bucket if path is empty,
+		// or those of the store if bucket is empty too
+		Forget(namespace, store, bucket, path string) error
+	}
+
+	lifecycles struct {
+		db.KVStore
+	}
+)
+
+// Normalize validates the rule, and trims the slashes of its prefix
+func (rule *LifecycleRule) Normalize() error {
+	if rule.Store == "" || rule.Bucket == "" || strings.Contains(rule.Store, "/") || strings.Contains(rule.Bucket, "/") {
+		return errors.Wrap(ErrLifecycleRule, "store and bucket are required, and can't contain /")
+	}
+	switch rule.Action {
+	case LifecycleDelete:
+		rule.StorageClass = ""
+	case LifecycleArchive:
+		if rule.StorageClass == "" {
+			rule.StorageClass = DefaultStorageClass
+		}
+	default:
+		return errors.Wrapf(ErrLifecycleRule, "action must be %s or %s", LifecycleDelete, LifecycleArchive)
+	}
+	if rule.Days <= 0 {
+		return errors.Wrap(ErrLifecycleRule, "days must be positive")
+	}
+	rule.Prefix = strings.Trim(rule.Prefix, "/")
+	if IsTrashPath(rule.Prefix) {
+		return errors.Wrap(ErrLifecycleRule, "the recycle bin empties itself")
+	}
+	return nil
+}
+
+// Due returns true if an object last modified at the time is old enough for the rule by now
+func (rule *LifecycleRule) Due(modified, now time.Time) bool {
+	return !modified.Add(time.Duration(rule.Days) * 24 * time.Hour).After(now)
+}
+
+func NewLifecycles(kv db.KVStore) Lifecycles {
+	return &lifecycles{
+		KVStore: kv,
+	}
+}
+
+func (m *lifecycles) PutRule(namespace string, rule *LifecycleRule) error {
+	if rule.ID == "" {
+		id, err := randomHex(16)
+		if err != nil {
+			return err
+		}
+		rule.ID, rule.CreatedAt = id, time.Now().Unix()
+	}
+	v, err := json.Marshal(rule)
+	if err != nil {
+		return err
+	}
+	return m.Put(lifecycleNamespace, []byte(namespace+"/"+rule.ID), v)
+}
+
+func (m *lifecycles) GetRule(namespace, id string) (*LifecycleRule, error) {
+	v, err := m.Get(lifecycleNamespace, []byte(namespace+"/"+id))
+	switch errors.Cause(err) {
+	case nil:
+		break
+	case db.ErrBucketNotExist, db.ErrNotExist:
+		return nil, errors.Wrapf(ErrLifecycleRuleNotFound, "lifecycle rule %s", id)
+	default:
+		return nil, err
+	}
+	rule := &LifecycleRule{Owner: namespace}
+	if err := json.Unmarshal(v, rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (m *lifecycles) DelRule(namespace, id string) error {
+	if _, err := m.GetRule(namespace, id); err != nil {
+		return err
+	}
+	return m.Delete(lifecycleNamespace, []byte(namespace+"/"+id))
+}
+
+func (m *lifecycles) Rules(namespace string) ([]*LifecycleRule, error) {
+	keys, values, err := m.List(lifecycleNamespace, ownerPrefix(namespace))
+	if err != nil {
+		return nil, err
+	}
+	list := []*LifecycleRule{}
+	for i, v := range values {
+		rule := &LifecycleRule{Owner: keyOwner(keys[i])}
+		if err := json.Unmarshal(v, rule); err != nil {
+			return nil, err
+		}
+		list = append(list, rule)
+	}
+	return list, nil
+}
+
+func expiryKey(namespace, store, bucket, path string) []byte {
+	return []byte(strings.Join([]string{namespace, store, bucket, path}, "/"))
+}
+
+func (m *lifecycles) SetExpiry(namespace string, e *Expiry) error {
+	key := expiryKey(namespace, e.Store, e.Bucket, e.Path)
+	if e.ExpiresAt == 0 {
+		return m.Delete(expiryNamespace, key)
+	}
+	return m.Put(expiryNamespace, key, []byte(strconv.FormatInt(e.ExpiresAt, 10)))
+}
+
+func (m *lifecycles) Expired(namespace string, t int64) ([]*Expiry, error) {
+	keys, values, err := m.List(expiryNamespace, ownerPrefix(namespace))
+	if err != nil {
+		return nil, err
+	}
+	list := []*Expiry{}
+	for i, v := range values {
+		expiresAt, err := strconv.ParseInt(string(v), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		if expiresAt > t {
+			continue
+		}
+		// owner, store and bucket can't contain /, the path can
+		parts := strings.SplitN(string(keys[i]), "/", 4)
+		if len(parts) != 4 {
+			return nil, errors.Errorf("invalid expiry key %s", keys[i])
+		}
+		list = append(list, &Expiry{Owner: parts[0], Store: parts[1], Bucket: parts[2], Path: parts[3], ExpiresAt: expiresAt})
+	}
+	return list, nil
+}
+
+func (m *lifecycles) Forget(namespace, store, bucket, path string) error {
+	if path != "" {
+		return m.Delete(expiryNamespace, expiryKey(namespace, store, bucket, path))
+	}
+	prefix := namespace + "/" + store + "/"
+	if bucket != "" {
+		prefix += bucket + "/"
+	}
+	keys, _, err := m.List(expiryNamespace, []byte(prefix))
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := m.Delete(expiryNamespace, key); err != nil {
+			return err
+		}
+	}
+	rules, err := m.Rules(namespace)
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		if rule.Store == store && (bucket == "" || rule.Bucket == bucket) {
+			if err := m.Delete(lifecycleNamespace, []byte(namespace+"/"+rule.ID)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ownerPrefix returns the prefix of keys of owner's records, nil to list those of all owners
+func ownerPrefix(namespace string) []byte {
+	if namespace == "" {
+		return nil
+	}
+	return []byte(namespace + "/")
+}
+
+// keyOwner returns the owner of the record according to its key
+func keyOwner(key []byte) string {
+	return strings.SplitN(string(key), "/", 2)[0]
+}
@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iotexproject/phoenix/db"
+)
+
+func TestLifecycles(t *testing.T) {
+	r := require.New(t)
+
+	testFile, err := ioutil.TempFile(os.TempDir(), "test-bolt")
+	path := testFile.Name()
+	r.NoError(err)
+	testFile.Close()
+	defer func() {
+		r.NoError(os.Remove(path))
+	}()
+
+	d := db.NewBoltDB(path)
+	ctx := context.Background()
+	r.NoError(d.Start(ctx))
+	defer func() {
+		r.NoError(d.Stop(ctx))
+	}()
+
+	for _, rule := range []*LifecycleRule{
+		{Store: "s3", Action: LifecycleDelete, Days: 1},
+		{Store: "s3", Bucket: "a/b", Action: LifecycleDelete, Days: 1},
+		{Store: "s3", Bucket: "logs", Action: "expire", Days: 1},
+		{Store: "s3", Bucket: "logs", Action: LifecycleDelete},
+		{Store: "s3", Bucket: "logs", Prefix: TrashPrefix + "/", Action: LifecycleDelete, Days: 1},
+	} {
+		r.Equal(ErrLifecycleRule, errors.Cause(rule.Normalize()))
+	}
+	archive := &LifecycleRule{Store: "s3", Bucket: "logs", Prefix: "/raw/", Action: LifecycleArchive, Days: 30}
+	r.NoError(archive.Normalize())
+	r.Equal("raw", archive.Prefix)
+	r.Equal(DefaultStorageClass, archive.StorageClass)
+	now := time.Now()
+	r.True(archive.Due(now.Add(-30*24*time.Hour), now))
+	r.False(archive.Due(now.Add(-29*24*time.Hour), now))
+
+	m := NewLifecycles(d)
+	rules, err := m.Rules("")
+	r.NoError(err)
+	r.Empty(rules)
+	r.NoError(m.PutRule("owner1", archive))
+	r.Len(archive.ID, 32)
+	other := &LifecycleRule{Store: "minio", Bucket: "tmp", Action: LifecycleDelete, Days: 1}
+	r.NoError(m.PutRule("owner2", other))
+
+	rule, err := m.GetRule("owner1", archive.ID)
+	r.NoError(err)
+	r.Equal("owner1", rule.Owner)
+	r.Equal(archive.Prefix, rule.Prefix)
+	_, err = m.GetRule("owner2", archive.ID)
+	r.Equal(ErrLifecycleRuleNotFound, errors.Cause(err))
+	rules, err = m.Rules("")
+	r.NoError(err)
+	r.Len(rules, 2)
+	rules, err = m.Rules("owner2")
+	r.NoError(err)
+	r.Len(rules, 1)
+	r.Equal("owner2", rules[0].Owner)
+
+	// expiries of objects
+	r.NoError(m.SetExpiry("owner1", &Expiry{Store: "s3", Bucket: "logs", Path: "raw/a.json", ExpiresAt: 100}))
+	r.NoError(m.SetExpiry("owner1", &Expiry{Store: "s3", Bucket: "logs", Path: "raw/b.json", ExpiresAt: 200}))
+	r.NoError(m.SetExpiry("owner2", &Expiry{Store: "minio", Bucket: "tmp", Path: "c", ExpiresAt: 100}))
+	expired, err := m.Expired("", 150)
+	r.NoError(err)
+	r.Len(expired, 2)
+	expired, err = m.Expired("owner1", 200)
+	r.NoError(err)
+	r.Len(expired, 2)
+	r.Equal(&Expiry{Owner: "owner1", Store: "s3", Bucket: "logs", Path: "raw/a.json", ExpiresAt: 100}, expired[0])
+
+	// uploaded again without TTL
+	r.NoError(m.SetExpiry("owner1", &Expiry{Store: "s3", Bucket: "logs", Path: "raw/a.json"}))
+	expired, err = m.Expired("owner1", 200)
+	r.NoError(err)
+	r.Len(expired, 1)
+	r.NoError(m.Forget("owner1", "s3", "logs", "raw/b.json"))
+	expired, err = m.Expired("owner1", 200)
+	r.NoError(err)
+	r.Empty(expired)
+
+	// the rules and expiries of a bucket, or store, deleted are forgotten
+	r.NoError(m.SetExpiry("owner1", &Expiry{Store: "s3", Bucket: "logs", Path: "raw/a.json", ExpiresAt: 100}))
+	r.NoError(m.Forget("owner1", "s3", "logs", ""))
+	expired, err = m.Expired("owner1", 200)
+	r.NoError(err)
+	r.Empty(expired)
+	rules, err = m.Rules("owner1")
+	r.NoError(err)
+	r.Empty(rules)
+	r.NoError(m.Forget("owner2", "minio", "", ""))
+	rules, err = m.Rules("")
+	r.NoError(err)
+	r.Empty(rules)
+	expired, err = m.Expired("", 200)
+	r.NoError(err)
+	r.Empty(expired)
+
+	r.NoError(m.PutRule("owner1", archive))
+	r.NoError(m.DelRule("owner1", archive.ID))
+	r.Equal(ErrLifecycleRuleNotFound, errors.Cause(m.DelRule("owner1", archive.ID)))
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/iotexproject/phoenix/db"
+	"github.com/iotexproject/phoenix/json"
+)
+
+const meterNamespace = "meter"
+
+// granularities meters are aggregated in
+const (
+	MeterHourly = "hour"
+	MeterDaily  = "day"
+)
+
+// ErrGranularity is returned if the granularity is neither hour nor day
+var ErrGranularity = errors.New("granularity must be hour or day")
+
+// meterPeriods are the seconds of each granularity
+var meterPeriods = map[string]int64{
+	MeterHourly: 60 * 60,
+	MeterDaily:  24 * 60 * 60,
+}
+
+type (
+	// Meter is the requests, bytes received and sent, and storage operations of an owner, or of a token of it,
+	// in the hour or day since Start. The meter of the owner has no token
+	Meter struct {
+		Granularity string           `json:"granularity"`
+		Start       int64            `json:"start"`
+		Owner       string           `json:"owner"`
+		Token       string           `json:"token,omitempty"`
+		Requests    int64            `json:"requests"`
+		BytesIn     int64            `json:"bytesIn"`
+		BytesOut    int64            `json:"bytesOut"`
+		Ops         map[string]int64 `json:"ops"`
+	}
+
+	Metering interface {
+		// Record adds a request of the operation with the bytes received and sent to the hourly and daily meters
+		// of the owner and of the token
+		Record(namespace, token, op string, in, out int64, at time.Time) error
+
+		// Export returns the meters of the granularity starting within the time range, of all owners if
+		// namespace is empty
+		Export(namespace, granularity string, from, to int64) ([]*Meter, error)
+	}
+
+	metering struct {
+		db.KVStore
+	}
+)
+
+func NewMetering(kv db.KVStore) Metering {
+	return &metering{
+		KVStore: kv,
+	}
+}
+
+// meterKey orders meters by granularity, owner and start, the owner's own meter before those of its tokens
+func meterKey(granularity, namespace string, start int64, token string) []byte {
+	return []byte(fmt.Sprintf("%s/%s/%012d/%s", granularity, namespace, start, token))
+}
+
+func (m *metering) Record(namespace, token, op string, in, out int64, at time.Time) error {
+	// the hourly and daily meters of the owner and of the token are updated in one transaction
+	return m.Batch(func(tx db.Tx) error {
+		for granularity, period := range meterPeriods {
+			start := at.Unix() - at.Unix()%period
+			for _, t := range []string{"", token} {
+				if err := addMeter(tx, &Meter{Granularity: granularity, Start: start, Owner: namespace, Token: t}, op, in, out); err != nil {
+					return err
+				}
+				if token == "" {
+					break
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func addMeter(tx db.Tx, meter *Meter, op string, in, out int64) error {
+	key := meterKey(meter.Granularity, meter.Owner, meter.Start, meter.Token)
+	v, err := tx.Get(meterNamespace, key)
+	switch errors.Cause(err) {
+	case nil:
+		if err := json.Unmarshal(v, meter); err != nil {
+			return err
+		}
+	case db.ErrBucketNotExist, db.ErrNotExist:
+		break
+	default:
+		return err
+	}
+	if meter.Ops == nil {
+		meter.Ops = map[string]int64{}
+	}
+	meter.Requests++
+	meter.BytesIn += in
+	meter.BytesOut += out
+	if op != "" {
+		meter.Ops[op]++
+	}
+	return putJSON(tx, meterNamespace, key, meter)
+}
+
+func (m *metering) Export(namespace, granularity string, from, to int64) ([]*Meter, error) {
+	if _, ok := meterPeriods[granularity]; !ok {
+		return nil, ErrGranularity
+	}
+	prefix := granularity + "/"
+	if namespace != "" {
+		prefix += namespace + "/"
+	}
+	_, values, err := m.List(meterNamespace, []byte(prefix))
+	if err != nil {
+		return nil, err
+	}
+	list := []*Meter{}
+	for _, v := range values {
+		meter := &Meter{}
+		if err := json.Unmarshal(v, meter); err != nil {
+			return nil, err
+		}
+		if (from == 0 || meter.Start >= from) && (to == 0 || meter.Start <= to) {
+			list = append(list, meter)
+		}
+	}
+	return list, nil
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/iotexproject/iotex-antenna-go/v2/jwt"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iotexproject/phoenix/db"
+)
+
+func TestMetering(t *testing.T) {
+	r := require.New(t)
+
+	testFile, err := ioutil.TempFile(os.TempDir(), "test-bolt")
+	path := testFile.Name()
+	r.NoError(err)
+	testFile.Close()
+	defer func() {
+		r.NoError(os.Remove(path))
+	}()
+
+	d := db.NewBoltDB(path)
+	ctx := context.Background()
+	r.NoError(d.Start(ctx))
+	defer func() {
+		r.NoError(d.Stop(ctx))
+	}()
+
+	m := NewMetering(d)
+	day := time.Date(2020, 12, 12, 0, 0, 0, 0, time.UTC)
+	r.NoError(m.Record("owner", "alice", jwt.UPDATE, 100, 20, day.Add(10*time.Minute)))
+	r.NoError(m.Record("owner", "alice", jwt.READ, 0, 100, day.Add(50*time.Minute)))
+	r.NoError(m.Record("owner", "bob", jwt.READ, 0, 30, day.Add(2*time.Hour)))
+	r.NoError(m.Record("owner", "", "", 0, 0, day.Add(25*time.Hour)))
+	r.NoError(m.Record("other", "carol", jwt.DELETE, 0, 0, day))
+
+	// owner's meters come before those of its tokens
+	hourly, err := m.Export("owner", MeterHourly, 0, 0)
+	r.NoError(err)
+	r.Len(hourly, 5)
+	r.Equal("", hourly[0].Token)
+	r.Equal(day.Unix(), hourly[0].Start)
+	r.Equal(&Meter{
+		Granularity: MeterHourly,
+		Start:       day.Unix(),
+		Owner:       "owner",
+		Token:       "alice",
+		Requests:    2,
+		BytesIn:     100,
+		BytesOut:    120,
+		Ops:         map[string]int64{jwt.UPDATE: 1, jwt.READ: 1},
+	}, hourly[1])
+	r.Equal("bob", hourly[3].Token)
+	r.Equal(day.Add(25*time.Hour).Unix(), hourly[4].Start)
+
+	daily, err := m.Export("owner", MeterDaily, day.Unix(), day.Unix())
+	r.NoError(err)
+	r.Len(daily, 3)
+	r.Equal(int64(3), daily[0].Requests)
+	r.Equal(int64(150), daily[0].BytesOut)
+	r.Equal(map[string]int64{jwt.UPDATE: 1, jwt.READ: 2}, daily[0].Ops)
+	r.Equal("alice", daily[1].Token)
+	r.Equal("bob", daily[2].Token)
+
+	// all owners
+	daily, err = m.Export("", MeterDaily, 0, 0)
+	r.NoError(err)
+	r.Len(daily, 6)
+	r.Equal("other", daily[0].Owner)
+
+	_, err = m.Export("owner", "week", 0, 0)
+	r.Equal(ErrGranularity, err)
+
+	// concurrent requests are all counted
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		go func() {
+			errs <- m.Record("busy", "dave", jwt.READ, 1, 2, day)
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		r.NoError(<-errs)
+	}
+	daily, err = m.Export("busy", MeterDaily, 0, 0)
+	r.NoError(err)
+	r.Len(daily, 2)
+	r.Equal(int64(10), daily[0].Requests)
+	r.Equal(int64(20), daily[1].BytesOut)
+}
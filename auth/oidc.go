@@ -0,0 +1,443 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/hex"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/iotexproject/go-pkgs/hash"
+	"github.com/iotexproject/iotex-antenna-go/v2/jwt"
+	"github.com/pkg/errors"
+
+	"github.com/iotexproject/phoenix/db"
+	"github.com/iotexproject/phoenix/json"
+)
+
+// OwnerHeader is the header naming the owner whose data a token of identity provider accesses
+const OwnerHeader = "X-Phoenix-Owner"
+
+const identityNamespace = "identity"
+
+// DefaultJWKSCacheTTL is how long the keys fetched from JWKS URL are cached
+const DefaultJWKSCacheTTL = time.Hour
+
+var (
+	// ErrIdentity is returned if the token of identity provider is invalid
+	ErrIdentity = errors.New("invalid identity token")
+
+	// ErrNoGrant is returned if the owner hasn't granted access to the identity
+	ErrNoGrant = errors.New("no grant for identity")
+)
+
+type (
+	// OIDCProvider is an identity provider whose RS256/ES256 tokens are accepted
+	OIDCProvider struct {
+		Issuer   string
+		JWKSURL  string
+		Audience string
+	}
+
+	// Identity is the verified identity of a token issued by identity provider
+	Identity struct {
+		Issuer    string
+		Subject   string
+		IssuedAt  int64
+		ExpiresAt int64
+		Method    string
+	}
+
+	// OIDCVerifier verifies tokens of the configured identity providers
+	OIDCVerifier struct {
+		providers map[string]*oidcProvider
+	}
+
+	oidcProvider struct {
+		OIDCProvider
+		jwks *JWKS
+	}
+
+	// JWKS caches the keys of a JWKS URL, the keys are fetched again when the cache expires
+	// or a token is signed by an unknown key ID, so rotated keys are picked up
+	JWKS struct {
+		url        string
+		ttl        time.Duration
+		minRefresh time.Duration
+		client     *http.Client
+		mu         sync.Mutex
+		keys       map[string]interface{}
+		fetchedAt  time.Time
+		fetching   *jwksFetch
+	}
+
+	// jwksFetch is the fetch of keys in flight, callers refreshing meanwhile wait for it to be done
+	jwksFetch struct {
+		done chan struct{}
+		err  error
+	}
+
+	jsonWebKey struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+	}
+
+	// IdentityGrant is the access an owner grants to an identity of identity provider
+	IdentityGrant struct {
+		ID        string `json:"id"`
+		Issuer    string `json:"issuer"`
+		Identity  string `json:"identity"`
+		Subject   string `json:"subject"`
+		Scope     string `json:"scope"`
+		ExpiresAt int64  `json:"expiresAt"`
+	}
+
+	Identities interface {
+		// GetIdentity returns owner's grant according to grant ID
+		GetIdentity(string, string) (*IdentityGrant, error)
+
+		// PutIdentity puts owner's grant into db
+		PutIdentity(string, *IdentityGrant) error
+
+		// DelIdentity deletes owner's grant from db
+		DelIdentity(string, string) error
+	}
+
+	identities struct {
+		db.KVStore
+	}
+)
+
+// NewJWKS creates the key cache of the JWKS URL
+func NewJWKS(url string, ttl time.Duration) *JWKS {
+	if ttl <= 0 {
+		ttl = DefaultJWKSCacheTTL
+	}
+	return &JWKS{
+		url:        url,
+		ttl:        ttl,
+		minRefresh: time.Minute,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Key returns the key of key ID, the only key is returned if key ID is empty
+func (j *JWKS) Key(kid string) (interface{}, error) {
+	j.mu.Lock()
+	stale, cached := time.Since(j.fetchedAt) > j.ttl, j.keys != nil
+	j.mu.Unlock()
+	if stale {
+		if err := j.refresh(); err != nil && !cached {
+			return nil, err
+		}
+	}
+	key, ok, recent := j.lookup(kid)
+	if !ok && !recent {
+		if err := j.refresh(); err != nil {
+			return nil, err
+		}
+		key, ok, _ = j.lookup(kid)
+	}
+	if !ok {
+		return nil, errors.Wrapf(ErrIdentity, "unknown key %s", kid)
+	}
+	return key, nil
+}
+
+// lookup returns the key of key ID cached, and whether the keys are fetched too recently to fetch them again
+func (j *JWKS) lookup(kid string) (interface{}, bool, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	recent := time.Since(j.fetchedAt) <= j.minRefresh
+	if kid == "" && len(j.keys) == 1 {
+		for _, k := range j.keys {
+			return k, true, recent
+		}
+	}
+	key, ok := j.keys[kid]
+	return key, ok, recent
+}
+
+// refresh fetches the keys without holding the lock, so lookups of cached keys aren't held up by the URL. Only
+// one fetch is in flight, callers refreshing meanwhile wait for it and share its result
+func (j *JWKS) refresh() error {
+	j.mu.Lock()
+	if f := j.fetching; f != nil {
+		j.mu.Unlock()
+		<-f.done
+		return f.err
+	}
+	f := &jwksFetch{done: make(chan struct{})}
+	j.fetching = f
+	j.mu.Unlock()
+
+	keys, err := j.fetch()
+	j.mu.Lock()
+	if err == nil {
+		j.keys, j.fetchedAt = keys, time.Now()
+	}
+	f.err, j.fetching = err, nil
+	j.mu.Unlock()
+	close(f.done)
+	return err
+}
+
+func (j *JWKS) fetch() (map[string]interface{}, error) {
+	resp, err := j.client.Get(j.url)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to fetch %s", j.url)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("failed to fetch %s: %s", j.url, resp.Status)
+	}
+	set := struct {
+		Keys []jsonWebKey `json:"keys"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode %s", j.url)
+	}
+	keys := make(map[string]interface{})
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		// skip the key types not supported
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+func (k *jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, errors.Errorf("curve %s not supported", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	default:
+		return nil, errors.Errorf("key type %s not supported", k.Kty)
+	}
+}
+
+// NewOIDCVerifier creates a verifier of the identity providers, whose keys are cached for ttl. Providers must name
+// the audience of phoenix, otherwise tokens they issue to other relying parties would be accepted
+func NewOIDCVerifier(ttl time.Duration, providers ...OIDCProvider) (*OIDCVerifier, error) {
+	v := &OIDCVerifier{
+		providers: make(map[string]*oidcProvider),
+	}
+	for _, p := range providers {
+		if err := p.Validate(); err != nil {
+			return nil, err
+		}
+		v.providers[p.Issuer] = &oidcProvider{
+			OIDCProvider: p,
+			jwks:         NewJWKS(p.JWKSURL, ttl),
+		}
+	}
+	return v, nil
+}
+
+// Validate checks the provider has an issuer, keys and the audience tokens must be issued to
+func (p *OIDCProvider) Validate() error {
+	if p.Issuer == "" || p.JWKSURL == "" || p.Audience == "" {
+		return errors.Wrapf(ErrIdentity, "provider %s requires issuer, jwksURL and audience", p.Issuer)
+	}
+	return nil
+}
+
+// Accepts returns true if the token is issued by one of the identity providers, the token is not verified
+func (v *OIDCVerifier) Accepts(jwtString string) bool {
+	if len(v.providers) == 0 {
+		return false
+	}
+	claim := jwtgo.MapClaims{}
+	if _, _, err := new(jwtgo.Parser).ParseUnverified(jwtString, claim); err != nil {
+		return false
+	}
+	iss, _ := claim["iss"].(string)
+	_, ok := v.providers[iss]
+	return ok
+}
+
+// Verify verifies the token against the keys of its identity provider
+func (v *OIDCVerifier) Verify(jwtString string) (*Identity, error) {
+	var provider *oidcProvider
+	claim := jwtgo.MapClaims{}
+	parser := &jwtgo.Parser{ValidMethods: []string{jwtgo.SigningMethodRS256.Alg(), jwtgo.SigningMethodES256.Alg()}}
+	token, err := parser.ParseWithClaims(jwtString, claim, func(token *jwtgo.Token) (interface{}, error) {
+		iss, _ := claim["iss"].(string)
+		var ok bool
+		if provider, ok = v.providers[iss]; !ok {
+			return nil, errors.Wrapf(ErrIdentity, "unknown issuer %s", iss)
+		}
+		kid, _ := token.Header["kid"].(string)
+		key, err := provider.jwks.Key(kid)
+		if err != nil {
+			return nil, err
+		}
+		switch key.(type) {
+		case *rsa.PublicKey:
+			ok = token.Method.Alg() == jwtgo.SigningMethodRS256.Alg()
+		case *ecdsa.PublicKey:
+			ok = token.Method.Alg() == jwtgo.SigningMethodES256.Alg()
+		}
+		if !ok {
+			return nil, errors.Wrapf(ErrIdentity, "alg %s doesn't match key %s", token.Method.Alg(), kid)
+		}
+		return key, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	if !hasAudience(claim["aud"], provider.Audience) {
+		return nil, errors.Wrapf(ErrIdentity, "token is not issued to %s", provider.Audience)
+	}
+	id := &Identity{
+		Issuer: provider.Issuer,
+		Method: token.Method.Alg(),
+	}
+	id.Subject, _ = claim["sub"].(string)
+	if exp, ok := claim["exp"].(float64); ok {
+		id.ExpiresAt = int64(exp)
+	}
+	if iat, ok := claim["iat"].(float64); ok {
+		id.IssuedAt = int64(iat)
+	}
+	if id.Subject == "" || id.ExpiresAt == 0 {
+		return nil, errors.Wrap(ErrIdentity, "sub and exp are required")
+	}
+	return id, nil
+}
+
+func hasAudience(aud interface{}, audience string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == audience
+	case []interface{}:
+		for _, a := range v {
+			if s, ok := a.(string); ok && s == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IdentityID returns the grant ID of the identity
+func IdentityID(issuer, subject string) string {
+	h := hash.Hash160b([]byte(issuer + "#" + subject))
+	return hex.EncodeToString(h[:])
+}
+
+// OwnerNamespace returns the namespace of the owner, an IoTeX or Ethereum address, or a DID
+func OwnerNamespace(owner string) (string, error) {
+	if strings.HasPrefix(owner, "did:") {
+		return didNamespace(owner)
+	}
+	addr, err := ParseAddress(owner)
+	if err != nil {
+		return "", err
+	}
+	return addr.Hex()[2:], nil
+}
+
+// Claims maps the identity into claims with the access the owner granted, until the earlier of
+// the identity token's and the grant's expiry
+func (g *IdentityGrant) Claims(namespace, jwtString string, id *Identity) (*Claims, error) {
+	if g.ExpiresAt != 0 && g.ExpiresAt < time.Now().Unix() {
+		return nil, errors.Wrap(ErrNoGrant, "grant expired")
+	}
+	expire := id.ExpiresAt
+	if g.ExpiresAt != 0 && g.ExpiresAt < expire {
+		expire = g.ExpiresAt
+	}
+	return &Claims{
+		JWT: &jwt.JWT{
+			IssuedAt:   id.IssuedAt,
+			ExpiresAt:  expire,
+			Issuer:     id.Issuer,
+			Subject:    g.Subject,
+			Scope:      g.Scope,
+			SignMethod: id.Method,
+		},
+		Token:     jwtString,
+		Namespace: namespace,
+	}, nil
+}
+
+func NewIdentities(kv db.KVStore) Identities {
+	return &identities{
+		KVStore: kv,
+	}
+}
+
+func (i *identities) GetIdentity(namespace, id string) (*IdentityGrant, error) {
+	v, err := i.Get(identityNamespace, []byte(namespace+"/"+id))
+	if err != nil {
+		return nil, err
+	}
+	g := &IdentityGrant{}
+	if err := json.Unmarshal(v, g); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func (i *identities) PutIdentity(namespace string, g *IdentityGrant) error {
+	if g.Issuer == "" || g.Identity == "" {
+		return errors.New("issuer and identity are required")
+	}
+	g.ID = IdentityID(g.Issuer, g.Identity)
+	v, err := json.Marshal(g)
+	if err != nil {
+		return err
+	}
+	return i.Put(identityNamespace, []byte(namespace+"/"+g.ID), v)
+}
+
+func (i *identities) DelIdentity(namespace, id string) error {
+	return i.Delete(identityNamespace, []byte(namespace+"/"+id))
+}
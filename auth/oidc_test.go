@@ -0,0 +1,241 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/iotexproject/iotex-antenna-go/v2/jwt"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iotexproject/phoenix/db"
+	"github.com/iotexproject/phoenix/json"
+)
+
+type testJWKSServer struct {
+	mu      sync.Mutex
+	keys    []jsonWebKey
+	fetches int
+	delay   time.Duration
+}
+
+func (s *testJWKSServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.fetches++
+	keys, delay := s.keys, s.delay
+	s.mu.Unlock()
+	time.Sleep(delay)
+	json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+}
+
+func (s *testJWKSServer) set(keys ...jsonWebKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+}
+
+func rsaJWK(kid string, key *rsa.PrivateKey) jsonWebKey {
+	return jsonWebKey{
+		Kty: "RSA",
+		Kid: kid,
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func ecJWK(kid string, key *ecdsa.PrivateKey) jsonWebKey {
+	return jsonWebKey{
+		Kty: "EC",
+		Kid: kid,
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(key.X.Bytes()),
+		Y:   base64.RawURLEncoding.EncodeToString(key.Y.Bytes()),
+	}
+}
+
+func signIdentityToken(method jwtgo.SigningMethod, kid string, claims jwtgo.MapClaims, key interface{}) (string, error) {
+	token := jwtgo.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	return token.SignedString(key)
+}
+
+func TestOIDCVerifier(t *testing.T) {
+	r := require.New(t)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	r.NoError(err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	r.NoError(err)
+	jwks := &testJWKSServer{}
+	jwks.set(rsaJWK("rsa-1", rsaKey), ecJWK("ec-1", ecKey))
+	srv := httptest.NewServer(jwks)
+	defer srv.Close()
+
+	const issuer = "https://idp.example.com"
+	// providers must name the audience tokens are issued to
+	_, err = NewOIDCVerifier(time.Hour, OIDCProvider{Issuer: issuer, JWKSURL: srv.URL})
+	r.Equal(ErrIdentity, errors.Cause(err))
+	v, err := NewOIDCVerifier(time.Hour, OIDCProvider{Issuer: issuer, JWKSURL: srv.URL, Audience: "phoenix"})
+	r.NoError(err)
+	v.providers[issuer].jwks.minRefresh = 0
+	exp := time.Now().Add(time.Hour).Unix()
+	claims := jwtgo.MapClaims{"iss": issuer, "sub": "alice@example.com", "aud": []string{"phoenix"}, "exp": exp}
+
+	tok, err := signIdentityToken(jwtgo.SigningMethodRS256, "rsa-1", claims, rsaKey)
+	r.NoError(err)
+	r.True(v.Accepts(tok))
+	id, err := v.Verify(tok)
+	r.NoError(err)
+	r.Equal(issuer, id.Issuer)
+	r.Equal("alice@example.com", id.Subject)
+	r.Equal(exp, id.ExpiresAt)
+
+	tok, err = signIdentityToken(jwtgo.SigningMethodES256, "ec-1", claims, ecKey)
+	r.NoError(err)
+	_, err = v.Verify(tok)
+	r.NoError(err)
+	r.Equal(1, jwks.fetches)
+
+	// alg doesn't match the key
+	tok, err = signIdentityToken(jwtgo.SigningMethodHS256, "rsa-1", claims, []byte("secret"))
+	r.NoError(err)
+	_, err = v.Verify(tok)
+	r.Error(err)
+
+	// wrong audience
+	tok, err = signIdentityToken(jwtgo.SigningMethodRS256, "rsa-1", jwtgo.MapClaims{"iss": issuer, "sub": "alice", "aud": "other", "exp": exp}, rsaKey)
+	r.NoError(err)
+	_, err = v.Verify(tok)
+	r.Equal(ErrIdentity, errors.Cause(err))
+	tok, err = signIdentityToken(jwtgo.SigningMethodRS256, "rsa-1", jwtgo.MapClaims{"iss": issuer, "sub": "alice", "exp": exp}, rsaKey)
+	r.NoError(err)
+	_, err = v.Verify(tok)
+	r.Equal(ErrIdentity, errors.Cause(err))
+
+	// other issuers are left to other verifiers
+	tok, err = signIdentityToken(jwtgo.SigningMethodRS256, "rsa-1", jwtgo.MapClaims{"iss": "https://other.com", "sub": "alice", "exp": exp}, rsaKey)
+	r.NoError(err)
+	r.False(v.Accepts(tok))
+
+	// rotated key is fetched on unknown key ID
+	rotated, err := rsa.GenerateKey(rand.Reader, 2048)
+	r.NoError(err)
+	jwks.set(rsaJWK("rsa-2", rotated))
+	tok, err = signIdentityToken(jwtgo.SigningMethodRS256, "rsa-2", claims, rotated)
+	r.NoError(err)
+	_, err = v.Verify(tok)
+	r.NoError(err)
+	r.Equal(2, jwks.fetches)
+	tok, err = signIdentityToken(jwtgo.SigningMethodRS256, "rsa-1", claims, rsaKey)
+	r.NoError(err)
+	_, err = v.Verify(tok)
+	r.Error(err)
+}
+
+func TestJWKSRefresh(t *testing.T) {
+	r := require.New(t)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	r.NoError(err)
+	jwks := &testJWKSServer{delay: 200 * time.Millisecond}
+	jwks.set(rsaJWK("rsa-1", rsaKey))
+	srv := httptest.NewServer(jwks)
+	defer srv.Close()
+
+	// concurrent lookups wait for the one fetch in flight
+	j := NewJWKS(srv.URL, time.Hour)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := j.Key("rsa-1")
+			r.NoError(err)
+		}()
+	}
+	wg.Wait()
+	r.Equal(1, jwks.fetches)
+
+	// cached keys are looked up while unknown key ID is fetched
+	j.minRefresh = 0
+	done := make(chan error)
+	go func() {
+		_, err := j.Key("rsa-2")
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	start := time.Now()
+	_, err = j.Key("rsa-1")
+	r.NoError(err)
+	r.True(time.Since(start) < 100*time.Millisecond)
+	r.Equal(ErrIdentity, errors.Cause(<-done))
+	r.Equal(2, jwks.fetches)
+}
+
+func TestIdentities(t *testing.T) {
+	r := require.New(t)
+
+	testFile, err := ioutil.TempFile(os.TempDir(), "test-bolt")
+	path := testFile.Name()
+	r.NoError(err)
+	testFile.Close()
+	defer func() {
+		r.NoError(os.Remove(path))
+	}()
+
+	d := db.NewBoltDB(path)
+	ctx := context.Background()
+	r.NoError(d.Start(ctx))
+	defer func() {
+		r.NoError(d.Stop(ctx))
+	}()
+
+	ids := NewIdentities(d)
+	ns, err := OwnerNamespace("io1mflp9m6hcgm2qcghchsdqj3z3eccrnekx9p0ms")
+	r.NoError(err)
+	g := &IdentityGrant{
+		Issuer:    "https://idp.example.com",
+		Identity:  "alice@example.com",
+		Subject:   "s3/reports",
+		Scope:     jwt.READ,
+		ExpiresAt: time.Now().Add(time.Minute).Unix(),
+	}
+	r.NoError(ids.PutIdentity(ns, g))
+	r.Equal(IdentityID(g.Issuer, g.Identity), g.ID)
+
+	g, err = ids.GetIdentity(ns, g.ID)
+	r.NoError(err)
+	id := &Identity{Issuer: g.Issuer, Subject: g.Identity, ExpiresAt: time.Now().Add(time.Hour).Unix()}
+	c, err := g.Claims(ns, "token", id)
+	r.NoError(err)
+	r.Equal(ns, c.Namespace)
+	r.Equal("s3", c.Store())
+	r.True(c.Allow(jwt.READ))
+	r.False(c.Allow(jwt.CREATE))
+	r.Equal(g.ExpiresAt, c.ExpiresAt)
+
+	// other owner has no grant
+	_, err = ids.GetIdentity("other", g.ID)
+	r.Error(err)
+
+	g.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+	_, err = g.Claims(ns, "token", id)
+	r.Equal(ErrNoGrant, errors.Cause(err))
+
+	r.NoError(ids.DelIdentity(ns, g.ID))
+	_, err = ids.GetIdentity(ns, g.ID)
+	r.Equal(db.ErrNotExist, errors.Cause(err))
+}
@@ -0,0 +1,335 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/iotexproject/phoenix/db"
+	"github.com/iotexproject/phoenix/json"
+)
+
+const (
+	ownershipNamespace = "ownership"
+	actionNamespace    = "action"
+	// actionOwnerNamespace indexes actions by the namespaces owning their store, keyed <namespace>/<action ID>
+	actionOwnerNamespace = "actionOwner"
+
+	// ActionTTL is how long a destructive action waits for approvals of co-owners
+	ActionTTL = 7 * 24 * time.Hour
+)
+
+// destructive operations of a co-owned store
+const (
+	ActionDeleteBucket    = "DeleteBucket"
+	ActionDeleteObject    = "DeleteObject"
+	ActionUnregisterStore = "UnregisterStore"
+	ActionPurgeTrash      = "PurgeTrash"
+)
+
+// states of action
+const (
+	ActionPending  = "pending"
+	ActionExecuted = "executed"
+	ActionFailed   = "failed"
+)
+
+var (
+	// ErrOwnership is returned if the owners or threshold of a co-owned store are invalid
+	ErrOwnership = errors.New("invalid ownership")
+
+	// ErrActionNotFound is returned if the action doesn't exist
+	ErrActionNotFound = errors.New("action not found")
+
+	// ErrActionClosed is returned if the action is expired, or executed already
+	ErrActionClosed = errors.New("action is not pending")
+
+	// ErrNotCoOwner is returned if the approval is not signed by an owner of the store
+	ErrNotCoOwner = errors.New("not an owner of the store")
+)
+
+type (
+	// Ownership lists the owners of a co-owned store, destructive operations on the store are only executed once
+	// Threshold of them approve
+	Ownership struct {
+		Store string `json:"store"`
+		// Owners are the namespaces of co-owners' addresses
+		Owners    []string `json:"owners"`
+		Threshold int      `json:"threshold"`
+	}
+
+	// Action is a destructive operation on a co-owned store pending for approvals of the owners
+	Action struct {
+		ID string `json:"id"`
+		// Namespace is the namespace the store is registered in
+		Namespace   string   `json:"namespace"`
+		Op          string   `json:"op"`
+		Store       string   `json:"store"`
+		Bucket      string   `json:"bucket,omitempty"`
+		Path        string   `json:"path,omitempty"`
+		RequestedBy string   `json:"requestedBy"`
+		Owners      []string `json:"owners"`
+		Threshold   int      `json:"threshold"`
+		// Approvals are the signatures of owners who approved, keyed by owner's namespace
+		Approvals map[string]string `json:"approvals"`
+		State     string            `json:"state"`
+		Error     string            `json:"error,omitempty"`
+		CreatedAt int64             `json:"createdAt"`
+		ExpiresAt int64             `json:"expiresAt"`
+	}
+
+	Ownerships interface {
+		// GetOwnership returns the ownership of owner's store, nil if the store is not co-owned
+		GetOwnership(string, string) (*Ownership, error)
+
+		// PutOwnership validates the ownership of owner's store and puts it into db
+		PutOwnership(string, *Ownership) error
+
+		// DelOwnership deletes the ownership of owner's store
+		DelOwnership(string, string) error
+
+		// Propose creates the pending action of the store's ownership
+		Propose(*Ownership, *Action) error
+
+		// GetAction returns the action according to action ID
+		GetAction(string) (*Action, error)
+
+		// ListActions returns the actions of stores the namespace owns or co-owns
+		ListActions(string) ([]*Action, error)
+
+		// Approve records the approval signed by a co-owner, and returns the action approved
+		Approve(id, owner string, sig []byte) (*Action, error)
+
+		// Close marks the approved action executed, or failed with the error
+		Close(*Action, error) error
+	}
+
+	ownerships struct {
+		db.KVStore
+	}
+)
+
+// Validate checks there are owners and the threshold is within 1 and the number of owners
+func (o *Ownership) Validate() error {
+	if o.Threshold < 1 || o.Threshold > len(o.Owners) {
+		return errors.Wrapf(ErrOwnership, "threshold must be within 1 and %d", len(o.Owners))
+	}
+	seen := make(map[string]bool, len(o.Owners))
+	for _, owner := range o.Owners {
+		if seen[owner] {
+			return errors.Wrapf(ErrOwnership, "duplicate owner %s", owner)
+		}
+		seen[owner] = true
+	}
+	return nil
+}
+
+// Equal returns true if both ownerships have the same owners and threshold
+func (o *Ownership) Equal(other *Ownership) bool {
+	if other == nil || o.Threshold != other.Threshold || len(o.Owners) != len(other.Owners) {
+		return false
+	}
+	owners := make(map[string]bool, len(o.Owners))
+	for _, owner := range o.Owners {
+		owners[owner] = true
+	}
+	for _, owner := range other.Owners {
+		if !owners[owner] {
+			return false
+		}
+	}
+	return true
+}
+
+// Message returns the message co-owners sign with personal_sign to approve the action
+func (a *Action) Message() string {
+	target := a.Store
+	if a.Bucket != "" {
+		target += "/" + a.Bucket
+	}
+	if a.Path != "" {
+		target += "/" + a.Path
+	}
+	return fmt.Sprintf("phoenix wants you to approve %s of %s\n\nAction: %s\nExpires: %s",
+		a.Op, target, a.ID, time.Unix(a.ExpiresAt, 0).UTC().Format(time.RFC3339))
+}
+
+// Approved returns true if enough owners approved the action
+func (a *Action) Approved() bool {
+	return len(a.Approvals) >= a.Threshold
+}
+
+// owners returns the namespace the store is registered in and the co-owners, once each
+func (a *Action) owners() []string {
+	owners := []string{a.Namespace}
+	for _, owner := range a.Owners {
+		if owner != a.Namespace {
+			owners = append(owners, owner)
+		}
+	}
+	return owners
+}
+
+func NewOwnerships(kv db.KVStore) Ownerships {
+	return &ownerships{
+		KVStore: kv,
+	}
+}
+
+func ownershipKey(namespace, store string) []byte {
+	return []byte(namespace + "/" + store)
+}
+
+func (o *ownerships) GetOwnership(namespace, store string) (*Ownership, error) {
+	v, err := o.Get(ownershipNamespace, ownershipKey(namespace, store))
+	switch errors.Cause(err) {
+	case nil:
+		break
+	case db.ErrBucketNotExist, db.ErrNotExist:
+		return nil, nil
+	default:
+		return nil, err
+	}
+	ownership := &Ownership{}
+	if err := json.Unmarshal(v, ownership); err != nil {
+		return nil, err
+	}
+	return ownership, nil
+}
+
+func (o *ownerships) PutOwnership(namespace string, ownership *Ownership) error {
+	if err := ownership.Validate(); err != nil {
+		return err
+	}
+	v, err := json.Marshal(ownership)
+	if err != nil {
+		return err
+	}
+	return o.Put(ownershipNamespace, ownershipKey(namespace, ownership.Store), v)
+}
+
+func (o *ownerships) DelOwnership(namespace, store string) error {
+	return o.Delete(ownershipNamespace, ownershipKey(namespace, store))
+}
+
+func (o *ownerships) Propose(ownership *Ownership, a *Action) error {
+	id, err := randomHex(16)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	a.ID, a.Store, a.Owners, a.Threshold = id, ownership.Store, ownership.Owners, ownership.Threshold
+	a.Approvals, a.State, a.Error = map[string]string{}, ActionPending, ""
+	a.CreatedAt, a.ExpiresAt = now.Unix(), now.Add(ActionTTL).Unix()
+	v, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	// the action is listed by each owner from the index, without scanning the actions of all stores
+	return o.Batch(func(tx db.Tx) error {
+		if err := tx.Put(actionNamespace, []byte(a.ID), v); err != nil {
+			return err
+		}
+		for _, owner := range a.owners() {
+			if err := tx.Put(actionOwnerNamespace, []byte(owner+"/"+a.ID), []byte(a.ID)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (o *ownerships) GetAction(id string) (*Action, error) {
+	v, err := o.Get(actionNamespace, []byte(id))
+	switch errors.Cause(err) {
+	case nil:
+		break
+	case db.ErrBucketNotExist, db.ErrNotExist:
+		return nil, errors.Wrapf(ErrActionNotFound, "action %s", id)
+	default:
+		return nil, err
+	}
+	a := &Action{}
+	if err := json.Unmarshal(v, a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (o *ownerships) ListActions(namespace string) ([]*Action, error) {
+	_, ids, err := o.List(actionOwnerNamespace, []byte(namespace+"/"))
+	if err != nil {
+		return nil, err
+	}
+	list := []*Action{}
+	for _, id := range ids {
+		a, err := o.GetAction(string(id))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, a)
+	}
+	return list, nil
+}
+
+func (o *ownerships) Approve(id, owner string, sig []byte) (*Action, error) {
+	addr, err := ParseAddress(owner)
+	if err != nil {
+		return nil, err
+	}
+	namespace := addr.Hex()[2:]
+	a := &Action{}
+	err = o.Update(actionNamespace, []byte(id), func(v []byte) ([]byte, error) {
+		if v == nil {
+			return nil, errors.Wrapf(ErrActionNotFound, "action %s", id)
+		}
+		if err := json.Unmarshal(v, a); err != nil {
+			return nil, err
+		}
+		if a.State != ActionPending || a.Approved() {
+			return nil, errors.Wrapf(ErrActionClosed, "action is %s", a.State)
+		}
+		if time.Now().Unix() > a.ExpiresAt {
+			return nil, errors.Wrap(ErrActionClosed, "action expired")
+		}
+		co := false
+		for _, ns := range a.Owners {
+			co = co || ns == namespace
+		}
+		if !co {
+			return nil, ErrNotCoOwner
+		}
+		if _, err := RecoverSigner(PersonalMessageHash(a.Message()), sig, addr); err != nil {
+			return nil, err
+		}
+		a.Approvals[namespace] = fmt.Sprintf("%x", sig)
+		return json.Marshal(a)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (o *ownerships) Close(a *Action, err error) error {
+	a.State = ActionExecuted
+	if err != nil {
+		a.State, a.Error = ActionFailed, err.Error()
+	}
+	return o.putAction(a)
+}
+
+func (o *ownerships) putAction(a *Action) error {
+	v, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	return o.Put(actionNamespace, []byte(a.ID), v)
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/iotexproject/go-pkgs/crypto"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iotexproject/phoenix/db"
+)
+
+func TestOwnerships(t *testing.T) {
+	r := require.New(t)
+
+	testFile, err := ioutil.TempFile(os.TempDir(), "test-bolt")
+	path := testFile.Name()
+	r.NoError(err)
+	testFile.Close()
+	defer func() {
+		r.NoError(os.Remove(path))
+	}()
+
+	d := db.NewBoltDB(path)
+	ctx := context.Background()
+	r.NoError(d.Start(ctx))
+	defer func() {
+		r.NoError(d.Stop(ctx))
+	}()
+
+	var keys []crypto.PrivateKey
+	var owners []string
+	for i := 0; i < 3; i++ {
+		key, err := crypto.GenerateKey()
+		r.NoError(err)
+		keys = append(keys, key)
+		owners = append(owners, key.PublicKey().Address().Hex()[2:])
+	}
+	namespace := owners[0]
+	o := NewOwnerships(d)
+
+	ownership, err := o.GetOwnership(namespace, "s3")
+	r.NoError(err)
+	r.Nil(ownership)
+	r.Equal(ErrOwnership, errors.Cause(o.PutOwnership(namespace, &Ownership{Store: "s3", Owners: owners, Threshold: 4})))
+	r.Equal(ErrOwnership, errors.Cause(o.PutOwnership(namespace, &Ownership{Store: "s3", Owners: []string{owners[0], owners[0]}, Threshold: 1})))
+	r.NoError(o.PutOwnership(namespace, &Ownership{Store: "s3", Owners: owners, Threshold: 2}))
+	ownership, err = o.GetOwnership(namespace, "s3")
+	r.NoError(err)
+	r.True(ownership.Equal(&Ownership{Store: "s3", Owners: []string{owners[2], owners[1], owners[0]}, Threshold: 2}))
+	r.False(ownership.Equal(&Ownership{Store: "s3", Owners: owners, Threshold: 1}))
+
+	a := &Action{Namespace: namespace, Op: ActionDeleteObject, Bucket: "shared", Path: "data.csv"}
+	r.NoError(o.Propose(ownership, a))
+	r.Contains(a.Message(), "DeleteObject of s3/shared/data.csv")
+	approve := func(key crypto.PrivateKey, msg string) (*Action, error) {
+		sig, err := key.Sign(PersonalMessageHash(msg))
+		r.NoError(err)
+		return o.Approve(a.ID, key.PublicKey().Address().String(), sig)
+	}
+
+	// approval must be signed by an owner, of the action's message
+	stranger, err := crypto.GenerateKey()
+	r.NoError(err)
+	_, err = approve(stranger, a.Message())
+	r.Equal(ErrNotCoOwner, errors.Cause(err))
+	_, err = approve(keys[1], "other message")
+	r.Equal(ErrChallenge, errors.Cause(err))
+
+	approved, err := approve(keys[1], a.Message())
+	r.NoError(err)
+	r.False(approved.Approved())
+	approved, err = approve(keys[1], a.Message())
+	r.NoError(err)
+	r.False(approved.Approved())
+	approved, err = approve(keys[2], a.Message())
+	r.NoError(err)
+	r.True(approved.Approved())
+	_, err = approve(keys[0], a.Message())
+	r.Equal(ErrActionClosed, errors.Cause(err))
+
+	r.NoError(o.Close(approved, errors.New("bucket not found")))
+	got, err := o.GetAction(a.ID)
+	r.NoError(err)
+	r.Equal(ActionFailed, got.State)
+	r.Len(got.Approvals, 2)
+
+	for _, ns := range owners {
+		list, err := o.ListActions(ns)
+		r.NoError(err)
+		r.Len(list, 1)
+		r.Equal(ActionFailed, list[0].State)
+	}
+	list, err := o.ListActions("other")
+	r.NoError(err)
+	r.Empty(list)
+	_, err = o.GetAction("unknown")
+	r.Equal(ErrActionNotFound, errors.Cause(err))
+
+	r.NoError(o.DelOwnership(namespace, "s3"))
+	ownership, err = o.GetOwnership(namespace, "s3")
+	r.NoError(err)
+	r.Nil(ownership)
+}
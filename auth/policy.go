@@ -0,0 +1,263 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/interpreter/functions"
+	"github.com/pkg/errors"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+
+	"github.com/iotexproject/phoenix/db"
+	"github.com/iotexproject/phoenix/json"
+)
+
+const policyNamespace = "policy"
+
+var (
+	// ErrPolicy is returned if a policy expression doesn't compile to a bool
+	ErrPolicy = errors.New("invalid policy")
+
+	// ErrPolicyDenied is returned if the request is denied by a policy of the store or bucket
+	ErrPolicyDenied = errors.New("denied by policy")
+
+	policyEnv *cel.Env
+)
+
+func init() {
+	var err error
+	policyEnv, err = cel.NewEnv(cel.Declarations(
+		decls.NewVar("claims", decls.NewMapType(decls.String, decls.Dyn)),
+		decls.NewVar("request", decls.NewMapType(decls.String, decls.Dyn)),
+		decls.NewVar("object", decls.NewMapType(decls.String, decls.Dyn)),
+		decls.NewFunction("inCIDR",
+			decls.NewOverload("inCIDR_string_string", []*exprpb.Type{decls.String, decls.String}, decls.Bool)),
+	))
+	if err != nil {
+		panic(err)
+	}
+}
+
+type (
+	// Policy is a CEL expression an owner attaches to a registered store, or a bucket in it. A request is
+	// allowed only if the policies of both its store and bucket evaluate to true
+	Policy struct {
+		Store      string `json:"store"`
+		Bucket     string `json:"bucket,omitempty"`
+		Expression string `json:"expression"`
+	}
+
+	// PolicyInput is the request a policy is evaluated against
+	PolicyInput struct {
+		Claims *Claims
+		Method string
+		Op     string
+		Bucket string
+		Path   string
+		IP     string
+		Time   time.Time
+		// Size and ContentType are the uploaded object's, unknown for other operations
+		Size        int64
+		ContentType string
+	}
+
+	Policies interface {
+		// GetPolicy returns owner's policy according to store and bucket
+		GetPolicy(string, string, string) (*Policy, error)
+
+		// PutPolicy validates owner's policy and puts it into db
+		PutPolicy(string, *Policy) error
+
+		// DelPolicy deletes owner's policy from db
+		DelPolicy(string, string, string) error
+
+		// Evaluate returns ErrPolicyDenied if a policy of the store or bucket denies the request
+		Evaluate(string, *PolicyInput) error
+	}
+
+	policies struct {
+		db.KVStore
+		mu       sync.RWMutex
+		programs map[string]cel.Program
+	}
+)
+
+// CompilePolicy compiles the expression, which must evaluate to a bool. The expression can use the maps claims
+// (iss, sub, scope, store, resource, namespace, iat, exp), request (method, op, bucket, path, ip, time) and
+// object (size, contentType), and inCIDR(ip, cidr) which tells if the ip is within the cidr
+func CompilePolicy(expression string) (cel.Program, error) {
+	ast, iss := policyEnv.Compile(expression)
+	if iss.Err() != nil {
+		return nil, errors.Wrap(ErrPolicy, iss.Err().Error())
+	}
+	if !proto.Equal(ast.ResultType(), decls.Bool) && !proto.Equal(ast.ResultType(), decls.Dyn) {
+		return nil, errors.Wrapf(ErrPolicy, "expression evaluates to %v instead of bool", ast.ResultType())
+	}
+	return policyEnv.Program(ast, cel.Functions(&functions.Overload{
+		Operator: "inCIDR",
+		Binary:   inCIDR,
+	}))
+}
+
+func inCIDR(ip, cidr ref.Val) ref.Val {
+	addr, ok := ip.Value().(string)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(ip)
+	}
+	block, ok := cidr.Value().(string)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(cidr)
+	}
+	_, network, err := net.ParseCIDR(block)
+	if err != nil {
+		return types.NewErr(err.Error())
+	}
+	return types.Bool(network.Contains(net.ParseIP(addr)))
+}
+
+// EvaluatePolicy evaluates the compiled policy against the request
+func EvaluatePolicy(prg cel.Program, in *PolicyInput) (bool, error) {
+	out, _, err := prg.Eval(in.activation())
+	if err != nil {
+		return false, errors.Wrap(ErrPolicy, err.Error())
+	}
+	allowed, ok := out.Value().(bool)
+	if !ok {
+		return false, errors.Wrapf(ErrPolicy, "expression evaluates to %v instead of bool", out.Value())
+	}
+	return allowed, nil
+}
+
+func (in *PolicyInput) activation() map[string]interface{} {
+	claims := map[string]interface{}{}
+	if c := in.Claims; c != nil {
+		claims = map[string]interface{}{
+			"iss":       c.Issuer,
+			"sub":       c.Subject,
+			"scope":     c.Scope,
+			"store":     c.Store(),
+			"resource":  c.Resource(),
+			"namespace": c.Root().Namespace,
+			"iat":       c.IssuedAt,
+			"exp":       c.ExpiresAt,
+		}
+	}
+	return map[string]interface{}{
+		"claims": claims,
+		"request": map[string]interface{}{
+			"method": in.Method,
+			"op":     in.Op,
+			"bucket": in.Bucket,
+			"path":   in.Path,
+			"ip":     in.IP,
+			"time":   in.Time,
+		},
+		"object": map[string]interface{}{
+			"size":        in.Size,
+			"contentType": in.ContentType,
+		},
+	}
+}
+
+func NewPolicies(kv db.KVStore) Policies {
+	return &policies{
+		KVStore:  kv,
+		programs: make(map[string]cel.Program),
+	}
+}
+
+func policyKey(namespace, store, bucket string) []byte {
+	return []byte(strings.TrimSuffix(namespace+"/"+store+"/"+bucket, "/"))
+}
+
+func (p *policies) GetPolicy(namespace, store, bucket string) (*Policy, error) {
+	v, err := p.Get(policyNamespace, policyKey(namespace, store, bucket))
+	if err != nil {
+		return nil, err
+	}
+	policy := &Policy{}
+	if err := json.Unmarshal(v, policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+func (p *policies) PutPolicy(namespace string, policy *Policy) error {
+	if policy.Store == "" || strings.Contains(policy.Store, "/") || strings.Contains(policy.Bucket, "/") {
+		return errors.Wrap(ErrPolicy, "store is required, and store and bucket can't contain /")
+	}
+	if _, err := p.program(policy.Expression); err != nil {
+		return err
+	}
+	v, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+	return p.Put(policyNamespace, policyKey(namespace, policy.Store, policy.Bucket), v)
+}
+
+func (p *policies) DelPolicy(namespace, store, bucket string) error {
+	return p.Delete(policyNamespace, policyKey(namespace, store, bucket))
+}
+
+func (p *policies) Evaluate(namespace string, in *PolicyInput) error {
+	store := in.Claims.Store()
+	buckets := []string{""}
+	if in.Bucket != "" {
+		buckets = append(buckets, in.Bucket)
+	}
+	for _, bucket := range buckets {
+		policy, err := p.GetPolicy(namespace, store, bucket)
+		switch errors.Cause(err) {
+		case nil:
+			break
+		case db.ErrBucketNotExist, db.ErrNotExist:
+			continue
+		default:
+			return err
+		}
+		prg, err := p.program(policy.Expression)
+		if err != nil {
+			return err
+		}
+		allowed, err := EvaluatePolicy(prg, in)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return errors.Wrapf(ErrPolicyDenied, "policy of %s", strings.Trim(store+"/"+bucket, "/"))
+		}
+	}
+	return nil
+}
+
+// program returns the compiled expression, which is cached
+func (p *policies) program(expression string) (cel.Program, error) {
+	p.mu.RLock()
+	prg, ok := p.programs[expression]
+	p.mu.RUnlock()
+	if ok {
+		return prg, nil
+	}
+	prg, err := CompilePolicy(expression)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	p.programs[expression] = prg
+	p.mu.Unlock()
+	return prg, nil
+}
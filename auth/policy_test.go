@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/iotexproject/iotex-antenna-go/v2/jwt"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iotexproject/phoenix/db"
+)
+
+func TestPolicyHarness(t *testing.T) {
+	claims := &Claims{
+		JWT:       &jwt.JWT{Issuer: "0x04", Subject: "s3/reports", Scope: jwt.READ + "," + jwt.UPDATE},
+		Namespace: "owner",
+	}
+	// Tuesday 10:30 in Berlin
+	businessHours := time.Date(2020, 11, 17, 9, 30, 0, 0, time.UTC)
+	night := time.Date(2020, 11, 17, 23, 30, 0, 0, time.UTC)
+	request := func(op, path, ip string, now time.Time, size int64) *PolicyInput {
+		return &PolicyInput{Claims: claims, Op: op, Bucket: "reports", Path: path, IP: ip, Time: now, Size: size}
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		input      *PolicyInput
+		allowed    bool
+	}{
+		{"public path", `request.path.startsWith("public/")`, request(jwt.READ, "public/a.csv", "", night, 0), true},
+		{"private path", `request.path.startsWith("public/")`, request(jwt.READ, "private/a.csv", "", night, 0), false},
+		{
+			"business hours", `request.time.getHours("Europe/Berlin") >= 9 && request.time.getHours("Europe/Berlin") < 17`,
+			request(jwt.READ, "a.csv", "", businessHours, 0), true,
+		},
+		{
+			"after hours", `request.time.getHours("Europe/Berlin") >= 9 && request.time.getHours("Europe/Berlin") < 17`,
+			request(jwt.READ, "a.csv", "", night, 0), false,
+		},
+		{"cidr", `inCIDR(request.ip, "10.0.0.0/8")`, request(jwt.READ, "a.csv", "10.1.2.3", night, 0), true},
+		{"outside cidr", `inCIDR(request.ip, "10.0.0.0/8")`, request(jwt.READ, "a.csv", "192.168.1.1", night, 0), false},
+		{"max size", `request.op != "Update" || object.size <= 10 * 1024 * 1024`, request(jwt.UPDATE, "a.csv", "", night, 1024), true},
+		{"too large", `request.op != "Update" || object.size <= 10 * 1024 * 1024`, request(jwt.UPDATE, "a.csv", "", night, 11*1024*1024), false},
+		{"claims", `claims.store == "s3" && claims.resource == "reports" && claims.namespace == "owner"`, request(jwt.READ, "a.csv", "", night, 0), true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := require.New(t)
+			prg, err := CompilePolicy(test.expression)
+			r.NoError(err)
+			allowed, err := EvaluatePolicy(prg, test.input)
+			r.NoError(err)
+			r.Equal(test.allowed, allowed)
+		})
+	}
+
+	for _, expression := range []string{`request.path +`, `request.size + 1`, `unknown == 1`, `"string"`} {
+		_, err := CompilePolicy(expression)
+		require.Equal(t, ErrPolicy, errors.Cause(err), expression)
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	r := require.New(t)
+
+	testFile, err := ioutil.TempFile(os.TempDir(), "test-bolt")
+	path := testFile.Name()
+	r.NoError(err)
+	testFile.Close()
+	defer func() {
+		r.NoError(os.Remove(path))
+	}()
+
+	d := db.NewBoltDB(path)
+	ctx := context.Background()
+	r.NoError(d.Start(ctx))
+	defer func() {
+		r.NoError(d.Stop(ctx))
+	}()
+
+	p := NewPolicies(d)
+	claims := &Claims{JWT: &jwt.JWT{Subject: "s3", Scope: jwt.READ}, Namespace: "owner"}
+	in := &PolicyInput{Claims: claims, Op: jwt.READ, Bucket: "reports", Path: "public/a.csv", Time: time.Now()}
+
+	// no policy
+	r.NoError(p.Evaluate("owner", in))
+
+	r.Equal(ErrPolicy, errors.Cause(p.PutPolicy("owner", &Policy{Store: "s3", Expression: `request.op ==`})))
+	r.NoError(p.PutPolicy("owner", &Policy{Store: "s3", Expression: `request.op == "Read"`}))
+	r.NoError(p.PutPolicy("owner", &Policy{Store: "s3", Bucket: "reports", Expression: `request.path.startsWith("public/")`}))
+	r.NoError(p.Evaluate("owner", in))
+
+	in.Path = "private/a.csv"
+	r.Equal(ErrPolicyDenied, errors.Cause(p.Evaluate("owner", in)))
+	in.Bucket, in.Op = "other", jwt.DELETE
+	r.Equal(ErrPolicyDenied, errors.Cause(p.Evaluate("owner", in)))
+	r.NoError(p.Evaluate("other owner", in))
+
+	r.NoError(p.DelPolicy("owner", "s3", ""))
+	r.NoError(p.Evaluate("owner", in))
+	policy, err := p.GetPolicy("owner", "s3", "reports")
+	r.NoError(err)
+	r.Equal("reports", policy.Bucket)
+}
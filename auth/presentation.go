@@ -0,0 +1,347 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"encoding/hex"
+	"strings"
+	"time"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/iotexproject/iotex-antenna-go/v2/jwt"
+	"github.com/pkg/errors"
+
+	"github.com/iotexproject/phoenix/json"
+)
+
+// types of W3C verifiable credential and presentation
+const (
+	VerifiableCredential   = "VerifiableCredential"
+	VerifiablePresentation = "VerifiablePresentation"
+)
+
+// MaxPresentationTTL is the longest a presentation can be valid, it is presented as bearer token so a presentation
+// leaked is only replayable shortly
+const MaxPresentationTTL = time.Hour
+
+// ErrCredential is returned if a verifiable credential or presentation is invalid
+var ErrCredential = errors.New("invalid verifiable credential")
+
+type (
+	// CredentialSubject is the access the credential grants to its holder
+	CredentialSubject struct {
+		// ID is the DID of the holder
+		ID string `json:"id"`
+		// Subject is the store and resource the holder can access, same as the subject of JWT
+		Subject string `json:"subject"`
+		// Scope is the operations the holder can do, same as the scope of JWT
+		Scope string `json:"scope"`
+	}
+
+	credentialClaims struct {
+		jwtgo.StandardClaims
+		VC struct {
+			Type              []string          `json:"type"`
+			CredentialSubject CredentialSubject `json:"credentialSubject"`
+		} `json:"vc"`
+	}
+
+	presentationClaims struct {
+		jwtgo.StandardClaims
+		VP struct {
+			Type                 []string `json:"type"`
+			VerifiableCredential []string `json:"verifiableCredential"`
+		} `json:"vp"`
+	}
+
+	// PresentationVerifier verifies JWT encoded verifiable credentials and presentations,
+	// the DIDs of owner and holder are resolved by the DID resolver
+	PresentationVerifier struct {
+		resolver DIDResolver
+		audience string
+	}
+)
+
+// NewPresentationVerifier creates a verifier using the DID resolver, which accepts presentations made for the
+// audience only
+func NewPresentationVerifier(resolver DIDResolver, audience string) *PresentationVerifier {
+	return &PresentationVerifier{
+		resolver: resolver,
+		audience: audience,
+	}
+}
+
+// IsPresentation returns true if the JWT carries a verifiable presentation
+func IsPresentation(jwtString string) bool {
+	return hasClaim(jwtString, "vp")
+}
+
+// IsCredential returns true if the JWT carries a verifiable credential
+func IsCredential(jwtString string) bool {
+	return hasClaim(jwtString, "vc")
+}
+
+// hasClaim returns true if the payload of the JWT has the claim
+func hasClaim(jwtString, name string) bool {
+	parts := strings.Split(jwtString, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	b, err := jwtgo.DecodeSegment(parts[1])
+	if err != nil {
+		return false
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(b, &payload); err != nil {
+		return false
+	}
+	_, ok := payload[name]
+	return ok
+}
+
+// Verify verifies the presentation signed by the holder and the credential in it signed by the owner,
+// the claims returned are the presentation's, whose parent is the credential's. The presentation must be made
+// for the audience of the verifier, and expire within MaxPresentationTTL
+func (v *PresentationVerifier) Verify(vpString string) (*Claims, error) {
+	claim := &presentationClaims{}
+	sig, err := v.parse(vpString, claim)
+	if err != nil {
+		return nil, err
+	}
+	if !hasType(claim.VP.Type, VerifiablePresentation) {
+		return nil, errors.Wrap(ErrCredential, "not a verifiable presentation")
+	}
+	if !claim.VerifyAudience(v.audience, true) {
+		return nil, errors.Wrapf(ErrCredential, "presentation is not made for %s", v.audience)
+	}
+	if claim.ExpiresAt == 0 {
+		return nil, errors.Wrap(ErrCredential, "presentation must expire")
+	}
+	if time.Until(time.Unix(claim.ExpiresAt, 0)) > MaxPresentationTTL {
+		return nil, errors.Wrapf(ErrCredential, "presentation can't be valid for more than %s", MaxPresentationTTL)
+	}
+	if len(claim.VP.VerifiableCredential) != 1 {
+		return nil, errors.Wrap(ErrCredential, "presentation must carry exactly one credential")
+	}
+	vc, err := v.verifyCredential(claim.VP.VerifiableCredential[0])
+	if err != nil {
+		return nil, err
+	}
+	if holder := didOf(claim.Issuer); holder != didOf(vc.holder) {
+		return nil, errors.Wrapf(ErrCredential, "presentation is not signed by the holder %s", vc.holder)
+	}
+	expire := vc.ExpiresAt
+	if claim.ExpiresAt != 0 && (expire == 0 || claim.ExpiresAt < expire) {
+		expire = claim.ExpiresAt
+	}
+	return &Claims{
+		JWT: &jwt.JWT{
+			IssuedAt:   claim.IssuedAt,
+			ExpiresAt:  expire,
+			Issuer:     claim.Issuer,
+			Subject:    vc.Subject,
+			Scope:      vc.Scope,
+			SignMethod: sig.alg,
+			SigHex:     sig.hex,
+		},
+		Token:     vpString,
+		Namespace: vc.Namespace,
+		Parent:    vc.Claims,
+	}, nil
+}
+
+type (
+	verifiedCredential struct {
+		*Claims
+		holder string
+	}
+
+	signature struct {
+		alg string
+		hex string
+	}
+
+	issuerClaims interface {
+		jwtgo.Claims
+		issuer() string
+	}
+)
+
+// VerifyCredential verifies the credential signed by the owner, and maps it into claims
+func (v *PresentationVerifier) VerifyCredential(vcString string) (*Claims, error) {
+	vc, err := v.verifyCredential(vcString)
+	if err != nil {
+		return nil, err
+	}
+	return vc.Claims, nil
+}
+
+func (v *PresentationVerifier) verifyCredential(vcString string) (*verifiedCredential, error) {
+	claim := &credentialClaims{}
+	sig, err := v.parse(vcString, claim)
+	if err != nil {
+		return nil, err
+	}
+	if !hasType(claim.VC.Type, VerifiableCredential) {
+		return nil, errors.Wrap(ErrCredential, "not a verifiable credential")
+	}
+	subject := claim.VC.CredentialSubject
+	if subject.ID == "" || (claim.Subject != "" && claim.Subject != subject.ID) {
+		return nil, errors.Wrap(ErrCredential, "holder of credential mismatches")
+	}
+	ns, err := didNamespace(claim.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	return &verifiedCredential{
+		Claims: &Claims{
+			JWT: &jwt.JWT{
+				IssuedAt:   claim.IssuedAt,
+				ExpiresAt:  claim.ExpiresAt,
+				Issuer:     claim.Issuer,
+				Subject:    subject.Subject,
+				Scope:      subject.Scope,
+				SignMethod: sig.alg,
+				SigHex:     sig.hex,
+			},
+			Token:     vcString,
+			Namespace: ns,
+		},
+		holder: subject.ID,
+	}, nil
+}
+
+// parse verifies the JWT signed by a verification method of the issuer's DID document
+func (v *PresentationVerifier) parse(jwtString string, claim issuerClaims) (*signature, error) {
+	token, err := jwtgo.ParseWithClaims(jwtString, claim, func(token *jwtgo.Token) (interface{}, error) {
+		iss := claim.issuer()
+		if !strings.HasPrefix(iss, "did:") {
+			return nil, errors.Wrapf(ErrCredential, "issuer %s is not a DID", iss)
+		}
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" && strings.Contains(iss, "#") {
+			kid = iss[strings.Index(iss, "#"):]
+		}
+		if strings.HasPrefix(kid, "#") {
+			kid = didOf(iss) + kid
+		}
+		if kid != "" && didOf(kid) != didOf(iss) {
+			return nil, errors.Wrapf(ErrCredential, "key %s is not controlled by %s", kid, iss)
+		}
+		url := kid
+		if url == "" {
+			url = iss
+		}
+		doc, err := v.resolver.Resolve(url)
+		if err != nil {
+			return nil, err
+		}
+		m, err := doc.Method(kid)
+		if err != nil {
+			return nil, err
+		}
+		key, err := m.PublicKeyOf()
+		if err != nil {
+			return nil, err
+		}
+		if m := signingMethodFor(key); m == nil || m.Alg() != token.Method.Alg() {
+			return nil, errors.Wrapf(ErrCredential, "alg %s doesn't match verification method", token.Method.Alg())
+		}
+		return key, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	sig, err := jwtgo.DecodeSegment(token.Signature)
+	if err != nil {
+		return nil, err
+	}
+	return &signature{alg: token.Method.Alg(), hex: hex.EncodeToString(sig)}, nil
+}
+
+func (c *credentialClaims) issuer() string {
+	return c.Issuer
+}
+
+func (c *presentationClaims) issuer() string {
+	return c.Issuer
+}
+
+// SignCredential creates a JWT encoded credential, granting the holder DID the scope on the subject
+func SignCredential(iss, kid, holder string, issue, expire int64, subject, scope string, key interface{}) (string, error) {
+	c := &credentialClaims{
+		StandardClaims: jwtgo.StandardClaims{
+			ExpiresAt: expire,
+			IssuedAt:  issue,
+			Issuer:    iss,
+			Subject:   holder,
+		},
+	}
+	c.VC.Type = []string{VerifiableCredential}
+	c.VC.CredentialSubject = CredentialSubject{ID: holder, Subject: subject, Scope: scope}
+	return signJWT(kid, c, key)
+}
+
+// SignPresentation creates a JWT encoded presentation of the credential for the audience, signed by the holder
+func SignPresentation(holder, kid, audience string, issue, expire int64, vc string, key interface{}) (string, error) {
+	c := &presentationClaims{
+		StandardClaims: jwtgo.StandardClaims{
+			Audience:  audience,
+			ExpiresAt: expire,
+			IssuedAt:  issue,
+			Issuer:    holder,
+		},
+	}
+	c.VP.Type = []string{VerifiablePresentation}
+	c.VP.VerifiableCredential = []string{vc}
+	return signJWT(kid, c, key)
+}
+
+func signJWT(kid string, c jwtgo.Claims, key interface{}) (string, error) {
+	method := signingMethodFor(key)
+	if method == nil {
+		return "", jwtgo.ErrInvalidKeyType
+	}
+	token := jwtgo.NewWithClaims(method, c)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	return token.SignedString(key)
+}
+
+// didNamespace returns the owner namespace of the DID, the address of a did:io
+func didNamespace(did string) (string, error) {
+	did = didOf(did)
+	if !strings.HasPrefix(did, "did:io:") {
+		return DIDNamespace(did), nil
+	}
+	addr, err := ParseAddress(strings.TrimPrefix(did, "did:io:"))
+	if err != nil {
+		return "", errors.Wrap(ErrCredential, err.Error())
+	}
+	return addr.Hex()[2:], nil
+}
+
+// didOf strips the fragment of a DID URL
+func didOf(url string) string {
+	if i := strings.Index(url, "#"); i >= 0 {
+		return url[:i]
+	}
+	return url
+}
+
+func hasType(types []string, t string) bool {
+	for _, v := range types {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
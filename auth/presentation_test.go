@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/iotexproject/go-pkgs/crypto"
+	"github.com/iotexproject/iotex-antenna-go/v2/jwt"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPresentationVerifier(t *testing.T) {
+	r := require.New(t)
+
+	// owner's did:io with a p256 verification method, holder's did:key
+	owner, err := crypto.GenerateKey()
+	r.NoError(err)
+	ownerDID := "did:io:" + owner.PublicKey().Address().Hex()
+	ownerKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	r.NoError(err)
+	resolver := NewLocalDIDResolver(&DIDDocument{
+		ID: ownerDID,
+		VerificationMethod: []VerificationMethod{{
+			ID:           ownerDID + "#key-1",
+			Type:         EcdsaSecp256r1VerificationKey2019,
+			Controller:   ownerDID,
+			PublicKeyHex: hex.EncodeToString(elliptic.Marshal(elliptic.P256(), ownerKey.X, ownerKey.Y)),
+		}},
+	})
+	holderPub, holderPrv, err := ed25519.GenerateKey(rand.Reader)
+	r.NoError(err)
+	holderDID := EncodeDIDKey(holderPub)
+	v := NewPresentationVerifier(resolver, "phoenix")
+
+	issue := time.Now().Unix()
+	expire := time.Now().Add(time.Hour).Unix()
+	vc, err := SignCredential(ownerDID, "#key-1", holderDID, issue, expire, "s3/dataset", jwt.READ, ownerKey)
+	r.NoError(err)
+	vp, err := SignPresentation(holderDID, "", "phoenix", issue, expire-60, vc, holderPrv)
+	r.NoError(err)
+	r.True(IsPresentation(vp))
+	r.False(IsPresentation(vc))
+	r.True(IsCredential(vc))
+	r.False(IsCredential(vp))
+
+	c, err := v.Verify(vp)
+	r.NoError(err)
+	r.Equal(owner.PublicKey().Address().Hex()[2:], c.Namespace)
+	r.Equal(owner.PublicKey().Address().Hex()[2:], c.Root().Namespace)
+	r.Equal("s3", c.Store())
+	r.Equal("dataset", c.Resource())
+	r.True(c.Allow(jwt.READ))
+	r.False(c.Allow(jwt.DELETE))
+	r.Equal(expire-60, c.ExpiresAt)
+	r.Len(c.Chain(), 2)
+	r.Equal(vc, c.Parent.Token)
+
+	// presentation made for another audience, without expiry or for too long
+	vp2, err := SignPresentation(holderDID, "", "other", issue, expire-60, vc, holderPrv)
+	r.NoError(err)
+	_, err = v.Verify(vp2)
+	r.Equal(ErrCredential, errors.Cause(err))
+	vp2, err = SignPresentation(holderDID, "", "phoenix", issue, 0, vc, holderPrv)
+	r.NoError(err)
+	_, err = v.Verify(vp2)
+	r.Equal(ErrCredential, errors.Cause(err))
+	vp2, err = SignPresentation(holderDID, "", "phoenix", issue, issue+int64(2*MaxPresentationTTL/time.Second), vc, holderPrv)
+	r.NoError(err)
+	_, err = v.Verify(vp2)
+	r.Equal(ErrCredential, errors.Cause(err))
+
+	// presented by someone else
+	_, otherPrv, err := ed25519.GenerateKey(rand.Reader)
+	r.NoError(err)
+	otherPub := otherPrv.Public().(ed25519.PublicKey)
+	vp2, err = SignPresentation(EncodeDIDKey(otherPub), "", "phoenix", issue, expire, vc, otherPrv)
+	r.NoError(err)
+	_, err = v.Verify(vp2)
+	r.Equal(ErrCredential, errors.Cause(err))
+
+	// presentation claims to be the holder but isn't signed by it
+	vp2, err = SignPresentation(holderDID, "", "phoenix", issue, expire, vc, otherPrv)
+	r.NoError(err)
+	_, err = v.Verify(vp2)
+	r.Error(err)
+
+	// credential not signed by the owner's verification method
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	r.NoError(err)
+	vc2, err := SignCredential(ownerDID, "#key-1", holderDID, issue, expire, "s3/dataset", jwt.READ, otherKey)
+	r.NoError(err)
+	vp2, err = SignPresentation(holderDID, "", "phoenix", issue, expire, vc2, holderPrv)
+	r.NoError(err)
+	_, err = v.Verify(vp2)
+	r.Error(err)
+
+	// unknown DID
+	vc2, err = SignCredential("did:io:io1mflp9m6hcgm2qcghchsdqj3z3eccrnekx9p0ms", "#key-1", holderDID, issue, expire, "s3", jwt.READ, ownerKey)
+	r.NoError(err)
+	_, err = v.VerifyCredential(vc2)
+	r.Contains(err.Error(), ErrDIDNotFound.Error())
+
+	// expired credential
+	vc2, err = SignCredential(ownerDID, "#key-1", holderDID, issue-7200, issue-3600, "s3/dataset", jwt.READ, ownerKey)
+	r.NoError(err)
+	vp2, err = SignPresentation(holderDID, "", "phoenix", issue, expire, vc2, holderPrv)
+	r.NoError(err)
+	_, err = v.Verify(vp2)
+	r.Error(err)
+
+	// did:key owner, namespace derived from the DID
+	ownerPub, ownerPrv, err := ed25519.GenerateKey(rand.Reader)
+	r.NoError(err)
+	vc2, err = SignCredential(EncodeDIDKey(ownerPub), "", holderDID, issue, expire, "s3", jwt.READ, ownerPrv)
+	r.NoError(err)
+	c, err = v.VerifyCredential(vc2)
+	r.NoError(err)
+	r.Equal(DIDNamespace(EncodeDIDKey(ownerPub)), c.Namespace)
+}
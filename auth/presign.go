@@ -0,0 +1,123 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iotexproject/iotex-antenna-go/v2/jwt"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrPresignedURL is returned if the signature of presigned URL doesn't verify
+	ErrPresignedURL = errors.New("invalid presigned url")
+
+	// ErrPresignedURLExpired is returned if presigned URL has expired
+	ErrPresignedURLExpired = errors.New("presigned url has expired")
+)
+
+// PresignedRequest is the transfer of an object phoenix signs for whoever holds the URL, until it expires. It is
+// handed out for backends that can't presign URLs themselves
+type PresignedRequest struct {
+	Method  string
+	Owner   string // namespace of the owner
+	Store   string
+	Bucket  string
+	Path    string
+	Expires int64
+	// Chain is the hashes of the token the request is presigned with and the tokens it is delegated from, so
+	// revoking any of them cuts off the URL
+	Chain []string
+	// Issuer, Subject and Scope are of the token the request is presigned with, policies and embargoes are
+	// evaluated against them when the object is transferred. They are empty if the owner presigns it with its
+	// session, which is subject to neither
+	Issuer  string
+	Subject string
+	Scope   string
+}
+
+// ParsePresignedRequest returns the request of the method to the object, presigned with the query
+func ParsePresignedRequest(method, bucket, path string, query url.Values) (*PresignedRequest, error) {
+	p := &PresignedRequest{
+		Method: method,
+		Owner:  query.Get("owner"),
+		Store:  query.Get("store"),
+		Bucket: bucket,
+		Path:   path,
+	}
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || p.Owner == "" || p.Store == "" || query.Get("signature") == "" {
+		return nil, errors.Wrap(ErrPresignedURL, "owner, store, expires and signature must be given")
+	}
+	p.Expires = expires
+	if chain := query.Get("chain"); chain != "" {
+		p.Chain = strings.Split(chain, ",")
+	}
+	p.Issuer, p.Subject, p.Scope = query.Get("iss"), query.Get("sub"), query.Get("scope")
+	return p, nil
+}
+
+// Claims returns the claims of the token the request is presigned with
+func (p *PresignedRequest) Claims() *Claims {
+	return &Claims{
+		JWT:       &jwt.JWT{Issuer: p.Issuer, Subject: p.Subject, Scope: p.Scope, ExpiresAt: p.Expires},
+		Namespace: p.Owner,
+		Owner:     p.Scope == "",
+	}
+}
+
+func (p *PresignedRequest) message() []byte {
+	return []byte(strings.Join([]string{
+		"phoenix-presigned",
+		p.Method,
+		p.Owner,
+		p.Store,
+		p.Bucket,
+		p.Path,
+		strconv.FormatInt(p.Expires, 10),
+		strings.Join(p.Chain, ","),
+		p.Issuer,
+		p.Subject,
+		p.Scope,
+	}, "\n"))
+}
+
+// Query returns the query of the URL presigning the request with the key
+func (p *PresignedRequest) Query(key ed25519.PrivateKey) url.Values {
+	query := url.Values{}
+	query.Set("owner", p.Owner)
+	query.Set("store", p.Store)
+	query.Set("expires", strconv.FormatInt(p.Expires, 10))
+	if len(p.Chain) > 0 {
+		query.Set("chain", strings.Join(p.Chain, ","))
+	}
+	if p.Scope != "" {
+		query.Set("iss", p.Issuer)
+		query.Set("sub", p.Subject)
+		query.Set("scope", p.Scope)
+	}
+	query.Set("signature", hex.EncodeToString(ed25519.Sign(key, p.message())))
+	return query
+}
+
+// Verify checks the request is signed by the key with the signature, and has not expired by the time
+func (p *PresignedRequest) Verify(key ed25519.PublicKey, signature string, now time.Time) error {
+	sig, err := hex.DecodeString(signature)
+	if err != nil || !ed25519.Verify(key, p.message(), sig) {
+		return ErrPresignedURL
+	}
+	if now.Unix() >= p.Expires {
+		return errors.Wrapf(ErrPresignedURLExpired, "expired at %d", p.Expires)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPresignedRequest(t *testing.T) {
+	r := require.New(t)
+
+	pub, prv, err := ed25519.GenerateKey(rand.Reader)
+	r.NoError(err)
+	now := time.Now()
+	p := &PresignedRequest{
+		Method:  http.MethodGet,
+		Owner:   "owner",
+		Store:   "s3",
+		Bucket:  "test",
+		Path:    "dir/foo.txt",
+		Expires: now.Add(time.Minute).Unix(),
+		Chain:   []string{"leaf", "root"},
+		Issuer:  "io1trustee",
+		Subject: "s3/test",
+		Scope:   "Read",
+	}
+	query := p.Query(prv)
+
+	parsed, err := ParsePresignedRequest(http.MethodGet, "test", "dir/foo.txt", query)
+	r.NoError(err)
+	r.Equal(p, parsed)
+	r.NoError(parsed.Verify(pub, query.Get("signature"), now))
+	claims := parsed.Claims()
+	r.False(claims.Owner)
+	r.Equal("s3", claims.Store())
+	r.Equal("owner", claims.Root().Namespace)
+
+	// the URL expires
+	r.Equal(ErrPresignedURLExpired, errors.Cause(parsed.Verify(pub, query.Get("signature"), now.Add(time.Minute))))
+
+	// the URL is bound to the method and object
+	put, err := ParsePresignedRequest(http.MethodPut, "test", "dir/foo.txt", query)
+	r.NoError(err)
+	r.Equal(ErrPresignedURL, put.Verify(pub, query.Get("signature"), now))
+	other, err := ParsePresignedRequest(http.MethodGet, "test", "dir/bar.txt", query)
+	r.NoError(err)
+	r.Equal(ErrPresignedURL, other.Verify(pub, query.Get("signature"), now))
+
+	// nor the scope of the token presigning it widened
+	query.Set("scope", "Read,Update")
+	parsed, err = ParsePresignedRequest(http.MethodGet, "test", "dir/foo.txt", query)
+	r.NoError(err)
+	r.Equal(ErrPresignedURL, parsed.Verify(pub, query.Get("signature"), now))
+	query.Set("scope", "Read")
+
+	// the chain can't be cut short
+	query.Set("chain", "leaf")
+	parsed, err = ParsePresignedRequest(http.MethodGet, "test", "dir/foo.txt", query)
+	r.NoError(err)
+	r.Equal(ErrPresignedURL, parsed.Verify(pub, query.Get("signature"), now))
+
+	// key of another phoenix doesn't verify
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	r.NoError(err)
+	r.Equal(ErrPresignedURL, p.Verify(otherPub, p.Query(prv).Get("signature"), now))
+
+	query.Del("signature")
+	_, err = ParsePresignedRequest(http.MethodGet, "test", "dir/foo.txt", query)
+	r.Equal(ErrPresignedURL, errors.Cause(err))
+}
@@ -0,0 +1,625 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/iotexproject/phoenix/db"
+	"github.com/iotexproject/phoenix/json"
+)
+
+const (
+	quotaNamespace       = "quota"
+	quotaUsageNamespace  = "quotausage"
+	quotaObjectNamespace = "quotaobject"
+
+	// DefaultQuotaPeriod is the period bytes transferred are counted in, if the quota doesn't set one
+	DefaultQuotaPeriod = 24 * 60 * 60
+)
+
+var (
+	// ErrQuotaStorage is returned if the bytes or objects stored would exceed a quota
+	ErrQuotaStorage = errors.New("storage quota exceeded")
+
+	// ErrQuotaTransfer is returned if the bytes transferred in the period would exceed a quota
+	ErrQuotaTransfer = errors.New("transfer quota exceeded")
+
+	// ErrQuota is returned if the quota is invalid
+	ErrQuota = errors.New("invalid quota")
+)
+
+type (
+	// Quota limits the bytes and objects stored, and the bytes uploaded and downloaded per period, zero is unlimited
+	Quota struct {
+		MaxBytes    int64 `json:"maxBytes,omitempty"`
+		MaxObjects  int64 `json:"maxObjects,omitempty"`
+		MaxTransfer int64 `json:"maxTransfer,omitempty"`
+		Period      int64 `json:"period,omitempty"` // second, DefaultQuotaPeriod if not given
+	}
+
+	// StoreQuota is the quota the owner sets when registering a store, of the store and of each bucket in it
+	StoreQuota struct {
+		Store  string `json:"store"`
+		Quota  *Quota `json:"quota,omitempty"`
+		Bucket *Quota `json:"bucket,omitempty"`
+	}
+
+	// QuotaUsage is the bytes and objects stored, and the bytes transferred in the period since PeriodStart
+	QuotaUsage struct {
+		Bytes       int64 `json:"bytes"`
+		Objects     int64 `json:"objects"`
+		Transfer    int64 `json:"transfer"`
+		PeriodStart int64 `json:"periodStart,omitempty"`
+	}
+
+	// OwnerUsage is the usage of an owner, of its stores and buckets, and of its tokens limited by quota
+	OwnerUsage struct {
+		Owner   QuotaUsage             `json:"owner"`
+		Stores  map[string]*QuotaUsage `json:"stores,omitempty"`
+		Buckets map[string]*QuotaUsage `json:"buckets,omitempty"`
+		Tokens  map[string]*QuotaUsage `json:"tokens,omitempty"`
+	}
+
+	Quotas interface {
+		// GetQuota returns the quota of owner's store, nil if there isn't
+		GetQuota(string, string) (*StoreQuota, error)
+
+		// PutQuota puts the quota of owner's store into db
+		PutQuota(string, *StoreQuota) error
+
+		// DelQuota deletes the quota of owner's store
+		DelQuota(string, string) error
+
+		// CheckUpload returns ErrQuotaStorage or ErrQuotaTransfer if uploading the object of the size would exceed
+		// a quota of the owner, store, bucket or token
+		CheckUpload(c *Claims, bucket, path string, size int64) error
+
+		// Reserve checks and records the object of the size uploaded at once, failing with ErrQuotaStorage or
+		// ErrQuotaTransfer if it would exceed a quota. The reservation is released if the upload fails
+		Reserve(c *Claims, bucket, path string, size int64) (*QuotaReservation, error)
+
+		// Release takes the object of a failed upload off the usage, the object it was to replace counts again
+		Release(*QuotaReservation) error
+
+		// Remaining returns the bytes of the object that can be uploaded within the quotas, -1 if unlimited
+		Remaining(c *Claims, bucket, path string) (int64, error)
+
+		// CheckDownload returns ErrQuotaTransfer if downloading the bytes would exceed a quota
+		CheckDownload(c *Claims, bucket string, size int64) error
+
+		// Download records the bytes downloaded, failing with ErrQuotaTransfer if it would exceed a quota
+		Download(c *Claims, bucket string, size int64) error
+
+		// Remove records the object of owner's store deleted, or all objects of the bucket if path is empty
+		Remove(namespace, store, bucket, path string) error
+
+		// Usage returns the usage of the owner
+		Usage(string) (*OwnerUsage, error)
+	}
+
+	// QuotaReservation is the object of an upload recorded before it is stored, and the object it replaces
+	QuotaReservation struct {
+		namespace, store, bucket, path string
+		object, prev                   *quotaObject
+	}
+
+	// quotaObject is the size of a stored object, and the hashes of the tokens limited by quota uploading it
+	quotaObject struct {
+		Size   int64    `json:"size"`
+		Tokens []string `json:"tokens,omitempty"`
+	}
+
+	// quotaLevel is a usage and the quota it is limited by
+	quotaLevel struct {
+		name  string
+		usage *QuotaUsage
+		quota *Quota
+	}
+
+	// getter reads records from the db, or within a transaction of it
+	getter interface {
+		Get(string, []byte) ([]byte, error)
+	}
+
+	quotas struct {
+		db.KVStore
+
+		// global is the quota of each owner set by operator
+		global *Quota
+	}
+)
+
+// NewQuotas creates quotas, global is the quota of each owner, nil if owners are unlimited
+func NewQuotas(kv db.KVStore, global *Quota) Quotas {
+	return &quotas{
+		KVStore: kv,
+		global:  global,
+	}
+}
+
+// Validate checks the limits and period of the quotas are not negative
+func (sq *StoreQuota) Validate() error {
+	for _, q := range []*Quota{sq.Quota, sq.Bucket} {
+		if q != nil && (q.MaxBytes < 0 || q.MaxObjects < 0 || q.MaxTransfer < 0 || q.Period < 0) {
+			return errors.Wrap(ErrQuota, "limits and period can't be negative")
+		}
+	}
+	return nil
+}
+
+func (q *Quota) period() int64 {
+	if q == nil || q.Period <= 0 {
+		return DefaultQuotaPeriod
+	}
+	return q.Period
+}
+
+// roll starts a new period of the usage once the current one is over
+func (u *QuotaUsage) roll(quota *Quota, now int64) {
+	if period := quota.period(); now >= u.PeriodStart+period {
+		u.PeriodStart, u.Transfer = now-now%period, 0
+	}
+}
+
+// checkStorage returns ErrQuotaStorage if storing the bytes and objects more exceeds the quota
+func (l *quotaLevel) checkStorage(bytes, objects int64) error {
+	if l.quota == nil {
+		return nil
+	}
+	if l.quota.MaxBytes > 0 && bytes > 0 && l.usage.Bytes+bytes > l.quota.MaxBytes {
+		return errors.Wrapf(ErrQuotaStorage, "%s would store %d of %d bytes", l.name, l.usage.Bytes+bytes, l.quota.MaxBytes)
+	}
+	if l.quota.MaxObjects > 0 && objects > 0 && l.usage.Objects+objects > l.quota.MaxObjects {
+		return errors.Wrapf(ErrQuotaStorage, "%s would store %d of %d objects", l.name, l.usage.Objects+objects, l.quota.MaxObjects)
+	}
+	return nil
+}
+
+// checkTransfer returns ErrQuotaTransfer if transferring the bytes more in the period exceeds the quota
+func (l *quotaLevel) checkTransfer(bytes int64) error {
+	if l.quota == nil || l.quota.MaxTransfer <= 0 || l.usage.Transfer+bytes <= l.quota.MaxTransfer {
+		return nil
+	}
+	reset := time.Unix(l.usage.PeriodStart+l.quota.period(), 0).UTC().Format(time.RFC3339)
+	return errors.Wrapf(ErrQuotaTransfer, "%s would transfer %d of %d bytes in %ds, resets at %s",
+		l.name, l.usage.Transfer+bytes, l.quota.MaxTransfer, l.quota.period(), reset)
+}
+
+func quotaKey(namespace, store string) []byte {
+	return []byte(namespace + "/" + store)
+}
+
+func quotaObjectKey(namespace, store, bucket, path string) []byte {
+	return []byte(strings.TrimSuffix(namespace+"/"+store+"/"+bucket+"/"+path, "/"))
+}
+
+func (m *quotas) GetQuota(namespace, store string) (*StoreQuota, error) {
+	v, err := m.Get(quotaNamespace, quotaKey(namespace, store))
+	switch errors.Cause(err) {
+	case nil:
+		break
+	case db.ErrBucketNotExist, db.ErrNotExist:
+		return nil, nil
+	default:
+		return nil, err
+	}
+	sq := &StoreQuota{}
+	if err := json.Unmarshal(v, sq); err != nil {
+		return nil, err
+	}
+	return sq, nil
+}
+
+func (m *quotas) PutQuota(namespace string, sq *StoreQuota) error {
+	if err := sq.Validate(); err != nil {
+		return err
+	}
+	v, err := json.Marshal(sq)
+	if err != nil {
+		return err
+	}
+	return m.Put(quotaNamespace, quotaKey(namespace, sq.Store), v)
+}
+
+func (m *quotas) DelQuota(namespace, store string) error {
+	return m.Delete(quotaNamespace, quotaKey(namespace, store))
+}
+
+func (m *quotas) CheckUpload(c *Claims, bucket, path string, size int64) error {
+	namespace, store := c.Root().Namespace, c.Store()
+	sq, err := m.GetQuota(namespace, store)
+	if err != nil {
+		return err
+	}
+	prev, err := m.object(namespace, store, bucket, path)
+	if err != nil {
+		return err
+	}
+	usage, err := m.Usage(namespace)
+	if err != nil {
+		return err
+	}
+	bytes, objects := size, int64(1)
+	if prev != nil {
+		bytes, objects = size-prev.Size, 0
+	}
+	for _, l := range m.levels(usage, sq, c, bucket, time.Now().Unix()) {
+		if err := l.checkStorage(bytes, objects); err != nil {
+			return err
+		}
+		if err := l.checkTransfer(size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *quotas) Reserve(c *Claims, bucket, path string, size int64) (*QuotaReservation, error) {
+	namespace, store := c.Root().Namespace, c.Store()
+	sq, err := m.GetQuota(namespace, store)
+	if err != nil {
+		return nil, err
+	}
+	res := &QuotaReservation{namespace: namespace, store: store, bucket: bucket, path: path, object: &quotaObject{Size: size}}
+	for _, link := range c.Chain() {
+		if link.Quota != nil {
+			res.object.Tokens = append(res.object.Tokens, link.Hash())
+		}
+	}
+	key := quotaObjectKey(namespace, store, bucket, path)
+	err = m.Batch(func(tx db.Tx) error {
+		// the object replaced and the usage are read in the transaction, so concurrent uploads are checked in turn
+		prev, err := getObject(tx, key)
+		if err != nil {
+			return err
+		}
+		usage, err := getUsage(tx, namespace)
+		if err != nil {
+			return err
+		}
+		bytes, objects := size, int64(1)
+		if prev != nil {
+			bytes, objects = size-prev.Size, 0
+		}
+		levels := m.levels(usage, sq, c, bucket, time.Now().Unix())
+		for _, l := range levels {
+			if err := l.checkStorage(bytes, objects); err != nil {
+				return err
+			}
+			if err := l.checkTransfer(size); err != nil {
+				return err
+			}
+		}
+		if prev != nil {
+			usage.remove(store, bucket, prev)
+		}
+		for _, l := range levels {
+			l.usage.Bytes += size
+			l.usage.Objects++
+			l.usage.Transfer += size
+		}
+		if err := putJSON(tx, quotaUsageNamespace, []byte(namespace), usage); err != nil {
+			return err
+		}
+		res.prev = prev
+		return putJSON(tx, quotaObjectNamespace, key, res.object)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (m *quotas) Release(res *QuotaReservation) error {
+	key := quotaObjectKey(res.namespace, res.store, res.bucket, res.path)
+	return m.Batch(func(tx db.Tx) error {
+		usage, err := getUsage(tx, res.namespace)
+		if err != nil {
+			return err
+		}
+		usage.remove(res.store, res.bucket, res.object)
+		for _, u := range usage.usages(res.store, res.bucket, res.object) {
+			if u.Transfer -= res.object.Size; u.Transfer < 0 {
+				u.Transfer = 0
+			}
+		}
+		if res.prev != nil {
+			usage.add(res.store, res.bucket, res.prev)
+			err = putJSON(tx, quotaObjectNamespace, key, res.prev)
+		} else {
+			err = tx.Delete(quotaObjectNamespace, key)
+		}
+		if err != nil {
+			return err
+		}
+		return putJSON(tx, quotaUsageNamespace, []byte(res.namespace), usage)
+	})
+}
+
+func (m *quotas) Remaining(c *Claims, bucket, path string) (int64, error) {
+	namespace, store := c.Root().Namespace, c.Store()
+	sq, err := m.GetQuota(namespace, store)
+	if err != nil {
+		return 0, err
+	}
+	prev, err := m.object(namespace, store, bucket, path)
+	if err != nil {
+		return 0, err
+	}
+	usage, err := m.Usage(namespace)
+	if err != nil {
+		return 0, err
+	}
+	replaced := int64(0)
+	if prev != nil {
+		replaced = prev.Size
+	}
+	remaining := int64(-1)
+	for _, l := range m.levels(usage, sq, c, bucket, time.Now().Unix()) {
+		if l.quota == nil {
+			continue
+		}
+		if l.quota.MaxBytes > 0 {
+			remaining = least(remaining, l.quota.MaxBytes-l.usage.Bytes+replaced)
+		}
+		if l.quota.MaxTransfer > 0 {
+			remaining = least(remaining, l.quota.MaxTransfer-l.usage.Transfer)
+		}
+	}
+	return remaining, nil
+}
+
+// least returns the lesser of the bytes remaining and the bytes left at a level, which is at least 0
+func least(remaining, left int64) int64 {
+	if left < 0 {
+		left = 0
+	}
+	if remaining < 0 || left < remaining {
+		return left
+	}
+	return remaining
+}
+
+func (m *quotas) CheckDownload(c *Claims, bucket string, size int64) error {
+	namespace := c.Root().Namespace
+	sq, err := m.GetQuota(namespace, c.Store())
+	if err != nil {
+		return err
+	}
+	usage, err := m.Usage(namespace)
+	if err != nil {
+		return err
+	}
+	for _, l := range m.levels(usage, sq, c, bucket, time.Now().Unix()) {
+		if err := l.checkTransfer(size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *quotas) Download(c *Claims, bucket string, size int64) error {
+	namespace := c.Root().Namespace
+	sq, err := m.GetQuota(namespace, c.Store())
+	if err != nil {
+		return err
+	}
+	return m.update(namespace, func(usage *OwnerUsage) error {
+		levels := m.levels(usage, sq, c, bucket, time.Now().Unix())
+		for _, l := range levels {
+			if err := l.checkTransfer(size); err != nil {
+				return err
+			}
+		}
+		for _, l := range levels {
+			l.usage.Transfer += size
+		}
+		return nil
+	})
+}
+
+func (m *quotas) Remove(namespace, store, bucket, path string) error {
+	var keys, values [][]byte
+	if path != "" {
+		key := quotaObjectKey(namespace, store, bucket, path)
+		v, err := m.Get(quotaObjectNamespace, key)
+		switch errors.Cause(err) {
+		case nil:
+			keys, values = [][]byte{key}, [][]byte{v}
+		case db.ErrBucketNotExist, db.ErrNotExist:
+			return nil
+		default:
+			return err
+		}
+	} else {
+		var err error
+		keys, values, err = m.List(quotaObjectNamespace, quotaObjectKey(namespace, store, bucket, "/"))
+		if err != nil {
+			return err
+		}
+	}
+	removed := make([]*quotaObject, 0, len(keys))
+	for i, k := range keys {
+		o := &quotaObject{}
+		if err := json.Unmarshal(values[i], o); err != nil {
+			return err
+		}
+		if err := m.Delete(quotaObjectNamespace, k); err != nil {
+			return err
+		}
+		removed = append(removed, o)
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+	return m.update(namespace, func(usage *OwnerUsage) error {
+		for _, o := range removed {
+			usage.remove(store, bucket, o)
+		}
+		return nil
+	})
+}
+
+func (m *quotas) Usage(namespace string) (*OwnerUsage, error) {
+	return getUsage(m, namespace)
+}
+
+func getUsage(g getter, namespace string) (*OwnerUsage, error) {
+	usage := &OwnerUsage{}
+	v, err := g.Get(quotaUsageNamespace, []byte(namespace))
+	switch errors.Cause(err) {
+	case nil:
+		break
+	case db.ErrBucketNotExist, db.ErrNotExist:
+		return usage, nil
+	default:
+		return nil, err
+	}
+	if err := json.Unmarshal(v, usage); err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// levels returns the usages of the owner, store, bucket and tokens limited by quota the request counts towards,
+// with the periods of transfer rolled
+func (m *quotas) levels(usage *OwnerUsage, sq *StoreQuota, c *Claims, bucket string, now int64) []*quotaLevel {
+	if sq == nil {
+		sq = &StoreQuota{}
+	}
+	store := c.Store()
+	levels := []*quotaLevel{
+		{name: "owner", usage: &usage.Owner, quota: m.global},
+		{name: "store " + store, usage: usage.store(store), quota: sq.Quota},
+		{name: fmt.Sprintf("bucket %s/%s", store, bucket), usage: usage.bucket(store, bucket), quota: sq.Bucket},
+	}
+	for _, link := range c.Chain() {
+		if link.Quota != nil {
+			levels = append(levels, &quotaLevel{name: "token", usage: usage.token(link.Hash()), quota: link.Quota})
+		}
+	}
+	for _, l := range levels {
+		l.usage.roll(l.quota, now)
+	}
+	return levels
+}
+
+// update atomically reads and rewrites the usage of the owner
+func (m *quotas) update(namespace string, fn func(*OwnerUsage) error) error {
+	return m.Update(quotaUsageNamespace, []byte(namespace), func(v []byte) ([]byte, error) {
+		usage := &OwnerUsage{}
+		if v != nil {
+			if err := json.Unmarshal(v, usage); err != nil {
+				return nil, err
+			}
+		}
+		if err := fn(usage); err != nil {
+			return nil, err
+		}
+		return json.Marshal(usage)
+	})
+}
+
+// putJSON writes the record encoded in json within the transaction
+func putJSON(tx db.Tx, namespace string, key []byte, record interface{}) error {
+	v, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return tx.Put(namespace, key, v)
+}
+
+// object returns the stored object, nil if it isn't recorded
+func (m *quotas) object(namespace, store, bucket, path string) (*quotaObject, error) {
+	return getObject(m, quotaObjectKey(namespace, store, bucket, path))
+}
+
+func getObject(g getter, key []byte) (*quotaObject, error) {
+	v, err := g.Get(quotaObjectNamespace, key)
+	switch errors.Cause(err) {
+	case nil:
+		break
+	case db.ErrBucketNotExist, db.ErrNotExist:
+		return nil, nil
+	default:
+		return nil, err
+	}
+	o := &quotaObject{}
+	if err := json.Unmarshal(v, o); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (u *OwnerUsage) store(store string) *QuotaUsage {
+	if u.Stores == nil {
+		u.Stores = map[string]*QuotaUsage{}
+	}
+	if u.Stores[store] == nil {
+		u.Stores[store] = &QuotaUsage{}
+	}
+	return u.Stores[store]
+}
+
+func (u *OwnerUsage) bucket(store, bucket string) *QuotaUsage {
+	if u.Buckets == nil {
+		u.Buckets = map[string]*QuotaUsage{}
+	}
+	key := store + "/" + bucket
+	if u.Buckets[key] == nil {
+		u.Buckets[key] = &QuotaUsage{}
+	}
+	return u.Buckets[key]
+}
+
+func (u *OwnerUsage) token(hash string) *QuotaUsage {
+	if u.Tokens == nil {
+		u.Tokens = map[string]*QuotaUsage{}
+	}
+	if u.Tokens[hash] == nil {
+		u.Tokens[hash] = &QuotaUsage{}
+	}
+	return u.Tokens[hash]
+}
+
+// usages returns the usages the stored object counts towards
+func (u *OwnerUsage) usages(store, bucket string, o *quotaObject) []*QuotaUsage {
+	usages := []*QuotaUsage{&u.Owner, u.store(store), u.bucket(store, bucket)}
+	for _, hash := range o.Tokens {
+		if u.Tokens[hash] != nil {
+			usages = append(usages, u.Tokens[hash])
+		}
+	}
+	return usages
+}
+
+// add counts the stored object towards the usages again
+func (u *OwnerUsage) add(store, bucket string, o *quotaObject) {
+	for _, usage := range u.usages(store, bucket, o) {
+		usage.Bytes += o.Size
+		usage.Objects++
+	}
+}
+
+// remove takes the stored object off the usages it counts towards
+func (u *OwnerUsage) remove(store, bucket string, o *quotaObject) {
+	for _, usage := range u.usages(store, bucket, o) {
+		usage.Bytes -= o.Size
+		usage.Objects--
+		if usage.Bytes < 0 {
+			usage.Bytes = 0
+		}
+		if usage.Objects < 0 {
+			usage.Objects = 0
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/iotexproject/go-pkgs/crypto"
+	"github.com/iotexproject/iotex-antenna-go/v2/jwt"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iotexproject/phoenix/db"
+)
+
+func TestQuotas(t *testing.T) {
+	r := require.New(t)
+
+	testFile, err := ioutil.TempFile(os.TempDir(), "test-bolt")
+	path := testFile.Name()
+	r.NoError(err)
+	testFile.Close()
+	defer func() {
+		r.NoError(os.Remove(path))
+	}()
+
+	d := db.NewBoltDB(path)
+	ctx := context.Background()
+	r.NoError(d.Start(ctx))
+	defer func() {
+		r.NoError(d.Stop(ctx))
+	}()
+
+	owner, err := crypto.GenerateKey()
+	r.NoError(err)
+	namespace := owner.PublicKey().Address().Hex()[2:]
+	q := NewQuotas(d, &Quota{MaxBytes: 100})
+	issue, expire := time.Now().Unix(), time.Now().Add(time.Hour).Unix()
+	tok, err := SignToken(issue, expire, "s3", jwt.UPDATE+","+jwt.READ, Extension{}, owner)
+	r.NoError(err)
+	c, err := NewClaims(tok)
+	r.NoError(err)
+
+	// owner's quota set by operator
+	r.NoError(q.CheckUpload(c, "reports", "a.csv", 60))
+	_, err = q.Reserve(c, "reports", "a.csv", 60)
+	r.NoError(err)
+	r.Equal(ErrQuotaStorage, errors.Cause(q.CheckUpload(c, "reports", "b.csv", 50)))
+	_, err = q.Reserve(c, "reports", "b.csv", 50)
+	r.Equal(ErrQuotaStorage, errors.Cause(err))
+	remaining, err := q.Remaining(c, "reports", "b.csv")
+	r.NoError(err)
+	r.Equal(int64(40), remaining)
+	// overwriting counts the difference only
+	remaining, err = q.Remaining(c, "reports", "a.csv")
+	r.NoError(err)
+	r.Equal(int64(100), remaining)
+	r.NoError(q.CheckUpload(c, "reports", "a.csv", 90))
+	_, err = q.Reserve(c, "reports", "a.csv", 90)
+	r.NoError(err)
+	usage, err := q.Usage(namespace)
+	r.NoError(err)
+	r.Equal(int64(90), usage.Owner.Bytes)
+	r.Equal(int64(1), usage.Owner.Objects)
+	r.Equal(int64(150), usage.Owner.Transfer)
+	r.Equal(int64(90), usage.Buckets["s3/reports"].Bytes)
+
+	// a failed upload is released, the object it was to replace counts again
+	res, err := q.Reserve(c, "reports", "a.csv", 10)
+	r.NoError(err)
+	res2, err := q.Reserve(c, "reports", "b.csv", 10)
+	r.NoError(err)
+	r.NoError(q.Release(res))
+	r.NoError(q.Release(res2))
+	usage, err = q.Usage(namespace)
+	r.NoError(err)
+	r.Equal(int64(90), usage.Owner.Bytes)
+	r.Equal(int64(1), usage.Owner.Objects)
+	r.Equal(int64(150), usage.Owner.Transfer)
+	remaining, err = q.Remaining(c, "reports", "b.csv")
+	r.NoError(err)
+	r.Equal(int64(10), remaining)
+
+	// quota of store and bucket set with registration
+	r.Equal(ErrQuota, errors.Cause(q.PutQuota(namespace, &StoreQuota{Store: "s3", Quota: &Quota{MaxBytes: -1}})))
+	r.NoError(q.PutQuota(namespace, &StoreQuota{Store: "s3", Bucket: &Quota{MaxObjects: 1, MaxTransfer: 200}}))
+	sq, err := q.GetQuota(namespace, "s3")
+	r.NoError(err)
+	r.Equal(int64(1), sq.Bucket.MaxObjects)
+	r.Equal(ErrQuotaStorage, errors.Cause(q.CheckUpload(c, "reports", "b.csv", 1)))
+	r.NoError(q.CheckUpload(c, "other", "b.csv", 1))
+	r.NoError(q.Download(c, "reports", 40))
+	r.Equal(ErrQuotaTransfer, errors.Cause(q.Download(c, "reports", 20)))
+	r.NoError(q.Download(c, "other", 20))
+	r.NoError(q.DelQuota(namespace, "s3"))
+	sq, err = q.GetQuota(namespace, "s3")
+	r.NoError(err)
+	r.Nil(sq)
+
+	// token's quota
+	limited, err := SignToken(issue, expire, "s3", jwt.UPDATE, Extension{Quota: &Quota{MaxObjects: 1}}, owner)
+	r.NoError(err)
+	lc, err := NewClaims(limited)
+	r.NoError(err)
+	_, err = q.Reserve(lc, "other", "c.csv", 1)
+	r.NoError(err)
+	r.Equal(ErrQuotaStorage, errors.Cause(q.CheckUpload(lc, "other", "d.csv", 1)))
+	r.NoError(q.CheckUpload(c, "other", "d.csv", 1))
+
+	// deleting frees the quota
+	r.NoError(q.Remove(namespace, "s3", "other", "c.csv"))
+	r.NoError(q.CheckUpload(lc, "other", "d.csv", 1))
+	r.NoError(q.Remove(namespace, "s3", "reports", ""))
+	usage, err = q.Usage(namespace)
+	r.NoError(err)
+	r.Equal(int64(0), usage.Owner.Bytes)
+	r.Equal(int64(0), usage.Owner.Objects)
+	r.Equal(int64(0), usage.Tokens[lc.Hash()].Objects)
+
+	// concurrent uploads are reserved in turn, never beyond the quota
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		go func(i int) {
+			_, err := q.Reserve(c, "concurrent", fmt.Sprintf("%d.csv", i), 30)
+			errs <- err
+		}(i)
+	}
+	reserved := 0
+	for i := 0; i < 10; i++ {
+		if err := <-errs; err == nil {
+			reserved++
+		} else {
+			r.Equal(ErrQuotaStorage, errors.Cause(err))
+		}
+	}
+	r.Equal(3, reserved)
+}
@@ -0,0 +1,200 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iotexproject/go-pkgs/crypto"
+	"github.com/pkg/errors"
+
+	"github.com/iotexproject/phoenix/db"
+)
+
+// SignatureHeader is the header carrying the request signature of a token bound to its holder,
+// in the format of "t=<unix timestamp>,n=<nonce>,s=<hex signature>"
+const SignatureHeader = "X-Phoenix-Signature"
+
+const nonceNamespace = "nonce"
+
+// error definition
+var (
+	ErrRequestSignature = errors.New("invalid request signature")
+	ErrRequestReplayed  = errors.New("request has been replayed")
+)
+
+type (
+	// ReplayGuard remembers the nonces seen within the time window in db, so requests can't be replayed to any
+	// route or after a restart
+	ReplayGuard interface {
+		// Check returns ErrRequestReplayed if the nonce has been seen within the time window, and remembers it
+		// otherwise
+		Check(string) error
+
+		// Window returns the time window signed requests are accepted within
+		Window() time.Duration
+	}
+
+	replayGuard struct {
+		db.KVStore
+		window time.Duration
+
+		mu       sync.Mutex
+		prunedAt time.Time
+	}
+)
+
+// RequestDigest returns the hash the holder signs, which covers the method, path, body hash, timestamp and nonce
+func RequestDigest(method, uri string, body []byte, ts int64, nonce string) []byte {
+	bodyHash := sha256.Sum256(body)
+	msg := strings.Join([]string{
+		strings.ToUpper(method),
+		uri,
+		hex.EncodeToString(bodyHash[:]),
+		strconv.FormatInt(ts, 10),
+		nonce,
+	}, "\n")
+	h := sha256.Sum256([]byte(msg))
+	return h[:]
+}
+
+// SignRequest signs the request with the holder's key and sets the signature header
+func SignRequest(r *http.Request, body []byte, key crypto.PrivateKey) error {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	ts := time.Now().Unix()
+	n := hex.EncodeToString(nonce)
+	sig, err := key.Sign(RequestDigest(r.Method, r.URL.RequestURI(), body, ts, n))
+	if err != nil {
+		return err
+	}
+	r.Header.Set(SignatureHeader, fmt.Sprintf("t=%d,n=%s,s=%s", ts, n, hex.EncodeToString(sig)))
+	return nil
+}
+
+// VerifyRequest verifies the signature header of the request against the holder's public key,
+// and returns the nonce of the request
+func VerifyRequest(r *http.Request, body []byte, holder string, window time.Duration) (string, error) {
+	header := r.Header.Get(SignatureHeader)
+	if header == "" {
+		return "", errors.Wrap(ErrRequestSignature, "missing "+SignatureHeader+" header")
+	}
+	var (
+		ts         int64
+		nonce, sig string
+		err        error
+	)
+	for _, kv := range strings.Split(header, ",") {
+		parts := strings.SplitN(strings.TrimSpace(kv), "=", 2)
+		if len(parts) != 2 {
+			return "", errors.Wrap(ErrRequestSignature, "malformed header")
+		}
+		switch parts[0] {
+		case "t":
+			if ts, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+				return "", errors.Wrap(ErrRequestSignature, "malformed timestamp")
+			}
+		case "n":
+			nonce = parts[1]
+		case "s":
+			sig = parts[1]
+		}
+	}
+	if ts == 0 || nonce == "" || sig == "" {
+		return "", errors.Wrap(ErrRequestSignature, "malformed header")
+	}
+	if skew := time.Since(time.Unix(ts, 0)); skew > window || skew < -window {
+		return "", errors.Wrap(ErrRequestSignature, "timestamp is out of window")
+	}
+	key, err := crypto.HexStringToPublicKey(holder)
+	if err != nil {
+		return "", errors.Wrap(ErrRequestSignature, "invalid holder key")
+	}
+	sigBytes, err := hex.DecodeString(strings.TrimPrefix(sig, "0x"))
+	if err != nil {
+		return "", errors.Wrap(ErrRequestSignature, "malformed signature")
+	}
+	if !key.Verify(RequestDigest(r.Method, r.URL.RequestURI(), body, ts, nonce), sigBytes) {
+		return "", errors.Wrap(ErrRequestSignature, "signature doesn't match holder")
+	}
+	return nonce, nil
+}
+
+// NewReplayGuard creates a ReplayGuard that remembers nonces for the time window
+func NewReplayGuard(kv db.KVStore, window time.Duration) ReplayGuard {
+	return &replayGuard{
+		KVStore:  kv,
+		window:   window,
+		prunedAt: time.Now(),
+	}
+}
+
+func (g *replayGuard) Window() time.Duration {
+	return g.window
+}
+
+func (g *replayGuard) Check(nonce string) error {
+	now := time.Now()
+	if err := g.prune(now); err != nil {
+		return err
+	}
+	return g.Update(nonceNamespace, []byte(nonce), func(v []byte) ([]byte, error) {
+		if v != nil {
+			expire, err := strconv.ParseInt(string(v), 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			if expire >= now.Unix() {
+				return nil, ErrRequestReplayed
+			}
+		}
+		// timestamp is accepted within window in both directions, remember the nonce long enough
+		return []byte(strconv.FormatInt(now.Add(2*g.window).Unix(), 10)), nil
+	})
+}
+
+// prune removes the nonces expired, once every window for the cost to spread over the requests of the window
+func (g *replayGuard) prune(now time.Time) error {
+	g.mu.Lock()
+	if now.Sub(g.prunedAt) < g.window {
+		g.mu.Unlock()
+		return nil
+	}
+	g.prunedAt = now
+	g.mu.Unlock()
+
+	keys, _, err := g.List(nonceNamespace, nil)
+	if err != nil {
+		return err
+	}
+	return g.Batch(func(tx db.Tx) error {
+		for _, key := range keys {
+			// the nonce may have been seen again since it is listed
+			v, err := tx.Get(nonceNamespace, key)
+			if err != nil {
+				continue
+			}
+			if expire, err := strconv.ParseInt(string(v), 10, 64); err == nil && expire >= now.Unix() {
+				continue
+			}
+			if err := tx.Delete(nonceNamespace, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
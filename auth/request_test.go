@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/iotexproject/go-pkgs/crypto"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iotexproject/phoenix/db"
+)
+
+func TestSignVerifyRequest(t *testing.T) {
+	r := require.New(t)
+
+	holder, err := crypto.GenerateKey()
+	r.NoError(err)
+	other, err := crypto.GenerateKey()
+	r.NoError(err)
+	body := []byte("foobar")
+	window := time.Minute
+
+	req, err := http.NewRequest(http.MethodPost, "http://localhost:8080/pea/test/foobar.txt", bytes.NewReader(body))
+	r.NoError(err)
+	_, err = VerifyRequest(req, body, holder.PublicKey().HexString(), window)
+	r.Equal(ErrRequestSignature, errors.Cause(err))
+
+	r.NoError(SignRequest(req, body, holder))
+	nonce, err := VerifyRequest(req, body, holder.PublicKey().HexString(), window)
+	r.NoError(err)
+	r.NotEmpty(nonce)
+
+	// signed by other key
+	_, err = VerifyRequest(req, body, other.PublicKey().HexString(), window)
+	r.Equal(ErrRequestSignature, errors.Cause(err))
+	// body, method or path tampered
+	_, err = VerifyRequest(req, []byte("foobaz"), holder.PublicKey().HexString(), window)
+	r.Equal(ErrRequestSignature, errors.Cause(err))
+	req.Method = http.MethodDelete
+	_, err = VerifyRequest(req, body, holder.PublicKey().HexString(), window)
+	r.Equal(ErrRequestSignature, errors.Cause(err))
+	req.Method = http.MethodPost
+	req.URL.Path = "/pea/test/foobaz.txt"
+	_, err = VerifyRequest(req, body, holder.PublicKey().HexString(), window)
+	r.Equal(ErrRequestSignature, errors.Cause(err))
+
+	// out of window
+	req.URL.Path = "/pea/test/foobar.txt"
+	_, err = VerifyRequest(req, body, holder.PublicKey().HexString(), 0)
+	r.Equal(ErrRequestSignature, errors.Cause(err))
+}
+
+func TestReplayGuard(t *testing.T) {
+	r := require.New(t)
+
+	testFile, err := ioutil.TempFile(os.TempDir(), "test-bolt")
+	path := testFile.Name()
+	r.NoError(err)
+	testFile.Close()
+	defer func() {
+		r.NoError(os.Remove(path))
+	}()
+
+	kv := db.NewBoltDB(path)
+	ctx := context.Background()
+	r.NoError(kv.Start(ctx))
+	defer func() {
+		r.NoError(kv.Stop(ctx))
+	}()
+
+	g := NewReplayGuard(kv, time.Minute)
+	r.Equal(time.Minute, g.Window())
+	r.NoError(g.Check("JtQTAme2SKJzXVs"))
+	r.NoError(g.Check("exUALhrxXi3DcLg2"))
+	r.Equal(ErrRequestReplayed, g.Check("JtQTAme2SKJzXVs"))
+
+	// nonces are remembered by every guard on the db, and after the restart
+	r.Equal(ErrRequestReplayed, NewReplayGuard(kv, time.Minute).Check("exUALhrxXi3DcLg2"))
+
+	// nonces expired are forgotten, and pruned
+	g = NewReplayGuard(kv, 0)
+	r.NoError(kv.Put(nonceNamespace, []byte("JtQTAme2SKJzXVs"), []byte("1")))
+	r.NoError(g.Check("JtQTAme2SKJzXVs"))
+	r.NoError(kv.Put(nonceNamespace, []byte("JtQTAme2SKJzXVs"), []byte("1")))
+	r.NoError(g.Check("tzjeg+3xrA5"))
+	_, err = kv.Get(nonceNamespace, []byte("JtQTAme2SKJzXVs"))
+	r.Equal(db.ErrNotExist, errors.Cause(err))
+}
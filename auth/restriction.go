@@ -0,0 +1,133 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// errors of the restrictions a token is bound to, each rejection is told apart
+var (
+	ErrNotYetValid = errors.New("token is not valid yet")
+	ErrClientIP    = errors.New("client IP is not allowed")
+	ErrUserAgent   = errors.New("user agent is not allowed")
+	ErrTimeWindow  = errors.New("outside allowed time window")
+)
+
+// TimeWindow is the time of day a token can be used in, on the days if given. The window spans midnight if End
+// is before Start
+type TimeWindow struct {
+	// Days are the weekdays, Mon to Sun, all days if not given
+	Days []string `json:"days,omitempty"`
+	// Start and End are the time of day in 15:04
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// Timezone is the IANA time zone Start and End are in, UTC if not given
+	Timezone string `json:"tz,omitempty"`
+}
+
+// Contains returns true if the time is within the window
+func (tw *TimeWindow) Contains(t time.Time) (bool, error) {
+	loc, err := time.LoadLocation(tw.Timezone)
+	if err != nil {
+		return false, err
+	}
+	start, err := time.Parse("15:04", tw.Start)
+	if err != nil {
+		return false, err
+	}
+	end, err := time.Parse("15:04", tw.End)
+	if err != nil {
+		return false, err
+	}
+	t = t.In(loc)
+	if len(tw.Days) > 0 {
+		day := t.Weekday().String()[:3]
+		found := false
+		for _, d := range tw.Days {
+			found = found || strings.EqualFold(d, day)
+		}
+		if !found {
+			return false, nil
+		}
+	}
+	now := t.Hour()*60 + t.Minute()
+	from, to := start.Hour()*60+start.Minute(), end.Hour()*60+end.Minute()
+	if from <= to {
+		return from <= now && now < to, nil
+	}
+	return now >= from || now < to, nil
+}
+
+// Restricted returns true if the token, or any token it is delegated from, is bound to client IPs, user agents
+// or time windows
+func (c *Claims) Restricted() bool {
+	for _, link := range c.Chain() {
+		if len(link.CIDRs) > 0 || len(link.UserAgents) > 0 || len(link.Windows) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Restrict checks the client IP, user agent and time of the request against the restrictions of the token and
+// all tokens it is delegated from
+func (c *Claims) Restrict(ip, userAgent string, now time.Time) error {
+	for _, link := range c.Chain() {
+		if err := link.restrict(ip, userAgent, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Claims) restrict(ip, userAgent string, now time.Time) error {
+	if c.NotBefore != 0 && now.Unix() < c.NotBefore {
+		return errors.Wrapf(ErrNotYetValid, "token is valid since %s", time.Unix(c.NotBefore, 0).UTC().Format(time.RFC3339))
+	}
+	if len(c.CIDRs) > 0 {
+		addr := net.ParseIP(ip)
+		allowed := false
+		for _, cidr := range c.CIDRs {
+			_, network, err := net.ParseCIDR(cidr)
+			if err != nil {
+				return errors.Wrapf(ErrClientIP, "invalid cidr %s", cidr)
+			}
+			allowed = allowed || (addr != nil && network.Contains(addr))
+		}
+		if !allowed {
+			return errors.Wrapf(ErrClientIP, "%s is not within %s", ip, strings.Join(c.CIDRs, ", "))
+		}
+	}
+	if len(c.UserAgents) > 0 {
+		allowed := false
+		for _, prefix := range c.UserAgents {
+			allowed = allowed || strings.HasPrefix(userAgent, prefix)
+		}
+		if !allowed {
+			return errors.Wrapf(ErrUserAgent, "user agent %q", userAgent)
+		}
+	}
+	if len(c.Windows) > 0 {
+		allowed := false
+		for i := range c.Windows {
+			in, err := c.Windows[i].Contains(now)
+			if err != nil {
+				return errors.Wrapf(ErrTimeWindow, "invalid time window: %v", err)
+			}
+			allowed = allowed || in
+		}
+		if !allowed {
+			return errors.Wrapf(ErrTimeWindow, "%s", now.UTC().Format(time.RFC3339))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iotexproject/go-pkgs/crypto"
+	"github.com/iotexproject/iotex-antenna-go/v2/jwt"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeWindow(t *testing.T) {
+	r := require.New(t)
+
+	// Monday 2020-12-07 10:30 UTC is 18:30 in Shanghai
+	monday := time.Date(2020, 12, 7, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		window TimeWindow
+		at     time.Time
+		in     bool
+	}{
+		{TimeWindow{Start: "09:00", End: "17:00"}, monday, true},
+		{TimeWindow{Start: "09:00", End: "10:30"}, monday, false},
+		{TimeWindow{Days: []string{"Tue", "Wed"}, Start: "09:00", End: "17:00"}, monday, false},
+		{TimeWindow{Days: []string{"mon"}, Start: "09:00", End: "17:00"}, monday, true},
+		{TimeWindow{Start: "09:00", End: "17:00", Timezone: "Asia/Shanghai"}, monday, false},
+		{TimeWindow{Start: "18:00", End: "02:00", Timezone: "Asia/Shanghai"}, monday, true},
+		{TimeWindow{Start: "18:00", End: "02:00"}, monday.Add(12 * time.Hour), true},
+		{TimeWindow{Start: "18:00", End: "02:00"}, monday, false},
+	}
+	for i, test := range tests {
+		in, err := test.window.Contains(test.at)
+		r.NoError(err)
+		r.Equal(test.in, in, "case %d", i)
+	}
+
+	_, err := (&TimeWindow{Start: "09:00", End: "17:00", Timezone: "Nowhere/Land"}).Contains(monday)
+	r.Error(err)
+	_, err = (&TimeWindow{Start: "9am", End: "17:00"}).Contains(monday)
+	r.Error(err)
+}
+
+func TestRestrict(t *testing.T) {
+	r := require.New(t)
+
+	owner, err := crypto.GenerateKey()
+	r.NoError(err)
+	trustee, err := crypto.GenerateKey()
+	r.NoError(err)
+	issue := time.Now().Unix()
+	expire := time.Now().Add(time.Hour).Unix()
+
+	tok, err := SignToken(issue, expire, "s3/reports", jwt.READ, Extension{
+		Holder:     trustee.PublicKey().HexString(),
+		CIDRs:      []string{"10.0.0.0/8", "192.168.1.0/24"},
+		UserAgents: []string{"partner-sync/"},
+		Windows:    []TimeWindow{{Start: "00:00", End: "12:00"}},
+	}, owner)
+	r.NoError(err)
+	c, err := NewClaims(tok)
+	r.NoError(err)
+	r.Len(c.CIDRs, 2)
+
+	morning := time.Date(2020, 12, 7, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		ip, ua string
+		at     time.Time
+		err    error
+	}{
+		{"10.1.2.3", "partner-sync/1.0", morning, nil},
+		{"192.168.1.20", "partner-sync/2.1", morning, nil},
+		{"192.168.2.20", "partner-sync/1.0", morning, ErrClientIP},
+		{"not an ip", "partner-sync/1.0", morning, ErrClientIP},
+		{"10.1.2.3", "curl/7.64.1", morning, ErrUserAgent},
+		{"10.1.2.3", "partner-sync/1.0", morning.Add(6 * time.Hour), ErrTimeWindow},
+	}
+	for i, test := range tests {
+		r.Equal(test.err, errors.Cause(c.Restrict(test.ip, test.ua, test.at)), "case %d", i)
+	}
+
+	// delegated token is bound to the restrictions of its parent
+	child, err := SignToken(issue, expire, "s3/reports", jwt.READ, Extension{ParentToken: tok}, trustee)
+	r.NoError(err)
+	c, err = NewClaims(child)
+	r.NoError(err)
+	r.NoError(c.Restrict("10.1.2.3", "partner-sync/1.0", morning))
+	r.Equal(ErrClientIP, errors.Cause(c.Restrict("127.0.0.1", "partner-sync/1.0", morning)))
+	r.True(c.Restricted())
+
+	// token is not valid before nbf
+	tok, err = SignToken(issue, expire, "s3", jwt.READ, Extension{NotBefore: time.Now().Add(time.Hour).Unix()}, owner)
+	r.NoError(err)
+	_, err = NewClaims(tok)
+	r.Equal(ErrNotYetValid, errors.Cause(err))
+	nbf := time.Now().Add(-time.Minute).Unix()
+	tok, err = SignToken(issue, expire, "s3", jwt.READ, Extension{NotBefore: nbf}, owner)
+	r.NoError(err)
+	c, err = NewClaims(tok)
+	r.NoError(err)
+	r.Equal(nbf, c.NotBefore)
+	r.False(c.Restricted())
+	r.NoError(c.Restrict("127.0.0.1", "", time.Now()))
+	r.Equal(ErrNotYetValid, errors.Cause(c.Restrict("127.0.0.1", "", time.Unix(nbf-1, 0))))
+}
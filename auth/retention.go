@@ -0,0 +1,304 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/iotexproject/phoenix/db"
+	"github.com/iotexproject/phoenix/json"
+)
+
+const (
+	retentionNamespace  = "retention"
+	objectLockNamespace = "objectlock"
+)
+
+var (
+	// ErrRetention is returned if the retention policy or object lock is invalid
+	ErrRetention = errors.New("invalid retention")
+
+	// ErrRetentionNotFound is returned if the bucket has no retention policy
+	ErrRetentionNotFound = errors.New("retention policy not found")
+
+	// ErrRetentionShortened is returned if the retention of an object is set earlier than it is in force until
+	ErrRetentionShortened = errors.New("retention can't be shortened")
+
+	// ErrObjectLocked is returned if the object is retained or under legal hold
+	ErrObjectLocked = errors.New("object is locked")
+)
+
+type (
+	// RetentionPolicy retains the objects uploaded to a bucket against changes and deletion for Days. Native is
+	// true if the backend retains them as well
+	RetentionPolicy struct {
+		Owner     string `json:"-"`
+		Store     string `json:"store"`
+		Bucket    string `json:"bucket"`
+		Days      int    `json:"days"`
+		Native    bool   `json:"native"`
+		CreatedAt int64  `json:"createdAt"`
+	}
+
+	// ObjectLock retains an object against changes and deletion until RetainUntil, and while it is under legal
+	// hold. Native is true if the backend locks it as well
+	ObjectLock struct {
+		Owner       string `json:"-"`
+		Store       string `json:"store"`
+		Bucket      string `json:"bucket"`
+		Path        string `json:"path"`
+		RetainUntil int64  `json:"retainUntil,omitempty"`
+		LegalHold   bool   `json:"legalHold"`
+		Native      bool   `json:"native"`
+	}
+
+	Retentions interface {
+		// PutRetention puts the retention policy of owner's bucket into db, replacing the one it has
+		PutRetention(string, *RetentionPolicy) error
+
+		// GetRetention returns the retention policy of owner's bucket, nil if the bucket has none
+		GetRetention(namespace, store, bucket string) (*RetentionPolicy, error)
+
+		// DelRetention removes the retention policy of owner's bucket, objects uploaded before stay locked
+		DelRetention(namespace, store, bucket string) error
+
+		// Retentions returns the retention policies of owner's buckets
+		Retentions(string) ([]*RetentionPolicy, error)
+
+		// PutLock puts the lock of owner's object into db, fails with ErrRetentionShortened if it retains the
+		// object for less than the lock in force
+		PutLock(string, *ObjectLock) error
+
+		// GetLock returns the lock of owner's object, nil if the object has none
+		GetLock(namespace, store, bucket, path string) (*ObjectLock, error)
+
+		// Locks returns the locks of owner's objects
+		Locks(string) ([]*ObjectLock, error)
+
+		// Check fails with ErrObjectLocked if owner's object, or any object of the bucket if path is empty, is
+		// locked at the time
+		Check(namespace, store, bucket, path string, t int64) error
+
+		// Retained returns true if owner's bucket has a retention policy, or objects locked at the time by phoenix
+		// alone, so uploads to it must be checked and recorded by phoenix
+		Retained(namespace, store, bucket string, t int64) (bool, error)
+
+		// Release removes the lock of owner's object deleted, or the locks and retention policy of the bucket if
+		// path is empty
+		Release(namespace, store, bucket, path string) error
+	}
+
+	retentions struct {
+		db.KVStore
+	}
+)
+
+// Validate checks the policy retains objects of a bucket for some days
+func (p *RetentionPolicy) Validate() error {
+	if p.Store == "" || p.Bucket == "" || strings.Contains(p.Store, "/") || strings.Contains(p.Bucket, "/") {
+		return errors.Wrap(ErrRetention, "store and bucket are required, and can't contain /")
+	}
+	if p.Days <= 0 {
+		return errors.Wrap(ErrRetention, "days must be positive")
+	}
+	return nil
+}
+
+// Retention returns how long objects are retained
+func (p *RetentionPolicy) Retention() time.Duration {
+	return time.Duration(p.Days) * 24 * time.Hour
+}
+
+// Locked returns true if the object can't be changed or deleted at the time
+func (l *ObjectLock) Locked(t int64) bool {
+	return l.LegalHold || l.RetainUntil > t
+}
+
+func NewRetentions(kv db.KVStore) Retentions {
+	return &retentions{
+		KVStore: kv,
+	}
+}
+
+func retentionKey(namespace, store, bucket string) []byte {
+	return []byte(strings.Join([]string{namespace, store, bucket}, "/"))
+}
+
+func objectLockKey(namespace, store, bucket, path string) []byte {
+	return []byte(strings.Join([]string{namespace, store, bucket, path}, "/"))
+}
+
+func (m *retentions) PutRetention(namespace string, p *RetentionPolicy) error {
+	if p.CreatedAt == 0 {
+		p.CreatedAt = time.Now().Unix()
+	}
+	v, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return m.Put(retentionNamespace, retentionKey(namespace, p.Store, p.Bucket), v)
+}
+
+func (m *retentions) GetRetention(namespace, store, bucket string) (*RetentionPolicy, error) {
+	v, err := m.Get(retentionNamespace, retentionKey(namespace, store, bucket))
+	switch errors.Cause(err) {
+	case nil:
+		break
+	case db.ErrBucketNotExist, db.ErrNotExist:
+		return nil, nil
+	default:
+		return nil, err
+	}
+	p := &RetentionPolicy{Owner: namespace}
+	if err := json.Unmarshal(v, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (m *retentions) DelRetention(namespace, store, bucket string) error {
+	p, err := m.GetRetention(namespace, store, bucket)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return errors.Wrapf(ErrRetentionNotFound, "bucket %s/%s", store, bucket)
+	}
+	return m.Delete(retentionNamespace, retentionKey(namespace, store, bucket))
+}
+
+func (m *retentions) Retentions(namespace string) ([]*RetentionPolicy, error) {
+	_, values, err := m.List(retentionNamespace, []byte(namespace+"/"))
+	if err != nil {
+		return nil, err
+	}
+	list := []*RetentionPolicy{}
+	for _, v := range values {
+		p := &RetentionPolicy{Owner: namespace}
+		if err := json.Unmarshal(v, p); err != nil {
+			return nil, err
+		}
+		list = append(list, p)
+	}
+	return list, nil
+}
+
+func (m *retentions) PutLock(namespace string, l *ObjectLock) error {
+	key := objectLockKey(namespace, l.Store, l.Bucket, l.Path)
+	return m.Update(objectLockNamespace, key, func(v []byte) ([]byte, error) {
+		if v != nil {
+			current := &ObjectLock{}
+			if err := json.Unmarshal(v, current); err != nil {
+				return nil, err
+			}
+			if l.RetainUntil < current.RetainUntil && current.RetainUntil > time.Now().Unix() {
+				return nil, errors.Wrapf(ErrRetentionShortened, "%s is retained until %d", l.Path, current.RetainUntil)
+			}
+		}
+		return json.Marshal(l)
+	})
+}
+
+func (m *retentions) GetLock(namespace, store, bucket, path string) (*ObjectLock, error) {
+	v, err := m.Get(objectLockNamespace, objectLockKey(namespace, store, bucket, path))
+	switch errors.Cause(err) {
+	case nil:
+		break
+	case db.ErrBucketNotExist, db.ErrNotExist:
+		return nil, nil
+	default:
+		return nil, err
+	}
+	l := &ObjectLock{Owner: namespace}
+	if err := json.Unmarshal(v, l); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (m *retentions) Locks(namespace string) ([]*ObjectLock, error) {
+	return m.locks(namespace, []byte(namespace+"/"))
+}
+
+func (m *retentions) locks(namespace string, prefix []byte) ([]*ObjectLock, error) {
+	_, values, err := m.List(objectLockNamespace, prefix)
+	if err != nil {
+		return nil, err
+	}
+	list := []*ObjectLock{}
+	for _, v := range values {
+		l := &ObjectLock{Owner: namespace}
+		if err := json.Unmarshal(v, l); err != nil {
+			return nil, err
+		}
+		list = append(list, l)
+	}
+	return list, nil
+}
+
+func (m *retentions) Check(namespace, store, bucket, path string, t int64) error {
+	var locks []*ObjectLock
+	if path != "" {
+		l, err := m.GetLock(namespace, store, bucket, path)
+		if err != nil {
+			return err
+		}
+		if l != nil {
+			locks = append(locks, l)
+		}
+	} else {
+		var err error
+		if locks, err = m.locks(namespace, objectLockKey(namespace, store, bucket, "")); err != nil {
+			return err
+		}
+	}
+	for _, l := range locks {
+		switch {
+		case l.LegalHold:
+			return errors.Wrapf(ErrObjectLocked, "%s is under legal hold", l.Path)
+		case l.RetainUntil > t:
+			return errors.Wrapf(ErrObjectLocked, "%s is retained until %d", l.Path, l.RetainUntil)
+		}
+	}
+	return nil
+}
+
+func (m *retentions) Retained(namespace, store, bucket string, t int64) (bool, error) {
+	policy, err := m.GetRetention(namespace, store, bucket)
+	if err != nil || policy != nil {
+		return policy != nil, err
+	}
+	locks, err := m.locks(namespace, objectLockKey(namespace, store, bucket, ""))
+	if err != nil {
+		return false, err
+	}
+	for _, l := range locks {
+		if !l.Native && l.Locked(t) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *retentions) Release(namespace, store, bucket, path string) error {
+	if path != "" {
+		return m.Delete(objectLockNamespace, objectLockKey(namespace, store, bucket, path))
+	}
+	keys, _, err := m.List(objectLockNamespace, objectLockKey(namespace, store, bucket, ""))
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := m.Delete(objectLockNamespace, key); err != nil {
+			return err
+		}
+	}
+	return m.Delete(retentionNamespace, retentionKey(namespace, store, bucket))
+}
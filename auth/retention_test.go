@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iotexproject/phoenix/db"
+)
+
+func TestRetentions(t *testing.T) {
+	r := require.New(t)
+
+	testFile, err := ioutil.TempFile(os.TempDir(), "test-bolt")
+	path := testFile.Name()
+	r.NoError(err)
+	testFile.Close()
+	defer func() {
+		r.NoError(os.Remove(path))
+	}()
+
+	d := db.NewBoltDB(path)
+	ctx := context.Background()
+	r.NoError(d.Start(ctx))
+	defer func() {
+		r.NoError(d.Stop(ctx))
+	}()
+
+	for _, p := range []*RetentionPolicy{
+		{Store: "s3", Days: 1},
+		{Store: "s3", Bucket: "a/b", Days: 1},
+		{Store: "s3", Bucket: "ledger"},
+	} {
+		r.Equal(ErrRetention, errors.Cause(p.Validate()))
+	}
+
+	m := NewRetentions(d)
+	policy, err := m.GetRetention("owner1", "s3", "ledger")
+	r.NoError(err)
+	r.Nil(policy)
+	r.Equal(ErrRetentionNotFound, errors.Cause(m.DelRetention("owner1", "s3", "ledger")))
+	r.NoError(m.PutRetention("owner1", &RetentionPolicy{Store: "s3", Bucket: "ledger", Days: 365}))
+	policy, err = m.GetRetention("owner1", "s3", "ledger")
+	r.NoError(err)
+	r.Equal(365, policy.Days)
+	r.Equal("owner1", policy.Owner)
+	r.NotZero(policy.CreatedAt)
+	r.Equal(365*24*time.Hour, policy.Retention())
+	policies, err := m.Retentions("owner1")
+	r.NoError(err)
+	r.Len(policies, 1)
+	policies, err = m.Retentions("owner2")
+	r.NoError(err)
+	r.Empty(policies)
+
+	// objects retained until a time, or while under legal hold
+	now := time.Now().Unix()
+	r.NoError(m.PutLock("owner1", &ObjectLock{Store: "s3", Bucket: "ledger", Path: "2020.csv", RetainUntil: now + 100}))
+	r.NoError(m.PutLock("owner1", &ObjectLock{Store: "s3", Bucket: "ledger", Path: "old.csv", RetainUntil: now - 100}))
+	r.NoError(m.Check("owner1", "s3", "ledger", "old.csv", now))
+	r.NoError(m.Check("owner1", "s3", "ledger", "new.csv", now))
+	r.NoError(m.Check("owner2", "s3", "ledger", "2020.csv", now))
+	r.Equal(ErrObjectLocked, errors.Cause(m.Check("owner1", "s3", "ledger", "2020.csv", now)))
+	r.NoError(m.Check("owner1", "s3", "ledger", "2020.csv", now+100))
+	r.Equal(ErrObjectLocked, errors.Cause(m.Check("owner1", "s3", "ledger", "", now)))
+	r.NoError(m.Check("owner1", "s3", "ledger2", "", now))
+
+	// retention can be extended but not shortened while in force
+	lock, err := m.GetLock("owner1", "s3", "ledger", "2020.csv")
+	r.NoError(err)
+	r.True(lock.Locked(now))
+	lock.RetainUntil = now + 50
+	r.Equal(ErrRetentionShortened, errors.Cause(m.PutLock("owner1", lock)))
+	lock.RetainUntil = now + 200
+	r.NoError(m.PutLock("owner1", lock))
+	expired := &ObjectLock{Store: "s3", Bucket: "ledger", Path: "old.csv", RetainUntil: now - 200, LegalHold: true}
+	r.NoError(m.PutLock("owner1", expired))
+	r.Equal(ErrObjectLocked, errors.Cause(m.Check("owner1", "s3", "ledger", "old.csv", now)))
+	expired.LegalHold = false
+	r.NoError(m.PutLock("owner1", expired))
+	r.NoError(m.Check("owner1", "s3", "ledger", "old.csv", now))
+	locks, err := m.Locks("owner1")
+	r.NoError(err)
+	r.Len(locks, 2)
+
+	// uploads pass through phoenix while it retains the bucket, or alone locks objects of it
+	retained, err := m.Retained("owner1", "s3", "ledger", now)
+	r.NoError(err)
+	r.True(retained)
+	r.NoError(m.DelRetention("owner1", "s3", "ledger"))
+	retained, err = m.Retained("owner1", "s3", "ledger", now)
+	r.NoError(err)
+	r.True(retained)
+	retained, err = m.Retained("owner1", "s3", "ledger", now+1000)
+	r.NoError(err)
+	r.False(retained)
+	lock.Native = true
+	r.NoError(m.PutLock("owner1", lock))
+	retained, err = m.Retained("owner1", "s3", "ledger", now)
+	r.NoError(err)
+	r.False(retained)
+	r.NoError(m.PutRetention("owner1", &RetentionPolicy{Store: "s3", Bucket: "ledger", Days: 365}))
+
+	// locks of objects, and policies of buckets, deleted are released
+	r.NoError(m.Release("owner1", "s3", "ledger", "old.csv"))
+	lock, err = m.GetLock("owner1", "s3", "ledger", "old.csv")
+	r.NoError(err)
+	r.Nil(lock)
+	r.NoError(m.Release("owner1", "s3", "ledger", ""))
+	locks, err = m.Locks("owner1")
+	r.NoError(err)
+	r.Empty(locks)
+	policy, err = m.GetRetention("owner1", "s3", "ledger")
+	r.NoError(err)
+	r.Nil(policy)
+}
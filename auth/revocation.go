@@ -0,0 +1,78 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/iotexproject/phoenix/db"
+)
+
+const revocationNamespace = "revocation"
+
+// ErrTokenRevoked is returned if the token or any token it is delegated from has been revoked
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+type (
+	Revocation interface {
+		// Revoke revokes the token, which cuts off all tokens delegated from it
+		Revoke(*Claims) error
+
+		// RevokeHash revokes the token by its hash
+		RevokeHash(string) error
+
+		// Check returns ErrTokenRevoked if any token in the chain has been revoked
+		Check(*Claims) error
+
+		// CheckHashes returns ErrTokenRevoked if any token of the hashes has been revoked
+		CheckHashes([]string) error
+	}
+
+	revocation struct {
+		db.KVStore
+	}
+)
+
+func NewRevocation(kv db.KVStore) Revocation {
+	return &revocation{
+		KVStore: kv,
+	}
+}
+
+func (r *revocation) Revoke(c *Claims) error {
+	return r.RevokeHash(c.Hash())
+}
+
+func (r *revocation) RevokeHash(hash string) error {
+	return r.Put(revocationNamespace, []byte(hash), []byte(strconv.FormatInt(time.Now().Unix(), 10)))
+}
+
+func (r *revocation) Check(c *Claims) error {
+	hashes := []string{}
+	for _, link := range c.Chain() {
+		hashes = append(hashes, link.Hash())
+	}
+	return r.CheckHashes(hashes)
+}
+
+func (r *revocation) CheckHashes(hashes []string) error {
+	for _, hash := range hashes {
+		_, err := r.Get(revocationNamespace, []byte(hash))
+		switch errors.Cause(err) {
+		case nil:
+			return ErrTokenRevoked
+		case db.ErrBucketNotExist, db.ErrNotExist:
+			continue
+		default:
+			return err
+		}
+	}
+	return nil
+}
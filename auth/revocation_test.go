@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/iotexproject/go-pkgs/crypto"
+	"github.com/iotexproject/iotex-antenna-go/v2/jwt"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iotexproject/phoenix/db"
+)
+
+func TestRevocation(t *testing.T) {
+	r := require.New(t)
+
+	testFile, err := ioutil.TempFile(os.TempDir(), "test-bolt")
+	path := testFile.Name()
+	r.NoError(err)
+	testFile.Close()
+	defer func() {
+		r.NoError(os.Remove(path))
+	}()
+
+	d := db.NewBoltDB(path)
+	ctx := context.Background()
+	r.NoError(d.Start(ctx))
+	defer func() {
+		r.NoError(d.Stop(ctx))
+	}()
+
+	owner, err := crypto.GenerateKey()
+	r.NoError(err)
+	trustee, err := crypto.GenerateKey()
+	r.NoError(err)
+	issue := time.Now().Unix()
+	expire := time.Now().Add(time.Hour).Unix()
+	root, err := SignToken(issue, expire, "s3", jwt.READ, Extension{Holder: trustee.PublicKey().HexString()}, owner)
+	r.NoError(err)
+	leaf, err := SignToken(issue, expire, "s3", jwt.READ, Extension{ParentToken: root}, trustee)
+	r.NoError(err)
+	rc, err := NewClaims(root)
+	r.NoError(err)
+	lc, err := NewClaims(leaf)
+	r.NoError(err)
+
+	rev := NewRevocation(d)
+	r.NoError(rev.Check(rc))
+	r.NoError(rev.Check(lc))
+
+	// revoking the parent cuts off the delegated token
+	r.NoError(rev.Revoke(rc))
+	r.Equal(ErrTokenRevoked, rev.Check(rc))
+	r.Equal(ErrTokenRevoked, rev.Check(lc))
+	r.Equal(ErrTokenRevoked, rev.CheckHashes([]string{lc.Hash(), rc.Hash()}))
+	r.NoError(rev.CheckHashes([]string{lc.Hash()}))
+}
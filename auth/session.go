@@ -0,0 +1,245 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iotexproject/iotex-antenna-go/v2/jwt"
+	"github.com/pkg/errors"
+
+	"github.com/iotexproject/phoenix/db"
+	"github.com/iotexproject/phoenix/json"
+)
+
+const (
+	challengeNamespace = "challenge"
+	sessionNamespace   = "session"
+
+	// ChallengeTTL is how long a login challenge can be signed
+	ChallengeTTL = 5 * time.Minute
+)
+
+// error definition
+var (
+	ErrChallenge = errors.New("invalid or expired challenge")
+	ErrSession   = errors.New("invalid or expired session")
+)
+
+type (
+	Sessions interface {
+		// Challenge issues a nonce for the owner address to sign with its wallet
+		Challenge(string) (*Challenge, error)
+
+		// Login verifies the signed challenge and creates a short-lived owner session
+		Login(nonce string, sig []byte, sigType string) (*Session, error)
+
+		// Claims returns the owner claims of a live session
+		Claims(string) (*Claims, error)
+	}
+
+	// Challenge is a nonce for the owner to sign
+	Challenge struct {
+		Nonce     string     `json:"nonce"`
+		Address   string     `json:"address"`
+		Message   string     `json:"message"`
+		TypedData *TypedData `json:"typedData"`
+		ExpiresAt int64      `json:"expiresAt"`
+	}
+
+	// Session is an owner session created by wallet login
+	Session struct {
+		ID        string `json:"session"`
+		Owner     string `json:"owner"`
+		Namespace string `json:"namespace"`
+		ExpiresAt int64  `json:"expiresAt"`
+	}
+
+	sessions struct {
+		db.KVStore
+		ttl time.Duration
+
+		mu       sync.Mutex
+		prunedAt time.Time
+	}
+)
+
+func NewSessions(kv db.KVStore, ttl time.Duration) Sessions {
+	return &sessions{
+		KVStore:  kv,
+		ttl:      ttl,
+		prunedAt: time.Now(),
+	}
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (s *sessions) Challenge(addr string) (*Challenge, error) {
+	owner, err := ParseAddress(addr)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if err := s.prune(now); err != nil {
+		return nil, err
+	}
+	nonce, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	expire := now.Add(ChallengeTTL)
+	c := &Challenge{
+		Nonce:   nonce,
+		Address: owner.String(),
+		Message: fmt.Sprintf("phoenix wants you to sign in with your account:\n%s\n\nNonce: %s\nExpires: %s",
+			owner.String(), nonce, expire.UTC().Format(time.RFC3339)),
+		TypedData: NewLoginTypedData(owner, nonce),
+		ExpiresAt: expire.Unix(),
+	}
+	v, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Put(challengeNamespace, []byte(nonce), v); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// prune removes the challenges expired without being signed and the sessions expired, once every ChallengeTTL for
+// the cost to spread over the challenges issued meanwhile
+func (s *sessions) prune(now time.Time) error {
+	s.mu.Lock()
+	if now.Sub(s.prunedAt) < ChallengeTTL {
+		s.mu.Unlock()
+		return nil
+	}
+	s.prunedAt = now
+	s.mu.Unlock()
+
+	expired := map[string][][]byte{}
+	for _, ns := range []string{challengeNamespace, sessionNamespace} {
+		keys, values, err := s.List(ns, nil)
+		if err != nil {
+			return err
+		}
+		for i, key := range keys {
+			// challenges and sessions both expire at expiresAt, records failing to decode are removed as well
+			record := &struct {
+				ExpiresAt int64 `json:"expiresAt"`
+			}{}
+			if err := json.Unmarshal(values[i], record); err == nil && record.ExpiresAt >= now.Unix() {
+				continue
+			}
+			expired[ns] = append(expired[ns], key)
+		}
+	}
+	return s.Batch(func(tx db.Tx) error {
+		for ns, keys := range expired {
+			for _, key := range keys {
+				if err := tx.Delete(ns, key); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (s *sessions) Login(nonce string, sig []byte, sigType string) (*Session, error) {
+	c := &Challenge{}
+	// a challenge can only be used once
+	if err := s.Update(challengeNamespace, []byte(nonce), func(v []byte) ([]byte, error) {
+		if v == nil {
+			return nil, ErrChallenge
+		}
+		return nil, json.Unmarshal(v, c)
+	}); err != nil {
+		return nil, err
+	}
+	if time.Now().Unix() > c.ExpiresAt {
+		return nil, ErrChallenge
+	}
+	owner, err := ParseAddress(c.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	var digest []byte
+	switch sigType {
+	case PersonalSign, "":
+		digest = PersonalMessageHash(c.Message)
+	case TypedSign:
+		digest = TypedDataHash(owner, c.Nonce)
+	default:
+		return nil, errors.Wrapf(ErrChallenge, "unsupported signature type %s", sigType)
+	}
+	pk, err := RecoverSigner(digest, sig, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+	session := &Session{
+		ID:        id,
+		Owner:     "0x" + pk.HexString(),
+		Namespace: owner.Hex()[2:],
+		ExpiresAt: time.Now().Add(s.ttl).Unix(),
+	}
+	v, err := json.Marshal(session)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Put(sessionNamespace, []byte(id), v); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (s *sessions) Claims(id string) (*Claims, error) {
+	v, err := s.Get(sessionNamespace, []byte(id))
+	switch errors.Cause(err) {
+	case nil:
+		break
+	case db.ErrBucketNotExist, db.ErrNotExist:
+		return nil, ErrSession
+	default:
+		return nil, err
+	}
+	session := &Session{}
+	if err := json.Unmarshal(v, session); err != nil {
+		return nil, err
+	}
+	if time.Now().Unix() > session.ExpiresAt {
+		s.Delete(sessionNamespace, []byte(id))
+		return nil, ErrSession
+	}
+	return &Claims{
+		JWT: &jwt.JWT{
+			ExpiresAt: session.ExpiresAt,
+			Issuer:    session.Owner,
+			Scope:     strings.Join(Ops, ","),
+		},
+		Token:     id,
+		Namespace: session.Namespace,
+		Owner:     true,
+	}, nil
+}
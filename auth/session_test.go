@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"context"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/iotexproject/go-pkgs/crypto"
+	"github.com/iotexproject/iotex-antenna-go/v2/jwt"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iotexproject/phoenix/db"
+	"github.com/iotexproject/phoenix/json"
+)
+
+func TestTypedDataHash(t *testing.T) {
+	r := require.New(t)
+
+	owner, err := ParseAddress("0x6a26b3056679e6adf079350c778ae7ab71a287fa")
+	r.NoError(err)
+	// computed by eth_signTypedData_v4 implementation of go-ethereum
+	r.Equal("d7953a06c1e41796d201f81cab33ccdc4787f62659e7151e857d9826aef4912e", hex.EncodeToString(TypedDataHash(owner, "abc")))
+
+	io, err := ParseAddress(owner.String())
+	r.NoError(err)
+	r.Equal(owner.Bytes(), io.Bytes())
+}
+
+func TestSessions(t *testing.T) {
+	r := require.New(t)
+
+	testFile, err := ioutil.TempFile(os.TempDir(), "test-bolt")
+	path := testFile.Name()
+	r.NoError(err)
+	testFile.Close()
+	defer func() {
+		r.NoError(os.Remove(path))
+	}()
+
+	d := db.NewBoltDB(path)
+	ctx := context.Background()
+	r.NoError(d.Start(ctx))
+	defer func() {
+		r.NoError(d.Stop(ctx))
+	}()
+
+	key, err := crypto.GenerateKey()
+	r.NoError(err)
+	other, err := crypto.GenerateKey()
+	r.NoError(err)
+	s := NewSessions(d, time.Minute)
+
+	// personal_sign with Ethereum address
+	c, err := s.Challenge(key.PublicKey().Address().Hex())
+	r.NoError(err)
+	r.Equal(key.PublicKey().Address().String(), c.Address)
+	sig, err := other.Sign(PersonalMessageHash(c.Message))
+	r.NoError(err)
+	_, err = s.Login(c.Nonce, sig, PersonalSign)
+	r.Equal(ErrChallenge, errors.Cause(err))
+	// challenge is consumed by failed login
+	sig, err = key.Sign(PersonalMessageHash(c.Message))
+	r.NoError(err)
+	_, err = s.Login(c.Nonce, sig, PersonalSign)
+	r.Equal(ErrChallenge, errors.Cause(err))
+
+	c, err = s.Challenge(key.PublicKey().Address().String())
+	r.NoError(err)
+	sig, err = key.Sign(PersonalMessageHash(c.Message))
+	r.NoError(err)
+	sig[64] += 27 // wallets add 27 to recovery id
+	session, err := s.Login(c.Nonce, sig, PersonalSign)
+	r.NoError(err)
+	r.Equal("0x"+key.PublicKey().HexString(), session.Owner)
+	claims, err := s.Claims(session.ID)
+	r.NoError(err)
+	r.True(claims.Owner)
+	r.True(claims.Allow(jwt.DELETE))
+	r.Equal(session.Owner, claims.Root().Issuer)
+
+	// EIP-712 typed data
+	c, err = s.Challenge(key.PublicKey().Address().String())
+	r.NoError(err)
+	owner, err := ParseAddress(c.Address)
+	r.NoError(err)
+	sig, err = key.Sign(TypedDataHash(owner, c.Nonce))
+	r.NoError(err)
+	_, err = s.Login(c.Nonce, sig, TypedSign)
+	r.NoError(err)
+
+	_, err = s.Claims("exUALhrxXi3DcLg2")
+	r.Equal(ErrSession, errors.Cause(err))
+
+	// challenges expired without being signed, and sessions expired, are pruned
+	c, err = s.Challenge(key.PublicKey().Address().String())
+	r.NoError(err)
+	c.ExpiresAt = time.Now().Add(-time.Second).Unix()
+	v, err := json.Marshal(c)
+	r.NoError(err)
+	r.NoError(d.Put(challengeNamespace, []byte(c.Nonce), v))
+	session.ExpiresAt = time.Now().Add(-time.Second).Unix()
+	v, err = json.Marshal(session)
+	r.NoError(err)
+	r.NoError(d.Put(sessionNamespace, []byte(session.ID), v))
+	s.(*sessions).prunedAt = time.Now().Add(-ChallengeTTL)
+	_, err = s.Challenge(key.PublicKey().Address().String())
+	r.NoError(err)
+	_, err = d.Get(challengeNamespace, []byte(c.Nonce))
+	r.Equal(db.ErrNotExist, errors.Cause(err))
+	keys, _, err := d.List(challengeNamespace, nil)
+	r.NoError(err)
+	r.Len(keys, 1)
+	_, err = d.Get(sessionNamespace, []byte(session.ID))
+	r.Equal(db.ErrNotExist, errors.Cause(err))
+	keys, _, err = d.List(sessionNamespace, nil)
+	r.NoError(err)
+	r.Len(keys, 1)
+}
@@ -0,0 +1,241 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iotexproject/iotex-antenna-go/v2/jwt"
+	"github.com/pkg/errors"
+
+	"github.com/iotexproject/phoenix/db"
+)
+
+const (
+	shareNamespace    = "share"
+	shareKeyNamespace = "sharekey"
+)
+
+var (
+	// ErrShareLink is returned if the signature of share link doesn't verify
+	ErrShareLink = errors.New("invalid share link")
+
+	// ErrShareLinkExpired is returned if share link has expired
+	ErrShareLinkExpired = errors.New("share link has expired")
+
+	// ErrShareLinkUsedUp is returned if share link has been downloaded as many times as it allows
+	ErrShareLinkUsedUp = errors.New("share link has been used up")
+)
+
+type (
+	// ShareLink is a link to download an object, which phoenix signs for whoever holds it, until it expires
+	ShareLink struct {
+		ID      string
+		Owner   string // namespace of the owner
+		Store   string
+		Bucket  string
+		Path    string
+		Expires int64
+		// MaxDownloads is the times the link can be downloaded, unlimited if zero
+		MaxDownloads int
+		// Chain is the hashes of the token sharing the link and the tokens it is delegated from, so revoking any of
+		// them cuts off the link
+		Chain []string
+		// Issuer, Subject and Scope are of the token sharing the link, policies are evaluated against them when the
+		// link is downloaded. They are empty if the owner shares it with its session, which is not subject to them
+		Issuer  string
+		Subject string
+		Scope   string
+	}
+
+	Shares interface {
+		// Redeem records a download of the link, fails with ErrShareLinkUsedUp if the link has been downloaded as
+		// many times as it allows
+		Redeem(*ShareLink) error
+
+		// Refund takes back the download Redeem recorded for a download that failed
+		Refund(*ShareLink) error
+	}
+
+	shares struct {
+		db.KVStore
+	}
+)
+
+// ShareKey returns the key phoenix signs share links with, which is generated on first use and kept in db
+func ShareKey(kv db.KVStore) ([]byte, error) {
+	var key []byte
+	err := kv.Update(shareKeyNamespace, []byte("phoenix"), func(v []byte) ([]byte, error) {
+		if v != nil {
+			key = v
+			return v, nil
+		}
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		return key, nil
+	})
+	return key, err
+}
+
+// NewShareLink returns the link to download owner's object, with a new ID
+func NewShareLink(owner, store, bucket, path string, expires int64, maxDownloads int, chain []string) (*ShareLink, error) {
+	id, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	return &ShareLink{
+		ID:           id,
+		Owner:        owner,
+		Store:        store,
+		Bucket:       bucket,
+		Path:         path,
+		Expires:      expires,
+		MaxDownloads: maxDownloads,
+		Chain:        chain,
+	}, nil
+}
+
+// ParseShareLink returns the link to the object, shared with the query
+func ParseShareLink(bucket, path string, query url.Values) (*ShareLink, error) {
+	s := &ShareLink{
+		ID:     query.Get("id"),
+		Owner:  query.Get("owner"),
+		Store:  query.Get("store"),
+		Bucket: bucket,
+		Path:   path,
+	}
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || s.ID == "" || s.Owner == "" || s.Store == "" || query.Get("signature") == "" {
+		return nil, errors.Wrap(ErrShareLink, "id, owner, store, expires and signature must be given")
+	}
+	s.Expires = expires
+	if max := query.Get("max"); max != "" {
+		if s.MaxDownloads, err = strconv.Atoi(max); err != nil || s.MaxDownloads < 0 {
+			return nil, errors.Wrap(ErrShareLink, "max must be a count of downloads")
+		}
+	}
+	if chain := query.Get("chain"); chain != "" {
+		s.Chain = strings.Split(chain, ",")
+	}
+	s.Issuer, s.Subject, s.Scope = query.Get("iss"), query.Get("sub"), query.Get("scope")
+	return s, nil
+}
+
+// Claims returns the claims of the token sharing the link
+func (s *ShareLink) Claims() *Claims {
+	return &Claims{
+		JWT:       &jwt.JWT{Issuer: s.Issuer, Subject: s.Subject, Scope: s.Scope, ExpiresAt: s.Expires},
+		Namespace: s.Owner,
+		Owner:     s.Scope == "",
+	}
+}
+
+func (s *ShareLink) sign(key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(strings.Join([]string{
+		"phoenix-share",
+		s.ID,
+		s.Owner,
+		s.Store,
+		s.Bucket,
+		s.Path,
+		strconv.FormatInt(s.Expires, 10),
+		strconv.Itoa(s.MaxDownloads),
+		strings.Join(s.Chain, ","),
+		s.Issuer,
+		s.Subject,
+		s.Scope,
+	}, "\n")))
+	return mac.Sum(nil)
+}
+
+// Query returns the query of the link signed with the key
+func (s *ShareLink) Query(key []byte) url.Values {
+	query := url.Values{}
+	query.Set("id", s.ID)
+	query.Set("owner", s.Owner)
+	query.Set("store", s.Store)
+	query.Set("expires", strconv.FormatInt(s.Expires, 10))
+	if s.MaxDownloads > 0 {
+		query.Set("max", strconv.Itoa(s.MaxDownloads))
+	}
+	if len(s.Chain) > 0 {
+		query.Set("chain", strings.Join(s.Chain, ","))
+	}
+	if s.Scope != "" {
+		query.Set("iss", s.Issuer)
+		query.Set("sub", s.Subject)
+		query.Set("scope", s.Scope)
+	}
+	query.Set("signature", hex.EncodeToString(s.sign(key)))
+	return query
+}
+
+// Verify checks the link is signed with the key, and has not expired by the time
+func (s *ShareLink) Verify(key []byte, signature string, now time.Time) error {
+	sig, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(sig, s.sign(key)) {
+		return ErrShareLink
+	}
+	if now.Unix() >= s.Expires {
+		return errors.Wrapf(ErrShareLinkExpired, "expired at %d", s.Expires)
+	}
+	return nil
+}
+
+func NewShares(kv db.KVStore) Shares {
+	return &shares{
+		KVStore: kv,
+	}
+}
+
+func (m *shares) Redeem(s *ShareLink) error {
+	if s.MaxDownloads == 0 {
+		return nil
+	}
+	return m.Update(shareNamespace, []byte(s.Owner+"/"+s.ID), func(v []byte) ([]byte, error) {
+		downloads := 0
+		if v != nil {
+			var err error
+			if downloads, err = strconv.Atoi(string(v)); err != nil {
+				return nil, err
+			}
+		}
+		if downloads >= s.MaxDownloads {
+			return nil, errors.Wrapf(ErrShareLinkUsedUp, "downloaded %d times", downloads)
+		}
+		return []byte(strconv.Itoa(downloads + 1)), nil
+	})
+}
+
+func (m *shares) Refund(s *ShareLink) error {
+	if s.MaxDownloads == 0 {
+		return nil
+	}
+	return m.Update(shareNamespace, []byte(s.Owner+"/"+s.ID), func(v []byte) ([]byte, error) {
+		downloads := 0
+		if v != nil {
+			var err error
+			if downloads, err = strconv.Atoi(string(v)); err != nil {
+				return nil, err
+			}
+		}
+		if downloads > 0 {
+			downloads--
+		}
+		return []byte(strconv.Itoa(downloads)), nil
+	})
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iotexproject/phoenix/db"
+)
+
+func TestShareLink(t *testing.T) {
+	r := require.New(t)
+
+	testFile, err := ioutil.TempFile(os.TempDir(), "test-bolt")
+	path := testFile.Name()
+	r.NoError(err)
+	testFile.Close()
+	defer func() {
+		r.NoError(os.Remove(path))
+	}()
+
+	d := db.NewBoltDB(path)
+	ctx := context.Background()
+	r.NoError(d.Start(ctx))
+	defer func() {
+		r.NoError(d.Stop(ctx))
+	}()
+
+	key, err := ShareKey(d)
+	r.NoError(err)
+	r.Len(key, 32)
+	again, err := ShareKey(d)
+	r.NoError(err)
+	r.Equal(key, again)
+
+	now := time.Now()
+	link, err := NewShareLink("owner", "s3", "test", "dir/foo.txt", now.Add(time.Minute).Unix(), 2, []string{"leaf", "root"})
+	r.NoError(err)
+	r.Len(link.ID, 32)
+	query := link.Query(key)
+
+	parsed, err := ParseShareLink("test", "dir/foo.txt", query)
+	r.NoError(err)
+	r.Equal(link, parsed)
+	r.NoError(parsed.Verify(key, query.Get("signature"), now))
+	r.Equal(ErrShareLinkExpired, errors.Cause(parsed.Verify(key, query.Get("signature"), now.Add(time.Minute))))
+
+	// the link is bound to the object and its limit
+	other, err := ParseShareLink("test", "dir/bar.txt", query)
+	r.NoError(err)
+	r.Equal(ErrShareLink, other.Verify(key, query.Get("signature"), now))
+	query.Del("max")
+	unlimited, err := ParseShareLink("test", "dir/foo.txt", query)
+	r.NoError(err)
+	r.Equal(ErrShareLink, unlimited.Verify(key, query.Get("signature"), now))
+	r.Equal(ErrShareLink, link.Verify([]byte("another key"), query.Get("signature"), now))
+
+	// downloads are counted up to the limit
+	shares := NewShares(d)
+	r.NoError(shares.Redeem(link))
+	r.NoError(shares.Redeem(link))
+	r.Equal(ErrShareLinkUsedUp, errors.Cause(shares.Redeem(link)))
+	// a download failing is given back
+	r.NoError(shares.Refund(link))
+	r.NoError(shares.Redeem(link))
+	r.Equal(ErrShareLinkUsedUp, errors.Cause(shares.Redeem(link)))
+	for i := 0; i < 3; i++ {
+		r.NoError(shares.Redeem(unlimited))
+	}
+
+	// links shared by a trustee carry its claims, which can't be changed
+	link.Issuer, link.Subject, link.Scope = "0x04ab", "s3/test", "Read"
+	query = link.Query(key)
+	parsed, err = ParseShareLink("test", "dir/foo.txt", query)
+	r.NoError(err)
+	r.Equal(link, parsed)
+	r.NoError(parsed.Verify(key, query.Get("signature"), now))
+	r.False(parsed.Claims().Owner)
+	r.Equal("s3/test", parsed.Claims().Subject)
+	query.Set("scope", "Read,Update")
+	widened, err := ParseShareLink("test", "dir/foo.txt", query)
+	r.NoError(err)
+	r.Equal(ErrShareLink, widened.Verify(key, query.Get("signature"), now))
+	r.True(unlimited.Claims().Owner)
+
+	query.Del("id")
+	_, err = ParseShareLink("test", "dir/foo.txt", query)
+	r.Equal(ErrShareLink, errors.Cause(err))
+}
@@ -0,0 +1,434 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/iotexproject/phoenix/db"
+	"github.com/iotexproject/phoenix/json"
+)
+
+const (
+	subscriptionNamespace  = "subscription"
+	deliveryNamespace      = "delivery"
+	deliveryQueueNamespace = "deliveryqueue"
+)
+
+// status of deliveries
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// deliveryPruneInterval is how often deliveries delivered or failed are pruned
+const deliveryPruneInterval = time.Hour
+
+var (
+	// ErrSubscription is returned if the subscription is invalid
+	ErrSubscription = errors.New("invalid subscription")
+
+	// ErrSubscriptionNotFound is returned if the subscription doesn't exist
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+
+	// ErrPrivateAddress is returned if the subscription posts to a loopback, private or link-local address
+	ErrPrivateAddress = errors.New("events can't be posted to loopback, private or link-local addresses")
+
+	// privateNetworks are the networks not routed on the internet, besides loopback, link-local and unspecified
+	// addresses net.IP tells
+	privateNetworks = parseNetworks("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7")
+)
+
+type (
+	// Subscription is owner's webhook, events are posted to URL signed with Secret. Events, Store, Bucket and Prefix
+	// select the events posted, empty ones select all
+	Subscription struct {
+		ID        string   `json:"id"`
+		URL       string   `json:"url"`
+		Events    []string `json:"events,omitempty"`
+		Store     string   `json:"store,omitempty"`
+		Bucket    string   `json:"bucket,omitempty"`
+		Prefix    string   `json:"prefix,omitempty"`
+		Secret    string   `json:"secret,omitempty"`
+		CreatedAt int64    `json:"createdAt"`
+	}
+
+	// Delivery is an event posted to a subscription. Pending deliveries are queued until they are delivered, or
+	// have failed the attempts allowed
+	Delivery struct {
+		ID           string `json:"id"`
+		Owner        string `json:"-"`
+		Subscription string `json:"subscription"`
+		Event        string `json:"event"`
+		URL          string `json:"url"`
+		Payload      string `json:"payload"`
+		Status       string `json:"status"`
+		Attempts     int    `json:"attempts"`
+		NextAt       int64  `json:"nextAt,omitempty"`
+		Response     int    `json:"response,omitempty"` // status of the last attempt
+		Error        string `json:"error,omitempty"`
+		CreatedAt    int64  `json:"createdAt"`
+		DeliveredAt  int64  `json:"deliveredAt,omitempty"`
+	}
+
+	// queuedDelivery is a pending delivery with its owner
+	queuedDelivery struct {
+		*Delivery
+		Owner string `json:"owner"`
+	}
+
+	Subscriptions interface {
+		// PutSubscription puts owner's new subscription into db, the ID and secret are assigned
+		PutSubscription(string, *Subscription) error
+
+		// GetSubscription returns owner's subscription according to its ID
+		GetSubscription(string, string) (*Subscription, error)
+
+		// DelSubscription removes owner's subscription, its pending deliveries fail
+		DelSubscription(string, string) error
+
+		// ListSubscriptions returns owner's subscriptions without their secrets
+		ListSubscriptions(string) ([]*Subscription, error)
+
+		// Subscribed returns owner's subscriptions selecting the event of the object, or bucket if path is empty
+		Subscribed(namespace, event, store, bucket, path string) ([]*Subscription, error)
+	}
+
+	Deliveries interface {
+		// Enqueue queues the delivery of owner's event to the subscription
+		Enqueue(string, *Subscription, string, []byte) (*Delivery, error)
+
+		// Due returns the pending deliveries of all owners due by the time
+		Due(int64) ([]*Delivery, error)
+
+		// Done records the attempt of the delivery, it is dequeued unless it is pending still
+		Done(*Delivery) error
+
+		// Deliveries returns owner's deliveries, of the subscription and with the status if given, latest first
+		Deliveries(namespace, subscription, status string) ([]*Delivery, error)
+	}
+
+	subscriptions struct {
+		db.KVStore
+	}
+
+	deliveries struct {
+		db.KVStore
+		retention time.Duration
+
+		mu       sync.Mutex
+		prunedAt time.Time
+	}
+)
+
+func parseNetworks(blocks ...string) []*net.IPNet {
+	networks := []*net.IPNet{}
+	for _, block := range blocks {
+		_, network, err := net.ParseCIDR(block)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// PublicIP returns true if the address is routed on the internet, events are only posted to public addresses
+// unless private ones are allowed
+func PublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, network := range privateNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Normalize validates the subscription, and trims the slashes of its prefix. Unless private addresses are allowed,
+// the URL can't be of localhost or a loopback, private or link-local address; host names resolved to those are
+// refused when events are posted
+func (s *Subscription) Normalize(events []string, allowPrivate bool) error {
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.Wrapf(ErrSubscription, "url %s must be http or https", s.URL)
+	}
+	if host := strings.ToLower(strings.TrimSuffix(u.Hostname(), ".")); !allowPrivate {
+		ip := net.ParseIP(host)
+		if host == "localhost" || strings.HasSuffix(host, ".localhost") || (ip != nil && !PublicIP(ip)) {
+			return errors.Wrapf(ErrSubscription, "url %s: %s", s.URL, ErrPrivateAddress)
+		}
+	}
+	for _, e := range s.Events {
+		known := false
+		for _, k := range events {
+			known = known || e == k
+		}
+		if !known {
+			return errors.Wrapf(ErrSubscription, "unknown event %s", e)
+		}
+	}
+	if strings.Contains(s.Store, "/") || strings.Contains(s.Bucket, "/") {
+		return errors.Wrap(ErrSubscription, "store and bucket can't contain /")
+	}
+	s.Prefix = strings.TrimLeft(s.Prefix, "/")
+	return nil
+}
+
+// Matches returns true if the subscription selects the event of the object, or bucket if path is empty
+func (s *Subscription) Matches(event, store, bucket, path string) bool {
+	selected := len(s.Events) == 0
+	for _, e := range s.Events {
+		selected = selected || e == event
+	}
+	switch {
+	case !selected:
+		return false
+	case s.Store != "" && s.Store != store:
+		return false
+	case s.Bucket != "" && s.Bucket != bucket:
+		return false
+	}
+	return s.Prefix == "" || strings.HasPrefix(path, s.Prefix)
+}
+
+// Sign returns the signature of the payload posted at the time, the hex of HMAC-SHA256 of "time.payload" keyed
+// by the secret
+func (s *Subscription) Sign(t int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(s.Secret))
+	mac.Write([]byte(strconv.FormatInt(t, 10) + "."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func NewSubscriptions(kv db.KVStore) Subscriptions {
+	return &subscriptions{
+		KVStore: kv,
+	}
+}
+
+func (m *subscriptions) PutSubscription(namespace string, s *Subscription) error {
+	id, err := randomHex(16)
+	if err != nil {
+		return err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return err
+	}
+	s.ID, s.Secret, s.CreatedAt = id, secret, time.Now().Unix()
+	v, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return m.Put(subscriptionNamespace, []byte(namespace+"/"+s.ID), v)
+}
+
+func (m *subscriptions) GetSubscription(namespace, id string) (*Subscription, error) {
+	v, err := m.Get(subscriptionNamespace, []byte(namespace+"/"+id))
+	switch errors.Cause(err) {
+	case nil:
+		break
+	case db.ErrBucketNotExist, db.ErrNotExist:
+		return nil, errors.Wrapf(ErrSubscriptionNotFound, "subscription %s", id)
+	default:
+		return nil, err
+	}
+	s := &Subscription{}
+	if err := json.Unmarshal(v, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (m *subscriptions) DelSubscription(namespace, id string) error {
+	if _, err := m.GetSubscription(namespace, id); err != nil {
+		return err
+	}
+	return m.Delete(subscriptionNamespace, []byte(namespace+"/"+id))
+}
+
+func (m *subscriptions) ListSubscriptions(namespace string) ([]*Subscription, error) {
+	list, err := m.list(namespace)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range list {
+		s.Secret = ""
+	}
+	return list, nil
+}
+
+func (m *subscriptions) Subscribed(namespace, event, store, bucket, path string) ([]*Subscription, error) {
+	all, err := m.list(namespace)
+	if err != nil {
+		return nil, err
+	}
+	list := []*Subscription{}
+	for _, s := range all {
+		if s.Matches(event, store, bucket, path) {
+			list = append(list, s)
+		}
+	}
+	return list, nil
+}
+
+func (m *subscriptions) list(namespace string) ([]*Subscription, error) {
+	_, values, err := m.List(subscriptionNamespace, []byte(namespace+"/"))
+	if err != nil {
+		return nil, err
+	}
+	list := []*Subscription{}
+	for _, v := range values {
+		s := &Subscription{}
+		if err := json.Unmarshal(v, s); err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+// NewDeliveries returns the delivery queue and log, deliveries delivered or failed are kept for the retention
+func NewDeliveries(kv db.KVStore, retention time.Duration) Deliveries {
+	return &deliveries{
+		KVStore:   kv,
+		retention: retention,
+		prunedAt:  time.Now(),
+	}
+}
+
+// deliveryKey orders owner's deliveries by the time they are created
+func deliveryKey(namespace string, d *Delivery) []byte {
+	return []byte(fmt.Sprintf("%s/%012d/%s", namespace, d.CreatedAt, d.ID))
+}
+
+func (m *deliveries) Enqueue(namespace string, s *Subscription, event string, payload []byte) (*Delivery, error) {
+	id, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	d := &Delivery{
+		ID:           id,
+		Owner:        namespace,
+		Subscription: s.ID,
+		Event:        event,
+		URL:          s.URL,
+		Payload:      string(payload),
+		Status:       DeliveryPending,
+		NextAt:       now,
+		CreatedAt:    now,
+	}
+	if err := m.Done(d); err != nil {
+		return nil, err
+	}
+	if err := m.prune(time.Now()); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// prune removes the deliveries delivered or failed before the retention, once every deliveryPruneInterval for the
+// cost to spread over the deliveries queued meanwhile
+func (m *deliveries) prune(now time.Time) error {
+	m.mu.Lock()
+	if now.Sub(m.prunedAt) < deliveryPruneInterval {
+		m.mu.Unlock()
+		return nil
+	}
+	m.prunedAt = now
+	m.mu.Unlock()
+
+	keys, values, err := m.List(deliveryNamespace, nil)
+	if err != nil {
+		return err
+	}
+	before := now.Add(-m.retention).Unix()
+	return m.Batch(func(tx db.Tx) error {
+		for i, key := range keys {
+			d := &Delivery{}
+			if err := json.Unmarshal(values[i], d); err == nil && (d.Status == DeliveryPending || d.CreatedAt >= before) {
+				continue
+			}
+			if err := tx.Delete(deliveryNamespace, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (m *deliveries) Due(t int64) ([]*Delivery, error) {
+	_, values, err := m.List(deliveryQueueNamespace, nil)
+	if err != nil {
+		return nil, err
+	}
+	list := []*Delivery{}
+	for _, v := range values {
+		q := &queuedDelivery{Delivery: &Delivery{}}
+		if err := json.Unmarshal(v, q); err != nil {
+			return nil, err
+		}
+		if q.NextAt <= t {
+			q.Delivery.Owner = q.Owner
+			list = append(list, q.Delivery)
+		}
+	}
+	return list, nil
+}
+
+func (m *deliveries) Done(d *Delivery) error {
+	v, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	key := deliveryKey(d.Owner, d)
+	if err := m.Put(deliveryNamespace, key, v); err != nil {
+		return err
+	}
+	if d.Status != DeliveryPending {
+		return m.Delete(deliveryQueueNamespace, key)
+	}
+	if v, err = json.Marshal(&queuedDelivery{Delivery: d, Owner: d.Owner}); err != nil {
+		return err
+	}
+	return m.Put(deliveryQueueNamespace, key, v)
+}
+
+func (m *deliveries) Deliveries(namespace, subscription, status string) ([]*Delivery, error) {
+	_, values, err := m.List(deliveryNamespace, []byte(namespace+"/"))
+	if err != nil {
+		return nil, err
+	}
+	list := []*Delivery{}
+	for i := len(values) - 1; i >= 0; i-- {
+		d := &Delivery{}
+		if err := json.Unmarshal(values[i], d); err != nil {
+			return nil, err
+		}
+		if (subscription == "" || d.Subscription == subscription) && (status == "" || d.Status == status) {
+			d.Owner = namespace
+			list = append(list, d)
+		}
+	}
+	return list, nil
+}
@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iotexproject/phoenix/db"
+)
+
+func TestSubscriptions(t *testing.T) {
+	r := require.New(t)
+
+	testFile, err := ioutil.TempFile(os.TempDir(), "test-bolt")
+	path := testFile.Name()
+	r.NoError(err)
+	testFile.Close()
+	defer func() {
+		r.NoError(os.Remove(path))
+	}()
+
+	d := db.NewBoltDB(path)
+	ctx := context.Background()
+	r.NoError(d.Start(ctx))
+	defer func() {
+		r.NoError(d.Stop(ctx))
+	}()
+
+	events := []string{"object.created", "object.deleted"}
+	r.Equal(ErrSubscription, errors.Cause((&Subscription{URL: "ftp://example.com"}).Normalize(events, false)))
+	r.Equal(ErrSubscription, errors.Cause((&Subscription{URL: "https://example.com", Events: []string{"bucket.created"}}).Normalize(events, false)))
+	// events aren't posted to internal hosts, unless allowed
+	for _, u := range []string{"http://localhost:8080", "http://127.0.0.1/hook", "http://[::1]/hook", "http://10.0.0.1",
+		"http://172.20.1.1", "http://192.168.1.1", "http://169.254.169.254/latest", "http://0.0.0.0", "http://[fd00::1]"} {
+		r.Equal(ErrSubscription, errors.Cause((&Subscription{URL: u}).Normalize(events, false)), u)
+		r.NoError((&Subscription{URL: u}).Normalize(events, true), u)
+	}
+	r.NoError((&Subscription{URL: "http://93.184.216.34/hook"}).Normalize(events, false))
+	r.True(PublicIP(net.ParseIP("8.8.8.8")))
+	r.False(PublicIP(net.ParseIP("100.64.0.1")))
+	s := &Subscription{URL: "https://example.com/hook", Events: []string{"object.created"}, Bucket: "reports", Prefix: "/2020/"}
+	r.NoError(s.Normalize(events, false))
+	r.Equal("2020/", s.Prefix)
+	r.True(s.Matches("object.created", "s3", "reports", "2020/q1.csv"))
+	r.False(s.Matches("object.deleted", "s3", "reports", "2020/q1.csv"))
+	r.False(s.Matches("object.created", "s3", "results", "2020/q1.csv"))
+	r.False(s.Matches("object.created", "s3", "reports", "2021/q1.csv"))
+
+	m := NewSubscriptions(d)
+	r.NoError(m.PutSubscription("owner", s))
+	r.NoError(m.PutSubscription("owner", &Subscription{URL: "https://example.com/all"}))
+	r.Len(s.Secret, 64)
+	list, err := m.ListSubscriptions("owner")
+	r.NoError(err)
+	r.Len(list, 2)
+	for _, l := range list {
+		r.Empty(l.Secret)
+	}
+	subscribed, err := m.Subscribed("owner", "object.created", "s3", "reports", "2020/q1.csv")
+	r.NoError(err)
+	r.Len(subscribed, 2)
+	subscribed, err = m.Subscribed("owner", "object.deleted", "s3", "reports", "2020/q1.csv")
+	r.NoError(err)
+	r.Len(subscribed, 1)
+	r.Equal("https://example.com/all", subscribed[0].URL)
+	r.NotEqual(s.Sign(1, []byte("{}")), s.Sign(2, []byte("{}")))
+
+	// pending deliveries are queued until done
+	q := NewDeliveries(d, time.Hour)
+	delivery, err := q.Enqueue("owner", s, "object.created", []byte(`{"event": "object.created"}`))
+	r.NoError(err)
+	r.Equal(DeliveryPending, delivery.Status)
+	due, err := q.Due(time.Now().Unix())
+	r.NoError(err)
+	r.Len(due, 1)
+	r.Equal("owner", due[0].Owner)
+	due[0].Attempts, due[0].NextAt = 1, time.Now().Add(time.Minute).Unix()
+	r.NoError(q.Done(due[0]))
+	due, err = q.Due(time.Now().Unix())
+	r.NoError(err)
+	r.Len(due, 0)
+	due, err = q.Due(time.Now().Add(time.Minute).Unix())
+	r.NoError(err)
+	r.Len(due, 1)
+	due[0].Status = DeliveryDelivered
+	r.NoError(q.Done(due[0]))
+	due, err = q.Due(time.Now().Add(time.Hour).Unix())
+	r.NoError(err)
+	r.Len(due, 0)
+	log, err := q.Deliveries("owner", s.ID, DeliveryDelivered)
+	r.NoError(err)
+	r.Len(log, 1)
+	r.Equal(1, log[0].Attempts)
+	log, err = q.Deliveries("owner", "", DeliveryFailed)
+	r.NoError(err)
+	r.Len(log, 0)
+
+	// deliveries done before the retention are pruned, pending ones are kept
+	pending, err := q.Enqueue("owner", s, "object.deleted", []byte(`{"event": "object.deleted"}`))
+	r.NoError(err)
+	q.(*deliveries).prunedAt = time.Now().Add(-deliveryPruneInterval)
+	r.NoError(q.(*deliveries).prune(time.Now().Add(2 * time.Hour)))
+	log, err = q.Deliveries("owner", "", "")
+	r.NoError(err)
+	r.Len(log, 1)
+	r.Equal(pending.ID, log[0].ID)
+
+	r.NoError(m.DelSubscription("owner", s.ID))
+	r.Equal(ErrSubscriptionNotFound, errors.Cause(m.DelSubscription("owner", s.ID)))
+}
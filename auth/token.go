@@ -0,0 +1,209 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/iotexproject/go-pkgs/crypto"
+	"github.com/iotexproject/iotex-antenna-go/v2/jwt"
+	"github.com/pkg/errors"
+)
+
+type (
+	// Extension is the optional claims phoenix supports on top of the iotex JWT
+	Extension struct {
+		// MaxUses is the maximum number of times the token can be used
+		MaxUses uint64 `json:"maxUses,omitempty"`
+		// OpUses is the maximum number of times per operation, keyed by scope (Create, Read, Update, Delete)
+		OpUses map[string]uint64 `json:"opUses,omitempty"`
+		// Holder is the public key of the trustee the token is issued to, who can delegate it further
+		Holder string `json:"holder,omitempty"`
+		// ParentToken is the raw token this one is delegated from, signed by the parent's holder
+		ParentToken string `json:"parent,omitempty"`
+		// CIDRs are the client networks the token can be used from
+		CIDRs []string `json:"cidrs,omitempty"`
+		// UserAgents are the prefixes of user agents the token can be used with
+		UserAgents []string `json:"userAgents,omitempty"`
+		// Windows are the times of day the token can be used in
+		Windows []TimeWindow `json:"windows,omitempty"`
+		// NotBefore is the time the token can be used since, signed as the standard claim nbf
+		NotBefore int64 `json:"-"`
+		// Quota limits the bytes and objects stored, and the bytes transferred with the token
+		Quota *Quota `json:"quota,omitempty"`
+	}
+
+	signedClaims struct {
+		jwtgo.StandardClaims
+		Scope string
+		Extension
+	}
+)
+
+// MaxDelegationDepth is the maximum number of links in a delegation chain
+const MaxDelegationDepth = 8
+
+// ErrDelegation is returned if a delegated token is not attenuated from its parent
+var ErrDelegation = errors.New("invalid delegation")
+
+// NewClaims verifies the jwt string and parses its claims, a delegated token is verified
+// along the whole chain back to the token issued by the owner. The issuer is resolved by KeyResolver
+func NewClaims(jwtString string) (*Claims, error) {
+	return newClaims(jwtString, KeyResolver, 0)
+}
+
+// NewClaimsWithResolver is NewClaims with the issuer resolved by resolver
+func NewClaimsWithResolver(jwtString string, resolver IssuerResolver) (*Claims, error) {
+	return newClaims(jwtString, resolver, 0)
+}
+
+func newClaims(jwtString string, resolver IssuerResolver, depth int) (*Claims, error) {
+	if depth >= MaxDelegationDepth {
+		return nil, errors.Wrap(ErrDelegation, "delegation chain is too long")
+	}
+	claim := &signedClaims{}
+	var issuer *Issuer
+	token, err := jwtgo.ParseWithClaims(jwtString, claim, func(token *jwtgo.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		var err error
+		if issuer, err = resolver.Resolve(claim.Issuer, kid); err != nil {
+			return nil, err
+		}
+		if m := signingMethodFor(issuer.Key); m == nil || m.Alg() != token.Method.Alg() {
+			return nil, errors.Wrapf(ErrIssuer, "alg %s doesn't match issuer key", token.Method.Alg())
+		}
+		return issuer.Key, nil
+	})
+	if ve, ok := err.(*jwtgo.ValidationError); ok && ve.Errors == jwtgo.ValidationErrorNotValidYet {
+		return nil, errors.Wrapf(ErrNotYetValid, "token is valid since %s", time.Unix(claim.StandardClaims.NotBefore, 0).UTC().Format(time.RFC3339))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid || len(token.Header) < 2 {
+		// should not happen with a success parsing, check anyway
+		return nil, errors.New("invalid token")
+	}
+	sig, err := jwtgo.DecodeSegment(token.Signature)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Claims{
+		JWT: &jwt.JWT{
+			IssuedAt:   claim.IssuedAt,
+			ExpiresAt:  claim.ExpiresAt,
+			Issuer:     claim.Issuer,
+			Subject:    claim.Subject,
+			Scope:      claim.Scope,
+			SignMethod: token.Method.Alg(),
+			SigHex:     hex.EncodeToString(sig),
+		},
+		Extension: claim.Extension,
+		Token:     jwtString,
+		Namespace: issuer.Namespace,
+	}
+	c.NotBefore = claim.StandardClaims.NotBefore
+	if c.ParentToken == "" {
+		return c, nil
+	}
+	if c.Parent, err = newClaims(c.ParentToken, resolver, depth+1); err != nil {
+		return nil, err
+	}
+	if err := c.attenuates(c.Parent); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// attenuates checks the token is signed by the parent's holder and never grants more than the parent
+func (c *Claims) attenuates(parent *Claims) error {
+	if parent.Holder == "" || (parent.Holder != c.Issuer && !SameKey(parent.Holder, c.Issuer)) {
+		return errors.Wrap(ErrDelegation, "token is not signed by the holder of its parent")
+	}
+	for _, op := range Ops {
+		if c.Allow(op) && !parent.Allow(op) {
+			return errors.Wrapf(ErrDelegation, "scope %s is not granted by parent", op)
+		}
+	}
+	if c.Store() != parent.Store() || !resourceWithin(c.Resource(), parent.Resource()) {
+		return errors.Wrapf(ErrDelegation, "subject %s is not within parent subject %s", c.Subject, parent.Subject)
+	}
+	if parent.ExpiresAt != 0 && (c.ExpiresAt == 0 || c.ExpiresAt > parent.ExpiresAt) {
+		return errors.Wrap(ErrDelegation, "token expires after its parent")
+	}
+	return nil
+}
+
+// SameKey returns true if both hex strings are the same public key
+func SameKey(a, b string) bool {
+	ka, err := crypto.HexStringToPublicKey(a)
+	if err != nil {
+		return false
+	}
+	kb, err := crypto.HexStringToPublicKey(b)
+	if err != nil {
+		return false
+	}
+	return ka.HexString() == kb.HexString()
+}
+
+// SignToken creates a JWT carrying the optional claims, signed by the secp256k1 key
+func SignToken(issue, expire int64, subject, scope string, ext Extension, key crypto.PrivateKey) (string, error) {
+	return SignTokenWithKey("0x"+key.PublicKey().HexString(), "", issue, expire, subject, scope, ext, key.EcdsaPrivateKey())
+}
+
+// SignTokenWithKey creates a JWT for the issuer and key ID, signed by a *ecdsa.PrivateKey or ed25519.PrivateKey
+func SignTokenWithKey(iss, kid string, issue, expire int64, subject, scope string, ext Extension, key interface{}) (string, error) {
+	return signClaims(kid, &signedClaims{
+		StandardClaims: jwtgo.StandardClaims{
+			ExpiresAt: expire,
+			IssuedAt:  issue,
+			Issuer:    iss,
+			NotBefore: ext.NotBefore,
+			Subject:   subject,
+		},
+		Scope:     scope,
+		Extension: ext,
+	}, key)
+}
+
+func signClaims(kid string, c *signedClaims, key interface{}) (string, error) {
+	method := signingMethodFor(key)
+	if method == nil {
+		return "", jwtgo.ErrInvalidKeyType
+	}
+	token := jwtgo.NewWithClaims(method, c)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	return token.SignedString(key)
+}
+
+// Hash returns the hex-encoded sha256 hash of the raw token
+func (c *Claims) Hash() string {
+	h := sha256.Sum256([]byte(c.Token))
+	return hex.EncodeToString(h[:])
+}
+
+// Chain returns the claims of the token and all its ancestors, starting from the token itself
+func (c *Claims) Chain() []*Claims {
+	chain := []*Claims{c}
+	for c.Parent != nil {
+		c = c.Parent
+		chain = append(chain, c)
+	}
+	return chain
+}
+
+// Limited returns true if the token carries a usage limit
+func (c *Claims) Limited() bool {
+	return c.MaxUses > 0 || len(c.OpUses) > 0
+}
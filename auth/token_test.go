@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iotexproject/go-pkgs/crypto"
+	"github.com/iotexproject/iotex-antenna-go/v2/jwt"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewClaims(t *testing.T) {
+	r := require.New(t)
+
+	key, err := crypto.GenerateKey()
+	r.NoError(err)
+	issue := time.Now().Unix()
+	expire := time.Now().Add(time.Hour).Unix()
+
+	// token signed by ioctl carries no extension
+	tok, err := jwt.SignJWT(issue, expire, "s3", jwt.READ, key)
+	r.NoError(err)
+	c, err := NewClaims(tok)
+	r.NoError(err)
+	r.Equal("s3", c.Subject)
+	r.True(c.AllowRead())
+	r.False(c.Limited())
+	r.Equal(tok, c.Token)
+	r.Len(c.Hash(), 64)
+
+	ext := Extension{
+		MaxUses: 100,
+		OpUses:  map[string]uint64{jwt.READ: 1},
+	}
+	tok, err = SignToken(issue, expire, "s3", jwt.READ, ext, key)
+	r.NoError(err)
+	c, err = NewClaims(tok)
+	r.NoError(err)
+	r.Equal("0x"+key.PublicKey().HexString(), c.Issuer)
+	r.Equal(ext, c.Extension)
+	r.True(c.Limited())
+
+	_, err = NewClaims(tok + "x")
+	r.Error(err)
+}
+
+func TestDelegation(t *testing.T) {
+	r := require.New(t)
+
+	owner, err := crypto.GenerateKey()
+	r.NoError(err)
+	trustee, err := crypto.GenerateKey()
+	r.NoError(err)
+	sub, err := crypto.GenerateKey()
+	r.NoError(err)
+	issue := time.Now().Unix()
+	expire := time.Now().Add(time.Hour).Unix()
+
+	root, err := SignToken(issue, expire, "s3/reports", jwt.READ+","+jwt.UPDATE, Extension{
+		Holder: trustee.PublicKey().HexString(),
+	}, owner)
+	r.NoError(err)
+
+	delegate := func(exp int64, subject, scope string, key crypto.PrivateKey) error {
+		tok, err := SignToken(issue, exp, subject, scope, Extension{ParentToken: root}, key)
+		r.NoError(err)
+		_, err = NewClaims(tok)
+		return err
+	}
+	r.NoError(delegate(expire-1, "s3/reports/2020", jwt.READ, trustee))
+	// only the holder can delegate
+	r.Equal(ErrDelegation, errors.Cause(delegate(expire-1, "s3/reports", jwt.READ, sub)))
+	// scope, subject and expiry can't be widened
+	r.Equal(ErrDelegation, errors.Cause(delegate(expire-1, "s3/reports", jwt.DELETE, trustee)))
+	r.Equal(ErrDelegation, errors.Cause(delegate(expire-1, "s3", jwt.READ, trustee)))
+	r.Equal(ErrDelegation, errors.Cause(delegate(expire-1, "s3/reports2020", jwt.READ, trustee)))
+	r.Equal(ErrDelegation, errors.Cause(delegate(expire+1, "s3/reports", jwt.READ, trustee)))
+	r.Equal(ErrDelegation, errors.Cause(delegate(0, "s3/reports", jwt.READ, trustee)))
+
+	// two-link chain
+	mid, err := SignToken(issue, expire, "s3/reports/2020", jwt.READ, Extension{
+		ParentToken: root,
+		Holder:      sub.PublicKey().HexString(),
+	}, trustee)
+	r.NoError(err)
+	leaf, err := SignToken(issue, expire, "s3/reports/2020/q1", jwt.READ, Extension{ParentToken: mid}, sub)
+	r.NoError(err)
+	c, err := NewClaims(leaf)
+	r.NoError(err)
+	r.Len(c.Chain(), 3)
+	r.Equal("0x"+owner.PublicKey().HexString(), c.Root().Issuer)
+	r.Equal("s3", c.Store())
+	r.True(c.Covers("reports", "2020/q1/data.csv"))
+	r.False(c.Covers("reports", "2020/q2/data.csv"))
+	r.False(c.Covers("", ""))
+}
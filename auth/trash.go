@@ -0,0 +1,275 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/iotexproject/phoenix/db"
+	"github.com/iotexproject/phoenix/json"
+)
+
+const (
+	recycleBinNamespace  = "recyclebin"
+	trashNamespace       = "trash"
+	trashBucketNamespace = "trashbucket"
+)
+
+// TrashPrefix is the hidden folder of a bucket objects in the recycle bin are moved to
+const TrashPrefix = ".phoenix-trash"
+
+var (
+	// ErrRecycleBin is returned if the recycle bin is invalid
+	ErrRecycleBin = errors.New("invalid recycle bin")
+
+	// ErrTrashItemNotFound is returned if the item is not in the recycle bin
+	ErrTrashItemNotFound = errors.New("trash item not found")
+)
+
+type (
+	// RecycleBin keeps the objects and buckets deleted from a store for Days, before they are purged
+	RecycleBin struct {
+		Store string `json:"store"`
+		Days  int    `json:"days"`
+	}
+
+	// TrashItem is an object, or a bucket if Path is empty, in the recycle bin until ExpiresAt
+	TrashItem struct {
+		ID        string `json:"id"`
+		Owner     string `json:"-"`
+		Store     string `json:"store"`
+		Bucket    string `json:"bucket"`
+		Path      string `json:"path,omitempty"`
+		Size      int64  `json:"size,omitempty"`
+		DeletedBy string `json:"deletedBy,omitempty"`
+		DeletedAt int64  `json:"deletedAt"`
+		ExpiresAt int64  `json:"expiresAt"`
+	}
+
+	Trash interface {
+		// PutBin puts the recycle bin of owner's store into db
+		PutBin(string, *RecycleBin) error
+
+		// GetBin returns the recycle bin of owner's store, nil if the store has none
+		GetBin(string, string) (*RecycleBin, error)
+
+		// DelBin removes the recycle bin of owner's store, the items in it are kept until they expire
+		DelBin(string, string) error
+
+		// Add puts the item into owner's recycle bin, it is assigned an ID
+		Add(string, *TrashItem) error
+
+		// Item returns the item in owner's recycle bin according to its ID
+		Item(string, string) (*TrashItem, error)
+
+		// Remove takes the item out of owner's recycle bin, once it is restored or purged
+		Remove(string, string) error
+
+		// Items returns the items in owner's recycle bin, or those of all owners if namespace is empty
+		Items(namespace string) ([]*TrashItem, error)
+
+		// Bucket returns the item of owner's bucket if the bucket is in the recycle bin, nil otherwise
+		Bucket(namespace, store, bucket string) (*TrashItem, error)
+
+		// Expired returns the items in owner's recycle bin expired by the time, or those of all owners if namespace
+		// is empty
+		Expired(namespace string, t int64) ([]*TrashItem, error)
+
+		// Forget removes the items of owner's bucket, or those of the store if bucket is empty
+		Forget(namespace, store, bucket string) error
+	}
+
+	trash struct {
+		db.KVStore
+	}
+)
+
+// Validate checks the recycle bin keeps items for some days
+func (bin *RecycleBin) Validate() error {
+	if bin.Days <= 0 {
+		return errors.Wrap(ErrRecycleBin, "days must be positive")
+	}
+	return nil
+}
+
+// Retention returns how long items are kept in the recycle bin
+func (bin *RecycleBin) Retention() time.Duration {
+	return time.Duration(bin.Days) * 24 * time.Hour
+}
+
+// TrashPath returns the path the object in the recycle bin is moved to, empty for a bucket
+func (item *TrashItem) TrashPath() string {
+	if item.Path == "" {
+		return ""
+	}
+	return TrashPrefix + "/" + item.ID
+}
+
+// IsTrashPath returns true if the path is in the hidden folder of the recycle bin
+func IsTrashPath(path string) bool {
+	return path == TrashPrefix || strings.HasPrefix(path, TrashPrefix+"/")
+}
+
+// TrashID returns the ID of the item moved to the path of the recycle bin
+func TrashID(path string) string {
+	return strings.TrimPrefix(path, TrashPrefix+"/")
+}
+
+func NewTrash(kv db.KVStore) Trash {
+	return &trash{
+		KVStore: kv,
+	}
+}
+
+func (m *trash) PutBin(namespace string, bin *RecycleBin) error {
+	v, err := json.Marshal(bin)
+	if err != nil {
+		return err
+	}
+	return m.Put(recycleBinNamespace, []byte(namespace+"/"+bin.Store), v)
+}
+
+func (m *trash) GetBin(namespace, store string) (*RecycleBin, error) {
+	v, err := m.Get(recycleBinNamespace, []byte(namespace+"/"+store))
+	switch errors.Cause(err) {
+	case nil:
+		break
+	case db.ErrBucketNotExist, db.ErrNotExist:
+		return nil, nil
+	default:
+		return nil, err
+	}
+	bin := &RecycleBin{}
+	if err := json.Unmarshal(v, bin); err != nil {
+		return nil, err
+	}
+	return bin, nil
+}
+
+func (m *trash) DelBin(namespace, store string) error {
+	return m.Delete(recycleBinNamespace, []byte(namespace+"/"+store))
+}
+
+func trashBucketKey(namespace, store, bucket string) []byte {
+	return []byte(strings.Join([]string{namespace, store, bucket}, "/"))
+}
+
+func (m *trash) Add(namespace string, item *TrashItem) error {
+	id, err := randomHex(16)
+	if err != nil {
+		return err
+	}
+	item.ID, item.Owner = id, namespace
+	if item.DeletedAt == 0 {
+		item.DeletedAt = time.Now().Unix()
+	}
+	v, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	if item.Path == "" {
+		if err := m.Put(trashBucketNamespace, trashBucketKey(namespace, item.Store, item.Bucket), []byte(item.ID)); err != nil {
+			return err
+		}
+	}
+	return m.Put(trashNamespace, []byte(namespace+"/"+item.ID), v)
+}
+
+func (m *trash) Item(namespace, id string) (*TrashItem, error) {
+	v, err := m.Get(trashNamespace, []byte(namespace+"/"+id))
+	switch errors.Cause(err) {
+	case nil:
+		break
+	case db.ErrBucketNotExist, db.ErrNotExist:
+		return nil, errors.Wrapf(ErrTrashItemNotFound, "trash item %s", id)
+	default:
+		return nil, err
+	}
+	item := &TrashItem{Owner: namespace}
+	if err := json.Unmarshal(v, item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (m *trash) Remove(namespace, id string) error {
+	item, err := m.Item(namespace, id)
+	switch errors.Cause(err) {
+	case nil:
+		break
+	case ErrTrashItemNotFound:
+		return nil
+	default:
+		return err
+	}
+	if item.Path == "" {
+		if err := m.Delete(trashBucketNamespace, trashBucketKey(namespace, item.Store, item.Bucket)); err != nil {
+			return err
+		}
+	}
+	return m.Delete(trashNamespace, []byte(namespace+"/"+id))
+}
+
+func (m *trash) Items(namespace string) ([]*TrashItem, error) {
+	keys, values, err := m.List(trashNamespace, ownerPrefix(namespace))
+	if err != nil {
+		return nil, err
+	}
+	list := []*TrashItem{}
+	for i, v := range values {
+		item := &TrashItem{Owner: keyOwner(keys[i])}
+		if err := json.Unmarshal(v, item); err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+	}
+	return list, nil
+}
+
+func (m *trash) Bucket(namespace, store, bucket string) (*TrashItem, error) {
+	v, err := m.Get(trashBucketNamespace, trashBucketKey(namespace, store, bucket))
+	switch errors.Cause(err) {
+	case nil:
+		return m.Item(namespace, string(v))
+	case db.ErrBucketNotExist, db.ErrNotExist:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+func (m *trash) Expired(namespace string, t int64) ([]*TrashItem, error) {
+	items, err := m.Items(namespace)
+	if err != nil {
+		return nil, err
+	}
+	list := []*TrashItem{}
+	for _, item := range items {
+		if item.ExpiresAt <= t {
+			list = append(list, item)
+		}
+	}
+	return list, nil
+}
+
+func (m *trash) Forget(namespace, store, bucket string) error {
+	items, err := m.Items(namespace)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if item.Store == store && (bucket == "" || item.Bucket == bucket) {
+			if err := m.Remove(namespace, item.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iotexproject/phoenix/db"
+)
+
+func TestTrash(t *testing.T) {
+	r := require.New(t)
+
+	testFile, err := ioutil.TempFile(os.TempDir(), "test-bolt")
+	path := testFile.Name()
+	r.NoError(err)
+	testFile.Close()
+	defer func() {
+		r.NoError(os.Remove(path))
+	}()
+
+	d := db.NewBoltDB(path)
+	ctx := context.Background()
+	r.NoError(d.Start(ctx))
+	defer func() {
+		r.NoError(d.Stop(ctx))
+	}()
+
+	r.Equal(ErrRecycleBin, errors.Cause((&RecycleBin{Store: "s3"}).Validate()))
+	r.True(IsTrashPath(TrashPrefix))
+	r.True(IsTrashPath(TrashPrefix + "/abc"))
+	r.False(IsTrashPath(TrashPrefix + "-not/abc"))
+	r.False(IsTrashPath("docs/" + TrashPrefix))
+
+	m := NewTrash(d)
+	bin, err := m.GetBin("owner1", "s3")
+	r.NoError(err)
+	r.Nil(bin)
+	r.NoError(m.PutBin("owner1", &RecycleBin{Store: "s3", Days: 7}))
+	bin, err = m.GetBin("owner1", "s3")
+	r.NoError(err)
+	r.Equal(7, bin.Days)
+	r.NoError(bin.Validate())
+
+	object := &TrashItem{Store: "s3", Bucket: "docs", Path: "a/b.txt", Size: 3, ExpiresAt: 100}
+	r.NoError(m.Add("owner1", object))
+	r.Len(object.ID, 32)
+	r.NotZero(object.DeletedAt)
+	r.Equal(TrashPrefix+"/"+object.ID, object.TrashPath())
+	r.Equal(object.ID, TrashID(object.TrashPath()))
+	bucket := &TrashItem{Store: "s3", Bucket: "tmp", ExpiresAt: 200}
+	r.NoError(m.Add("owner1", bucket))
+	r.Empty(bucket.TrashPath())
+	r.NoError(m.Add("owner2", &TrashItem{Store: "minio", Bucket: "tmp", ExpiresAt: 100}))
+
+	item, err := m.Item("owner1", object.ID)
+	r.NoError(err)
+	r.Equal(object, item)
+	_, err = m.Item("owner2", object.ID)
+	r.Equal(ErrTrashItemNotFound, errors.Cause(err))
+	items, err := m.Items("")
+	r.NoError(err)
+	r.Len(items, 3)
+	items, err = m.Items("owner1")
+	r.NoError(err)
+	r.Len(items, 2)
+
+	// buckets in the recycle bin are looked up by name
+	item, err = m.Bucket("owner1", "s3", "tmp")
+	r.NoError(err)
+	r.Equal(bucket.ID, item.ID)
+	item, err = m.Bucket("owner1", "s3", "docs")
+	r.NoError(err)
+	r.Nil(item)
+	item, err = m.Bucket("owner1", "minio", "tmp")
+	r.NoError(err)
+	r.Nil(item)
+
+	expired, err := m.Expired("", 150)
+	r.NoError(err)
+	r.Len(expired, 2)
+	expired, err = m.Expired("owner1", 150)
+	r.NoError(err)
+	r.Len(expired, 1)
+	r.Equal(object.ID, expired[0].ID)
+
+	// restored or purged
+	r.NoError(m.Remove("owner1", bucket.ID))
+	r.NoError(m.Remove("owner1", bucket.ID))
+	item, err = m.Bucket("owner1", "s3", "tmp")
+	r.NoError(err)
+	r.Nil(item)
+
+	// the items of a store unregistered are forgotten
+	r.NoError(m.Add("owner1", bucket))
+	r.NoError(m.DelBin("owner1", "s3"))
+	items, err = m.Items("owner1")
+	r.NoError(err)
+	r.Len(items, 2)
+	r.NoError(m.Forget("owner1", "s3", ""))
+	items, err = m.Items("")
+	r.NoError(err)
+	r.Len(items, 1)
+	item, err = m.Bucket("owner1", "s3", "tmp")
+	r.NoError(err)
+	r.Nil(item)
+	bin, err = m.GetBin("owner1", "s3")
+	r.NoError(err)
+	r.Nil(bin)
+}
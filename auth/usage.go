@@ -0,0 +1,167 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/iotexproject/phoenix/db"
+	"github.com/iotexproject/phoenix/json"
+)
+
+const usageNamespace = "usage"
+
+// ErrTokenExhausted is returned once a usage-limited token has been used up
+var ErrTokenExhausted = errors.New("token usage limit exhausted")
+
+type (
+	Usage interface {
+		// Consume records one use of the token and its ancestors for the operation at once, fails with
+		// ErrTokenExhausted if any of them has reached its limit, recording none
+		Consume(*Claims, string) error
+
+		// Refund takes back the use Consume recorded for an operation that failed
+		Refund(*Claims, string) error
+
+		// Check returns ErrTokenExhausted if the token or any of its ancestors has reached its limit
+		// for the operation, without recording a use
+		Check(*Claims, string) error
+
+		// Count returns the recorded uses of the token
+		Count(*Claims) (*UsageCount, error)
+	}
+
+	// UsageCount is the number of times a token has been used
+	UsageCount struct {
+		Total uint64            `json:"total"`
+		Ops   map[string]uint64 `json:"ops,omitempty"`
+	}
+
+	usage struct {
+		db.KVStore
+	}
+)
+
+func NewUsage(kv db.KVStore) Usage {
+	return &usage{
+		KVStore: kv,
+	}
+}
+
+func (u *usage) Consume(c *Claims, op string) error {
+	links := limitedLinks(c)
+	if len(links) == 0 {
+		return nil
+	}
+	// the uses of all links are counted in one transaction, so concurrent requests are checked in turn
+	return u.Batch(func(tx db.Tx) error {
+		for _, link := range links {
+			count, err := getUsageCount(tx, link)
+			if err != nil {
+				return err
+			}
+			if err := link.exhausted(count, op); err != nil {
+				return err
+			}
+			if count.Ops == nil {
+				count.Ops = make(map[string]uint64)
+			}
+			count.Total++
+			count.Ops[op]++
+			if err := putJSON(tx, usageNamespace, []byte(link.Hash()), count); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (u *usage) Refund(c *Claims, op string) error {
+	links := limitedLinks(c)
+	if len(links) == 0 {
+		return nil
+	}
+	return u.Batch(func(tx db.Tx) error {
+		for _, link := range links {
+			count, err := getUsageCount(tx, link)
+			if err != nil {
+				return err
+			}
+			if count.Total > 0 {
+				count.Total--
+			}
+			if count.Ops[op] > 0 {
+				count.Ops[op]--
+			}
+			if err := putJSON(tx, usageNamespace, []byte(link.Hash()), count); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// limitedLinks returns the links of the chain whose uses are limited
+func limitedLinks(c *Claims) []*Claims {
+	links := []*Claims{}
+	for _, link := range c.Chain() {
+		if link.Limited() {
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+func (u *usage) Check(c *Claims, op string) error {
+	for _, link := range c.Chain() {
+		if !link.Limited() {
+			continue
+		}
+		count, err := u.Count(link)
+		if err != nil {
+			return err
+		}
+		if err := link.exhausted(count, op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exhausted returns ErrTokenExhausted if one more use of the operation exceeds the limit
+func (c *Claims) exhausted(count *UsageCount, op string) error {
+	if c.MaxUses > 0 && count.Total >= c.MaxUses {
+		return errors.Wrap(ErrTokenExhausted, fmt.Sprintf("all %d uses consumed", c.MaxUses))
+	}
+	if limit, ok := c.OpUses[op]; ok && count.Ops[op] >= limit {
+		return errors.Wrap(ErrTokenExhausted, fmt.Sprintf("all %d %s uses consumed", limit, op))
+	}
+	return nil
+}
+
+func (u *usage) Count(c *Claims) (*UsageCount, error) {
+	return getUsageCount(u, c)
+}
+
+func getUsageCount(g getter, c *Claims) (*UsageCount, error) {
+	count := &UsageCount{}
+	v, err := g.Get(usageNamespace, []byte(c.Hash()))
+	switch errors.Cause(err) {
+	case nil:
+		break
+	case db.ErrBucketNotExist, db.ErrNotExist:
+		return count, nil
+	default:
+		return nil, err
+	}
+	if err := json.Unmarshal(v, count); err != nil {
+		return nil, err
+	}
+	return count, nil
+}
@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iotexproject/go-pkgs/crypto"
+	"github.com/iotexproject/iotex-antenna-go/v2/jwt"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iotexproject/phoenix/db"
+)
+
+func TestUsage(t *testing.T) {
+	r := require.New(t)
+
+	testFile, err := ioutil.TempFile(os.TempDir(), "test-bolt")
+	path := testFile.Name()
+	r.NoError(err)
+	testFile.Close()
+	defer func() {
+		r.NoError(os.Remove(path))
+	}()
+
+	d := db.NewBoltDB(path)
+	ctx := context.Background()
+	r.NoError(d.Start(ctx))
+
+	key, err := crypto.GenerateKey()
+	r.NoError(err)
+	issue := time.Now().Unix()
+	expire := time.Now().Add(time.Hour).Unix()
+	tok, err := SignToken(issue, expire, "s3", jwt.READ+","+jwt.DELETE, Extension{
+		MaxUses: 3,
+		OpUses:  map[string]uint64{jwt.DELETE: 1},
+	}, key)
+	r.NoError(err)
+	c, err := NewClaims(tok)
+	r.NoError(err)
+
+	u := NewUsage(d)
+	r.NoError(u.Check(c, jwt.DELETE))
+	r.NoError(u.Consume(c, jwt.DELETE))
+	r.Equal(ErrTokenExhausted, errors.Cause(u.Check(c, jwt.DELETE)))
+	r.Equal(ErrTokenExhausted, errors.Cause(u.Consume(c, jwt.DELETE)))
+	r.NoError(u.Check(c, jwt.READ))
+	r.NoError(u.Consume(c, jwt.READ))
+
+	// counters survive restart
+	r.NoError(d.Stop(ctx))
+	d = db.NewBoltDB(path)
+	r.NoError(d.Start(ctx))
+	defer func() {
+		r.NoError(d.Stop(ctx))
+	}()
+	u = NewUsage(d)
+	count, err := u.Count(c)
+	r.NoError(err)
+	r.EqualValues(2, count.Total)
+	r.EqualValues(1, count.Ops[jwt.READ])
+
+	r.NoError(u.Consume(c, jwt.READ))
+	r.Equal(ErrTokenExhausted, errors.Cause(u.Consume(c, jwt.READ)))
+
+	// a use refunded for a failed operation can be used again
+	r.NoError(u.Refund(c, jwt.READ))
+	count, err = u.Count(c)
+	r.NoError(err)
+	r.EqualValues(2, count.Total)
+	r.NoError(u.Consume(c, jwt.READ))
+	r.Equal(ErrTokenExhausted, errors.Cause(u.Consume(c, jwt.READ)))
+
+	// concurrent requests through a chain whose root has one use left use it once, and the uses of the failing
+	// ones are recorded for no link
+	trustee, err := crypto.GenerateKey()
+	r.NoError(err)
+	root, err := SignToken(issue, expire, "s3", jwt.READ, Extension{MaxUses: 1, Holder: trustee.PublicKey().HexString()}, key)
+	r.NoError(err)
+	leaf, err := SignToken(issue, expire, "s3", jwt.READ, Extension{MaxUses: 5, ParentToken: root}, trustee)
+	r.NoError(err)
+	lc, err := NewClaims(leaf)
+	r.NoError(err)
+	var (
+		wg   sync.WaitGroup
+		used int32
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if u.Consume(lc, jwt.READ) == nil {
+				atomic.AddInt32(&used, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	r.EqualValues(1, used)
+	for _, link := range lc.Chain() {
+		count, err = u.Count(link)
+		r.NoError(err)
+		r.EqualValues(1, count.Total)
+	}
+
+	// unlimited token is not tracked
+	tok, err = jwt.SignJWT(issue, expire, "s3", jwt.READ, key)
+	r.NoError(err)
+	c, err = NewClaims(tok)
+	r.NoError(err)
+	r.NoError(u.Consume(c, jwt.READ))
+	count, err = u.Count(c)
+	r.NoError(err)
+	r.Zero(count.Total)
+}
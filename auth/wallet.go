@@ -0,0 +1,117 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/iotexproject/go-pkgs/crypto"
+	"github.com/iotexproject/go-pkgs/hash"
+	"github.com/iotexproject/iotex-address/address"
+	"github.com/pkg/errors"
+)
+
+// signature types accepted for wallet login
+const (
+	PersonalSign = "personal_sign"
+	TypedSign    = "eip712"
+)
+
+// domain of the EIP-712 typed data signed at login
+const (
+	typedDomainName    = "phoenix"
+	typedDomainVersion = "1"
+)
+
+type (
+	// TypedData is the EIP-712 typed data of a login challenge, as accepted by eth_signTypedData_v4
+	TypedData struct {
+		Types       map[string][]TypedField `json:"types"`
+		PrimaryType string                  `json:"primaryType"`
+		Domain      map[string]string       `json:"domain"`
+		Message     map[string]string       `json:"message"`
+	}
+
+	// TypedField is a field of a EIP-712 type
+	TypedField struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	}
+)
+
+// ParseAddress parses an IoTeX (io1...) or Ethereum (0x...) address
+func ParseAddress(addr string) (address.Address, error) {
+	if strings.HasPrefix(addr, "0x") || strings.HasPrefix(addr, "0X") {
+		return address.FromHex(addr)
+	}
+	return address.FromString(addr)
+}
+
+// PersonalMessageHash returns the hash signed by personal_sign for the message
+func PersonalMessageHash(msg string) []byte {
+	h := hash.Hash256b([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(msg), msg)))
+	return h[:]
+}
+
+// NewLoginTypedData returns the EIP-712 typed data an owner signs to login with the nonce
+func NewLoginTypedData(owner address.Address, nonce string) *TypedData {
+	return &TypedData{
+		Types: map[string][]TypedField{
+			"EIP712Domain": {
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+			},
+			"Login": {
+				{Name: "owner", Type: "address"},
+				{Name: "nonce", Type: "string"},
+			},
+		},
+		PrimaryType: "Login",
+		Domain: map[string]string{
+			"name":    typedDomainName,
+			"version": typedDomainVersion,
+		},
+		Message: map[string]string{
+			"owner": "0x" + fmt.Sprintf("%x", owner.Bytes()),
+			"nonce": nonce,
+		},
+	}
+}
+
+// TypedDataHash returns the EIP-712 hash of the login typed data
+func TypedDataHash(owner address.Address, nonce string) []byte {
+	domainType := hash.Hash256b([]byte("EIP712Domain(string name,string version)"))
+	name := hash.Hash256b([]byte(typedDomainName))
+	version := hash.Hash256b([]byte(typedDomainVersion))
+	domain := hash.Hash256b(bytes.Join([][]byte{domainType[:], name[:], version[:]}, nil))
+
+	loginType := hash.Hash256b([]byte("Login(address owner,string nonce)"))
+	ownerWord := make([]byte, 32)
+	copy(ownerWord[12:], owner.Bytes())
+	nonceHash := hash.Hash256b([]byte(nonce))
+	login := hash.Hash256b(bytes.Join([][]byte{loginType[:], ownerWord, nonceHash[:]}, nil))
+
+	h := hash.Hash256b(bytes.Join([][]byte{{0x19, 0x01}, domain[:], login[:]}, nil))
+	return h[:]
+}
+
+// RecoverSigner recovers the public key from the wallet signature, returns error if it doesn't match owner
+func RecoverSigner(digest, sig []byte, owner address.Address) (crypto.PublicKey, error) {
+	if len(sig) != crypto.Secp256k1SigSizeWithRecID {
+		return nil, errors.Wrap(ErrChallenge, "invalid signature length")
+	}
+	pk, err := crypto.RecoverPubkey(digest, sig)
+	if err != nil {
+		return nil, errors.Wrap(ErrChallenge, err.Error())
+	}
+	if !bytes.Equal(pk.Address().Bytes(), owner.Bytes()) {
+		return nil, errors.Wrap(ErrChallenge, "signature doesn't match owner address")
+	}
+	return pk, nil
+}
@@ -34,6 +34,10 @@ type (
 		RequestLimit int      `yaml:"requestLimit" json:"requestLimit"`
 		WindowLength int      `yaml:"windowLength" json:"windowLength"`
 	}
+	PublicRateLimit struct {
+		RequestLimit int `yaml:"requestLimit" json:"requestLimit"` // default 30
+		WindowLength int `yaml:"windowLength" json:"windowLength"` // second, default 60
+	}
 	Cors struct {
 		Enable         bool     `yaml:"enable" json:"enable"`
 		AllowedOrigins []string `yaml:"allowedOrigins" json:"allowedOrigins"`
@@ -41,16 +45,59 @@ type (
 		AllowedHeaders []string `yaml:"allowedHeaders" json:"allowedHeaders"`
 	}
 	Server struct {
-		Port      string    `yaml:"port" json:"port"`
-		RateLimit RateLimit `yaml:"rateLimit" json:"rateLimit"`
-		Cors      Cors      `yaml:"cors" json:"cors"`
-		DBPath    string    `yaml:"dbPath" json:"dbPath"`
+		Port            string          `yaml:"port" json:"port"`
+		RateLimit       RateLimit       `yaml:"rateLimit" json:"rateLimit"`
+		PublicRateLimit PublicRateLimit `yaml:"publicRateLimit" json:"publicRateLimit"`
+		Cors            Cors            `yaml:"cors" json:"cors"`
+		DBPath          string          `yaml:"dbPath" json:"dbPath"`
+		SignatureWindow int             `yaml:"signatureWindow" json:"signatureWindow"` // second, default 300
+		SessionTTL      int             `yaml:"sessionTTL" json:"sessionTTL"`           // second, default 900
+		RequireGrant    bool            `yaml:"requireGrant" json:"requireGrant"`       // JWT must be recorded in a live grant
+		PresignTTL      int             `yaml:"presignTTL" json:"presignTTL"`           // second presigned URLs last at most, default 900
+		ShareTTL        int             `yaml:"shareTTL" json:"shareTTL"`               // second share links last at most, default 86400
+		Audience        string          `yaml:"audience" json:"audience"`               // aud of verifiable presentations, default phoenix
+		TrustedProxies  []string        `yaml:"trustedProxies" json:"trustedProxies"`   // IPs or CIDRs whose forwarded client IP is honored
+	}
+	OIDCProvider struct {
+		Issuer   string `yaml:"issuer" json:"issuer"`
+		JWKSURL  string `yaml:"jwksURL" json:"jwksURL"`
+		Audience string `yaml:"audience" json:"audience"`
+	}
+	OIDC struct {
+		Providers []OIDCProvider `yaml:"providers" json:"providers"`
+		CacheTTL  int            `yaml:"cacheTTL" json:"cacheTTL"` // second, default 3600
+	}
+	Webhook struct {
+		URL          string `yaml:"url" json:"url"`                   // operator's webhook events of access requests are posted to
+		Timeout      int    `yaml:"timeout" json:"timeout"`           // second, default 10
+		MaxAttempts  int    `yaml:"maxAttempts" json:"maxAttempts"`   // attempts to deliver event to subscription, default 8
+		Backoff      int    `yaml:"backoff" json:"backoff"`           // second before the first retry, doubled each retry, default 30
+		Concurrency  int    `yaml:"concurrency" json:"concurrency"`   // deliveries posted at once, default 8
+		Retention    int    `yaml:"retention" json:"retention"`       // second deliveries are logged once done, default 604800
+		AllowPrivate bool   `yaml:"allowPrivate" json:"allowPrivate"` // allow posting to loopback, private and link-local addresses
+	}
+	Quota struct {
+		MaxBytes    int64 `yaml:"maxBytes" json:"maxBytes"`
+		MaxObjects  int64 `yaml:"maxObjects" json:"maxObjects"`
+		MaxTransfer int64 `yaml:"maxTransfer" json:"maxTransfer"` // bytes uploaded and downloaded per period
+		Period      int64 `yaml:"period" json:"period"`           // second, default 86400
+	}
+	Lifecycle struct {
+		Interval int `yaml:"interval" json:"interval"` // second between sweeps enforcing lifecycle rules and TTLs, default 3600
+	}
+	Admin struct {
+		Operators []string `yaml:"operators" json:"operators"` // addresses of operators managing access lists
 	}
 	Config struct {
-		Pinata  Pinata                      `yaml:"pinata" json:"pinata"`
-		Server  Server                      `yaml:"server" json:"server"`
-		Log     log.GlobalConfig            `yaml:"log" yaml:"log"`
-		SubLogs map[string]log.GlobalConfig `yaml:"subLogs" json:"subLogs"`
+		Pinata    Pinata                      `yaml:"pinata" json:"pinata"`
+		Server    Server                      `yaml:"server" json:"server"`
+		OIDC      OIDC                        `yaml:"oidc" json:"oidc"`
+		Webhook   Webhook                     `yaml:"webhook" json:"webhook"`
+		Admin     Admin                       `yaml:"admin" json:"admin"`
+		Quota     Quota                       `yaml:"quota" json:"quota"` // quota of each owner
+		Lifecycle Lifecycle                   `yaml:"lifecycle" json:"lifecycle"`
+		Log       log.GlobalConfig            `yaml:"log" yaml:"log"`
+		SubLogs   map[string]log.GlobalConfig `yaml:"subLogs" json:"subLogs"`
 	}
 )
 
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"bytes"
 	"context"
 
 	"github.com/pkg/errors"
@@ -26,6 +27,16 @@ type (
 		// Delete deletes a record by (namespace, key)
 		Delete(string, []byte) error
 
+		// Update atomically reads and rewrites a record identified by (namespace, key),
+		// the value passed to the func is nil if the record doesn't exist
+		Update(string, []byte, func([]byte) ([]byte, error)) error
+
+		// List returns the keys and values of the records in namespace whose key has the prefix, in key order
+		List(string, []byte) ([][]byte, [][]byte, error)
+
+		// Batch runs the func in a single transaction, the records it writes are committed together unless it fails
+		Batch(func(Tx) error) error
+
 		// Start starts the db
 		Start(context.Context) error
 
@@ -33,10 +44,26 @@ type (
 		Stop(context.Context) error
 	}
 
+	// Tx reads and writes records within the transaction of Batch
+	Tx interface {
+		// Get gets a record by (namespace, key)
+		Get(string, []byte) ([]byte, error)
+
+		// Put insert or update a record identified by (namespace, key)
+		Put(string, []byte, []byte) error
+
+		// Delete deletes a record by (namespace, key)
+		Delete(string, []byte) error
+	}
+
 	boltDB struct {
 		db   *bolt.DB
 		path string
 	}
+
+	boltTx struct {
+		tx *bolt.Tx
+	}
 )
 
 // NewBoltDB instantiates an boltDB with implements KVStore
@@ -140,3 +167,110 @@ func (b *boltDB) Delete(namespace string, key []byte) (err error) {
 	}
 	return err
 }
+
+// Update reads and rewrites a record in a single transaction, a nil value returned by fn deletes the record
+func (b *boltDB) Update(namespace string, key []byte, fn func([]byte) ([]byte, error)) error {
+	var fnErr error
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(namespace))
+		if err != nil {
+			return err
+		}
+		var value []byte
+		if v := bucket.Get(key); v != nil {
+			value = make([]byte, len(v))
+			copy(value, v)
+		}
+		value, fnErr = fn(value)
+		if fnErr != nil {
+			return fnErr
+		}
+		if value == nil {
+			return bucket.Delete(key)
+		}
+		return bucket.Put(key, value)
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	if err != nil {
+		err = errors.Wrap(ErrIO, err.Error())
+	}
+	return err
+}
+
+// List retrieves the records whose key has the prefix, nothing is returned if the bucket doesn't exist
+func (b *boltDB) List(namespace string, prefix []byte) ([][]byte, [][]byte, error) {
+	var keys, values [][]byte
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(namespace))
+		if bucket == nil {
+			return nil
+		}
+		c := bucket.Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			key := make([]byte, len(k))
+			copy(key, k)
+			value := make([]byte, len(v))
+			copy(value, v)
+			keys, values = append(keys, key), append(values, value)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, errors.Wrap(ErrIO, err.Error())
+	}
+	return keys, values, nil
+}
+
+// Batch runs fn in a single transaction, an error returned by fn rolls back what it has written
+func (b *boltDB) Batch(fn func(Tx) error) error {
+	var fnErr error
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		fnErr = fn(&boltTx{tx: tx})
+		return fnErr
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	if err != nil {
+		err = errors.Wrap(ErrIO, err.Error())
+	}
+	return err
+}
+
+func (t *boltTx) Get(namespace string, key []byte) ([]byte, error) {
+	bucket := t.tx.Bucket([]byte(namespace))
+	if bucket == nil {
+		return nil, errors.Wrapf(ErrBucketNotExist, "bucket = %x doesn't exist", []byte(namespace))
+	}
+	v := bucket.Get(key)
+	if v == nil {
+		return nil, errors.Wrapf(ErrNotExist, "key = %x doesn't exist", key)
+	}
+	value := make([]byte, len(v))
+	copy(value, v)
+	return value, nil
+}
+
+func (t *boltTx) Put(namespace string, key, value []byte) error {
+	bucket, err := t.tx.CreateBucketIfNotExists([]byte(namespace))
+	if err != nil {
+		return errors.Wrap(ErrIO, err.Error())
+	}
+	if err := bucket.Put(key, value); err != nil {
+		return errors.Wrap(ErrIO, err.Error())
+	}
+	return nil
+}
+
+func (t *boltTx) Delete(namespace string, key []byte) error {
+	bucket := t.tx.Bucket([]byte(namespace))
+	if bucket == nil {
+		return nil
+	}
+	if err := bucket.Delete(key); err != nil {
+		return errors.Wrap(ErrIO, err.Error())
+	}
+	return nil
+}
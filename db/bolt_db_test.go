@@ -73,3 +73,135 @@ func TestBoltDB(t *testing.T) {
 		r.Equal(ErrNotExist, errors.Cause(err))
 	}
 }
+
+func TestBoltDBUpdate(t *testing.T) {
+	r := require.New(t)
+
+	testFile, err := ioutil.TempFile(os.TempDir(), "test-bolt")
+	path := testFile.Name()
+	r.NoError(err)
+	testFile.Close()
+	defer func() {
+		r.NoError(os.Remove(path))
+	}()
+
+	db := NewBoltDB(path)
+	r.NotNil(db)
+	ctx := context.Background()
+	r.NoError(db.Start(ctx))
+	defer func() {
+		r.NoError(db.Stop(ctx))
+	}()
+
+	ns, k := "5NJ2Hqv", []byte("JtQTAme2SKJzXVs")
+	r.NoError(db.Update(ns, k, func(v []byte) ([]byte, error) {
+		r.Nil(v)
+		return []byte("exUALhrxXi3DcLg2"), nil
+	}))
+	v, err := db.Get(ns, k)
+	r.NoError(err)
+	r.Equal([]byte("exUALhrxXi3DcLg2"), v)
+
+	// error returned by the func aborts the update
+	errAbort := errors.New("abort")
+	r.Equal(errAbort, db.Update(ns, k, func(v []byte) ([]byte, error) {
+		return []byte("tzjeg+3xrA5"), errAbort
+	}))
+	v, err = db.Get(ns, k)
+	r.NoError(err)
+	r.Equal([]byte("exUALhrxXi3DcLg2"), v)
+
+	// nil value deletes the record
+	r.NoError(db.Update(ns, k, func(v []byte) ([]byte, error) {
+		return nil, nil
+	}))
+	_, err = db.Get(ns, k)
+	r.Equal(ErrNotExist, errors.Cause(err))
+}
+
+func TestBoltDBList(t *testing.T) {
+	r := require.New(t)
+
+	testFile, err := ioutil.TempFile(os.TempDir(), "test-bolt")
+	path := testFile.Name()
+	r.NoError(err)
+	testFile.Close()
+	defer func() {
+		r.NoError(os.Remove(path))
+	}()
+
+	db := NewBoltDB(path)
+	r.NotNil(db)
+	ctx := context.Background()
+	r.NoError(db.Start(ctx))
+	defer func() {
+		r.NoError(db.Stop(ctx))
+	}()
+
+	ns := "Hq5NJ2v"
+	keys, values, err := db.List(ns, nil)
+	r.NoError(err)
+	r.Empty(keys)
+	r.Empty(values)
+
+	r.NoError(db.Put(ns, []byte("a/2"), []byte("v2")))
+	r.NoError(db.Put(ns, []byte("a/1"), []byte("v1")))
+	r.NoError(db.Put(ns, []byte("b/1"), []byte("v3")))
+	keys, values, err = db.List(ns, []byte("a/"))
+	r.NoError(err)
+	r.Equal([][]byte{[]byte("a/1"), []byte("a/2")}, keys)
+	r.Equal([][]byte{[]byte("v1"), []byte("v2")}, values)
+	keys, _, err = db.List(ns, nil)
+	r.NoError(err)
+	r.Len(keys, 3)
+}
+
+func TestBoltDBBatch(t *testing.T) {
+	r := require.New(t)
+
+	testFile, err := ioutil.TempFile(os.TempDir(), "test-bolt")
+	path := testFile.Name()
+	r.NoError(err)
+	testFile.Close()
+	defer func() {
+		r.NoError(os.Remove(path))
+	}()
+
+	db := NewBoltDB(path)
+	r.NotNil(db)
+	ctx := context.Background()
+	r.NoError(db.Start(ctx))
+	defer func() {
+		r.NoError(db.Stop(ctx))
+	}()
+
+	ns, k1, k2 := "J2vHq5N", []byte("JtQTAme2SKJzXVs"), []byte("exUALhrxXi3DcLg2")
+	r.NoError(db.Batch(func(tx Tx) error {
+		_, err := tx.Get(ns, k1)
+		r.Equal(ErrBucketNotExist, errors.Cause(err))
+		if err := tx.Put(ns, k1, []byte("v1")); err != nil {
+			return err
+		}
+		v, err := tx.Get(ns, k1)
+		r.NoError(err)
+		r.Equal([]byte("v1"), v)
+		return tx.Put(ns, k2, []byte("v2"))
+	}))
+	keys, _, err := db.List(ns, nil)
+	r.NoError(err)
+	r.Len(keys, 2)
+
+	// error returned by the func rolls back all the records written
+	errAbort := errors.New("abort")
+	r.Equal(errAbort, db.Batch(func(tx Tx) error {
+		r.NoError(tx.Delete(ns, k1))
+		r.NoError(tx.Put(ns, k2, []byte("v3")))
+		return errAbort
+	}))
+	v, err := db.Get(ns, k1)
+	r.NoError(err)
+	r.Equal([]byte("v1"), v)
+	v, err = db.Get(ns, k2)
+	r.NoError(err)
+	r.Equal([]byte("v2"), v)
+}
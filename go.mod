@@ -4,18 +4,25 @@ go 1.14
 
 require (
 	github.com/aws/aws-sdk-go v1.35.32
+	github.com/dgrijalva/jwt-go v3.2.0+incompatible
+	github.com/ethereum/go-ethereum v1.8.27
 	github.com/go-chi/chi v1.5.0
 	github.com/go-chi/cors v1.1.1
 	github.com/go-chi/httprate v0.4.0
-	github.com/golang/protobuf v1.4.2
+	github.com/golang/protobuf v1.4.3
+	github.com/google/cel-go v0.7.3
 	github.com/iotexproject/go-pkgs v0.1.5-0.20201128191740-3f9b55cbea9b
+	github.com/iotexproject/iotex-address v0.2.4
 	github.com/iotexproject/iotex-antenna-go/v2 v2.4.2-0.20201128202745-31784a8b8ddd
 	github.com/johannesboyne/gofakes3 v0.0.0-20200716060623-6b2b4cb092cc
 	github.com/json-iterator/go v1.1.10
+	github.com/modern-go/reflect2 v1.0.2 // indirect
 	github.com/pkg/errors v0.9.1
 	github.com/stretchr/testify v1.6.1
 	go.etcd.io/bbolt v1.3.5
 	go.uber.org/zap v1.16.0
+	golang.org/x/net v0.0.0-20201110031124-69a78807bb2b
+	google.golang.org/genproto v0.0.0-20201102152239-715cce707fb0
 	google.golang.org/protobuf v1.25.0
 	gopkg.in/yaml.v2 v2.3.0
 )
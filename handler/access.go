@@ -0,0 +1,255 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/iotexproject/iotex-antenna-go/v2/jwt"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/iotexproject/phoenix/auth"
+	"github.com/iotexproject/phoenix/db"
+)
+
+// FileAccessRequest files trustee's request for access to owner's data, the returned secret redeems the token
+// once the owner approves the request
+// example: curl -H 'Content-Type: application/json' -d '{"owner": "io1...", "trustee": "alice", "subject": "s3/test", "scope": "Read", "duration": 86400, "justification": "Q1 audit"}' http://localhost:8080/access-requests
+func (h *StorageHandler) FileAccessRequest(w http.ResponseWriter, r *http.Request) {
+	item := &accessRequestObject{}
+	if err := decodeAndCloseRequest(r, item); err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	namespace, err := auth.OwnerNamespace(item.Owner)
+	if err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	req := &auth.AccessRequest{
+		Owner:         namespace,
+		Trustee:       item.Trustee,
+		Subject:       item.Subject,
+		Scope:         item.Scope,
+		Duration:      item.Duration,
+		Justification: item.Justification,
+	}
+	// the access lists apply to the owner, the subject requested and the client IP filing it
+	if !h.checkAccessLists(w, r, &auth.Claims{JWT: &jwt.JWT{Subject: req.Subject}, Namespace: namespace}) {
+		return
+	}
+	switch _, err := h.cred.GetStore(namespace, req.Store()); errors.Cause(err) {
+	case nil:
+		break
+	case db.ErrBucketNotExist, db.ErrNotExist:
+		renderJSON(w, http.StatusNotFound, H{"message": "store " + req.Store() + " is not registered"})
+		return
+	default:
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	secret, err := h.accessRequests.FileAccessRequest(req)
+	if err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	h.publishAccess(EventAccessRequested, req)
+	renderJSON(w, http.StatusOK, H{"message": "successful", "request": req, "secret": secret})
+}
+
+// RedeemAccessRequest mints the token of the grant the owner approved, with the secret of the access request.
+// The token can only be redeemed once
+// example: curl -H 'Content-Type: application/json' -d '{"owner": "io1...", "secret": "xxx"}' http://localhost:8080/access-requests/xxx/redeem
+func (h *StorageHandler) RedeemAccessRequest(w http.ResponseWriter, r *http.Request) {
+	item := &redeemObject{}
+	if err := decodeAndCloseRequest(r, item); err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	namespace, err := auth.OwnerNamespace(item.Owner)
+	if err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	id := chi.URLParam(r, "id")
+	switch err := h.accessRequests.VerifySecret(namespace, id, item.Secret); errors.Cause(err) {
+	case nil:
+		break
+	case auth.ErrAccessRequestNotFound, auth.ErrAccessSecret:
+		// don't tell unknown request from wrong secret
+		renderJSON(w, http.StatusForbidden, H{"message": auth.ErrAccessSecret.Error()})
+		return
+	default:
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	req, status, err := h.getAccessRequest(namespace, id)
+	if err != nil {
+		renderJSON(w, status, H{"message": err.Error()})
+		return
+	}
+	if !h.checkAccessLists(w, r, &auth.Claims{JWT: &jwt.JWT{Subject: req.Subject}, Namespace: namespace}) {
+		return
+	}
+	if req.State != auth.AccessApproved {
+		renderJSON(w, http.StatusConflict, H{"message": "access request is " + req.State, "request": req})
+		return
+	}
+	if h.mintKey == nil {
+		renderJSON(w, http.StatusServiceUnavailable, H{"message": "minting is not available"})
+		return
+	}
+	grant, status, err := h.getGrant(namespace, req.GrantID)
+	if err != nil {
+		renderJSON(w, status, H{"message": err.Error()})
+		return
+	}
+	if !grant.Alive() {
+		renderJSON(w, http.StatusForbidden, H{"message": "grant " + grant.ID + " is revoked or expired"})
+		return
+	}
+	if statusCode, err := h.moveAccessRequest(req, auth.AccessRedeemed); err != nil {
+		renderJSON(w, statusCode, H{"message": err.Error()})
+		return
+	}
+	token, err := h.mintGrantToken(namespace, grant)
+	if err != nil {
+		// the request can be redeemed again
+		if _, err := h.moveAccessRequest(req, auth.AccessApproved); err != nil {
+			h.log.Error("failed to restore access request", zap.Error(err))
+		}
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	h.publishAccess(EventAccessRedeemed, req)
+	renderJSON(w, http.StatusOK, H{"message": "successful", "token": token, "grant": grant})
+}
+
+// ListAccessRequests lists the access requests filed to the owner, of the state if given
+// example: curl -H "Authorization: Bearer session" http://localhost:8080/access-requests?state=pending
+func (h *StorageHandler) ListAccessRequests(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	list, err := h.accessRequests.ListAccessRequests(claims.Namespace, r.URL.Query().Get("state"))
+	if err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	renderJSON(w, http.StatusOK, H{"requests": list})
+}
+
+// ApproveAccessRequest approves a pending access request, which issues a grant of the requested access
+// for the requested duration. The trustee redeems the token of the grant
+// example: curl -H "Authorization: Bearer session" -X POST http://localhost:8080/access-requests/xxx/approve
+func (h *StorageHandler) ApproveAccessRequest(w http.ResponseWriter, r *http.Request) {
+	h.decideAccessRequest(w, r, auth.AccessApproved)
+}
+
+// DenyAccessRequest denies a pending access request, with an optional reason
+// example: curl -H 'Content-Type: application/json' -H "Authorization: Bearer session" -d '{"reason": "not needed"}' http://localhost:8080/access-requests/xxx/deny
+func (h *StorageHandler) DenyAccessRequest(w http.ResponseWriter, r *http.Request) {
+	h.decideAccessRequest(w, r, auth.AccessDenied)
+}
+
+func (h *StorageHandler) decideAccessRequest(w http.ResponseWriter, r *http.Request, state string) {
+	claims, ok := h.ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	item := &decisionObject{}
+	if err := decodeAndCloseRequest(r, item); err != nil && err != io.EOF {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	req, status, err := h.getAccessRequest(claims.Namespace, chi.URLParam(r, "id"))
+	if err != nil {
+		renderJSON(w, status, H{"message": err.Error()})
+		return
+	}
+	if req.State != auth.AccessPending {
+		renderJSON(w, http.StatusConflict, H{"message": "access request is " + req.State})
+		return
+	}
+
+	ret := H{"message": "successful", "request": req}
+	if state == auth.AccessApproved {
+		if h.mintKey == nil {
+			renderJSON(w, http.StatusServiceUnavailable, H{"message": "minting is not available"})
+			return
+		}
+		grant := &auth.Grant{
+			Trustee:   req.Trustee,
+			Subject:   req.Subject,
+			Scope:     req.Scope,
+			ExpiresAt: time.Now().Unix() + req.Duration,
+			Notes:     req.Justification,
+		}
+		if err := h.grants.CreateGrant(claims.Namespace, grant); err != nil {
+			renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+			return
+		}
+		req.GrantID = grant.ID
+		ret["grant"] = grant
+	}
+	req.Reason = item.Reason
+	if statusCode, err := h.moveAccessRequest(req, state); err != nil {
+		if grant, ok := ret["grant"].(*auth.Grant); ok {
+			// the request was decided meanwhile, the grant of this approval is never redeemed
+			grant.RevokedAt = time.Now().Unix()
+			if err := h.grants.UpdateGrant(claims.Namespace, grant); err != nil {
+				h.log.Error("failed to revoke grant", zap.Error(err))
+			}
+		}
+		renderJSON(w, statusCode, H{"message": err.Error()})
+		return
+	}
+	event := EventAccessApproved
+	if state == auth.AccessDenied {
+		event = EventAccessDenied
+	}
+	h.publishAccess(event, req)
+	renderJSON(w, http.StatusOK, ret)
+}
+
+// moveAccessRequest moves the access request to the state, unless it has been moved since it was read
+func (h *StorageHandler) moveAccessRequest(req *auth.AccessRequest, state string) (int, error) {
+	switch err := h.accessRequests.MoveAccessRequest(req, state); errors.Cause(err) {
+	case nil:
+		return http.StatusOK, nil
+	case auth.ErrAccessRequestState:
+		return http.StatusConflict, err
+	case auth.ErrAccessRequestNotFound:
+		return http.StatusNotFound, err
+	default:
+		return http.StatusInternalServerError, err
+	}
+}
+
+func (h *StorageHandler) getAccessRequest(namespace, id string) (*auth.AccessRequest, int, error) {
+	req, err := h.accessRequests.GetAccessRequest(namespace, id)
+	switch errors.Cause(err) {
+	case nil:
+		return req, http.StatusOK, nil
+	case auth.ErrAccessRequestNotFound:
+		return nil, http.StatusNotFound, err
+	default:
+		return nil, http.StatusInternalServerError, err
+	}
+}
+
+// publishAccess posts the event of the access request to the operator's webhook and publishes it to owner's
+// subscriptions of its store
+func (h *StorageHandler) publishAccess(event string, req *auth.AccessRequest) {
+	h.notify(event, req)
+	h.publish(req.Owner, event, req.Store(), "", "", req)
+}
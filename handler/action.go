@@ -0,0 +1,215 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/iotexproject/iotex-antenna-go/v2/jwt"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/iotexproject/phoenix/auth"
+	"github.com/iotexproject/phoenix/storage"
+)
+
+// GetAction returns a pending action of a co-owned store, and the message co-owners sign to approve it
+// example: curl http://localhost:8080/actions/xxx
+func (h *StorageHandler) GetAction(w http.ResponseWriter, r *http.Request) {
+	a, err := h.ownerships.GetAction(chi.URLParam(r, "id"))
+	switch errors.Cause(err) {
+	case nil:
+		renderJSON(w, http.StatusOK, H{"action": a, "message": a.Message()})
+	case auth.ErrActionNotFound:
+		renderJSON(w, http.StatusNotFound, H{"message": err.Error()})
+	default:
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+	}
+}
+
+// ListActions lists the actions of stores the owner owns or co-owns
+// example: curl -H "Authorization: Bearer session" http://localhost:8080/actions
+func (h *StorageHandler) ListActions(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	list, err := h.ownerships.ListActions(claims.Namespace)
+	if err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	renderJSON(w, http.StatusOK, H{"actions": list})
+}
+
+// ApproveAction records a co-owner's approval, signed with personal_sign of the action's message. The action is
+// executed once the threshold of owners approve it
+// example: curl -H 'Content-Type: application/json' -d '{"owner": "io1...", "signature": "xxx"}' http://localhost:8080/actions/xxx/approve
+func (h *StorageHandler) ApproveAction(w http.ResponseWriter, r *http.Request) {
+	item := &approvalObject{}
+	if err := decodeAndCloseRequest(r, item); err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	sig, err := hex.DecodeString(item.Signature)
+	if err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	namespace, err := auth.OwnerNamespace(item.Owner)
+	if err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	// the access lists apply to the co-owner approving and the client IP, as to the requests of its session
+	if !h.checkAccessLists(w, r, &auth.Claims{JWT: &jwt.JWT{}, Namespace: namespace, Owner: true}) {
+		return
+	}
+	a, err := h.ownerships.Approve(chi.URLParam(r, "id"), item.Owner, sig)
+	switch errors.Cause(err) {
+	case nil:
+		break
+	case auth.ErrActionNotFound:
+		renderJSON(w, http.StatusNotFound, H{"message": err.Error()})
+		return
+	case auth.ErrActionClosed:
+		renderJSON(w, http.StatusConflict, H{"message": err.Error()})
+		return
+	case auth.ErrNotCoOwner, auth.ErrChallenge:
+		renderJSON(w, http.StatusForbidden, H{"message": err.Error()})
+		return
+	default:
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	if !a.Approved() {
+		renderJSON(w, http.StatusAccepted, H{"message": "pending approval of owners", "action": a})
+		return
+	}
+
+	err = h.executeAction(a)
+	if err != nil {
+		h.log.Error("failed to execute action", zap.String("action", a.ID), zap.Error(err))
+	}
+	if err := h.ownerships.Close(a, err); err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	if a.State == auth.ActionFailed {
+		renderJSON(w, http.StatusBadRequest, H{"message": a.Error, "action": a})
+		return
+	}
+	renderJSON(w, http.StatusOK, H{"message": "successful", "action": a})
+}
+
+// proposeAction defers the destructive operation to approvals of co-owners if the store is co-owned, it returns
+// false if the store is not co-owned and the operation goes on
+func (h *StorageHandler) proposeAction(w http.ResponseWriter, claims *auth.Claims, op, store, bucket, path string) bool {
+	namespace := claims.Root().Namespace
+	ownership, err := h.ownerships.GetOwnership(namespace, store)
+	if err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return true
+	}
+	if ownership == nil {
+		return false
+	}
+	a := &auth.Action{
+		Namespace:   namespace,
+		Op:          op,
+		Bucket:      bucket,
+		Path:        path,
+		RequestedBy: claims.Issuer,
+	}
+	if err := h.ownerships.Propose(ownership, a); err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return true
+	}
+	renderJSON(w, http.StatusAccepted, H{"message": "pending approval of owners", "action": a})
+	return true
+}
+
+// executeAction executes the destructive operation approved by co-owners
+func (h *StorageHandler) executeAction(a *auth.Action) error {
+	if a.Op == auth.ActionUnregisterStore {
+		if err := h.cred.DelStore(a.Namespace, a.Store); err != nil {
+			return err
+		}
+		if err := h.quotas.DelQuota(a.Namespace, a.Store); err != nil {
+			return err
+		}
+		if err := h.ownerships.DelOwnership(a.Namespace, a.Store); err != nil {
+			return err
+		}
+		if err := h.lifecycles.Forget(a.Namespace, a.Store, "", ""); err != nil {
+			return err
+		}
+		if err := h.forgetTrash(a.Namespace, a.Store); err != nil {
+			return err
+		}
+		h.publish(a.Namespace, EventStoreUnregistered, a.Store, "", "", &storageEvent{Store: a.Store})
+		return nil
+	}
+	store, err := h.cred.GetStore(a.Namespace, a.Store)
+	if err != nil {
+		return err
+	}
+	backend, err := storage.NewStorage(store)
+	if err != nil {
+		return err
+	}
+	event := EventObjectDeleted
+	var trashed *auth.TrashItem
+	// objects may have been locked since the action was proposed
+	if a.Op == auth.ActionDeleteBucket || a.Op == auth.ActionDeleteObject {
+		if err := h.retentions.Check(a.Namespace, a.Store, a.Bucket, a.Path, time.Now().Unix()); err != nil {
+			return err
+		}
+	}
+	switch a.Op {
+	case auth.ActionDeleteBucket:
+		event = EventBucketDeleted
+		trashed, err = h.recycle(a.Namespace, a.Store, backend, a.Bucket, "", a.RequestedBy)
+		if err == nil && trashed == nil {
+			err = backend.DeleteBucket(a.Bucket)
+		}
+	case auth.ActionDeleteObject:
+		trashed, err = h.recycle(a.Namespace, a.Store, backend, a.Bucket, a.Path, a.RequestedBy)
+		if err == nil && trashed == nil {
+			err = backend.DeleteObject(a.Bucket, a.Path)
+		}
+	case auth.ActionPurgeTrash:
+		item, err := h.trashedItem(a.Namespace, a.Store, a.Bucket, a.Path)
+		if err != nil {
+			return err
+		}
+		return h.purge(backend, item)
+	default:
+		return errors.Errorf("unknown action %s", a.Op)
+	}
+	if err != nil {
+		return err
+	}
+	// a bucket in the recycle bin keeps its objects, quota usage and lifecycle rules until it is purged
+	if trashed == nil || trashed.Path != "" {
+		if err := h.quotas.Remove(a.Namespace, a.Store, a.Bucket, a.Path); err != nil {
+			return err
+		}
+		if err := h.lifecycles.Forget(a.Namespace, a.Store, a.Bucket, a.Path); err != nil {
+			return err
+		}
+		if err := h.retentions.Release(a.Namespace, a.Store, a.Bucket, a.Path); err != nil {
+			return err
+		}
+	}
+	h.publish(a.Namespace, event, a.Store, a.Bucket, a.Path, &storageEvent{Store: a.Store, Bucket: a.Bucket, Path: a.Path})
+	h.changed(a.Namespace, &auth.Change{Type: auth.ChangeDeleted, Store: a.Store, Bucket: a.Bucket, Path: a.Path})
+	return nil
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/iotexproject/phoenix/auth"
+)
+
+// ListAccessRules lists the rules of the access lists. Access lists can only be managed with owner session of
+// an operator
+// example: curl -H "Authorization: Bearer session" http://localhost:8080/admin/access-lists
+func (h *StorageHandler) ListAccessRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.accessLists.Rules()
+	if err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	renderJSON(w, http.StatusOK, H{"rules": rules})
+}
+
+// PutAccessRule allows or denies an issuer address, a subject or a client network, it applies to requests at once
+// example: curl -H 'Content-Type: application/json' -H "Authorization: Bearer session" -d '{"list": "deny", "kind": "cidr", "value": "203.0.113.0/24", "notes": "abuse"}' http://localhost:8080/admin/access-lists
+func (h *StorageHandler) PutAccessRule(w http.ResponseWriter, r *http.Request) {
+	item := &accessRuleObject{}
+	if err := decodeAndCloseRequest(r, item); err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	rule := &auth.AccessRule{
+		List:  item.List,
+		Kind:  item.Kind,
+		Value: item.Value,
+		Notes: item.Notes,
+	}
+	switch err := h.accessLists.PutRule(rule); errors.Cause(err) {
+	case nil:
+		break
+	case auth.ErrAccessRule:
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	default:
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	h.log.Info("access rule added", zap.String("list", rule.List), zap.String("kind", rule.Kind), zap.String("value", rule.Value))
+	renderJSON(w, http.StatusOK, H{"message": "successful", "rule": rule})
+}
+
+// DelAccessRule removes a rule of the access lists, the value is given in query as it can contain /
+// example: curl -H "Authorization: Bearer session" -X DELETE 'http://localhost:8080/admin/access-lists?list=deny&kind=cidr&value=203.0.113.0/24'
+func (h *StorageHandler) DelAccessRule(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	list, kind, value := query.Get("list"), query.Get("kind"), query.Get("value")
+	switch err := h.accessLists.DelRule(list, kind, value); errors.Cause(err) {
+	case nil:
+		break
+	case auth.ErrAccessRule:
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	case auth.ErrAccessRuleNotFound:
+		renderJSON(w, http.StatusNotFound, H{"message": err.Error()})
+		return
+	default:
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	h.log.Info("access rule removed", zap.String("list", list), zap.String("kind", kind), zap.String("value", value))
+	renderJSON(w, http.StatusOK, H{"message": "successful"})
+}
+
+// operators returns the namespaces of the operators configured, invalid addresses are skipped
+func (h *StorageHandler) operators() []string {
+	namespaces := make([]string, 0, len(h.cfg.Admin.Operators))
+	for _, addr := range h.cfg.Admin.Operators {
+		namespace, err := auth.OwnerNamespace(addr)
+		if err != nil {
+			h.log.Error("invalid operator address", zap.String("address", addr), zap.Error(err))
+			continue
+		}
+		namespaces = append(namespaces, namespace)
+	}
+	return namespaces
+}
@@ -0,0 +1,203 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/iotexproject/iotex-antenna-go/v2/jwt"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/iotexproject/phoenix/auth"
+	"github.com/iotexproject/phoenix/handler/midware"
+)
+
+// auditColumns are the columns of audit log exported as csv
+var auditColumns = []string{"seq", "time", "issuer", "token", "subject", "op", "bucket", "path", "bytes", "status", "result", "ip", "prev", "hash"}
+
+// GetAudit returns the audit log of requests to the owner's data, filtered by time, token hash, bucket and path,
+// as json, or as csv with format=csv. The audit log is verified first, a tampered log is reported with 409
+// example: curl -H "Authorization: Bearer session" 'http://localhost:8080/audit?from=1607772249&bucket=test&format=csv'
+func (h *StorageHandler) GetAudit(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	filter := &auth.AuditFilter{
+		Token:  query.Get("token"),
+		Bucket: query.Get("bucket"),
+		Path:   query.Get("path"),
+		Verify: query.Get("verify") == "true",
+	}
+	for name, t := range map[string]*int64{"from": &filter.From, "to": &filter.To} {
+		if v := query.Get(name); v != "" {
+			var err error
+			if *t, err = strconv.ParseInt(v, 10, 64); err != nil {
+				renderJSON(w, http.StatusBadRequest, H{"message": name + " must be unix time"})
+				return
+			}
+		}
+	}
+
+	records, err := h.audit.Query(claims.Namespace, filter)
+	switch errors.Cause(err) {
+	case nil:
+		break
+	case auth.ErrAuditChain:
+		renderJSON(w, http.StatusConflict, H{"message": err.Error()})
+		return
+	default:
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	if query.Get("format") != "csv" {
+		renderJSON(w, http.StatusOK, H{"records": records})
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit.csv"`)
+	if err := writeAuditCSV(w, records); err != nil {
+		h.log.Error("failed to export audit log", zap.Error(err))
+	}
+}
+
+// auditTrail appends each request authenticated by a token to the audit log of the owner, and meters it. It must
+// come right after the token is authenticated so requests rejected afterwards are recorded as well
+func (h *StorageHandler) auditTrail(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := r.Context().Value(auth.TokenCtxKey).(*auth.Claims)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rec := &auth.AuditRecord{
+			Time:    time.Now().Unix(),
+			Issuer:  claims.Issuer,
+			Token:   claims.Hash(),
+			Subject: claims.Subject,
+			IP:      midware.ClientIP(r),
+		}
+		body := &countingReader{ReadCloser: r.Body}
+		if r.Body != nil {
+			r.Body = body
+		}
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), auth.AuditCtxKey, rec)))
+
+		// the bucket of a bucket created is in the body, which the handler records
+		if rec.Op == "" {
+			rec.Op, rec.Bucket, rec.Path, _ = routeOf(r.Method, r.URL.Path)
+		}
+		// bytes transferred are the content downloaded, or the body uploaded
+		rec.Bytes = body.n
+		if rec.Op == jwt.READ {
+			rec.Bytes = int64(ww.BytesWritten())
+		}
+		rec.Status = ww.Status()
+		rec.Result = auditResult(rec.Status)
+		if err := h.audit.Append(claims.Root().Namespace, rec); err != nil {
+			h.log.Error("failed to append audit log", zap.Error(err))
+		}
+		if rec.Result == auth.AuditDenied {
+			h.publish(claims.Root().Namespace, EventRequestDenied, claims.Store(), rec.Bucket, rec.Path, rec)
+		}
+		if err := h.metering.Record(claims.Root().Namespace, rec.Token, rec.Op, body.n, int64(ww.BytesWritten()), time.Unix(rec.Time, 0)); err != nil {
+			h.log.Error("failed to meter request", zap.Error(err))
+		}
+	})
+}
+
+// auditLink appends the request authorized by a link in place of a token to the audit log of the owner, as the
+// request of the token making the link, and meters it. The record is filled in with the outcome of the request
+func (h *StorageHandler) auditLink(r *http.Request, ww middleware.WrapResponseWriter, in int64, namespace string, chain []string, rec *auth.AuditRecord) {
+	rec.Time = time.Now().Unix()
+	if len(chain) > 0 {
+		rec.Token = chain[0]
+	}
+	rec.IP = midware.ClientIP(r)
+	rec.Bytes = in
+	if rec.Op == jwt.READ {
+		rec.Bytes = int64(ww.BytesWritten())
+	}
+	rec.Status = ww.Status()
+	rec.Result = auditResult(rec.Status)
+	if err := h.audit.Append(namespace, rec); err != nil {
+		h.log.Error("failed to append audit log", zap.Error(err))
+	}
+	if err := h.metering.Record(namespace, rec.Token, rec.Op, in, int64(ww.BytesWritten()), time.Unix(rec.Time, 0)); err != nil {
+		h.log.Error("failed to meter request", zap.Error(err))
+	}
+}
+
+// auditResult returns the result of request recorded in the audit log according to the status responded
+func auditResult(status int) string {
+	switch {
+	case status < http.StatusBadRequest:
+		return auth.AuditSucceeded
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return auth.AuditDenied
+	default:
+		return auth.AuditFailed
+	}
+}
+
+// auditRecord returns the audit record of the request, for the handler to fill in what only it knows
+func auditRecord(r *http.Request) *auth.AuditRecord {
+	if rec, ok := r.Context().Value(auth.AuditCtxKey).(*auth.AuditRecord); ok {
+		return rec
+	}
+	return &auth.AuditRecord{}
+}
+
+func writeAuditCSV(w io.Writer, records []*auth.AuditRecord) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(auditColumns); err != nil {
+		return err
+	}
+	for _, rec := range records {
+		if err := cw.Write([]string{
+			strconv.FormatUint(rec.Seq, 10),
+			time.Unix(rec.Time, 0).UTC().Format(time.RFC3339),
+			rec.Issuer,
+			rec.Token,
+			rec.Subject,
+			rec.Op,
+			rec.Bucket,
+			rec.Path,
+			strconv.FormatInt(rec.Bytes, 10),
+			strconv.Itoa(rec.Status),
+			rec.Result,
+			rec.IP,
+			rec.Prev,
+			rec.Hash,
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// countingReader counts the bytes read from the request body
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -20,6 +20,12 @@ var (
 	ErrorPermissionDenied = errors.New("You don't have permission for this")
 	ErrorBodyEmpty        = errors.New("Body must be set")
 	ErrorStoreCtx         = errors.New("Failed to get store in context")
+	ErrorPresignLimited   = errors.New("Tokens carrying a quota or usage limit can't presign URLs")
+	ErrorShareLimited     = errors.New("Tokens carrying a quota or usage limit can't share links")
+	ErrorBucketTrashed    = errors.New("Bucket is in the recycle bin")
+	ErrorLinkRestricted   = errors.New("Tokens bound to client IPs, user agents or time windows can't presign URLs or share links")
+	ErrorKeyReserved      = errors.New("Key ID " + auth.MintKeyID + " is reserved for tokens minted by phoenix")
+	ErrorCoOwnedExpiry    = errors.New("Objects of co-owned stores are only deleted with the approval of co-owners, not by lifecycle rules or TTLs")
 )
 
 // H is a shortcut for map[string]interface{}
@@ -35,12 +41,173 @@ type registerObject struct {
 	Endpoint string `json:"endpoint"`
 	Key      string `json:"key"`
 	Token    string `json:"token"`
+	// Owners and Threshold make the store co-owned, if given
+	Owners    []string `json:"owners"`
+	Threshold int      `json:"threshold"`
+	// Quota limits the store, and BucketQuota each bucket in it, if given
+	Quota       *auth.Quota `json:"quota"`
+	BucketQuota *auth.Quota `json:"bucketQuota"`
+	// RecycleBin keeps objects and buckets deleted from the store for its days, if given
+	RecycleBin *auth.RecycleBin `json:"recycleBin"`
+}
+
+type revokeObject struct {
+	Token string `json:"token"`
+}
+
+type challengeObject struct {
+	Address string `json:"address"`
+}
+
+type loginObject struct {
+	Nonce     string `json:"nonce"`
+	Signature string `json:"signature"`
+	Type      string `json:"type"`
+}
+
+type issuerKeyObject struct {
+	ID        string `json:"kid"`
+	Type      string `json:"type"`
+	PublicKey string `json:"publicKey"`
+}
+
+type identityObject struct {
+	Issuer    string `json:"issuer"`
+	Identity  string `json:"identity"`
+	Subject   string `json:"subject"`
+	Scope     string `json:"scope"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+type introspectObject struct {
+	Token  string `json:"token"`
+	Owner  string `json:"owner"`
+	Method string `json:"method"`
+	Path   string `json:"path"`
+}
+
+type policyObject struct {
+	Store      string `json:"store"`
+	Bucket     string `json:"bucket"`
+	Expression string `json:"expression"`
+}
+
+type grantObject struct {
+	Trustee   string `json:"trustee"`
+	Subject   string `json:"subject"`
+	Scope     string `json:"scope"`
+	ExpiresAt int64  `json:"expiresAt"`
+	Notes     string `json:"notes"`
+	Token     string `json:"token"`
+	Mint      bool   `json:"mint"`
+}
+
+type accessRequestObject struct {
+	Owner         string `json:"owner"`
+	Trustee       string `json:"trustee"`
+	Subject       string `json:"subject"`
+	Scope         string `json:"scope"`
+	Duration      int64  `json:"duration"`
+	Justification string `json:"justification"`
+}
+
+type decisionObject struct {
+	Reason string `json:"reason"`
+}
+
+type approvalObject struct {
+	Owner     string `json:"owner"`
+	Signature string `json:"signature"`
+}
+
+type embargoObject struct {
+	Owner     string `json:"owner"`
+	Store     string `json:"store"`
+	Bucket    string `json:"bucket"`
+	Path      string `json:"path"`
+	ReleaseAt int64  `json:"releaseAt"`
+	Signature string `json:"signature"`
+}
+
+type redeemObject struct {
+	Owner  string `json:"owner"`
+	Secret string `json:"secret"`
+}
+
+type accessRuleObject struct {
+	List  string `json:"list"`
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+	Notes string `json:"notes"`
+}
+
+type subscriptionObject struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Store  string   `json:"store"`
+	Bucket string   `json:"bucket"`
+	Prefix string   `json:"prefix"`
+}
+
+type presignObject struct {
+	Bucket    string `json:"bucket"`
+	Path      string `json:"path"`
+	Method    string `json:"method"`
+	ExpiresIn int64  `json:"expiresIn"`
+	Proxy     bool   `json:"proxy"`
+}
+
+type shareObject struct {
+	Bucket       string `json:"bucket"`
+	Path         string `json:"path"`
+	ExpiresIn    int64  `json:"expiresIn"`
+	MaxDownloads int    `json:"maxDownloads"`
+	SingleUse    bool   `json:"singleUse"`
+}
+
+type lifecycleObject struct {
+	Store        string `json:"store"`
+	Bucket       string `json:"bucket"`
+	Prefix       string `json:"prefix"`
+	Action       string `json:"action"`
+	Days         int    `json:"days"`
+	StorageClass string `json:"storageClass"`
+}
+
+type retentionObject struct {
+	Store  string `json:"store"`
+	Bucket string `json:"bucket"`
+	Days   int    `json:"days"`
+}
+
+type objectLockObject struct {
+	Store       string `json:"store"`
+	Bucket      string `json:"bucket"`
+	Path        string `json:"path"`
+	RetainUntil int64  `json:"retainUntil"`
+	LegalHold   *bool  `json:"legalHold"`
 }
 
 func (r *registerObject) Store() auth.Store {
 	return auth.NewStore(r.Name, r.Region, r.Endpoint, r.Key, r.Token)
 }
 
+// Ownership returns the ownership of co-owned store, nil if owners are not given
+func (r *registerObject) Ownership() (*auth.Ownership, error) {
+	if len(r.Owners) == 0 {
+		return nil, nil
+	}
+	ownership := &auth.Ownership{Store: r.Name, Threshold: r.Threshold}
+	for _, owner := range r.Owners {
+		addr, err := auth.ParseAddress(owner)
+		if err != nil {
+			return nil, err
+		}
+		ownership.Owners = append(ownership.Owners, addr.Hex()[2:])
+	}
+	return ownership, ownership.Validate()
+}
+
 func decodeAndCloseRequest(r *http.Request, v interface{}) error {
 	err := json.NewDecoder(r.Body).Decode(v)
 	io.Copy(ioutil.Discard, r.Body)
@@ -57,3 +224,27 @@ func renderJSON(w http.ResponseWriter, status int, v interface{}) {
 		return
 	}
 }
+
+// StoreQuota returns the quota of the store and its buckets, nil if neither is given
+func (r *registerObject) StoreQuota() (*auth.StoreQuota, error) {
+	if r.Quota == nil && r.BucketQuota == nil {
+		return nil, nil
+	}
+	quota := &auth.StoreQuota{Store: r.Name, Quota: r.Quota, Bucket: r.BucketQuota}
+	if err := quota.Validate(); err != nil {
+		return nil, err
+	}
+	return quota, nil
+}
+
+// StoreRecycleBin returns the recycle bin of the store, nil if it is not given
+func (r *registerObject) StoreRecycleBin() (*auth.RecycleBin, error) {
+	if r.RecycleBin == nil {
+		return nil, nil
+	}
+	bin := &auth.RecycleBin{Store: r.Name, Days: r.RecycleBin.Days}
+	if err := bin.Validate(); err != nil {
+		return nil, err
+	}
+	return bin, nil
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"encoding/hex"
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"github.com/iotexproject/phoenix/auth"
+)
+
+// PutEmbargo keeps a bucket, or an object if path is given, unreadable until the release time, or until the
+// owner signs the release message if releaseAt is not given. Embargoes can only be managed with owner session
+// example: curl -H 'Content-Type: application/json' -H "Authorization: Bearer session" -d '{"store": "s3", "bucket": "results", "path": "q1.csv", "releaseAt": 1607772249}' http://localhost:8080/embargoes
+func (h *StorageHandler) PutEmbargo(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	item := &embargoObject{}
+	if err := decodeAndCloseRequest(r, item); err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	e := &auth.Embargo{
+		Store:     item.Store,
+		Bucket:    item.Bucket,
+		Path:      item.Path,
+		ReleaseAt: item.ReleaseAt,
+	}
+	if err := h.embargoes.PutEmbargo(claims.Namespace, e); err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	renderJSON(w, http.StatusOK, H{"message": "successful", "embargo": e, "release": e.Message()})
+}
+
+// ListEmbargoes lists the embargoes of the owner pending release, with the message to sign to release each
+// example: curl -H "Authorization: Bearer session" http://localhost:8080/embargoes
+func (h *StorageHandler) ListEmbargoes(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	list, err := h.embargoes.ListEmbargoes(claims.Namespace)
+	if err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	pending := make([]H, 0, len(list))
+	for _, e := range list {
+		pending = append(pending, H{"embargo": e, "release": e.Message()})
+	}
+	renderJSON(w, http.StatusOK, H{"embargoes": pending})
+}
+
+// ReleaseEmbargo releases an embargo with the owner's personal_sign signature of its release message
+// example: curl -H 'Content-Type: application/json' -d '{"owner": "io1...", "store": "s3", "bucket": "results", "path": "q1.csv", "signature": "xxx"}' http://localhost:8080/embargoes/release
+func (h *StorageHandler) ReleaseEmbargo(w http.ResponseWriter, r *http.Request) {
+	item := &embargoObject{}
+	if err := decodeAndCloseRequest(r, item); err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	sig, err := hex.DecodeString(item.Signature)
+	if err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	e, err := h.embargoes.Release(item.Owner, &auth.Embargo{Store: item.Store, Bucket: item.Bucket, Path: item.Path}, sig)
+	switch errors.Cause(err) {
+	case nil:
+		renderJSON(w, http.StatusOK, H{"message": "successful", "embargo": e})
+	case auth.ErrEmbargoNotFound:
+		renderJSON(w, http.StatusNotFound, H{"message": err.Error()})
+	case auth.ErrChallenge:
+		renderJSON(w, http.StatusForbidden, H{"message": err.Error()})
+	default:
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+	}
+}
+
+// checkEmbargo renders the error and returns false if the bucket or object is under embargo, the owner is not
+// held by embargoes
+func (h *StorageHandler) checkEmbargo(w http.ResponseWriter, claims *auth.Claims, bucket, path string) bool {
+	if claims.Owner {
+		return true
+	}
+	switch err := h.embargoes.Check(claims.Root().Namespace, claims.Store(), bucket, path); errors.Cause(err) {
+	case nil:
+		return true
+	case auth.ErrEmbargoed:
+		renderJSON(w, http.StatusForbidden, H{"message": err.Error()})
+	default:
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+	}
+	return false
+}
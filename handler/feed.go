@@ -0,0 +1,274 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iotexproject/iotex-antenna-go/v2/jwt"
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+
+	"github.com/iotexproject/phoenix/auth"
+	"github.com/iotexproject/phoenix/handler/midware"
+	"github.com/iotexproject/phoenix/json"
+)
+
+const (
+	// watchBuffer is the number of changes a watcher can fall behind, slower watchers are disconnected to resume
+	// from the last event ID
+	watchBuffer = 64
+
+	// watchHeartbeat is the interval of comments keeping idle event streams open
+	watchHeartbeat = 15 * time.Second
+)
+
+// changeHub fans changes of buckets out to their watchers
+type changeHub struct {
+	mu       sync.Mutex
+	watchers map[string]map[chan *auth.Change]bool
+	closed   bool
+}
+
+func newChangeHub() *changeHub {
+	return &changeHub{watchers: map[string]map[chan *auth.Change]bool{}}
+}
+
+// watch returns the channel of changes of the bucket, nil if the hub is closed
+func (hub *changeHub) watch(bucket string) chan *auth.Change {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if hub.closed {
+		return nil
+	}
+	ch := make(chan *auth.Change, watchBuffer)
+	if hub.watchers[bucket] == nil {
+		hub.watchers[bucket] = map[chan *auth.Change]bool{}
+	}
+	hub.watchers[bucket][ch] = true
+	return ch
+}
+
+func (hub *changeHub) unwatch(bucket string, ch chan *auth.Change) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if hub.watchers[bucket][ch] {
+		delete(hub.watchers[bucket], ch)
+		close(ch)
+	}
+	if len(hub.watchers[bucket]) == 0 {
+		delete(hub.watchers, bucket)
+	}
+}
+
+func (hub *changeHub) broadcast(bucket string, c *auth.Change) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	for ch := range hub.watchers[bucket] {
+		select {
+		case ch <- c:
+		default:
+			delete(hub.watchers[bucket], ch)
+			close(ch)
+		}
+	}
+}
+
+// close ends all watches, so the server can shut down
+func (hub *changeHub) close() {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	hub.closed = true
+	for bucket, watchers := range hub.watchers {
+		for ch := range watchers {
+			close(ch)
+		}
+		delete(hub.watchers, bucket)
+	}
+}
+
+// CloseWatches ends the change feeds being watched, it is called on server shutdown
+func (h *StorageHandler) CloseWatches() {
+	h.hub.close()
+}
+
+// changed records the change of owner's bucket, and sends it to the watchers of the bucket
+func (h *StorageHandler) changed(namespace string, c *auth.Change) {
+	if err := h.changes.Record(namespace, c); err != nil {
+		h.log.Error("failed to record change", zap.String("bucket", c.Bucket), zap.String("path", c.Path), zap.Error(err))
+		return
+	}
+	h.hub.broadcast(namespace+"/"+c.Store+"/"+c.Bucket, c)
+}
+
+// watchBucket streams the changes of the bucket as server-sent events, or over WebSocket if the request upgrades
+// to it, until the client leaves, the token expires or is revoked. Changes after the ID in header Last-Event-ID,
+// or in query lastEventId, are replayed first
+func (h *StorageHandler) watchBucket(w http.ResponseWriter, r *http.Request, claims *auth.Claims, bucket string) {
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("lastEventId")
+	}
+	var after uint64
+	if lastID != "" {
+		var err error
+		if after, err = strconv.ParseUint(lastID, 10, 64); err != nil {
+			renderJSON(w, http.StatusBadRequest, H{"message": "last event ID must be a change ID"})
+			return
+		}
+	}
+
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		// the token authenticates the client, so connections from any origin are accepted
+		websocket.Server{Handler: func(ws *websocket.Conn) {
+			ctx, cancel := context.WithCancel(r.Context())
+			defer cancel()
+			go func() {
+				// the client only closes the connection
+				io.Copy(ioutil.Discard, ws)
+				cancel()
+			}()
+			h.watch(ctx, r, claims, bucket, after, func(c *auth.Change) error {
+				if c == nil {
+					return nil
+				}
+				return websocket.JSON.Send(ws, c)
+			})
+		}}.ServeHTTP(w, r)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		renderJSON(w, http.StatusInternalServerError, H{"message": "streaming is not supported"})
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	h.watch(r.Context(), r, claims, bucket, after, func(c *auth.Change) error {
+		if c == nil {
+			_, err := io.WriteString(w, ": ping\n\n")
+			flusher.Flush()
+			return err
+		}
+		data, err := json.Marshal(c)
+		if err != nil {
+			return err
+		}
+		if _, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", c.EventID(), c.Type, data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	})
+}
+
+// watch sends the changes of the bucket after the change ID, then those to come, to the client. A nil change is
+// sent as heartbeat. The watch ends once any token of the chain is revoked or the access lists reject it, which is
+// checked on each change and heartbeat
+func (h *StorageHandler) watch(ctx context.Context, r *http.Request, claims *auth.Claims, bucket string, after uint64, send func(*auth.Change) error) {
+	namespace := claims.Root().Namespace
+	key := namespace + "/" + claims.Store() + "/" + bucket
+	ch := h.hub.watch(key)
+	if ch == nil {
+		return
+	}
+	defer h.hub.unwatch(key, ch)
+
+	// changes recorded while the log is read are sent twice, they are skipped by ID
+	backlog, err := h.changes.Since(namespace, claims.Store(), bucket, after)
+	if err != nil {
+		h.log.Error("failed to read changes", zap.String("bucket", bucket), zap.Error(err))
+		return
+	}
+	for _, c := range backlog {
+		if h.visible(r, claims, c) {
+			if err := send(c); err != nil {
+				return
+			}
+		}
+		after = c.ID
+	}
+
+	expiry := make(<-chan time.Time)
+	if claims.ExpiresAt != 0 {
+		timer := time.NewTimer(time.Until(time.Unix(claims.ExpiresAt, 0)))
+		defer timer.Stop()
+		expiry = timer.C
+	}
+	heartbeat := time.NewTicker(watchHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-expiry:
+			return
+		case <-heartbeat.C:
+			if !h.watchable(r, claims) {
+				return
+			}
+			if err := send(nil); err != nil {
+				return
+			}
+		case c, ok := <-ch:
+			if !ok || !h.watchable(r, claims) {
+				return
+			}
+			if c.ID <= after || !h.visible(r, claims, c) {
+				continue
+			}
+			if err := send(c); err != nil {
+				return
+			}
+			after = c.ID
+		}
+	}
+}
+
+// watchable returns true if no token of the chain has been revoked, the access lists accept the claims and the
+// client, and the restrictions of the token still allow the client now, since the watch started
+func (h *StorageHandler) watchable(r *http.Request, claims *auth.Claims) bool {
+	if err := h.revocation.Check(claims); err != nil {
+		return false
+	}
+	ip := midware.ClientIP(r)
+	if err := claims.Restrict(ip, r.UserAgent(), time.Now()); err != nil {
+		return false
+	}
+	return h.accessLists.Check(claims, ip) == nil
+}
+
+// visible returns true if the claims can read the object changed, changes of the bucket itself are visible to
+// all watchers
+func (h *StorageHandler) visible(r *http.Request, claims *auth.Claims, c *auth.Change) bool {
+	if c.Path == "" {
+		return true
+	}
+	if err := permit(claims, jwt.READ, c.Bucket, c.Path); err != nil {
+		return false
+	}
+	if claims.Owner {
+		return true
+	}
+	namespace := claims.Root().Namespace
+	if err := h.policies.Evaluate(namespace, policyInput(r, claims, jwt.READ, c.Bucket, c.Path)); err != nil {
+		return false
+	}
+	return h.embargoes.Check(namespace, c.Store, c.Bucket, c.Path) == nil
+}
@@ -0,0 +1,202 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/pkg/errors"
+
+	"github.com/iotexproject/phoenix/auth"
+)
+
+// CreateGrant records a grant to a trustee, with the token the owner issued, or a token phoenix mints on the
+// owner's behalf. Grants can only be managed with owner session
+// example: curl -H 'Content-Type: application/json' -H "Authorization: Bearer session" -d '{"trustee": "alice", "subject": "s3/test", "scope": "Read", "expiresAt": 1607772249, "mint": true}' http://localhost:8080/grants
+func (h *StorageHandler) CreateGrant(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	item := &grantObject{}
+	if err := decodeAndCloseRequest(r, item); err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+
+	grant := &auth.Grant{
+		Trustee:   item.Trustee,
+		Subject:   item.Subject,
+		Scope:     item.Scope,
+		ExpiresAt: item.ExpiresAt,
+		Notes:     item.Notes,
+	}
+	token := item.Token
+	switch {
+	case token != "":
+		var granted *auth.Claims
+		var err error
+		if auth.IsCredential(token) {
+			// a credential is granted to be presented by its holder
+			granted, err = h.verifier.VerifyCredential(token)
+		} else {
+			granted, err = h.parseToken(token, "")
+		}
+		if err != nil {
+			renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+			return
+		}
+		if granted.Parent != nil || granted.Namespace != claims.Namespace {
+			renderJSON(w, http.StatusBadRequest, H{"message": "token must be issued by the owner"})
+			return
+		}
+		grant.Subject, grant.Scope, grant.ExpiresAt = granted.Subject, granted.Scope, granted.ExpiresAt
+		grant.TokenHash = granted.Hash()
+	case item.Mint:
+		if h.mintKey == nil {
+			renderJSON(w, http.StatusServiceUnavailable, H{"message": "minting is not available"})
+			return
+		}
+		if grant.Subject == "" || grant.Scope == "" {
+			renderJSON(w, http.StatusBadRequest, H{"message": "subject and scope are required"})
+			return
+		}
+	default:
+		renderJSON(w, http.StatusBadRequest, H{"message": "token or mint is required"})
+		return
+	}
+
+	if err := h.grants.CreateGrant(claims.Namespace, grant); err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	ret := H{"message": "successful", "grant": grant}
+	if token == "" {
+		token, err := h.mintGrantToken(claims.Namespace, grant)
+		if err != nil {
+			renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+			return
+		}
+		ret["token"] = token
+	}
+	renderJSON(w, http.StatusOK, ret)
+}
+
+// ListGrants lists the grants of the owner, of a registered store if store is given
+// example: curl -H "Authorization: Bearer session" http://localhost:8080/grants?store=s3
+func (h *StorageHandler) ListGrants(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	grants, err := h.grants.ListGrants(claims.Namespace, r.URL.Query().Get("store"))
+	if err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	renderJSON(w, http.StatusOK, H{"grants": grants})
+}
+
+// EditGrant edits the trustee, notes and expiry of a grant, the expiry is enforced if live grant is required
+// example: curl -H 'Content-Type: application/json' -H "Authorization: Bearer session" -d '{"notes": "audit Q1", "expiresAt": 1607772249}' http://localhost:8080/grants/xxx
+func (h *StorageHandler) EditGrant(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	item := &grantObject{}
+	if err := decodeAndCloseRequest(r, item); err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	grant, status, err := h.getGrant(claims.Namespace, chi.URLParam(r, "id"))
+	if err != nil {
+		renderJSON(w, status, H{"message": err.Error()})
+		return
+	}
+	if item.Trustee != "" {
+		grant.Trustee = item.Trustee
+	}
+	if item.Notes != "" {
+		grant.Notes = item.Notes
+	}
+	if item.ExpiresAt != 0 {
+		grant.ExpiresAt = item.ExpiresAt
+	}
+	if err := h.grants.UpdateGrant(claims.Namespace, grant); err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	renderJSON(w, http.StatusOK, H{"message": "successful", "grant": grant})
+}
+
+// RevokeGrant revokes a grant and its token
+// example: curl -H "Authorization: Bearer session" -X DELETE http://localhost:8080/grants/xxx
+func (h *StorageHandler) RevokeGrant(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	grant, status, err := h.getGrant(claims.Namespace, chi.URLParam(r, "id"))
+	if err != nil {
+		renderJSON(w, status, H{"message": err.Error()})
+		return
+	}
+	if grant.TokenHash != "" {
+		if err := h.revocation.RevokeHash(grant.TokenHash); err != nil {
+			renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+			return
+		}
+	}
+	grant.RevokedAt = time.Now().Unix()
+	if err := h.grants.UpdateGrant(claims.Namespace, grant); err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	renderJSON(w, http.StatusOK, H{"message": "successful", "grant": grant})
+}
+
+// mintGrantToken mints the token of the grant and records it in the grant
+func (h *StorageHandler) mintGrantToken(namespace string, grant *auth.Grant) (string, error) {
+	token, err := auth.MintToken(h.keys, h.mintKey, namespace, grant)
+	if err != nil {
+		return "", err
+	}
+	grant.TokenHash = (&auth.Claims{Token: token}).Hash()
+	if err := h.grants.UpdateGrant(namespace, grant); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (h *StorageHandler) getGrant(namespace, id string) (*auth.Grant, int, error) {
+	grant, err := h.grants.GetGrant(namespace, id)
+	switch errors.Cause(err) {
+	case nil:
+		return grant, http.StatusOK, nil
+	case auth.ErrGrantNotFound:
+		return nil, http.StatusNotFound, err
+	default:
+		return nil, http.StatusInternalServerError, err
+	}
+}
+
+// ownerClaims returns the claims of owner session, and renders the error if the request isn't authenticated by it
+func (h *StorageHandler) ownerClaims(w http.ResponseWriter, r *http.Request) (*auth.Claims, bool) {
+	claims, ok := r.Context().Value(auth.TokenCtxKey).(*auth.Claims)
+	if !ok {
+		renderJSON(w, http.StatusInternalServerError, H{"message": http.StatusText(http.StatusInternalServerError)})
+		return nil, false
+	}
+	if !claims.Owner {
+		renderJSON(w, http.StatusForbidden, H{"message": ErrorPermissionDenied.Error()})
+		return nil, false
+	}
+	return claims, true
+}
@@ -7,11 +7,16 @@
 package handler
 
 import (
+	"crypto/ed25519"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi"
-	"github.com/iotexproject/go-pkgs/crypto"
+	"github.com/go-chi/chi/middleware"
+	"github.com/iotexproject/iotex-antenna-go/v2/jwt"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 
@@ -24,17 +29,91 @@ import (
 )
 
 type StorageHandler struct {
-	cfg  *config.Config
-	log  *zap.Logger
-	cred auth.Credential
+	cfg        *config.Config
+	log        *zap.Logger
+	cred       auth.Credential
+	usage      auth.Usage
+	revocation auth.Revocation
+	sessions   auth.Sessions
+	keys       auth.Keys
+	issuers    auth.IssuerResolver
+	verifier   *auth.PresentationVerifier
+	oidc       *auth.OIDCVerifier
+	identities auth.Identities
+	policies   auth.Policies
+	grants     auth.Grants
+	mintKey    ed25519.PrivateKey
+	shareKey   []byte
+
+	replayGuard    auth.ReplayGuard
+	accessRequests auth.AccessRequests
+	ownerships     auth.Ownerships
+	embargoes      auth.Embargoes
+	accessLists    auth.AccessLists
+	quotas         auth.Quotas
+	audit          auth.Audit
+	metering       auth.Metering
+	subscriptions  auth.Subscriptions
+	deliveries     auth.Deliveries
+	changes        auth.Changes
+	shares         auth.Shares
+	lifecycles     auth.Lifecycles
+	trash          auth.Trash
+	retentions     auth.Retentions
+	hub            *changeHub
+	webhook        *http.Client
+	operatorHook   *http.Client  // posts events of access requests to webhook.url
+	wake           chan struct{} // wakes delivery of webhook events queued
 }
 
-func NewStorageHandler(cfg *config.Config, cred auth.Credential) *StorageHandler {
-	return &StorageHandler{
-		cfg:  cfg,
-		log:  log.Logger("handler"),
-		cred: cred,
+func NewStorageHandler(cfg *config.Config, kv db.KVStore) *StorageHandler {
+	keys := auth.NewKeys(kv)
+	h := &StorageHandler{
+		cfg:        cfg,
+		log:        log.Logger("handler"),
+		cred:       auth.NewCredential(kv),
+		usage:      auth.NewUsage(kv),
+		revocation: auth.NewRevocation(kv),
+		sessions:   auth.NewSessions(kv, sessionTTL(cfg)),
+		keys:       keys,
+		issuers:    auth.NewIssuerResolver(keys),
+		verifier:   auth.NewPresentationVerifier(auth.NewDIDResolver(keys), audience(cfg)),
+		oidc:       newOIDCVerifier(cfg),
+		identities: auth.NewIdentities(kv),
+		policies:   auth.NewPolicies(kv),
+		grants:     auth.NewGrants(kv),
+
+		replayGuard:    auth.NewReplayGuard(kv, signatureWindow(cfg)),
+		accessRequests: auth.NewAccessRequests(kv),
+		ownerships:     auth.NewOwnerships(kv),
+		embargoes:      auth.NewEmbargoes(kv),
+		accessLists:    auth.NewAccessLists(kv),
+		quotas:         auth.NewQuotas(kv, globalQuota(cfg)),
+		audit:          auth.NewAudit(kv),
+		metering:       auth.NewMetering(kv),
+		subscriptions:  auth.NewSubscriptions(kv),
+		deliveries:     auth.NewDeliveries(kv, deliveryRetention(cfg)),
+		changes:        auth.NewChanges(kv),
+		shares:         auth.NewShares(kv),
+		lifecycles:     auth.NewLifecycles(kv),
+		trash:          auth.NewTrash(kv),
+		retentions:     auth.NewRetentions(kv),
+		hub:            newChangeHub(),
+		webhook:        newWebhookClient(cfg),
+		operatorHook:   &http.Client{Timeout: webhookTimeout(cfg)},
+		wake:           make(chan struct{}, 1),
+	}
+	mintKey, err := auth.MintKey(kv)
+	if err != nil {
+		h.log.Error("failed to load mint key", zap.Error(err))
+	}
+	h.mintKey = mintKey
+	shareKey, err := auth.ShareKey(kv)
+	if err != nil {
+		h.log.Error("failed to load share key", zap.Error(err))
 	}
+	h.shareKey = shareKey
+	return h
 }
 
 func (h *StorageHandler) ServerMux(r chi.Router) http.Handler {
@@ -42,17 +121,122 @@ func (h *StorageHandler) ServerMux(r chi.Router) http.Handler {
 		w.Write([]byte("OK"))
 		return
 	}))
+	r.With(midware.PublicRateLimit(h.cfg.Server.PublicRateLimit)).Post("/token/introspect", h.IntrospectToken) //parse token and explain whether a request is allowed
+	r.Route("/login", func(r chi.Router) {
+		r.Use(midware.PublicRateLimit(h.cfg.Server.PublicRateLimit))
+		r.Post("/challenge", h.LoginChallenge) //issue nonce for owner to sign with wallet
+		r.Post("/", h.Login)                   //verify signed nonce and create owner session
+	})
+	r.Group(func(r chi.Router) {
+		r.Use(midware.PublicRateLimit(h.cfg.Server.PublicRateLimit))
+		r.Post("/access-requests", h.FileAccessRequest)               //trustee requests access to owner's data
+		r.Post("/access-requests/{id}/redeem", h.RedeemAccessRequest) //trustee redeems token of approved request
+		r.Get("/actions/{id}", h.GetAction)                           //get pending action of co-owned store
+		r.Post("/actions/{id}/approve", h.ApproveAction)              //co-owner approves action with signature
+	})
+	r.Post("/embargoes/release", h.ReleaseEmbargo)    //owner releases embargo with signature
+	r.Get("/presigned/{bucket}/*", h.PresignedObject) //download object with url presigned by phoenix
+	r.Put("/presigned/{bucket}/*", h.PresignedObject) //upload object with url presigned by phoenix
+	rateLimit := midware.RateLimit(h.cfg.Server.RateLimit)
+	r.Group(func(r chi.Router) {
+		// share links authorize the request in place of a token, for clients that can't send one
+		r.Use(midware.ShareLinkValid(h.shareKey, h.revocation))
+		r.Use(rateLimit...)
+		r.Get("/shared/{bucket}/*", h.GetSharedObject) //download object with share link
+	})
+	r.Route("/admin", func(r chi.Router) {
+		// operators manage access lists with owner session, they are exempt from access lists themselves
+		r.Use(midware.OwnerTokenValid(h.sessions, h.issuers))
+		r.Use(midware.OperatorOnly(h.operators()))
+		r.Use(rateLimit...)
+		r.Route("/access-lists", func(r chi.Router) {
+			r.Get("/", h.ListAccessRules)  //list rules of access lists
+			r.Post("/", h.PutAccessRule)   //allow or deny issuer, subject or client network
+			r.Delete("/", h.DelAccessRule) //remove rule of access lists
+		})
+		r.Get("/metering", h.ExportAllMetering) //export usage meters of all owners
+	})
 	r.Group(func(r chi.Router) {
-		r.Use(midware.JWTTokenValid)
-		r.Use(midware.RateLimit(h.cfg.Server.RateLimit)...)
+		// owner-only endpoints accept owner session as well
+		r.Use(midware.OwnerTokenValid(h.sessions, h.issuers))
+		r.Use(midware.TokenNotRevoked(h.revocation))
+		r.Use(midware.AccessListed(h.accessLists))
+		r.Use(midware.TokenRestricted())
+		r.Use(midware.ProofOfPossession(h.replayGuard))
+		r.Use(rateLimit...)
 		r.Route("/register", func(r chi.Router) {
 			r.Post("/", h.RegisterStorage)             //register storage endpoint
 			r.Delete("/{driver}", h.UnRegisterStorage) //unregister storage endpoint
 		})
+		r.Post("/revoke", h.RevokeToken) //revoke token and all tokens delegated from it
+		r.Route("/keys", func(r chi.Router) {
+			r.Post("/", h.PutIssuerKey)        //register key for signing tokens
+			r.Delete("/{kid}", h.DelIssuerKey) //remove key for signing tokens
+		})
+		r.Route("/policies", func(r chi.Router) {
+			r.Post("/", h.PutPolicy)                   //attach policy to store or bucket
+			r.Delete("/{store}", h.DelPolicy)          //remove policy of store
+			r.Delete("/{store}/{bucket}", h.DelPolicy) //remove policy of bucket
+		})
+		r.Route("/grants", func(r chi.Router) {
+			r.Post("/", h.CreateGrant)       //record or mint token granted to trustee
+			r.Get("/", h.ListGrants)         //list grants
+			r.Post("/{id}", h.EditGrant)     //edit grant
+			r.Delete("/{id}", h.RevokeGrant) //revoke grant and its token
+		})
+		r.Get("/audit", h.GetAudit)                                     //query and export audit log of data access
+		r.Get("/metering", h.ExportMetering)                            //export usage meters of owner and tokens
+		r.Get("/actions", h.ListActions)                                //list actions of stores owned or co-owned
+		r.Post("/embargoes", h.PutEmbargo)                              //hold bucket or object until release
+		r.Get("/embargoes", h.ListEmbargoes)                            //list embargoes pending release
+		r.Get("/access-requests", h.ListAccessRequests)                 //list access requests filed to owner
+		r.Post("/access-requests/{id}/approve", h.ApproveAccessRequest) //approve access request with grant
+		r.Post("/access-requests/{id}/deny", h.DenyAccessRequest)       //deny access request
+		r.Route("/identities", func(r chi.Router) {
+			r.Post("/", h.PutIdentity)       //grant access to identity of identity provider
+			r.Delete("/{id}", h.DelIdentity) //remove access of identity
+		})
+		r.Route("/webhooks", func(r chi.Router) {
+			r.Post("/", h.CreateSubscription)       //subscribe url to events of owner's data
+			r.Get("/", h.ListSubscriptions)         //list subscriptions
+			r.Get("/deliveries", h.ListDeliveries)  //list deliveries of events
+			r.Delete("/{id}", h.DeleteSubscription) //remove subscription
+		})
+		r.Route("/lifecycle", func(r chi.Router) {
+			r.Post("/", h.PutLifecycleRule)          //add rule deleting or archiving old objects of bucket
+			r.Get("/", h.ListLifecycleRules)         //list lifecycle rules
+			r.Get("/report", h.LifecycleReport)      //report what a sweep would do, without doing it
+			r.Delete("/{id}", h.DeleteLifecycleRule) //remove lifecycle rule
+		})
+		r.Route("/trash", func(r chi.Router) {
+			r.Get("/", h.ListTrash)                 //list objects and buckets in recycle bins
+			r.Post("/{id}/restore", h.RestoreTrash) //restore object or bucket from recycle bin
+			r.Delete("/{id}", h.PurgeTrash)         //delete object or bucket in recycle bin for good
+		})
+		r.Route("/retention", func(r chi.Router) {
+			r.Post("/", h.PutRetentionPolicy)                      //retain objects uploaded to bucket for days
+			r.Get("/", h.ListRetention)                            //list retention policies and object locks
+			r.Post("/objects", h.LockObject)                       //retain object or place legal hold on it
+			r.Delete("/{store}/{bucket}", h.DeleteRetentionPolicy) //remove retention policy of bucket
+		})
+	})
+	r.Group(func(r chi.Router) {
+		// verifiable presentation and token of identity provider are accepted besides JWT
+		r.Use(midware.PresentationValid(h.verifier, h.requiredGrants()))
+		r.Use(midware.OIDCTokenValid(h.oidc, h.identities))
+		r.Use(midware.JWTTokenValid(h.issuers, h.requiredGrants()))
+		r.Use(h.auditTrail)
+		r.Use(midware.TokenNotRevoked(h.revocation))
+		r.Use(midware.AccessListed(h.accessLists))
+		r.Use(midware.TokenRestricted())
+		r.Use(midware.ProofOfPossession(h.replayGuard))
+		r.Use(rateLimit...)
 		r.Route("/pods", func(r chi.Router) {
 			r.Post("/", h.CreateBucket)           //create bucket
 			r.Delete("/{bucket}", h.DeleteBucket) //delete bucket
 		})
+		r.Post("/presign", h.PresignObject) //presign url to download or upload object
+		r.Post("/share", h.ShareObject)     //share link to download object
 		r.Route("/pea", func(r chi.Router) {
 			r.Get("/{bucket}", h.GetObjects)        //get all objects in bucket
 			r.Post("/{bucket}/*", h.CreateObject)   //upload object
@@ -71,22 +255,31 @@ func (h *StorageHandler) CreateBucket(w http.ResponseWriter, r *http.Request) {
 		renderJSON(w, statusCode, http.StatusText(statusCode))
 		return
 	}
-	//check scope permission
-	if !claims.AllowCreate() {
-		renderJSON(w, http.StatusForbidden, H{"message": ErrorPermissionDenied.Error()})
-		return
-	}
 	item := &podObject{}
 	if err := decodeAndCloseRequest(r, item); err != nil {
 		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
 		return
 	}
+	rec := auditRecord(r)
+	rec.Op, rec.Bucket = jwt.CREATE, item.Name
+	//check scope permission
+	if statusCode, err := h.authorize(r, claims, jwt.CREATE, item.Name, ""); err != nil {
+		renderJSON(w, statusCode, H{"message": err.Error()})
+		return
+	}
+	ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+	defer h.refundFailed(ww, claims, jwt.CREATE)
+	w = ww
 
 	_, err := storage.CreateBucket(item.Name)
 	if err != nil {
 		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
 		return
 	}
+	h.publish(claims.Root().Namespace, EventBucketCreated, claims.Store(), item.Name, "", &storageEvent{
+		Store: claims.Store(), Bucket: item.Name, Issuer: claims.Issuer, Subject: claims.Subject,
+	})
+	h.changed(claims.Root().Namespace, &auth.Change{Type: auth.ChangeCreated, Store: claims.Store(), Bucket: item.Name, Subject: claims.Subject})
 
 	ret := H{"name": item.Name, "message": "successful"}
 	renderJSON(w, http.StatusOK, ret)
@@ -100,19 +293,47 @@ func (h *StorageHandler) DeleteBucket(w http.ResponseWriter, r *http.Request) {
 		renderJSON(w, statusCode, http.StatusText(statusCode))
 		return
 	}
+	bucket := chi.URLParam(r, "bucket")
 	//check scope permission
-	if !claims.AllowDelete() {
-		renderJSON(w, http.StatusForbidden, H{"message": ErrorPermissionDenied.Error()})
+	if statusCode, err := h.authorize(r, claims, jwt.DELETE, bucket, ""); err != nil {
+		renderJSON(w, statusCode, H{"message": err.Error()})
+		return
+	}
+	ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+	defer h.refundFailed(ww, claims, jwt.DELETE)
+	w = ww
+	if h.proposeAction(w, claims, auth.ActionDeleteBucket, claims.Store(), bucket, "") {
 		return
 	}
 
-	bucket := chi.URLParam(r, "bucket")
-	err := storage.DeleteBucket(bucket)
+	trashed, err := h.recycle(claims.Root().Namespace, claims.Store(), storage, bucket, "", claims.Issuer)
+	if err == nil && trashed == nil {
+		err = storage.DeleteBucket(bucket)
+	}
 	if err != nil {
 		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
 		return
 	}
+	// a bucket in the recycle bin keeps its quota usage and lifecycle rules until it is purged
+	if trashed == nil {
+		if err := h.quotas.Remove(claims.Root().Namespace, claims.Store(), bucket, ""); err != nil {
+			h.log.Error("failed to record quota usage", zap.Error(err))
+		}
+		if err := h.lifecycles.Forget(claims.Root().Namespace, claims.Store(), bucket, ""); err != nil {
+			h.log.Error("failed to remove lifecycle rules", zap.Error(err))
+		}
+		if err := h.retentions.Release(claims.Root().Namespace, claims.Store(), bucket, ""); err != nil {
+			h.log.Error("failed to remove retention", zap.Error(err))
+		}
+	}
+	h.publish(claims.Root().Namespace, EventBucketDeleted, claims.Store(), bucket, "", &storageEvent{
+		Store: claims.Store(), Bucket: bucket, Issuer: claims.Issuer, Subject: claims.Subject,
+	})
+	h.changed(claims.Root().Namespace, &auth.Change{Type: auth.ChangeDeleted, Store: claims.Store(), Bucket: bucket, Subject: claims.Subject})
 	ret := H{"name": bucket, "message": "successful"}
+	if trashed != nil {
+		ret["trash"] = trashed.ID
+	}
 	renderJSON(w, http.StatusOK, ret)
 }
 
@@ -124,28 +345,73 @@ func (h *StorageHandler) CreateObject(w http.ResponseWriter, r *http.Request) {
 		renderJSON(w, statusCode, http.StatusText(statusCode))
 		return
 	}
+	bucket := chi.URLParam(r, "bucket")
+	path := chi.URLParam(r, "*")
 	//check scope permission
-	if !claims.AllowWrite() {
-		renderJSON(w, http.StatusForbidden, H{"message": ErrorPermissionDenied.Error()})
+	if statusCode, err := h.authorize(r, claims, jwt.UPDATE, bucket, path); err != nil {
+		renderJSON(w, statusCode, H{"message": err.Error()})
 		return
 	}
+	ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+	defer h.refundFailed(ww, claims, jwt.UPDATE)
+	w = ww
 	if r.Body == nil {
 		renderJSON(w, http.StatusBadRequest, H{"message": ErrorBodyEmpty.Error()})
 		return
 	}
-
-	bucket := chi.URLParam(r, "bucket")
-	path := chi.URLParam(r, "*")
-	content, err := ioutil.ReadAll(r.Body)
+	ttl, err := objectTTL(r)
 	if err != nil {
 		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
 		return
 	}
+	if ttl > 0 {
+		if statusCode, err := h.checkExpiring(claims.Root().Namespace, claims.Store()); err != nil {
+			renderJSON(w, statusCode, H{"message": err.Error()})
+			return
+		}
+	}
+
+	content, statusCode, err := h.readUpload(w, r, claims, bucket, path)
+	if err != nil {
+		renderJSON(w, statusCode, H{"message": err.Error()})
+		return
+	}
+	in := policyInput(r, claims, jwt.UPDATE, bucket, path)
+	in.Size = int64(len(content))
+	if statusCode, err := h.evaluatePolicies(claims, in); err != nil {
+		renderJSON(w, statusCode, H{"message": err.Error()})
+		return
+	}
+	reservation, err := h.quotas.Reserve(claims, bucket, path, int64(len(content)))
+	if err != nil {
+		renderJSON(w, quotaStatus(err), H{"message": err.Error()})
+		return
+	}
 	err = storage.PutObject(bucket, path, content)
 	if err != nil {
+		h.release(reservation)
 		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
 		return
 	}
+	// an object uploaded again lives as long as its new TTL
+	expiry := &auth.Expiry{Store: claims.Store(), Bucket: bucket, Path: path}
+	if ttl > 0 {
+		expiry.ExpiresAt = time.Now().Unix() + ttl
+	}
+	if err := h.lifecycles.SetExpiry(claims.Root().Namespace, expiry); err != nil {
+		h.log.Error("failed to record expiry", zap.Error(err))
+	}
+	if err := h.retain(claims.Root().Namespace, claims.Store(), bucket, path); err != nil {
+		h.log.Error("failed to retain object", zap.Error(err))
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	h.publish(claims.Root().Namespace, EventObjectCreated, claims.Store(), bucket, path, &storageEvent{
+		Store: claims.Store(), Bucket: bucket, Path: path, Size: int64(len(content)), Issuer: claims.Issuer, Subject: claims.Subject,
+	})
+	h.changed(claims.Root().Namespace, &auth.Change{
+		Type: auth.ChangeCreated, Store: claims.Store(), Bucket: bucket, Path: path, Size: int64(len(content)), Subject: claims.Subject,
+	})
 	ret := H{"name": bucket, "path": path, "message": "successful"}
 	renderJSON(w, http.StatusOK, ret)
 }
@@ -158,37 +424,67 @@ func (h *StorageHandler) GetObject(w http.ResponseWriter, r *http.Request) {
 		renderJSON(w, statusCode, http.StatusText(statusCode))
 		return
 	}
+	bucket := chi.URLParam(r, "bucket")
+	path := chi.URLParam(r, "*")
 	//check scope permission
-	if !claims.AllowRead() {
-		renderJSON(w, http.StatusForbidden, H{"message": ErrorPermissionDenied.Error()})
+	if statusCode, err := h.authorize(r, claims, jwt.READ, bucket, path); err != nil {
+		renderJSON(w, statusCode, H{"message": err.Error()})
+		return
+	}
+	ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+	defer h.refundFailed(ww, claims, jwt.READ)
+	w = ww
+	if !h.checkEmbargo(w, claims, bucket, path) {
 		return
 	}
 
-	bucket := chi.URLParam(r, "bucket")
-	path := chi.URLParam(r, "*")
 	object, err := storage.GetObject(bucket, path)
 	if err != nil {
 		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
 		return
 	}
+	if err := h.quotas.Download(claims, bucket, int64(len(object.Content))); err != nil {
+		renderJSON(w, quotaStatus(err), H{"message": err.Error()})
+		return
+	}
 	renderJSON(w, http.StatusOK, H{"message": "successful", "content": string(object.Content)})
 }
 
-// GetObjects get pea objects with bucket in storage
+// GetObjects get pea objects with bucket in storage, or streams changes of the bucket with watch=true
 // example: curl -H "Authorization: Bearer jwttoken" http://localhost:8080/pea/test11
+// example: curl -N -H "Authorization: Bearer jwttoken" 'http://localhost:8080/pea/test11?watch=true'
 func (h *StorageHandler) GetObjects(w http.ResponseWriter, r *http.Request) {
 	claims, storage, statusCode := h.createBackendForRequest(r)
 	if statusCode != http.StatusOK {
 		renderJSON(w, statusCode, http.StatusText(statusCode))
 		return
 	}
+	bucket := chi.URLParam(r, "bucket")
 	//check scope permission
-	if !claims.AllowRead() {
-		renderJSON(w, http.StatusForbidden, H{"message": ErrorPermissionDenied.Error()})
+	if statusCode, err := h.authorize(r, claims, jwt.READ, bucket, ""); err != nil {
+		renderJSON(w, statusCode, H{"message": err.Error()})
 		return
 	}
+	ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+	defer h.refundFailed(ww, claims, jwt.READ)
+	w = ww
+	if !h.checkEmbargo(w, claims, bucket, "") {
+		return
+	}
+	if r.URL.Query().Get("watch") == "true" {
+		h.watchBucket(w, r, claims, bucket)
+		return
+	}
+	// objects under embargo and in the recycle bin are left out of the listing
+	embargoed := map[string]bool{}
+	if !claims.Owner {
+		var err error
+		if embargoed, err = h.embargoes.Embargoed(claims.Root().Namespace, claims.Store(), bucket); err != nil {
+			renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+			return
+		}
+	}
 
-	bucket := chi.URLParam(r, "bucket")
 	objects, err := storage.ListObjects(bucket, "")
 	if err != nil {
 		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
@@ -197,7 +493,9 @@ func (h *StorageHandler) GetObjects(w http.ResponseWriter, r *http.Request) {
 
 	list := []string{}
 	for _, o := range objects {
-		list = append(list, o.Path)
+		if !embargoed[o.Path] && !auth.IsTrashPath(o.Path) {
+			list = append(list, o.Path)
+		}
 	}
 	renderJSON(w, http.StatusOK, H{"message": "successful", "content": list})
 }
@@ -210,20 +508,270 @@ func (h *StorageHandler) DeleteObject(w http.ResponseWriter, r *http.Request) {
 		renderJSON(w, statusCode, http.StatusText(statusCode))
 		return
 	}
+	bucket := chi.URLParam(r, "bucket")
+	path := chi.URLParam(r, "*")
 	//check scope permission
-	if !claims.AllowDelete() {
-		renderJSON(w, http.StatusForbidden, H{"message": ErrorPermissionDenied.Error()})
+	if statusCode, err := h.authorize(r, claims, jwt.DELETE, bucket, path); err != nil {
+		renderJSON(w, statusCode, H{"message": err.Error()})
+		return
+	}
+	ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+	defer h.refundFailed(ww, claims, jwt.DELETE)
+	w = ww
+	if h.proposeAction(w, claims, auth.ActionDeleteObject, claims.Store(), bucket, path) {
 		return
 	}
 
-	bucket := chi.URLParam(r, "bucket")
-	path := chi.URLParam(r, "*")
-	err := storage.DeleteObject(bucket, path)
+	// objects are moved to the recycle bin of the store if it has one
+	trashed, err := h.recycle(claims.Root().Namespace, claims.Store(), storage, bucket, path, claims.Issuer)
+	if err == nil && trashed == nil {
+		err = storage.DeleteObject(bucket, path)
+	}
 	if err != nil {
 		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
 		return
 	}
-	renderJSON(w, http.StatusOK, H{"message": "successful", "name": bucket, "path": path})
+	if err := h.quotas.Remove(claims.Root().Namespace, claims.Store(), bucket, path); err != nil {
+		h.log.Error("failed to record quota usage", zap.Error(err))
+	}
+	if err := h.lifecycles.Forget(claims.Root().Namespace, claims.Store(), bucket, path); err != nil {
+		h.log.Error("failed to remove expiry", zap.Error(err))
+	}
+	if err := h.retentions.Release(claims.Root().Namespace, claims.Store(), bucket, path); err != nil {
+		h.log.Error("failed to remove object lock", zap.Error(err))
+	}
+	h.publish(claims.Root().Namespace, EventObjectDeleted, claims.Store(), bucket, path, &storageEvent{
+		Store: claims.Store(), Bucket: bucket, Path: path, Issuer: claims.Issuer, Subject: claims.Subject,
+	})
+	h.changed(claims.Root().Namespace, &auth.Change{Type: auth.ChangeDeleted, Store: claims.Store(), Bucket: bucket, Path: path, Subject: claims.Subject})
+	ret := H{"message": "successful", "name": bucket, "path": path}
+	if trashed != nil {
+		ret["trash"] = trashed.ID
+	}
+	renderJSON(w, http.StatusOK, ret)
+}
+
+func sessionTTL(cfg *config.Config) time.Duration {
+	if cfg.Server.SessionTTL > 0 {
+		return time.Duration(cfg.Server.SessionTTL) * time.Second
+	}
+	return 15 * time.Minute
+}
+
+func presignTTL(cfg *config.Config) time.Duration {
+	if cfg.Server.PresignTTL > 0 {
+		return time.Duration(cfg.Server.PresignTTL) * time.Second
+	}
+	return 15 * time.Minute
+}
+
+func shareTTL(cfg *config.Config) time.Duration {
+	if cfg.Server.ShareTTL > 0 {
+		return time.Duration(cfg.Server.ShareTTL) * time.Second
+	}
+	return 24 * time.Hour
+}
+
+// audience returns the aud verifiable presentations must be made for
+func audience(cfg *config.Config) string {
+	if cfg.Server.Audience != "" {
+		return cfg.Server.Audience
+	}
+	return "phoenix"
+}
+
+// newOIDCVerifier creates the verifier of the identity providers configured, providers without audience are
+// rejected, their tokens aren't accepted
+func newOIDCVerifier(cfg *config.Config) *auth.OIDCVerifier {
+	providers := make([]auth.OIDCProvider, 0, len(cfg.OIDC.Providers))
+	for _, p := range cfg.OIDC.Providers {
+		provider := auth.OIDCProvider{Issuer: p.Issuer, JWKSURL: p.JWKSURL, Audience: p.Audience}
+		if err := provider.Validate(); err != nil {
+			log.Logger("handler").Error("identity provider is rejected", zap.String("issuer", p.Issuer), zap.Error(err))
+			continue
+		}
+		providers = append(providers, provider)
+	}
+	v, _ := auth.NewOIDCVerifier(time.Duration(cfg.OIDC.CacheTTL)*time.Second, providers...)
+	return v
+}
+
+// globalQuota returns the quota of each owner set by operator, nil if owners are unlimited
+func globalQuota(cfg *config.Config) *auth.Quota {
+	q := cfg.Quota
+	if q.MaxBytes == 0 && q.MaxObjects == 0 && q.MaxTransfer == 0 {
+		return nil
+	}
+	return &auth.Quota{MaxBytes: q.MaxBytes, MaxObjects: q.MaxObjects, MaxTransfer: q.MaxTransfer, Period: q.Period}
+}
+
+// readUpload reads the content uploaded for the object, the body is cut at the bytes the quotas leave, and the
+// quota it exceeds responded
+func (h *StorageHandler) readUpload(w http.ResponseWriter, r *http.Request, claims *auth.Claims, bucket, path string) ([]byte, int, error) {
+	remaining, err := h.quotas.Remaining(claims, bucket, path)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if remaining < 0 {
+		content, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		return content, http.StatusOK, nil
+	}
+	content, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, remaining))
+	switch {
+	case err == nil:
+		return content, http.StatusOK, nil
+	case int64(len(content)) < remaining:
+		return nil, http.StatusBadRequest, err
+	}
+	if err := h.quotas.CheckUpload(claims, bucket, path, remaining+1); err != nil {
+		return nil, quotaStatus(err), err
+	}
+	return nil, http.StatusRequestEntityTooLarge, err
+}
+
+// release releases the quota reserved for an upload failed
+func (h *StorageHandler) release(reservation *auth.QuotaReservation) {
+	if err := h.quotas.Release(reservation); err != nil {
+		h.log.Error("failed to release quota", zap.Error(err))
+	}
+}
+
+// quotaStatus maps the error of quota check to the status responded, 413 if the storage quota is exceeded
+// and 429 if the transfer quota is
+func quotaStatus(err error) int {
+	switch errors.Cause(err) {
+	case auth.ErrQuotaStorage:
+		return http.StatusRequestEntityTooLarge
+	case auth.ErrQuotaTransfer:
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// requiredGrants returns the grants tokens must be recorded in, nil if live grant is not required
+func (h *StorageHandler) requiredGrants() auth.Grants {
+	if h.cfg.Server.RequireGrant {
+		return h.grants
+	}
+	return nil
+}
+
+func signatureWindow(cfg *config.Config) time.Duration {
+	if cfg.Server.SignatureWindow > 0 {
+		return time.Duration(cfg.Server.SignatureWindow) * time.Second
+	}
+	return 300 * time.Second
+}
+
+// authorize checks the scope and subject permission of claims and the owner's policies, and reserves a use of the
+// token, which refundFailed takes back if the operation fails
+func (h *StorageHandler) authorize(r *http.Request, claims *auth.Claims, op, bucket, path string) (int, error) {
+	return h.authorizeInput(claims, policyInput(r, claims, op, bucket, path))
+}
+
+// authorizeInput authorizes the request of claims as authorize does, with the input policies are evaluated against
+func (h *StorageHandler) authorizeInput(claims *auth.Claims, in *auth.PolicyInput) (int, error) {
+	op, bucket, path := in.Op, in.Bucket, in.Path
+	if err := permit(claims, op, bucket, path); err != nil {
+		return http.StatusForbidden, err
+	}
+	if bucket != "" {
+		// buckets in the recycle bin are gone until they are restored
+		switch item, err := h.trash.Bucket(claims.Root().Namespace, claims.Store(), bucket); {
+		case err != nil:
+			h.log.Error("failed to read recycle bin", zap.Error(err))
+			return http.StatusInternalServerError, err
+		case item != nil && op == jwt.CREATE:
+			return http.StatusConflict, errors.Wrapf(ErrorBucketTrashed, "bucket %s", bucket)
+		case item != nil:
+			return http.StatusNotFound, errors.Wrapf(ErrorBucketTrashed, "bucket %s", bucket)
+		}
+		// objects retained or under legal hold can't be changed or deleted, whatever the scope
+		if op == jwt.UPDATE || op == jwt.DELETE {
+			if statusCode, err := h.checkLock(claims.Root().Namespace, claims.Store(), bucket, path); err != nil {
+				return statusCode, err
+			}
+		}
+	}
+	if statusCode, err := h.evaluatePolicies(claims, in); err != nil {
+		return statusCode, err
+	}
+	switch err := h.usage.Consume(claims, op); errors.Cause(err) {
+	case nil:
+		return http.StatusOK, nil
+	case auth.ErrTokenExhausted:
+		return http.StatusForbidden, err
+	default:
+		h.log.Error("failed to record token usage", zap.Error(err))
+		return http.StatusInternalServerError, err
+	}
+}
+
+// refundFailed takes back the use of the token authorize reserved for the operation if it is responded with an
+// error, operations failing don't use up the token
+func (h *StorageHandler) refundFailed(ww middleware.WrapResponseWriter, claims *auth.Claims, op string) {
+	if ww.Status() < http.StatusBadRequest {
+		return
+	}
+	if err := h.usage.Refund(claims, op); err != nil {
+		h.log.Error("failed to refund token usage", zap.Error(err))
+	}
+}
+
+// evaluatePolicies evaluates the owner's policies against the request of claims
+func (h *StorageHandler) evaluatePolicies(claims *auth.Claims, in *auth.PolicyInput) (int, error) {
+	if claims.Owner {
+		// policies restrict trustees, the owner is not subject to them
+		return http.StatusOK, nil
+	}
+	switch err := h.policies.Evaluate(claims.Root().Namespace, in); errors.Cause(err) {
+	case nil:
+		return http.StatusOK, nil
+	case auth.ErrPolicyDenied:
+		return http.StatusForbidden, err
+	default:
+		h.log.Error("failed to evaluate policy", zap.Error(err))
+		return http.StatusInternalServerError, err
+	}
+}
+
+// policyInput is the request policies are evaluated against, the size of an upload is its content length, which
+// is unknown for chunked uploads, so uploads are evaluated again with the size of the content read
+func policyInput(r *http.Request, claims *auth.Claims, op, bucket, path string) *auth.PolicyInput {
+	in := &auth.PolicyInput{
+		Claims: claims,
+		Method: r.Method,
+		Op:     op,
+		Bucket: bucket,
+		Path:   path,
+		IP:     r.RemoteAddr,
+		Time:   time.Now(),
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		in.IP = host
+	}
+	if op == jwt.UPDATE {
+		in.Size, in.ContentType = r.ContentLength, r.Header.Get("Content-Type")
+	}
+	return in
+}
+
+// permit checks the scope and subject permission of claims, the error tells which one is not granted
+func permit(claims *auth.Claims, op, bucket, path string) error {
+	if !claims.Allow(op) {
+		return errors.Wrapf(ErrorPermissionDenied, "scope %s is not granted", op)
+	}
+	if !claims.Covers(bucket, path) {
+		return errors.Wrapf(ErrorPermissionDenied, "%s is not within subject %s", strings.Trim(bucket+"/"+path, "/"), claims.Subject)
+	}
+	if auth.IsTrashPath(path) {
+		return errors.Wrapf(ErrorPermissionDenied, "%s is hidden", auth.TrashPrefix)
+	}
+	return nil
 }
 
 func (h *StorageHandler) createBackendForRequest(r *http.Request) (claims *auth.Claims, backend storage.Backend, statusCode int) {
@@ -234,16 +782,15 @@ func (h *StorageHandler) createBackendForRequest(r *http.Request) (claims *auth.
 		return
 	}
 
-	// trustor is the address that registers endpoint with us
-	trustor, err := crypto.HexStringToPublicKey(claims.Issuer)
-	if err != nil {
+	// trustor is the owner that registers endpoint with us
+	name := claims.Root().Namespace
+	if name == "" {
 		statusCode = http.StatusUnauthorized
 		return
 	}
 
 	// check trustor's storage endpoint
-	name := trustor.Address().Hex()[2:] // remove 0x prefix
-	store, err := h.cred.GetStore(name, claims.Subject)
+	store, err := h.cred.GetStore(name, claims.Store())
 	switch errors.Cause(err) {
 	case nil:
 		// continue
@@ -278,20 +825,21 @@ func (h *StorageHandler) RegisterStorage(w http.ResponseWriter, r *http.Request)
 	}
 
 	//check scope permission
-	if !claims.AllowCreate() {
-		renderJSON(w, http.StatusForbidden, H{"message": ErrorPermissionDenied.Error()})
+	if statusCode, err := h.authorize(r, claims, jwt.CREATE, "", ""); err != nil {
+		renderJSON(w, statusCode, H{"message": err.Error()})
 		return
 	}
-	// trustor is the address that registers endpoint with us
-	trustor, err := crypto.HexStringToPublicKey(claims.Issuer)
-	if err != nil {
-		h.log.Error(err.Error())
+	ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+	defer h.refundFailed(ww, claims, jwt.CREATE)
+	w = ww
+	// trustor is the owner that registers endpoint with us
+	name := claims.Root().Namespace
+	if name == "" {
 		renderJSON(w, http.StatusUnauthorized, H{"message": http.StatusText(http.StatusUnauthorized)})
 		return
 	}
 
 	// check trustor's storage endpoint
-	name := trustor.Address().Hex()[2:] // remove 0x prefix
 
 	item := &registerObject{}
 	if err := decodeAndCloseRequest(r, item); err != nil {
@@ -300,10 +848,63 @@ func (h *StorageHandler) RegisterStorage(w http.ResponseWriter, r *http.Request)
 	}
 
 	store := item.Store()
-	if err = h.cred.PutStore(name, store.Name(), store); err != nil {
+	ownership, err := item.Ownership()
+	if err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	quota, err := item.StoreQuota()
+	if err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	bin, err := item.StoreRecycleBin()
+	if err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	// a co-owned store, its endpoint, credentials, quotas and recycle bin as much as its owners, can only be
+	// changed by unregistering it with the approval of the owners and registering it again
+	current, err := h.ownerships.GetOwnership(name, store.Name())
+	if err != nil {
 		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
 		return
 	}
+	if current != nil {
+		renderJSON(w, http.StatusConflict, H{"message": "co-owned store can't be registered again until it is unregistered"})
+		return
+	}
+	if err := h.cred.PutStore(name, store.Name(), store); err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	if ownership != nil {
+		if err := h.ownerships.PutOwnership(name, ownership); err != nil {
+			renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+			return
+		}
+	}
+	// quotas are set with each registration, registering without them lifts the quotas
+	if quota != nil {
+		err = h.quotas.PutQuota(name, quota)
+	} else {
+		err = h.quotas.DelQuota(name, store.Name())
+	}
+	if err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	// so is the recycle bin, the items in it are kept until they expire
+	if bin != nil {
+		err = h.trash.PutBin(name, bin)
+	} else {
+		err = h.trash.DelBin(name, store.Name())
+	}
+	if err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	h.publish(name, EventStoreRegistered, store.Name(), "", "", &storageEvent{Store: store.Name()})
 
 	renderJSON(w, http.StatusOK, H{"message": "successful"})
 }
@@ -319,26 +920,89 @@ func (h *StorageHandler) UnRegisterStorage(w http.ResponseWriter, r *http.Reques
 	}
 
 	//check scope permission
-	if !claims.AllowDelete() {
-		renderJSON(w, http.StatusForbidden, H{"message": ErrorPermissionDenied.Error()})
+	if statusCode, err := h.authorize(r, claims, jwt.DELETE, "", ""); err != nil {
+		renderJSON(w, statusCode, H{"message": err.Error()})
 		return
 	}
-	// trustor is the address that registers endpoint with us
-	trustor, err := crypto.HexStringToPublicKey(claims.Issuer)
-	if err != nil {
-		h.log.Error(err.Error())
+	ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+	defer h.refundFailed(ww, claims, jwt.DELETE)
+	w = ww
+	// trustor is the owner that registers endpoint with us
+	name := claims.Root().Namespace
+	if name == "" {
 		renderJSON(w, http.StatusUnauthorized, H{"message": http.StatusText(http.StatusUnauthorized)})
 		return
 	}
 
 	// check trustor's storage endpoint
-	name := trustor.Address().Hex()[2:] // remove 0x prefix
 	driver := chi.URLParam(r, "driver")
+	if h.proposeAction(w, claims, auth.ActionUnregisterStore, driver, "", "") {
+		return
+	}
 
-	if err = h.cred.DelStore(name, driver); err != nil {
+	if err := h.cred.DelStore(name, driver); err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	if err := h.quotas.DelQuota(name, driver); err != nil {
 		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
 		return
 	}
+	if err := h.lifecycles.Forget(name, driver, "", ""); err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	if err := h.forgetTrash(name, driver); err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	h.publish(name, EventStoreUnregistered, driver, "", "", &storageEvent{Store: driver})
 
 	renderJSON(w, http.StatusOK, H{"message": "successful"})
 }
+
+// RevokeToken revokes a token, the token and all tokens delegated from it are rejected afterwards
+// example: curl -H 'Content-Type: application/json' -H "Authorization: Bearer jwttoken" -d '{"token": "revokedjwttoken"}' http://localhost:8080/revoke
+func (h *StorageHandler) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, ok := ctx.Value(auth.TokenCtxKey).(*auth.Claims)
+	if !ok {
+		renderJSON(w, http.StatusInternalServerError, H{"message": http.StatusText(http.StatusInternalServerError)})
+		return
+	}
+
+	item := &revokeObject{}
+	if err := decodeAndCloseRequest(r, item); err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	revoked, err := auth.NewClaimsWithResolver(item.Token, h.issuers)
+	if err != nil {
+		// or a verifiable credential issued by the owner
+		revoked, err = h.verifier.VerifyCredential(item.Token)
+	}
+	if err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+
+	// the token can be revoked by the holder of any token in its chain, or by the owner with owner session, tokens
+	// issued by the owner can't revoke the tokens of other trustees
+	allowed := claims.Owner && claims.Namespace == revoked.Root().Namespace
+	for _, link := range revoked.Chain() {
+		if link.Token == claims.Token {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		renderJSON(w, http.StatusForbidden, H{"message": ErrorPermissionDenied.Error()})
+		return
+	}
+
+	if err = h.revocation.Revoke(revoked); err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	renderJSON(w, http.StatusOK, H{"message": "successful", "token": revoked.Hash()})
+}
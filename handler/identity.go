@@ -0,0 +1,75 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"github.com/iotexproject/phoenix/auth"
+)
+
+// PutIdentity grants an identity of the configured identity providers access to owner's data, the identity
+// presents its own token along with header X-Phoenix-Owner. Grants can only be managed with owner session
+// example: curl -H 'Content-Type: application/json' -H "Authorization: Bearer session" -d '{"issuer": "https://login.example.com", "identity": "alice", "subject": "s3/reports", "scope": "Read", "expiresAt": 1607772249}' http://localhost:8080/identities
+func (h *StorageHandler) PutIdentity(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, ok := ctx.Value(auth.TokenCtxKey).(*auth.Claims)
+	if !ok {
+		renderJSON(w, http.StatusInternalServerError, H{"message": http.StatusText(http.StatusInternalServerError)})
+		return
+	}
+	if !claims.Owner {
+		renderJSON(w, http.StatusForbidden, H{"message": ErrorPermissionDenied.Error()})
+		return
+	}
+
+	item := &identityObject{}
+	if err := decodeAndCloseRequest(r, item); err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	grant := &auth.IdentityGrant{
+		Issuer:    item.Issuer,
+		Identity:  item.Identity,
+		Subject:   item.Subject,
+		Scope:     item.Scope,
+		ExpiresAt: item.ExpiresAt,
+	}
+	if grant.Issuer == "" || grant.Identity == "" || grant.Subject == "" {
+		renderJSON(w, http.StatusBadRequest, H{"message": "issuer, identity and subject are required"})
+		return
+	}
+	if err := h.identities.PutIdentity(claims.Namespace, grant); err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	renderJSON(w, http.StatusOK, H{"message": "successful", "id": grant.ID})
+}
+
+// DelIdentity removes the access granted to an identity
+// example: curl -H "Authorization: Bearer session" -X DELETE http://localhost:8080/identities/xxx
+func (h *StorageHandler) DelIdentity(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, ok := ctx.Value(auth.TokenCtxKey).(*auth.Claims)
+	if !ok {
+		renderJSON(w, http.StatusInternalServerError, H{"message": http.StatusText(http.StatusInternalServerError)})
+		return
+	}
+	if !claims.Owner {
+		renderJSON(w, http.StatusForbidden, H{"message": ErrorPermissionDenied.Error()})
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	if err := h.identities.DelIdentity(claims.Namespace, id); err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	renderJSON(w, http.StatusOK, H{"message": "successful", "id": id})
+}
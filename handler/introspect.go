@@ -0,0 +1,285 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/iotexproject/iotex-antenna-go/v2/jwt"
+	"github.com/pkg/errors"
+
+	"github.com/iotexproject/phoenix/auth"
+	"github.com/iotexproject/phoenix/db"
+	"github.com/iotexproject/phoenix/handler/midware"
+)
+
+// ruleCheck is the result of a rule evaluated for a request
+type ruleCheck struct {
+	Rule    string `json:"rule"`
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// IntrospectToken returns the parsed claims of a token, the owner namespace and store it maps to, its validity
+// and revocation status. With method and path, it explains whether the request would be allowed and by which rule.
+// The region and endpoint of the store are only returned for tokens not revoked
+// example: curl -H 'Content-Type: application/json' -d '{"token": "jwttoken", "method": "GET", "path": "/pea/test/a.txt"}' http://localhost:8080/token/introspect
+func (h *StorageHandler) IntrospectToken(w http.ResponseWriter, r *http.Request) {
+	item := &introspectObject{}
+	if err := decodeAndCloseRequest(r, item); err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	if item.Token == "" {
+		renderJSON(w, http.StatusBadRequest, H{"message": ErrorBodyEmpty.Error()})
+		return
+	}
+
+	claims, err := h.parseToken(item.Token, item.Owner)
+	if err != nil {
+		renderJSON(w, http.StatusOK, H{"active": false, "error": err.Error()})
+		return
+	}
+	ret := H{
+		"claims": H{
+			"iss":        claims.Issuer,
+			"sub":        claims.Subject,
+			"scope":      claims.Scope,
+			"holder":     claims.Holder,
+			"maxUses":    claims.MaxUses,
+			"opUses":     claims.OpUses,
+			"owner":      claims.Owner,
+			"depth":      len(claims.Chain()) - 1,
+			"cidrs":      claims.CIDRs,
+			"userAgents": claims.UserAgents,
+			"windows":    claims.Windows,
+			"nbf":        claims.NotBefore,
+			"quota":      claims.Quota,
+		},
+		"namespace": claims.Root().Namespace,
+		"issuedAt":  claims.IssuedAt,
+		"expiresAt": claims.ExpiresAt,
+	}
+	if claims.ExpiresAt != 0 {
+		ret["expiresIn"] = claims.ExpiresAt - time.Now().Unix()
+	}
+
+	revoked := false
+	switch err := h.revocation.Check(claims); errors.Cause(err) {
+	case nil:
+		break
+	case auth.ErrTokenRevoked:
+		revoked = true
+	default:
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	ret["revoked"], ret["active"] = revoked, !revoked
+
+	store := H{"name": claims.Store()}
+	if s, err := h.cred.GetStore(claims.Root().Namespace, claims.Store()); err == nil {
+		store["registered"] = true
+		if !revoked {
+			store["region"], store["endpoint"] = s.Region(), s.Endpoint()
+		}
+	} else {
+		store["registered"] = false
+	}
+	ret["store"] = store
+	if grant, err := h.grants.Live(claims); err == nil {
+		ret["grant"] = grant.ID
+	}
+	if claims.Limited() {
+		if count, err := h.usage.Count(claims); err == nil {
+			ret["usage"] = count
+		}
+	}
+
+	if item.Method != "" || item.Path != "" {
+		checks := h.explain(r, claims, item.Method, item.Path)
+		explain := H{"method": item.Method, "path": item.Path, "allowed": true, "checks": checks}
+		for _, c := range checks {
+			if !c.Allowed {
+				explain["allowed"], explain["rule"], explain["reason"] = false, c.Rule, c.Reason
+				break
+			}
+		}
+		ret["explain"] = explain
+	}
+	renderJSON(w, http.StatusOK, ret)
+}
+
+// parseToken verifies the token the way the midware of routes does
+func (h *StorageHandler) parseToken(token, owner string) (*auth.Claims, error) {
+	switch {
+	case !strings.Contains(token, "."):
+		return h.sessions.Claims(token)
+	case auth.IsPresentation(token):
+		return h.verifier.Verify(token)
+	case h.oidc.Accepts(token):
+		id, err := h.oidc.Verify(token)
+		if err != nil {
+			return nil, err
+		}
+		namespace, err := auth.OwnerNamespace(owner)
+		if err != nil {
+			return nil, errors.Wrap(err, "owner is required for token of identity provider")
+		}
+		grant, err := h.identities.GetIdentity(namespace, auth.IdentityID(id.Issuer, id.Subject))
+		if err != nil {
+			return nil, errors.Wrap(auth.ErrNoGrant, err.Error())
+		}
+		return grant.Claims(namespace, token, id)
+	default:
+		return auth.NewClaimsWithResolver(token, h.issuers)
+	}
+}
+
+// explain evaluates the rules of the request in the order the handler does, access lists, restrictions and
+// policies are evaluated with the client IP and user agent of the introspection request, quotas with a request
+// of one byte
+func (h *StorageHandler) explain(r *http.Request, claims *auth.Claims, method, path string) []ruleCheck {
+	op, bucket, object, err := routeOf(method, path)
+	if err != nil {
+		return []ruleCheck{{Rule: "route", Reason: err.Error()}}
+	}
+	checks := []ruleCheck{{Rule: "route", Allowed: true, Reason: op + " " + strings.Trim(bucket+"/"+object, "/")}}
+
+	check := ruleCheck{Rule: "revocation", Allowed: true}
+	if err := h.revocation.Check(claims); err != nil {
+		check.Allowed, check.Reason = false, err.Error()
+	}
+	checks = append(checks, check)
+
+	check = ruleCheck{Rule: "accesslist", Allowed: true}
+	if err := h.accessLists.Check(claims, midware.ClientIP(r)); err != nil {
+		check.Allowed, check.Reason = false, err.Error()
+	}
+	checks = append(checks, check)
+
+	check = ruleCheck{Rule: "restriction", Allowed: true}
+	if err := claims.Restrict(midware.ClientIP(r), r.UserAgent(), time.Now()); err != nil {
+		check.Allowed, check.Reason = false, err.Error()
+	}
+	checks = append(checks, check)
+
+	check = ruleCheck{Rule: "scope", Allowed: claims.Allow(op), Reason: "scope " + claims.Scope}
+	checks = append(checks, check)
+
+	check = ruleCheck{Rule: "subject", Allowed: claims.Covers(bucket, object), Reason: "subject " + claims.Subject}
+	if check.Allowed && auth.IsTrashPath(object) {
+		check.Allowed, check.Reason = false, auth.TrashPrefix+" is hidden"
+	}
+	checks = append(checks, check)
+
+	check = ruleCheck{Rule: "trash", Allowed: true}
+	switch item, err := h.trash.Bucket(claims.Root().Namespace, claims.Store(), bucket); {
+	case err != nil:
+		check.Allowed, check.Reason = false, err.Error()
+	case item != nil:
+		check.Allowed, check.Reason = false, errors.Wrapf(ErrorBucketTrashed, "bucket %s", bucket).Error()
+	}
+	checks = append(checks, check)
+
+	if op == jwt.UPDATE || op == jwt.DELETE {
+		check = ruleCheck{Rule: "lock", Allowed: true}
+		if err := h.retentions.Check(claims.Root().Namespace, claims.Store(), bucket, object, time.Now().Unix()); err != nil {
+			check.Allowed, check.Reason = false, err.Error()
+		}
+		checks = append(checks, check)
+	}
+
+	check = ruleCheck{Rule: "policy", Allowed: true}
+	if !claims.Owner {
+		in := policyInput(r, claims, op, bucket, object)
+		in.Method, in.Size, in.ContentType = strings.ToUpper(method), 0, ""
+		if err := h.policies.Evaluate(claims.Root().Namespace, in); err != nil {
+			check.Allowed, check.Reason = false, err.Error()
+		}
+	}
+	checks = append(checks, check)
+
+	check = ruleCheck{Rule: "usage", Allowed: true}
+	if err := h.usage.Check(claims, op); err != nil {
+		check.Allowed, check.Reason = false, err.Error()
+	}
+	checks = append(checks, check)
+
+	if op == jwt.READ {
+		check = ruleCheck{Rule: "embargo", Allowed: true}
+		if err := h.embargoes.Check(claims.Root().Namespace, claims.Store(), bucket, object); err != nil && !claims.Owner {
+			check.Allowed, check.Reason = false, err.Error()
+		}
+		checks = append(checks, check)
+	}
+
+	if op == jwt.UPDATE || (op == jwt.READ && object != "") {
+		check = ruleCheck{Rule: "quota", Allowed: true}
+		if op == jwt.UPDATE {
+			err = h.quotas.CheckUpload(claims, bucket, object, 1)
+		} else {
+			err = h.quotas.CheckDownload(claims, bucket, 1)
+		}
+		if err != nil {
+			check.Allowed, check.Reason = false, err.Error()
+		}
+		checks = append(checks, check)
+	}
+
+	if op == jwt.DELETE {
+		// deleting from a co-owned store is allowed, but only done once co-owners approve it
+		check = ruleCheck{Rule: "ownership", Allowed: true}
+		switch ownership, err := h.ownerships.GetOwnership(claims.Root().Namespace, claims.Store()); {
+		case err != nil:
+			check.Allowed, check.Reason = false, err.Error()
+		case ownership != nil:
+			check.Reason = fmt.Sprintf("pending approval of %d of %d owners", ownership.Threshold, len(ownership.Owners))
+		}
+		checks = append(checks, check)
+	}
+
+	check = ruleCheck{Rule: "store", Allowed: true, Reason: "store " + claims.Store()}
+	switch _, err := h.cred.GetStore(claims.Root().Namespace, claims.Store()); errors.Cause(err) {
+	case nil:
+		break
+	case db.ErrBucketNotExist, db.ErrNotExist:
+		check.Allowed, check.Reason = false, "store "+claims.Store()+" is not registered"
+	default:
+		check.Allowed, check.Reason = false, err.Error()
+	}
+	return append(checks, check)
+}
+
+// routeOf maps the method and path of a storage route to the operation, bucket and object path
+func routeOf(method, path string) (op, bucket, object string, err error) {
+	parts := strings.SplitN(strings.Trim(path, "/"), "/", 3)
+	if len(parts) < 2 || parts[1] == "" {
+		return "", "", "", errors.Errorf("%s %s is not a storage route", method, path)
+	}
+	bucket = parts[1]
+	if len(parts) == 3 {
+		object = parts[2]
+	}
+	switch method = strings.ToUpper(method); {
+	case parts[0] == "pods" && object == "" && method == http.MethodPost:
+		op = jwt.CREATE
+	case parts[0] == "pods" && object == "" && method == http.MethodDelete:
+		op = jwt.DELETE
+	case parts[0] == "pea" && method == http.MethodGet:
+		op = jwt.READ
+	case parts[0] == "pea" && object != "" && method == http.MethodPost:
+		op = jwt.UPDATE
+	case parts[0] == "pea" && object != "" && method == http.MethodDelete:
+		op = jwt.DELETE
+	default:
+		return "", "", "", errors.Errorf("%s %s is not a storage route", method, path)
+	}
+	return op, bucket, object, nil
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"github.com/iotexproject/phoenix/auth"
+)
+
+// PutIssuerKey registers a Ed25519, P-256 or secp256k1 key the owner signs tokens with, tokens are then
+// issued by the owner's address plus the key ID. Keys can only be managed with owner session
+// example: curl -H 'Content-Type: application/json' -H "Authorization: Bearer session" -d '{"kid": "key-1", "type": "ed25519", "publicKey": "xxx"}' http://localhost:8080/keys
+func (h *StorageHandler) PutIssuerKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, ok := ctx.Value(auth.TokenCtxKey).(*auth.Claims)
+	if !ok {
+		renderJSON(w, http.StatusInternalServerError, H{"message": http.StatusText(http.StatusInternalServerError)})
+		return
+	}
+	if !claims.Owner {
+		renderJSON(w, http.StatusForbidden, H{"message": ErrorPermissionDenied.Error()})
+		return
+	}
+
+	item := &issuerKeyObject{}
+	if err := decodeAndCloseRequest(r, item); err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	// phoenix registers its own key under the reserved ID to mint tokens, it can't be replaced by the owner
+	if item.ID == auth.MintKeyID {
+		renderJSON(w, http.StatusBadRequest, H{"message": ErrorKeyReserved.Error()})
+		return
+	}
+	key := &auth.IssuerKey{
+		ID:        item.ID,
+		Type:      item.Type,
+		PublicKey: item.PublicKey,
+	}
+	if _, err := key.PublicKeyOf(); err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	if err := h.keys.PutKey(claims.Namespace, key); err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	renderJSON(w, http.StatusOK, H{"message": "successful", "kid": key.ID})
+}
+
+// DelIssuerKey removes a key the owner signs tokens with, tokens signed by it are rejected afterwards
+// example: curl -H "Authorization: Bearer session" -X DELETE http://localhost:8080/keys/key-1
+func (h *StorageHandler) DelIssuerKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, ok := ctx.Value(auth.TokenCtxKey).(*auth.Claims)
+	if !ok {
+		renderJSON(w, http.StatusInternalServerError, H{"message": http.StatusText(http.StatusInternalServerError)})
+		return
+	}
+	if !claims.Owner {
+		renderJSON(w, http.StatusForbidden, H{"message": ErrorPermissionDenied.Error()})
+		return
+	}
+
+	kid := chi.URLParam(r, "kid")
+	if kid == auth.MintKeyID {
+		renderJSON(w, http.StatusBadRequest, H{"message": ErrorKeyReserved.Error()})
+		return
+	}
+	if err := h.keys.DelKey(claims.Namespace, kid); err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	renderJSON(w, http.StatusOK, H{"message": "successful", "kid": kid})
+}
@@ -0,0 +1,440 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"context"
+	"net/http"
+	pathutil "path"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/iotexproject/phoenix/auth"
+	"github.com/iotexproject/phoenix/config"
+	"github.com/iotexproject/phoenix/db"
+	"github.com/iotexproject/phoenix/storage"
+)
+
+type (
+	// lifecycleReport is what a sweep did, or would do in a dry run
+	lifecycleReport struct {
+		DryRun  bool               `json:"dryRun"`
+		Time    int64              `json:"time"`
+		Actions []*lifecycleAction `json:"actions"`
+	}
+
+	// lifecycleAction is the deletion or archiving of an object by a lifecycle rule, or by its TTL if Rule is empty,
+	// or the purge of an object or bucket expired in the recycle bin if Trash is its item. Native actions are taken
+	// by the backend, they are only reported in dry runs
+	lifecycleAction struct {
+		Owner        string `json:"-"`
+		Rule         string `json:"rule,omitempty"`
+		Action       string `json:"action"`
+		Store        string `json:"store"`
+		Bucket       string `json:"bucket"`
+		Path         string `json:"path,omitempty"`
+		StorageClass string `json:"storageClass,omitempty"`
+		Native       bool   `json:"native,omitempty"`
+		Trash        string `json:"trash,omitempty"`
+		Error        string `json:"error,omitempty"`
+	}
+)
+
+// PutLifecycleRule adds a rule deleting, or archiving to a colder storage class, the objects of a bucket in a
+// folder once they are older than days. Archiving rules of a folder are applied to the lifecycle configuration of
+// the backend if it has one, alongside the rules configured outside phoenix, otherwise phoenix enforces them.
+// Deleting rules are always enforced by phoenix, which keeps locks, quotas, webhooks and the change feed in step,
+// and can't be added to co-owned stores. Rules can only be managed with owner session
+// example: curl -H "Authorization: Bearer session" -d '{"store": "s3", "bucket": "telemetry", "prefix": "raw", "action": "delete", "days": 30}' http://localhost:8080/lifecycle
+func (h *StorageHandler) PutLifecycleRule(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	item := &lifecycleObject{}
+	if err := decodeAndCloseRequest(r, item); err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	rule := &auth.LifecycleRule{
+		Store:        item.Store,
+		Bucket:       item.Bucket,
+		Prefix:       item.Prefix,
+		Action:       item.Action,
+		Days:         item.Days,
+		StorageClass: item.StorageClass,
+	}
+	if err := rule.Normalize(); err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	switch _, err := h.cred.GetStore(claims.Namespace, rule.Store); errors.Cause(err) {
+	case nil:
+		break
+	case db.ErrBucketNotExist, db.ErrNotExist:
+		renderJSON(w, http.StatusNotFound, H{"message": "store " + rule.Store + " is not registered"})
+		return
+	default:
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	if rule.Action == auth.LifecycleDelete {
+		if statusCode, err := h.checkExpiring(claims.Namespace, rule.Store); err != nil {
+			renderJSON(w, statusCode, H{"message": err.Error()})
+			return
+		}
+	}
+	if err := h.lifecycles.PutRule(claims.Namespace, rule); err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	rule.Native = h.configureLifecycle(claims.Namespace, rule.Store, rule.Bucket) && nativeRule(rule)
+	renderJSON(w, http.StatusOK, H{"message": "successful", "rule": rule})
+}
+
+// ListLifecycleRules lists the lifecycle rules of the owner
+// example: curl -H "Authorization: Bearer session" http://localhost:8080/lifecycle
+func (h *StorageHandler) ListLifecycleRules(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	rules, err := h.lifecycles.Rules(claims.Namespace)
+	if err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	renderJSON(w, http.StatusOK, H{"rules": rules})
+}
+
+// DeleteLifecycleRule removes a lifecycle rule, and the lifecycle configuration of the backend is updated
+// example: curl -X DELETE -H "Authorization: Bearer session" http://localhost:8080/lifecycle/<id>
+func (h *StorageHandler) DeleteLifecycleRule(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	rule, err := h.lifecycles.GetRule(claims.Namespace, chi.URLParam(r, "id"))
+	if err == nil {
+		err = h.lifecycles.DelRule(claims.Namespace, rule.ID)
+	}
+	switch errors.Cause(err) {
+	case nil:
+		break
+	case auth.ErrLifecycleRuleNotFound:
+		renderJSON(w, http.StatusNotFound, H{"message": err.Error()})
+		return
+	default:
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	h.configureLifecycle(claims.Namespace, rule.Store, rule.Bucket)
+	renderJSON(w, http.StatusOK, H{"message": "successful"})
+}
+
+// LifecycleReport reports what a sweep would delete and archive of the owner's objects, and purge from recycle
+// bins, at the time given as unix time in at, now by default, without doing it. Native rules are reported as well,
+// as the backend would enforce them
+// example: curl -H "Authorization: Bearer session" 'http://localhost:8080/lifecycle/report?at=1607772249'
+func (h *StorageHandler) LifecycleReport(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	at := time.Now()
+	if v := r.URL.Query().Get("at"); v != "" {
+		t, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			renderJSON(w, http.StatusBadRequest, H{"message": "at must be unix time"})
+			return
+		}
+		at = time.Unix(t, 0)
+	}
+	renderJSON(w, http.StatusOK, h.sweep(r.Context(), claims.Namespace, at, true))
+}
+
+// configureLifecycle applies the archiving rules of the bucket to the lifecycle configuration of the backend, and
+// returns true if the backend enforces them. Deleting rules are left to phoenix, as the backend would delete
+// objects without releasing their quota usage, and without webhooks and the change feed knowing
+func (h *StorageHandler) configureLifecycle(namespace, store, bucket string) bool {
+	all, err := h.lifecycles.Rules(namespace)
+	if err != nil {
+		h.log.Error("failed to read lifecycle rules", zap.Error(err))
+		return false
+	}
+	bucketRules, rules := []*auth.LifecycleRule{}, []*auth.LifecycleRule{}
+	for _, rule := range all {
+		if rule.Store != store || rule.Bucket != bucket {
+			continue
+		}
+		bucketRules = append(bucketRules, rule)
+		if nativeRule(rule) {
+			rules = append(rules, rule)
+		}
+	}
+	native := false
+	if cred, err := h.cred.GetStore(namespace, store); err == nil {
+		if backend, err := storage.NewStorage(cred); err == nil {
+			if lifecycler, ok := backend.(storage.Lifecycler); ok {
+				err = lifecycler.PutBucketLifecycle(bucket, rules)
+				if err != nil {
+					h.log.Warn("lifecycle rules are enforced by phoenix", zap.String("bucket", bucket), zap.Error(err))
+				}
+				native = err == nil
+			}
+		}
+	}
+	for _, rule := range bucketRules {
+		if rule.Native == (native && nativeRule(rule)) {
+			continue
+		}
+		rule.Native = !rule.Native
+		if err := h.lifecycles.PutRule(namespace, rule); err != nil {
+			h.log.Error("failed to record lifecycle rule", zap.String("id", rule.ID), zap.Error(err))
+		}
+	}
+	return native
+}
+
+// nativeRule returns true if the rule can be enforced by the backend. Deletes are left to phoenix to honour locks,
+// and so are rules of the whole bucket, as backend rules can't leave the recycle bin out
+func nativeRule(rule *auth.LifecycleRule) bool {
+	return rule.Action == auth.LifecycleArchive && rule.Prefix != ""
+}
+
+// SweepLifecycle deletes and archives the objects due by the lifecycle rules phoenix enforces, deletes the objects
+// expired by their TTLs, and purges the objects and buckets expired in recycle bins, every lifecycle.interval until
+// the context is done
+func (h *StorageHandler) SweepLifecycle(ctx context.Context) {
+	ticker := time.NewTicker(lifecycleInterval(h.cfg))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		report := h.sweep(ctx, "", time.Now(), false)
+		failed := 0
+		for _, a := range report.Actions {
+			if a.Error != "" {
+				failed++
+				h.log.Error("failed lifecycle action", zap.String("action", a.Action), zap.String("bucket", a.Bucket),
+					zap.String("path", a.Path), zap.String("error", a.Error))
+			}
+		}
+		if len(report.Actions) > 0 {
+			h.log.Info("swept lifecycle", zap.Int("actions", len(report.Actions)), zap.Int("failed", failed))
+		}
+	}
+}
+
+// sweep enforces the lifecycle rules, TTLs and recycle bin retention of the owner, or of all owners if namespace is
+// empty, at the time. A dry run only reports what would be done
+func (h *StorageHandler) sweep(ctx context.Context, namespace string, now time.Time, dryRun bool) *lifecycleReport {
+	report := &lifecycleReport{DryRun: dryRun, Time: now.Unix(), Actions: []*lifecycleAction{}}
+	backends := map[string]storage.Backend{}
+	backend := func(owner, store string) (storage.Backend, error) {
+		if b, ok := backends[owner+"/"+store]; ok {
+			return b, nil
+		}
+		cred, err := h.cred.GetStore(owner, store)
+		if err != nil {
+			return nil, err
+		}
+		b, err := storage.NewStorage(cred)
+		if err != nil {
+			return nil, err
+		}
+		backends[owner+"/"+store] = b
+		return b, nil
+	}
+
+	rules, err := h.lifecycles.Rules(namespace)
+	if err != nil {
+		h.log.Error("failed to read lifecycle rules", zap.Error(err))
+	}
+	for _, rule := range rules {
+		if ctx.Err() != nil {
+			return report
+		}
+		if rule.Native && !dryRun {
+			continue
+		}
+		b, err := backend(rule.Owner, rule.Store)
+		var objects []storage.Object
+		if err == nil {
+			objects, err = b.ListObjects(rule.Bucket, rule.Prefix)
+		}
+		if err != nil {
+			report.Actions = append(report.Actions, &lifecycleAction{
+				Owner: rule.Owner, Rule: rule.ID, Action: rule.Action, Store: rule.Store, Bucket: rule.Bucket, Error: err.Error(),
+			})
+			continue
+		}
+		for _, o := range objects {
+			path := pathutil.Join(rule.Prefix, o.Path)
+			if auth.IsTrashPath(path) || !rule.Due(o.LastModified, now) {
+				continue
+			}
+			a := &lifecycleAction{
+				Owner:        rule.Owner,
+				Rule:         rule.ID,
+				Action:       rule.Action,
+				Store:        rule.Store,
+				Bucket:       rule.Bucket,
+				Path:         path,
+				StorageClass: rule.StorageClass,
+				Native:       rule.Native,
+			}
+			if dryRun {
+				h.retained(a, now.Unix())
+			} else {
+				h.enforce(b, a)
+			}
+			report.Actions = append(report.Actions, a)
+		}
+	}
+
+	expired, err := h.lifecycles.Expired(namespace, now.Unix())
+	if err != nil {
+		h.log.Error("failed to read expiries", zap.Error(err))
+	}
+	for _, e := range expired {
+		if ctx.Err() != nil {
+			return report
+		}
+		a := &lifecycleAction{Owner: e.Owner, Action: auth.LifecycleDelete, Store: e.Store, Bucket: e.Bucket, Path: e.Path}
+		report.Actions = append(report.Actions, a)
+		if dryRun {
+			h.retained(a, now.Unix())
+			continue
+		}
+		if b, err := backend(e.Owner, e.Store); err != nil {
+			a.Error = err.Error()
+		} else {
+			h.enforce(b, a)
+		}
+	}
+
+	trashed, err := h.trash.Expired(namespace, now.Unix())
+	if err != nil {
+		h.log.Error("failed to read recycle bins", zap.Error(err))
+	}
+	for _, item := range trashed {
+		if ctx.Err() != nil {
+			return report
+		}
+		a := &lifecycleAction{
+			Owner: item.Owner, Action: auth.LifecycleDelete, Store: item.Store, Bucket: item.Bucket, Path: item.Path, Trash: item.ID,
+		}
+		report.Actions = append(report.Actions, a)
+		if dryRun {
+			continue
+		}
+		b, err := backend(item.Owner, item.Store)
+		if err == nil {
+			err = h.purge(b, item)
+		}
+		if err != nil {
+			a.Error = err.Error()
+		}
+	}
+	return report
+}
+
+// enforce deletes or archives the object of the action, the error is recorded in the action
+func (h *StorageHandler) enforce(backend storage.Backend, a *lifecycleAction) {
+	if h.retained(a, time.Now().Unix()) {
+		return
+	}
+	if a.Action == auth.LifecycleArchive {
+		archiver, ok := backend.(storage.Archiver)
+		if !ok {
+			a.Error = "backend can't archive objects"
+			return
+		}
+		if err := archiver.ArchiveObject(a.Bucket, a.Path, a.StorageClass); err != nil {
+			a.Error = err.Error()
+		}
+		return
+	}
+	if err := backend.DeleteObject(a.Bucket, a.Path); err != nil {
+		a.Error = err.Error()
+		return
+	}
+	if err := h.lifecycles.Forget(a.Owner, a.Store, a.Bucket, a.Path); err != nil {
+		h.log.Error("failed to remove expiry", zap.Error(err))
+	}
+	if err := h.retentions.Release(a.Owner, a.Store, a.Bucket, a.Path); err != nil {
+		h.log.Error("failed to remove object lock", zap.Error(err))
+	}
+	if err := h.quotas.Remove(a.Owner, a.Store, a.Bucket, a.Path); err != nil {
+		h.log.Error("failed to record quota usage", zap.Error(err))
+	}
+	h.publish(a.Owner, EventObjectDeleted, a.Store, a.Bucket, a.Path, &storageEvent{Store: a.Store, Bucket: a.Bucket, Path: a.Path})
+	h.changed(a.Owner, &auth.Change{Type: auth.ChangeDeleted, Store: a.Store, Bucket: a.Bucket, Path: a.Path})
+}
+
+// retained returns true if the action deletes an object retained or under legal hold at the time, or of a store
+// co-owned since the rule was added or the object uploaded, which is recorded as the error of the action.
+// Archiving keeps the content, so locked objects are archived all the same
+func (h *StorageHandler) retained(a *lifecycleAction, t int64) bool {
+	if a.Action != auth.LifecycleDelete {
+		return false
+	}
+	if _, err := h.checkExpiring(a.Owner, a.Store); err != nil {
+		a.Error = err.Error()
+		return true
+	}
+	if err := h.retentions.Check(a.Owner, a.Store, a.Bucket, a.Path, t); err != nil {
+		a.Error = err.Error()
+		return true
+	}
+	return false
+}
+
+// checkExpiring fails with 409 if the store is co-owned, whose objects are only deleted with the approval of
+// co-owners, so lifecycle rules and TTLs can't delete them
+func (h *StorageHandler) checkExpiring(namespace, store string) (int, error) {
+	ownership, err := h.ownerships.GetOwnership(namespace, store)
+	switch {
+	case err != nil:
+		return http.StatusInternalServerError, err
+	case ownership != nil:
+		return http.StatusConflict, ErrorCoOwnedExpiry
+	}
+	return http.StatusOK, nil
+}
+
+// objectTTL returns the seconds an object uploaded lives, given in header X-Phoenix-TTL or query ttl, zero if it
+// lives until deleted
+func objectTTL(r *http.Request) (int64, error) {
+	v := r.Header.Get("X-Phoenix-TTL")
+	if v == "" {
+		v = r.URL.Query().Get("ttl")
+	}
+	if v == "" {
+		return 0, nil
+	}
+	ttl, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || ttl <= 0 {
+		return 0, errors.New("ttl must be positive seconds")
+	}
+	return ttl, nil
+}
+
+func lifecycleInterval(cfg *config.Config) time.Duration {
+	if cfg.Lifecycle.Interval > 0 {
+		return time.Duration(cfg.Lifecycle.Interval) * time.Second
+	}
+	return time.Hour
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"encoding/csv"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/iotexproject/phoenix/auth"
+)
+
+// ExportMetering exports the hourly or daily meters of the owner and its tokens starting within the time range,
+// as json, or as csv with format=csv. The meter of the owner has no token, token selects the meters of a token
+// example: curl -H "Authorization: Bearer session" 'http://localhost:8080/metering?granularity=hour&from=1607772249&format=csv'
+func (h *StorageHandler) ExportMetering(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	h.exportMetering(w, r, claims.Namespace)
+}
+
+// ExportAllMetering exports the meters of all owners, or of the owner given, for accounting. Meters can only be
+// exported with owner session of an operator
+// example: curl -H "Authorization: Bearer session" 'http://localhost:8080/admin/metering?owner=io1...&from=1607772249&format=csv'
+func (h *StorageHandler) ExportAllMetering(w http.ResponseWriter, r *http.Request) {
+	namespace := ""
+	if owner := r.URL.Query().Get("owner"); owner != "" {
+		addr, err := auth.ParseAddress(owner)
+		if err != nil {
+			renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+			return
+		}
+		namespace = addr.Hex()[2:]
+	}
+	h.exportMetering(w, r, namespace)
+}
+
+func (h *StorageHandler) exportMetering(w http.ResponseWriter, r *http.Request, namespace string) {
+	query := r.URL.Query()
+	var from, to int64
+	for name, t := range map[string]*int64{"from": &from, "to": &to} {
+		if v := query.Get(name); v != "" {
+			var err error
+			if *t, err = strconv.ParseInt(v, 10, 64); err != nil {
+				renderJSON(w, http.StatusBadRequest, H{"message": name + " must be unix time"})
+				return
+			}
+		}
+	}
+	granularity := query.Get("granularity")
+	if granularity == "" {
+		granularity = auth.MeterDaily
+	}
+
+	meters, err := h.metering.Export(namespace, granularity, from, to)
+	switch errors.Cause(err) {
+	case nil:
+		break
+	case auth.ErrGranularity:
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	default:
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	if token := query.Get("token"); token != "" {
+		selected := []*auth.Meter{}
+		for _, meter := range meters {
+			if meter.Token == token {
+				selected = append(selected, meter)
+			}
+		}
+		meters = selected
+	}
+	if query.Get("format") != "csv" {
+		renderJSON(w, http.StatusOK, H{"meters": meters})
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="metering.csv"`)
+	if err := writeMeteringCSV(w, meters); err != nil {
+		h.log.Error("failed to export meters", zap.Error(err))
+	}
+}
+
+// meteringColumns are the columns of meters exported as csv, followed by the count of each operation
+var meteringColumns = []string{"granularity", "start", "owner", "token", "requests", "bytesIn", "bytesOut"}
+
+func writeMeteringCSV(w io.Writer, meters []*auth.Meter) error {
+	cw := csv.NewWriter(w)
+	columns := append([]string{}, meteringColumns...)
+	for _, op := range auth.Ops {
+		columns = append(columns, strings.ToLower(op))
+	}
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+	for _, meter := range meters {
+		row := []string{
+			meter.Granularity,
+			time.Unix(meter.Start, 0).UTC().Format(time.RFC3339),
+			meter.Owner,
+			meter.Token,
+			strconv.FormatInt(meter.Requests, 10),
+			strconv.FormatInt(meter.BytesIn, 10),
+			strconv.FormatInt(meter.BytesOut, 10),
+		}
+		for _, op := range auth.Ops {
+			row = append(row, strconv.FormatInt(meter.Ops[op], 10))
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
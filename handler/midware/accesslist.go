@@ -0,0 +1,69 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package midware
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/iotexproject/phoenix/auth"
+	"github.com/iotexproject/phoenix/log"
+)
+
+// AccessListed rejects the request if the owner issuing its token, the subject of the token or the client IP is
+// denied, or not allowed, by the access lists of the operator. It must come after middleware.RealIP
+func AccessListed(lists auth.AccessLists) func(http.Handler) http.Handler {
+	logger := log.Logger("midware")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := r.Context().Value(auth.TokenCtxKey).(*auth.Claims)
+			if !ok {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			switch err := lists.Check(claims, ClientIP(r)); errors.Cause(err) {
+			case nil:
+				next.ServeHTTP(w, r)
+			case auth.ErrAccessListed:
+				logger.Info("access list rejected request",
+					zap.String("namespace", claims.Root().Namespace),
+					zap.String("subject", claims.Subject),
+					zap.String("ip", ClientIP(r)),
+					zap.Error(err),
+				)
+				http.Error(w, err.Error(), http.StatusForbidden)
+			default:
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		})
+	}
+}
+
+// OperatorOnly rejects the request unless it is authenticated by the owner session of an operator, whose
+// namespaces are given
+func OperatorOnly(operators []string) func(http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(operators))
+	for _, namespace := range operators {
+		allowed[namespace] = true
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := r.Context().Value(auth.TokenCtxKey).(*auth.Claims)
+			if !ok {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			if !claims.Owner || !allowed[claims.Namespace] {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
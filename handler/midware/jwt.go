@@ -11,33 +11,54 @@ import (
 	"net/http"
 	"strings"
 
-	"github.com/iotexproject/iotex-antenna-go/v2/jwt"
+	"github.com/pkg/errors"
 
 	"github.com/iotexproject/phoenix/auth"
 )
 
-// JWTTokenValid operation midware
-func JWTTokenValid(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Get token from authorization header.
-		jwtString := ""
-		bearer := r.Header.Get("Authorization")
-		if len(bearer) > 7 && strings.ToUpper(bearer[0:6]) == "BEARER" {
-			jwtString = bearer[7:]
-		}
-
-		if jwtString == "" {
-			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
-			return
-		}
-
-		jwtoken, err := jwt.VerifyJWT(jwtString)
-		if err != nil {
-			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
-			return
-		}
-
-		ctx := context.WithValue(r.Context(), auth.TokenCtxKey, &auth.Claims{JWT: jwtoken})
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+// JWTTokenValid operation midware, the issuer of token is resolved by resolver. The request already
+// authenticated by a midware before it is passed through, which checks the grant of its token itself.
+// If grants is not nil, the token must be recorded in a live grant
+func JWTTokenValid(resolver auth.IssuerResolver, grants auth.Grants) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := r.Context().Value(auth.TokenCtxKey).(*auth.Claims); ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			jwtString := bearerToken(r)
+			if jwtString == "" {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+
+			claims, err := auth.NewClaimsWithResolver(jwtString, resolver)
+			if errors.Cause(err) == auth.ErrNotYetValid {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			if grants != nil {
+				if _, err := grants.Live(claims); err != nil {
+					http.Error(w, err.Error(), http.StatusUnauthorized)
+					return
+				}
+			}
+
+			ctx := context.WithValue(r.Context(), auth.TokenCtxKey, claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// bearerToken gets token from authorization header
+func bearerToken(r *http.Request) string {
+	bearer := r.Header.Get("Authorization")
+	if len(bearer) > 7 && strings.ToUpper(bearer[0:6]) == "BEARER" {
+		return bearer[7:]
+	}
+	return ""
 }
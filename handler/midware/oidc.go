@@ -0,0 +1,54 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package midware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/iotexproject/phoenix/auth"
+)
+
+// OIDCTokenValid midware, accepts a token of the configured identity providers as the bearer token, which
+// is granted the access the owner in header X-Phoenix-Owner recorded for the identity. Other tokens are
+// left to the midware after it. The identity recorded is the grant of the token, so the token isn't
+// required to be recorded in a grant
+func OIDCTokenValid(verifier *auth.OIDCVerifier, identities auth.Identities) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			jwtString := bearerToken(r)
+			if !verifier.Accepts(jwtString) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			id, err := verifier.Verify(jwtString)
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			namespace, err := auth.OwnerNamespace(r.Header.Get(auth.OwnerHeader))
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+			grant, err := identities.GetIdentity(namespace, auth.IdentityID(id.Issuer, id.Subject))
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			claims, err := grant.Claims(namespace, jwtString, id)
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), auth.TokenCtxKey, claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package midware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/iotexproject/phoenix/auth"
+)
+
+// OwnerTokenValid accepts an owner session created by wallet login besides the JWT
+func OwnerTokenValid(sessions auth.Sessions, resolver auth.IssuerResolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		jwtNext := JWTTokenValid(resolver, nil)(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := bearerToken(r)
+			if token == "" || strings.Contains(token, ".") {
+				jwtNext.ServeHTTP(w, r)
+				return
+			}
+
+			claims, err := sessions.Claims(token)
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			ctx := context.WithValue(r.Context(), auth.TokenCtxKey, claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package midware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/iotexproject/phoenix/auth"
+)
+
+// PresentationValid midware, accepts a JWT encoded verifiable presentation as the bearer token,
+// other tokens are left to the midware after it. If grants is not nil, the credential in the
+// presentation must be recorded in a live grant
+func PresentationValid(verifier *auth.PresentationVerifier, grants auth.Grants) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			vpString := bearerToken(r)
+			if !auth.IsPresentation(vpString) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			claims, err := verifier.Verify(vpString)
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			if grants != nil {
+				if _, err := grants.Live(claims); err != nil {
+					http.Error(w, err.Error(), http.StatusUnauthorized)
+					return
+				}
+			}
+
+			ctx := context.WithValue(r.Context(), auth.TokenCtxKey, claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -13,7 +13,6 @@ import (
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/httprate"
-	"github.com/iotexproject/go-pkgs/crypto"
 	"github.com/iotexproject/phoenix/auth"
 	"github.com/iotexproject/phoenix/config"
 	"github.com/pkg/errors"
@@ -27,7 +26,7 @@ func RateLimit(rateLimit config.RateLimit) chi.Middlewares {
 		for _, key := range rateLimit.LimitByKey {
 			switch strings.ToLower(key) {
 			case "ip", "client":
-				keyFuncs = append(keyFuncs, httprate.KeyByIP)
+				keyFuncs = append(keyFuncs, keyByClientIP)
 			case "url", "endpoint":
 				keyFuncs = append(keyFuncs, httprate.KeyByEndpoint)
 			case "token", "user":
@@ -36,12 +35,8 @@ func RateLimit(rateLimit config.RateLimit) chi.Middlewares {
 					if !ok {
 						return "", errors.New("failed to get claims in context")
 					}
-					// trustor is the address that registers endpoint with us
-					trustor, err := crypto.HexStringToPublicKey(claims.Issuer)
-					if err != nil {
-						return "", err
-					}
-					return trustor.Address().Hex(), nil
+					// trustor is the owner that registers endpoint with us
+					return claims.Root().Namespace, nil
 				})
 			}
 		}
@@ -51,3 +46,21 @@ func RateLimit(rateLimit config.RateLimit) chi.Middlewares {
 	}
 	return middlewares
 }
+
+// PublicRateLimit limits the requests of each client IP to endpoints that take no token, whether RateLimit is
+// enabled or not. The requests to the routes of each call are counted separately
+func PublicRateLimit(rateLimit config.PublicRateLimit) func(http.Handler) http.Handler {
+	limit, window := rateLimit.RequestLimit, time.Duration(rateLimit.WindowLength)*time.Second
+	if limit <= 0 {
+		limit = 30
+	}
+	if window <= 0 {
+		window = time.Minute
+	}
+	return httprate.Limit(limit, window, keyByClientIP)
+}
+
+// keyByClientIP keys requests by the client IP, which RealIP only takes from the headers of trusted proxies
+func keyByClientIP(r *http.Request) (string, error) {
+	return ClientIP(r), nil
+}
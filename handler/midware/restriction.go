@@ -0,0 +1,76 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package midware
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/iotexproject/phoenix/auth"
+	"github.com/iotexproject/phoenix/log"
+)
+
+// restrictionRules names the restriction rejecting the request in logs
+var restrictionRules = map[error]string{
+	auth.ErrNotYetValid: "nbf",
+	auth.ErrClientIP:    "cidr",
+	auth.ErrUserAgent:   "userAgent",
+	auth.ErrTimeWindow:  "window",
+}
+
+// TokenRestricted rejects the request if its client IP, user agent or time is not allowed by the token, or any
+// token it is delegated from. The client IP is the remote address, it must come after RealIP to honor the
+// address forwarded by trusted proxies
+func TokenRestricted() func(http.Handler) http.Handler {
+	logger := log.Logger("midware")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := r.Context().Value(auth.TokenCtxKey).(*auth.Claims)
+			if !ok {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			ip := ClientIP(r)
+			err := claims.Restrict(ip, r.UserAgent(), time.Now())
+			if err == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			rule, ok := restrictionRules[errors.Cause(err)]
+			if !ok {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			logger.Info("token restriction rejected request",
+				zap.String("rule", rule),
+				zap.String("issuer", claims.Issuer),
+				zap.String("subject", claims.Subject),
+				zap.String("ip", ip),
+				zap.String("userAgent", r.UserAgent()),
+				zap.Error(err),
+			)
+			status := http.StatusForbidden
+			if rule == "nbf" {
+				status = http.StatusUnauthorized
+			}
+			http.Error(w, err.Error(), status)
+		})
+	}
+}
+
+// ClientIP returns the IP of the remote address, which RealIP sets to the address forwarded by trusted proxies
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,36 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package midware
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"github.com/iotexproject/phoenix/auth"
+)
+
+// TokenNotRevoked rejects the request if its token, or any token it is delegated from, has been revoked
+func TokenNotRevoked(revocation auth.Revocation) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := r.Context().Value(auth.TokenCtxKey).(*auth.Claims)
+			if !ok {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			switch err := revocation.Check(claims); errors.Cause(err) {
+			case nil:
+				next.ServeHTTP(w, r)
+			case auth.ErrTokenRevoked:
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+			default:
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		})
+	}
+}
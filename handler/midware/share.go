@@ -0,0 +1,49 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package midware
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/pkg/errors"
+
+	"github.com/iotexproject/phoenix/auth"
+)
+
+// ShareLinkValid authorizes the request with the share link in its query instead of a token, the link must be
+// signed with the key, not expired, and shared by tokens none of which has been revoked
+func ShareLinkValid(key []byte, revocation auth.Revocation) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key == nil {
+				http.Error(w, "sharing is not available", http.StatusServiceUnavailable)
+				return
+			}
+			query := r.URL.Query()
+			link, err := auth.ParseShareLink(chi.URLParam(r, "bucket"), chi.URLParam(r, "*"), query)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := link.Verify(key, query.Get("signature"), time.Now()); err != nil {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			switch err := revocation.CheckHashes(link.Chain); errors.Cause(err) {
+			case nil:
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), auth.ShareCtxKey, link)))
+			case auth.ErrTokenRevoked:
+				http.Error(w, err.Error(), http.StatusForbidden)
+			default:
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package midware
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/iotexproject/phoenix/auth"
+)
+
+// ProofOfPossession requires the request of a token bound to its holder to be signed by the holder,
+// and rejects requests replayed within the time window of the guard, which is shared by the routes
+func ProofOfPossession(guard auth.ReplayGuard) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := r.Context().Value(auth.TokenCtxKey).(*auth.Claims)
+			if !ok {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			if claims.Holder == "" {
+				// bearer token
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var body []byte
+			if r.Body != nil {
+				var err error
+				if body, err = ioutil.ReadAll(r.Body); err != nil {
+					http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+					return
+				}
+				r.Body.Close()
+				r.Body = ioutil.NopCloser(bytes.NewReader(body))
+			}
+			nonce, err := auth.VerifyRequest(r, body, claims.Holder, guard.Window())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			if err := guard.Check(claims.Holder + "/" + nonce); err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
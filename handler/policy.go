@@ -0,0 +1,76 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/pkg/errors"
+
+	"github.com/iotexproject/phoenix/auth"
+)
+
+// PutPolicy attaches a CEL expression to a registered store or a bucket in it, requests of trustees are allowed
+// only if the expression evaluates to true. Policies can only be managed with owner session
+// example: curl -H 'Content-Type: application/json' -H "Authorization: Bearer session" -d '{"store": "s3", "bucket": "test", "expression": "request.path.startsWith(\"public/\")"}' http://localhost:8080/policies
+func (h *StorageHandler) PutPolicy(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, ok := ctx.Value(auth.TokenCtxKey).(*auth.Claims)
+	if !ok {
+		renderJSON(w, http.StatusInternalServerError, H{"message": http.StatusText(http.StatusInternalServerError)})
+		return
+	}
+	if !claims.Owner {
+		renderJSON(w, http.StatusForbidden, H{"message": ErrorPermissionDenied.Error()})
+		return
+	}
+
+	item := &policyObject{}
+	if err := decodeAndCloseRequest(r, item); err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	policy := &auth.Policy{
+		Store:      item.Store,
+		Bucket:     item.Bucket,
+		Expression: item.Expression,
+	}
+	switch err := h.policies.PutPolicy(claims.Namespace, policy); errors.Cause(err) {
+	case nil:
+		break
+	case auth.ErrPolicy:
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	default:
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	renderJSON(w, http.StatusOK, H{"message": "successful", "store": policy.Store, "bucket": policy.Bucket})
+}
+
+// DelPolicy removes the policy of a store or a bucket
+// example: curl -H "Authorization: Bearer session" -X DELETE http://localhost:8080/policies/s3/test
+func (h *StorageHandler) DelPolicy(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, ok := ctx.Value(auth.TokenCtxKey).(*auth.Claims)
+	if !ok {
+		renderJSON(w, http.StatusInternalServerError, H{"message": http.StatusText(http.StatusInternalServerError)})
+		return
+	}
+	if !claims.Owner {
+		renderJSON(w, http.StatusForbidden, H{"message": ErrorPermissionDenied.Error()})
+		return
+	}
+
+	store, bucket := chi.URLParam(r, "store"), chi.URLParam(r, "bucket")
+	if err := h.policies.DelPolicy(claims.Namespace, store, bucket); err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	renderJSON(w, http.StatusOK, H{"message": "successful", "store": store, "bucket": bucket})
+}
@@ -0,0 +1,398 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"crypto/ed25519"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/iotexproject/iotex-antenna-go/v2/jwt"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/iotexproject/phoenix/auth"
+	"github.com/iotexproject/phoenix/db"
+	"github.com/iotexproject/phoenix/handler/midware"
+	"github.com/iotexproject/phoenix/storage"
+)
+
+// PresignObject authorizes the download (GET) or upload (PUT) of an object, and returns a short-lived URL to
+// transfer it with. The URL is presigned by the backend to transfer directly from it if it can, otherwise, or
+// if quotas, policies or locks phoenix enforces apply or proxy is requested, it is signed by phoenix to transfer
+// through phoenix. The URL expires with the token at the latest
+// example: curl -H "Authorization: Bearer jwttoken" -d '{"bucket": "test11", "path": "foo.txt", "method": "GET", "expiresIn": 300}' http://localhost:8080/presign
+func (h *StorageHandler) PresignObject(w http.ResponseWriter, r *http.Request) {
+	claims, backend, statusCode := h.createBackendForRequest(r)
+	if statusCode != http.StatusOK {
+		renderJSON(w, statusCode, http.StatusText(statusCode))
+		return
+	}
+	item := &presignObject{}
+	if err := decodeAndCloseRequest(r, item); err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	method := strings.ToUpper(item.Method)
+	var op string
+	switch method {
+	case "", http.MethodGet:
+		method, op = http.MethodGet, jwt.READ
+	case http.MethodPut:
+		op = jwt.UPDATE
+	default:
+		renderJSON(w, http.StatusBadRequest, H{"message": "method must be GET or PUT"})
+		return
+	}
+	if item.Bucket == "" || item.Path == "" {
+		renderJSON(w, http.StatusBadRequest, H{"message": "bucket and path are required"})
+		return
+	}
+	rec := auditRecord(r)
+	rec.Op, rec.Bucket, rec.Path = op, item.Bucket, item.Path
+	//check scope permission
+	in := policyInput(r, claims, op, item.Bucket, item.Path)
+	if op == jwt.UPDATE {
+		// the object is uploaded with the URL, policies are evaluated again with its size and content type
+		in.Size, in.ContentType = -1, ""
+	}
+	if statusCode, err := h.authorizeInput(claims, in); err != nil {
+		renderJSON(w, statusCode, H{"message": err.Error()})
+		return
+	}
+	ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+	defer h.refundFailed(ww, claims, op)
+	w = ww
+	if op == jwt.READ && !h.checkEmbargo(w, claims, item.Bucket, item.Path) {
+		return
+	}
+	// URLs are used from any client, at any time, so restricted tokens must transfer themselves
+	if claims.Restricted() {
+		renderJSON(w, http.StatusForbidden, H{"message": ErrorLinkRestricted.Error()})
+		return
+	}
+	// transfers of URLs are not counted against the token, so limited tokens must transfer themselves
+	for _, link := range claims.Chain() {
+		if link.Quota != nil || link.Limited() {
+			renderJSON(w, http.StatusForbidden, H{"message": ErrorPresignLimited.Error()})
+			return
+		}
+	}
+
+	now := time.Now()
+	expiresAt := linkExpiry(claims, presignTTL(h.cfg), item.ExpiresIn, now)
+	if !expiresAt.After(now) {
+		renderJSON(w, http.StatusUnauthorized, H{"message": "token has expired"})
+		return
+	}
+
+	namespace := claims.Root().Namespace
+	// transfers bypassing phoenix can't be counted against quotas
+	sq, err := h.quotas.GetQuota(namespace, claims.Store())
+	if err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	// nor retained by phoenix, or checked against the locks phoenix alone enforces
+	retained := false
+	if method == http.MethodPut {
+		if retained, err = h.retentions.Retained(namespace, claims.Store(), item.Bucket, now.Unix()); err != nil {
+			renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+			return
+		}
+	}
+	// nor checked against policies when the object is transferred
+	policed, err := h.policed(claims, item.Bucket)
+	if err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	presigner, ok := backend.(storage.Presigner)
+	direct := ok && !item.Proxy && sq == nil && globalQuota(h.cfg) == nil && !retained && !policed
+
+	var presigned string
+	if direct {
+		ttl := expiresAt.Sub(now)
+		if method == http.MethodGet {
+			presigned, err = presigner.PresignGetObject(item.Bucket, item.Path, ttl)
+		} else {
+			presigned, err = presigner.PresignPutObject(item.Bucket, item.Path, ttl)
+		}
+		if err != nil {
+			renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+			return
+		}
+	} else {
+		if h.mintKey == nil {
+			renderJSON(w, http.StatusServiceUnavailable, H{"message": "presigning is not available"})
+			return
+		}
+		p := &auth.PresignedRequest{
+			Method:  method,
+			Owner:   namespace,
+			Store:   claims.Store(),
+			Bucket:  item.Bucket,
+			Path:    item.Path,
+			Expires: expiresAt.Unix(),
+		}
+		for _, link := range claims.Chain() {
+			p.Chain = append(p.Chain, link.Hash())
+		}
+		if !claims.Owner {
+			p.Issuer, p.Subject, p.Scope = claims.Issuer, claims.Subject, claims.Scope
+		}
+		presigned = linkURL(r, "/presigned/"+item.Bucket+"/"+item.Path, p.Query(h.mintKey))
+	}
+	renderJSON(w, http.StatusOK, H{
+		"message":   "successful",
+		"url":       presigned,
+		"method":    method,
+		"expiresAt": expiresAt.Unix(),
+		"direct":    direct,
+	})
+}
+
+// linkExpiry returns the time a link made for the claims expires, capped by the lifetime of links and the expiry
+// of every token in the chain
+func linkExpiry(claims *auth.Claims, ttl time.Duration, expiresIn int64, now time.Time) time.Time {
+	if expiresIn > 0 && time.Duration(expiresIn)*time.Second < ttl {
+		ttl = time.Duration(expiresIn) * time.Second
+	}
+	expiresAt := now.Add(ttl)
+	for _, link := range claims.Chain() {
+		if link.ExpiresAt != 0 && time.Unix(link.ExpiresAt, 0).Before(expiresAt) {
+			expiresAt = time.Unix(link.ExpiresAt, 0)
+		}
+	}
+	return expiresAt
+}
+
+// linkURL returns the URL of phoenix the request is made to, with the path and query of a link
+func linkURL(r *http.Request, path string, query url.Values) string {
+	u := &url.URL{
+		Scheme:   "http",
+		Host:     r.Host,
+		Path:     path,
+		RawQuery: query.Encode(),
+	}
+	if r.TLS != nil {
+		u.Scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		u.Scheme = proto
+	}
+	return u.String()
+}
+
+// PresignedObject downloads (GET) or uploads (PUT) the object of URL signed by phoenix, the URL authorizes the
+// request in place of a token until it expires, or any token it is presigned with is revoked. Requests are
+// audited and metered as requests of the token presigning the URL
+// example: curl 'http://localhost:8080/presigned/test11/foo.txt?owner=...&store=s3&expires=1607772249&chain=...&signature=...'
+func (h *StorageHandler) PresignedObject(w http.ResponseWriter, r *http.Request) {
+	body := &countingReader{ReadCloser: r.Body}
+	if r.Body != nil {
+		r.Body = body
+	}
+	ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+	p := h.presignedObject(ww, r)
+	if p == nil {
+		return
+	}
+
+	op := jwt.READ
+	if p.Method == http.MethodPut {
+		op = jwt.UPDATE
+	}
+	h.auditLink(r, ww, body.n, p.Owner, p.Chain, &auth.AuditRecord{
+		Subject: strings.Join([]string{p.Store, p.Bucket, p.Path}, "/"),
+		Op:      op,
+		Bucket:  p.Bucket,
+		Path:    p.Path,
+	})
+}
+
+// presignedObject serves the request of presigned URL, and returns the request once its signature is verified
+func (h *StorageHandler) presignedObject(w http.ResponseWriter, r *http.Request) *auth.PresignedRequest {
+	if h.mintKey == nil {
+		renderJSON(w, http.StatusServiceUnavailable, H{"message": "presigning is not available"})
+		return nil
+	}
+	bucket := chi.URLParam(r, "bucket")
+	path := chi.URLParam(r, "*")
+	p, err := auth.ParsePresignedRequest(r.Method, bucket, path, r.URL.Query())
+	if err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return nil
+	}
+	if err := p.Verify(h.mintKey.Public().(ed25519.PublicKey), r.URL.Query().Get("signature"), time.Now()); err != nil {
+		renderJSON(w, http.StatusForbidden, H{"message": err.Error()})
+		return nil
+	}
+	switch err := h.revocation.CheckHashes(p.Chain); errors.Cause(err) {
+	case nil:
+		break
+	case auth.ErrTokenRevoked:
+		renderJSON(w, http.StatusForbidden, H{"message": err.Error()})
+		return p
+	default:
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return p
+	}
+
+	claims, backend, statusCode := h.linkBackend(p.Owner, p.Store, bucket, path)
+	if statusCode != http.StatusOK {
+		renderJSON(w, statusCode, http.StatusText(statusCode))
+		return p
+	}
+	if !h.checkAccessLists(w, r, claims) {
+		return p
+	}
+	// embargoes and policies apply to the token presigning the URL, as they would to its own requests
+	presigner := p.Claims()
+
+	if r.Method == http.MethodGet {
+		if !h.checkEmbargo(w, presigner, bucket, path) {
+			return p
+		}
+		if statusCode, err := h.evaluatePolicies(presigner, policyInput(r, presigner, jwt.READ, bucket, path)); err != nil {
+			renderJSON(w, statusCode, H{"message": err.Error()})
+			return p
+		}
+		object, err := backend.GetObject(bucket, path)
+		if err != nil {
+			renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+			return p
+		}
+		if err := h.quotas.Download(claims, bucket, int64(len(object.Content))); err != nil {
+			renderJSON(w, quotaStatus(err), H{"message": err.Error()})
+			return p
+		}
+		w.Header().Set("Content-Type", http.DetectContentType(object.Content))
+		w.WriteHeader(http.StatusOK)
+		w.Write(object.Content)
+		return p
+	}
+
+	// the object may have been locked since the URL was presigned
+	if statusCode, err := h.checkLock(p.Owner, p.Store, bucket, path); err != nil {
+		renderJSON(w, statusCode, H{"message": err.Error()})
+		return p
+	}
+	content, statusCode, err := h.readUpload(w, r, claims, bucket, path)
+	if err != nil {
+		renderJSON(w, statusCode, H{"message": err.Error()})
+		return p
+	}
+	in := policyInput(r, presigner, jwt.UPDATE, bucket, path)
+	in.Size = int64(len(content))
+	if statusCode, err := h.evaluatePolicies(presigner, in); err != nil {
+		renderJSON(w, statusCode, H{"message": err.Error()})
+		return p
+	}
+	reservation, err := h.quotas.Reserve(claims, bucket, path, int64(len(content)))
+	if err != nil {
+		renderJSON(w, quotaStatus(err), H{"message": err.Error()})
+		return p
+	}
+	if err := backend.PutObject(bucket, path, content); err != nil {
+		h.release(reservation)
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return p
+	}
+	if err := h.lifecycles.Forget(p.Owner, p.Store, bucket, path); err != nil {
+		h.log.Error("failed to remove expiry", zap.Error(err))
+	}
+	if err := h.retain(p.Owner, p.Store, bucket, path); err != nil {
+		h.log.Error("failed to retain object", zap.Error(err))
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return p
+	}
+	h.publish(p.Owner, EventObjectCreated, p.Store, bucket, path, &storageEvent{
+		Store: p.Store, Bucket: bucket, Path: path, Size: int64(len(content)), Subject: claims.Subject,
+	})
+	h.changed(p.Owner, &auth.Change{
+		Type: auth.ChangeCreated, Store: p.Store, Bucket: bucket, Path: path, Size: int64(len(content)), Subject: claims.Subject,
+	})
+	renderJSON(w, http.StatusOK, H{"name": bucket, "path": path, "message": "successful"})
+	return p
+}
+
+// policed returns true if a policy of the store or bucket applies to the requests of claims
+func (h *StorageHandler) policed(claims *auth.Claims, bucket string) (bool, error) {
+	if claims.Owner {
+		return false, nil
+	}
+	for _, b := range []string{"", bucket} {
+		switch _, err := h.policies.GetPolicy(claims.Root().Namespace, claims.Store(), b); errors.Cause(err) {
+		case nil:
+			return true, nil
+		case db.ErrBucketNotExist, db.ErrNotExist:
+			continue
+		default:
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// linkBackend returns the backend of owner's store an object is transferred with by a link, and the claims of the
+// owner the transfer is counted against the quotas of the owner, store and bucket with
+func (h *StorageHandler) linkBackend(namespace, store, bucket, path string) (claims *auth.Claims, backend storage.Backend, statusCode int) {
+	claims = &auth.Claims{
+		JWT:       &jwt.JWT{Subject: strings.Join([]string{store, bucket, path}, "/")},
+		Namespace: namespace,
+	}
+	// links to buckets in the recycle bin don't work until they are restored
+	switch item, err := h.trash.Bucket(namespace, store, bucket); {
+	case err != nil:
+		statusCode = http.StatusInternalServerError
+		return
+	case item != nil:
+		statusCode = http.StatusNotFound
+		return
+	}
+	backend, statusCode = h.storeBackend(namespace, store)
+	return
+}
+
+// checkAccessLists checks the access lists for the claims and the client IP of requests not authenticated by a token,
+// such as the owner of a link, the object of it and the client IP using it, and returns false if it is responded
+// that the request is rejected
+func (h *StorageHandler) checkAccessLists(w http.ResponseWriter, r *http.Request, claims *auth.Claims) bool {
+	switch err := h.accessLists.Check(claims, midware.ClientIP(r)); errors.Cause(err) {
+	case nil:
+		return true
+	case auth.ErrAccessListed:
+		renderJSON(w, http.StatusForbidden, H{"message": err.Error()})
+	default:
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+	}
+	return false
+}
+
+// storeBackend returns the backend of owner's store, and the status responded if it can't
+func (h *StorageHandler) storeBackend(namespace, store string) (storage.Backend, int) {
+	cred, err := h.cred.GetStore(namespace, store)
+	switch errors.Cause(err) {
+	case nil:
+		break
+	case db.ErrBucketNotExist, db.ErrNotExist:
+		return nil, http.StatusNoContent
+	default:
+		return nil, http.StatusInternalServerError
+	}
+	backend, err := storage.NewStorage(cred)
+	if err != nil {
+		h.log.Error("failed to new storage", zap.Error(err))
+		return nil, http.StatusServiceUnavailable
+	}
+	return backend, http.StatusOK
+}
@@ -0,0 +1,244 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/iotexproject/phoenix/auth"
+	"github.com/iotexproject/phoenix/storage"
+)
+
+// PutRetentionPolicy retains the objects uploaded to a bucket from now on against changes and deletion for days,
+// even by tokens with Update or Delete scope. The policy is applied to the object lock configuration of the backend
+// as well if the bucket has object lock enabled, otherwise phoenix alone enforces it
+// example: curl -H "Authorization: Bearer session" -d '{"store": "s3", "bucket": "ledger", "days": 365}' http://localhost:8080/retention
+func (h *StorageHandler) PutRetentionPolicy(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	item := &retentionObject{}
+	if err := decodeAndCloseRequest(r, item); err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	policy := &auth.RetentionPolicy{Store: item.Store, Bucket: item.Bucket, Days: item.Days}
+	if err := policy.Validate(); err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	backend, statusCode := h.storeBackend(claims.Namespace, policy.Store)
+	if statusCode == http.StatusNoContent {
+		renderJSON(w, http.StatusNotFound, H{"message": "store " + policy.Store + " is not registered"})
+		return
+	}
+	if statusCode != http.StatusOK {
+		renderJSON(w, statusCode, http.StatusText(statusCode))
+		return
+	}
+	if locker, ok := backend.(storage.ObjectLocker); ok {
+		err := locker.PutBucketRetention(policy.Bucket, policy.Days)
+		if err != nil {
+			h.log.Warn("retention is enforced by phoenix", zap.String("bucket", policy.Bucket), zap.Error(err))
+		}
+		policy.Native = err == nil
+	}
+	if err := h.retentions.PutRetention(claims.Namespace, policy); err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	renderJSON(w, http.StatusOK, H{"message": "successful", "policy": policy})
+}
+
+// ListRetention lists the retention policies of the owner's buckets and the locks of the owner's objects, of the
+// store and bucket if given
+// example: curl -H "Authorization: Bearer session" 'http://localhost:8080/retention?store=s3&bucket=ledger'
+func (h *StorageHandler) ListRetention(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	store, bucket := r.URL.Query().Get("store"), r.URL.Query().Get("bucket")
+	match := func(s, b string) bool {
+		return (store == "" || s == store) && (bucket == "" || b == bucket)
+	}
+	all, err := h.retentions.Retentions(claims.Namespace)
+	if err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	policies := []*auth.RetentionPolicy{}
+	for _, p := range all {
+		if match(p.Store, p.Bucket) {
+			policies = append(policies, p)
+		}
+	}
+	locks, err := h.retentions.Locks(claims.Namespace)
+	if err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	now := time.Now().Unix()
+	locked := []*auth.ObjectLock{}
+	for _, l := range locks {
+		if match(l.Store, l.Bucket) && l.Locked(now) {
+			locked = append(locked, l)
+		}
+	}
+	renderJSON(w, http.StatusOK, H{"policies": policies, "locks": locked})
+}
+
+// DeleteRetentionPolicy removes the retention policy of a bucket, the objects uploaded before stay retained
+// example: curl -X DELETE -H "Authorization: Bearer session" http://localhost:8080/retention/s3/ledger
+func (h *StorageHandler) DeleteRetentionPolicy(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	store, bucket := chi.URLParam(r, "store"), chi.URLParam(r, "bucket")
+	policy, err := h.retentions.GetRetention(claims.Namespace, store, bucket)
+	if err == nil {
+		err = h.retentions.DelRetention(claims.Namespace, store, bucket)
+	}
+	switch errors.Cause(err) {
+	case nil:
+		break
+	case auth.ErrRetentionNotFound:
+		renderJSON(w, http.StatusNotFound, H{"message": err.Error()})
+		return
+	default:
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	if policy.Native {
+		if backend, statusCode := h.storeBackend(claims.Namespace, store); statusCode == http.StatusOK {
+			if locker, ok := backend.(storage.ObjectLocker); ok {
+				if err := locker.PutBucketRetention(bucket, 0); err != nil {
+					h.log.Error("failed to remove default retention", zap.String("bucket", bucket), zap.Error(err))
+				}
+			}
+		}
+	}
+	renderJSON(w, http.StatusOK, H{"message": "successful"})
+}
+
+// LockObject retains an object until retainUntil, which can be extended but not shortened, and places or removes
+// its legal hold, which keeps it until removed. The lock is applied to the backend as well if the bucket has object
+// lock enabled
+// example: curl -H "Authorization: Bearer session" -d '{"store": "s3", "bucket": "ledger", "path": "2020.csv", "legalHold": true}' http://localhost:8080/retention/objects
+func (h *StorageHandler) LockObject(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	item := &objectLockObject{}
+	if err := decodeAndCloseRequest(r, item); err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	if item.Store == "" || item.Bucket == "" || item.Path == "" {
+		renderJSON(w, http.StatusBadRequest, H{"message": "store, bucket and path are required"})
+		return
+	}
+	if item.RetainUntil <= 0 && item.LegalHold == nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": "retainUntil or legalHold is required"})
+		return
+	}
+	backend, statusCode := h.storeBackend(claims.Namespace, item.Store)
+	if statusCode != http.StatusOK {
+		renderJSON(w, statusCode, http.StatusText(statusCode))
+		return
+	}
+	if _, err := backend.GetObject(item.Bucket, item.Path); err != nil {
+		renderJSON(w, http.StatusNotFound, H{"message": err.Error()})
+		return
+	}
+	lock, err := h.retentions.GetLock(claims.Namespace, item.Store, item.Bucket, item.Path)
+	if err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	if lock == nil {
+		lock = &auth.ObjectLock{Store: item.Store, Bucket: item.Bucket, Path: item.Path}
+	}
+	if item.RetainUntil > 0 {
+		if item.RetainUntil < lock.RetainUntil && lock.RetainUntil > time.Now().Unix() {
+			renderJSON(w, http.StatusConflict, H{"message": errors.Wrapf(auth.ErrRetentionShortened,
+				"%s is retained until %d", item.Path, lock.RetainUntil).Error()})
+			return
+		}
+		lock.RetainUntil = item.RetainUntil
+	}
+	if item.LegalHold != nil {
+		lock.LegalHold = *item.LegalHold
+	}
+	if locker, ok := backend.(storage.ObjectLocker); ok {
+		if item.RetainUntil > 0 {
+			err = locker.PutObjectRetention(item.Bucket, item.Path, time.Unix(item.RetainUntil, 0))
+		}
+		if err == nil && item.LegalHold != nil {
+			err = locker.PutObjectLegalHold(item.Bucket, item.Path, lock.LegalHold)
+		}
+		if err != nil {
+			h.log.Warn("object lock is enforced by phoenix", zap.String("bucket", item.Bucket), zap.Error(err))
+		}
+		lock.Native = err == nil
+	}
+	switch err := h.retentions.PutLock(claims.Namespace, lock); errors.Cause(err) {
+	case nil:
+		break
+	case auth.ErrRetentionShortened:
+		renderJSON(w, http.StatusConflict, H{"message": err.Error()})
+		return
+	default:
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	renderJSON(w, http.StatusOK, H{"message": "successful", "lock": lock})
+}
+
+// checkLock fails with 403 if owner's object, or any object of the bucket if path is empty, is retained or under
+// legal hold
+func (h *StorageHandler) checkLock(namespace, store, bucket, path string) (int, error) {
+	switch err := h.retentions.Check(namespace, store, bucket, path, time.Now().Unix()); errors.Cause(err) {
+	case nil:
+		return http.StatusOK, nil
+	case auth.ErrObjectLocked:
+		return http.StatusForbidden, err
+	default:
+		h.log.Error("failed to check object lock", zap.Error(err))
+		return http.StatusInternalServerError, err
+	}
+}
+
+// retain locks the object uploaded to a bucket with retention policy for the days of the policy. Uploads failing to
+// be locked must not be reported successful, as the object could be deleted before the retention ends
+func (h *StorageHandler) retain(namespace, store, bucket, path string) error {
+	policy, err := h.retentions.GetRetention(namespace, store, bucket)
+	if err != nil {
+		return errors.Wrap(err, "failed to read retention policy")
+	}
+	if policy == nil {
+		return nil
+	}
+	lock, err := h.retentions.GetLock(namespace, store, bucket, path)
+	if err != nil {
+		return errors.Wrap(err, "failed to read object lock")
+	}
+	if lock == nil {
+		lock = &auth.ObjectLock{Store: store, Bucket: bucket, Path: path}
+	}
+	// the backend retains the object by the default retention of the bucket
+	lock.RetainUntil, lock.Native = time.Now().Add(policy.Retention()).Unix(), policy.Native
+	return errors.Wrap(h.retentions.PutLock(namespace, lock), "failed to record object lock")
+}
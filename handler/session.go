@@ -0,0 +1,57 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/iotexproject/phoenix/auth"
+)
+
+// LoginChallenge issues a nonce for the owner to sign with its IoTeX or Ethereum wallet
+// example: curl -H 'Content-Type: application/json' -d '{"address": "io1..."}' http://localhost:8080/login/challenge
+func (h *StorageHandler) LoginChallenge(w http.ResponseWriter, r *http.Request) {
+	item := &challengeObject{}
+	if err := decodeAndCloseRequest(r, item); err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	challenge, err := h.sessions.Challenge(item.Address)
+	if err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	renderJSON(w, http.StatusOK, challenge)
+}
+
+// Login verifies the signed nonce and returns a short-lived owner session
+// example: curl -H 'Content-Type: application/json' -d '{"nonce": "xxx", "signature": "yyy", "type": "personal_sign"}' http://localhost:8080/login
+func (h *StorageHandler) Login(w http.ResponseWriter, r *http.Request) {
+	item := &loginObject{}
+	if err := decodeAndCloseRequest(r, item); err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	sig, err := hex.DecodeString(strings.TrimPrefix(item.Signature, "0x"))
+	if err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	session, err := h.sessions.Login(item.Nonce, sig, item.Type)
+	switch errors.Cause(err) {
+	case nil:
+		renderJSON(w, http.StatusOK, session)
+	case auth.ErrChallenge:
+		renderJSON(w, http.StatusUnauthorized, H{"message": err.Error()})
+	default:
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/iotexproject/iotex-antenna-go/v2/jwt"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/iotexproject/phoenix/auth"
+)
+
+// ShareObject returns a link to download an object without the token, for browsers and clients that can't send
+// one. The link is signed by phoenix, expires after expiresIn seconds, with the token at the latest, and can be
+// limited to maxDownloads downloads, or a single one with singleUse
+// example: curl -H "Authorization: Bearer jwttoken" -d '{"bucket": "test11", "path": "foo.txt", "expiresIn": 3600, "singleUse": true}' http://localhost:8080/share
+func (h *StorageHandler) ShareObject(w http.ResponseWriter, r *http.Request) {
+	claims, _, statusCode := h.createBackendForRequest(r)
+	if statusCode != http.StatusOK {
+		renderJSON(w, statusCode, http.StatusText(statusCode))
+		return
+	}
+	item := &shareObject{}
+	if err := decodeAndCloseRequest(r, item); err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	if item.Bucket == "" || item.Path == "" {
+		renderJSON(w, http.StatusBadRequest, H{"message": "bucket and path are required"})
+		return
+	}
+	if item.MaxDownloads < 0 {
+		renderJSON(w, http.StatusBadRequest, H{"message": "maxDownloads can't be negative"})
+		return
+	}
+	if item.SingleUse {
+		item.MaxDownloads = 1
+	}
+	rec := auditRecord(r)
+	rec.Op, rec.Bucket, rec.Path = jwt.READ, item.Bucket, item.Path
+	//check scope permission
+	if statusCode, err := h.authorize(r, claims, jwt.READ, item.Bucket, item.Path); err != nil {
+		renderJSON(w, statusCode, H{"message": err.Error()})
+		return
+	}
+	ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+	defer h.refundFailed(ww, claims, jwt.READ)
+	w = ww
+	if !h.checkEmbargo(w, claims, item.Bucket, item.Path) {
+		return
+	}
+	// links are used from any client, at any time, so restricted tokens must download themselves
+	if claims.Restricted() {
+		renderJSON(w, http.StatusForbidden, H{"message": ErrorLinkRestricted.Error()})
+		return
+	}
+	// downloads of links are not counted against the token, so limited tokens must download themselves
+	for _, link := range claims.Chain() {
+		if link.Quota != nil || link.Limited() {
+			renderJSON(w, http.StatusForbidden, H{"message": ErrorShareLimited.Error()})
+			return
+		}
+	}
+	if h.shareKey == nil {
+		renderJSON(w, http.StatusServiceUnavailable, H{"message": "sharing is not available"})
+		return
+	}
+
+	now := time.Now()
+	expiresAt := linkExpiry(claims, shareTTL(h.cfg), item.ExpiresIn, now)
+	if !expiresAt.After(now) {
+		renderJSON(w, http.StatusUnauthorized, H{"message": "token has expired"})
+		return
+	}
+	chain := []string{}
+	for _, link := range claims.Chain() {
+		chain = append(chain, link.Hash())
+	}
+	link, err := auth.NewShareLink(claims.Root().Namespace, claims.Store(), item.Bucket, item.Path, expiresAt.Unix(), item.MaxDownloads, chain)
+	if err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	// policies are evaluated again for the token sharing the link when it is downloaded
+	if !claims.Owner {
+		link.Issuer, link.Subject, link.Scope = claims.Issuer, claims.Subject, claims.Scope
+	}
+	renderJSON(w, http.StatusOK, H{
+		"message":      "successful",
+		"id":           link.ID,
+		"url":          linkURL(r, "/shared/"+item.Bucket+"/"+item.Path, link.Query(h.shareKey)),
+		"expiresAt":    link.Expires,
+		"maxDownloads": link.MaxDownloads,
+	})
+}
+
+// GetSharedObject downloads the object of a share link, the content is responded as is. Downloads are audited and
+// metered as requests of the token sharing the link
+// example: curl 'http://localhost:8080/shared/test11/foo.txt?id=...&owner=...&store=s3&expires=1607772249&chain=...&signature=...'
+func (h *StorageHandler) GetSharedObject(w http.ResponseWriter, r *http.Request) {
+	link, ok := r.Context().Value(auth.ShareCtxKey).(*auth.ShareLink)
+	if !ok {
+		renderJSON(w, http.StatusInternalServerError, H{"message": http.StatusText(http.StatusInternalServerError)})
+		return
+	}
+	ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+	defer h.auditLink(r, ww, 0, link.Owner, link.Chain, &auth.AuditRecord{
+		Subject: strings.Join([]string{link.Store, link.Bucket, link.Path}, "/"),
+		Op:      jwt.READ,
+		Bucket:  link.Bucket,
+		Path:    link.Path,
+	})
+
+	claims, backend, statusCode := h.linkBackend(link.Owner, link.Store, link.Bucket, link.Path)
+	if statusCode != http.StatusOK {
+		renderJSON(ww, statusCode, http.StatusText(statusCode))
+		return
+	}
+	if !h.checkAccessLists(ww, r, claims) {
+		return
+	}
+	// embargoes set after the link is shared hold it as well
+	switch err := h.embargoes.Check(link.Owner, link.Store, link.Bucket, link.Path); errors.Cause(err) {
+	case nil:
+		break
+	case auth.ErrEmbargoed:
+		renderJSON(ww, http.StatusForbidden, H{"message": err.Error()})
+		return
+	default:
+		renderJSON(ww, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	// policies apply to the token sharing the link, as they would to its own requests
+	sharer := link.Claims()
+	if statusCode, err := h.evaluatePolicies(sharer, policyInput(r, sharer, jwt.READ, link.Bucket, link.Path)); err != nil {
+		renderJSON(ww, statusCode, H{"message": err.Error()})
+		return
+	}
+	// the download is redeemed before the object is fetched, so links used up don't cost transfer any more
+	switch err := h.shares.Redeem(link); errors.Cause(err) {
+	case nil:
+		break
+	case auth.ErrShareLinkUsedUp:
+		renderJSON(ww, http.StatusGone, H{"message": err.Error()})
+		return
+	default:
+		renderJSON(ww, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	object, err := backend.GetObject(link.Bucket, link.Path)
+	if err != nil {
+		h.refundLink(link)
+		renderJSON(ww, http.StatusNotFound, H{"message": err.Error()})
+		return
+	}
+	if err := h.quotas.Download(claims, link.Bucket, int64(len(object.Content))); err != nil {
+		h.refundLink(link)
+		renderJSON(ww, quotaStatus(err), H{"message": err.Error()})
+		return
+	}
+	ww.Header().Set("Content-Type", http.DetectContentType(object.Content))
+	ww.WriteHeader(http.StatusOK)
+	ww.Write(object.Content)
+}
+
+// refundLink takes back the download of a link that failed, downloads failing don't use up the link
+func (h *StorageHandler) refundLink(link *auth.ShareLink) {
+	if err := h.shares.Refund(link); err != nil {
+		h.log.Error("failed to refund share link", zap.Error(err))
+	}
+}
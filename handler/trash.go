@@ -0,0 +1,275 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/iotexproject/iotex-antenna-go/v2/jwt"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/iotexproject/phoenix/auth"
+	"github.com/iotexproject/phoenix/storage"
+)
+
+// ListTrash lists the objects and buckets in the recycle bins of the owner, of the store and bucket if given
+// example: curl -H "Authorization: Bearer session" 'http://localhost:8080/trash?store=s3&bucket=test11'
+func (h *StorageHandler) ListTrash(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	items, err := h.trash.Items(claims.Namespace)
+	if err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	store, bucket := r.URL.Query().Get("store"), r.URL.Query().Get("bucket")
+	list := []*auth.TrashItem{}
+	for _, item := range items {
+		if (store == "" || item.Store == store) && (bucket == "" || item.Bucket == bucket) {
+			list = append(list, item)
+		}
+	}
+	renderJSON(w, http.StatusOK, H{"items": list})
+}
+
+// RestoreTrash restores an object or bucket from the recycle bin, an object can't be restored over one uploaded
+// to its path since, nor into a bucket in the recycle bin. Restored objects count towards quotas as uploaded again
+// example: curl -X POST -H "Authorization: Bearer session" http://localhost:8080/trash/<id>/restore
+func (h *StorageHandler) RestoreTrash(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	item, ok := h.trashItem(w, claims.Namespace, chi.URLParam(r, "id"))
+	if !ok {
+		return
+	}
+	backend, statusCode := h.storeBackend(item.Owner, item.Store)
+	if statusCode != http.StatusOK {
+		renderJSON(w, statusCode, http.StatusText(statusCode))
+		return
+	}
+	if item.Path == "" {
+		if err := h.trash.Remove(item.Owner, item.ID); err != nil {
+			renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+			return
+		}
+		h.publish(item.Owner, EventBucketCreated, item.Store, item.Bucket, "", &storageEvent{Store: item.Store, Bucket: item.Bucket})
+		h.changed(item.Owner, &auth.Change{Type: auth.ChangeCreated, Store: item.Store, Bucket: item.Bucket})
+		renderJSON(w, http.StatusOK, H{"message": "successful", "item": item})
+		return
+	}
+
+	switch trashed, err := h.trash.Bucket(item.Owner, item.Store, item.Bucket); {
+	case err != nil:
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	case trashed != nil:
+		renderJSON(w, http.StatusConflict, H{"message": errors.Wrapf(ErrorBucketTrashed, "restore bucket %s first", item.Bucket).Error()})
+		return
+	}
+	if _, err := backend.GetObject(item.Bucket, item.Path); err == nil {
+		renderJSON(w, http.StatusConflict, H{"message": item.Path + " has been uploaded since it was deleted"})
+		return
+	}
+	object, err := backend.GetObject(item.Bucket, item.TrashPath())
+	if err != nil {
+		renderJSON(w, http.StatusNotFound, H{"message": err.Error()})
+		return
+	}
+	quotaClaims := &auth.Claims{
+		JWT:       &jwt.JWT{Subject: strings.Join([]string{item.Store, item.Bucket, item.Path}, "/")},
+		Namespace: item.Owner,
+	}
+	reservation, err := h.quotas.Reserve(quotaClaims, item.Bucket, item.Path, int64(len(object.Content)))
+	if err != nil {
+		renderJSON(w, quotaStatus(err), H{"message": err.Error()})
+		return
+	}
+	if err := backend.PutObject(item.Bucket, item.Path, object.Content); err != nil {
+		h.release(reservation)
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	if err := backend.DeleteObject(item.Bucket, item.TrashPath()); err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	if err := h.trash.Remove(item.Owner, item.ID); err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	if err := h.retain(item.Owner, item.Store, item.Bucket, item.Path); err != nil {
+		h.log.Error("failed to retain object", zap.Error(err))
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	h.publish(item.Owner, EventObjectCreated, item.Store, item.Bucket, item.Path, &storageEvent{
+		Store: item.Store, Bucket: item.Bucket, Path: item.Path, Size: int64(len(object.Content)),
+	})
+	h.changed(item.Owner, &auth.Change{
+		Type: auth.ChangeCreated, Store: item.Store, Bucket: item.Bucket, Path: item.Path, Size: int64(len(object.Content)),
+	})
+	renderJSON(w, http.StatusOK, H{"message": "successful", "item": item})
+}
+
+// PurgeTrash deletes an object or bucket in the recycle bin for good. Tokens that delete data only move it to the
+// recycle bin, purging it takes the owner session, and the approval of co-owners if the store is co-owned
+// example: curl -X DELETE -H "Authorization: Bearer session" http://localhost:8080/trash/<id>
+func (h *StorageHandler) PurgeTrash(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	item, ok := h.trashItem(w, claims.Namespace, chi.URLParam(r, "id"))
+	if !ok {
+		return
+	}
+	if h.proposeAction(w, claims, auth.ActionPurgeTrash, item.Store, item.Bucket, item.TrashPath()) {
+		return
+	}
+	backend, statusCode := h.storeBackend(item.Owner, item.Store)
+	if statusCode != http.StatusOK {
+		renderJSON(w, statusCode, http.StatusText(statusCode))
+		return
+	}
+	if err := h.purge(backend, item); err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	renderJSON(w, http.StatusOK, H{"message": "successful"})
+}
+
+// trashItem returns the item in owner's recycle bin, and false if it is responded that it can't
+func (h *StorageHandler) trashItem(w http.ResponseWriter, namespace, id string) (*auth.TrashItem, bool) {
+	item, err := h.trash.Item(namespace, id)
+	switch errors.Cause(err) {
+	case nil:
+		return item, true
+	case auth.ErrTrashItemNotFound:
+		renderJSON(w, http.StatusNotFound, H{"message": err.Error()})
+	default:
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+	}
+	return nil, false
+}
+
+// recycle moves the object, or keeps the bucket if path is empty, to the recycle bin of the store instead of
+// deleting it, and returns the item in the bin, nil if the store has no recycle bin and it is to be deleted
+func (h *StorageHandler) recycle(namespace, store string, backend storage.Backend, bucket, path, deletedBy string) (*auth.TrashItem, error) {
+	bin, err := h.trash.GetBin(namespace, store)
+	if err != nil || bin == nil {
+		return nil, err
+	}
+	now := time.Now()
+	item := &auth.TrashItem{
+		Store:     store,
+		Bucket:    bucket,
+		Path:      path,
+		DeletedBy: deletedBy,
+		DeletedAt: now.Unix(),
+		ExpiresAt: now.Add(bin.Retention()).Unix(),
+	}
+	if path == "" {
+		// the bucket stays in the backend, so it must be as empty as the backend requires to delete it
+		objects, err := backend.ListObjects(bucket, "")
+		if err != nil {
+			return nil, err
+		}
+		// objects in the recycle bin are purged with the bucket
+		for _, o := range objects {
+			if !auth.IsTrashPath(o.Path) {
+				return nil, errors.Errorf("bucket %s is not empty", bucket)
+			}
+		}
+		return item, h.trash.Add(namespace, item)
+	}
+	object, err := backend.GetObject(bucket, path)
+	if err != nil {
+		return nil, err
+	}
+	item.Size = int64(len(object.Content))
+	if err := h.trash.Add(namespace, item); err != nil {
+		return nil, err
+	}
+	if err := backend.PutObject(bucket, item.TrashPath(), object.Content); err != nil {
+		if err := h.trash.Remove(namespace, item.ID); err != nil {
+			h.log.Error("failed to remove trash item", zap.Error(err))
+		}
+		return nil, err
+	}
+	return item, backend.DeleteObject(bucket, path)
+}
+
+// purge deletes the item in the recycle bin for good, a bucket is deleted with the objects of it in the bin
+func (h *StorageHandler) purge(backend storage.Backend, item *auth.TrashItem) error {
+	// the item is gone if the bucket it was deleted from has been purged
+	switch _, err := h.trash.Item(item.Owner, item.ID); errors.Cause(err) {
+	case nil:
+		break
+	case auth.ErrTrashItemNotFound:
+		return nil
+	default:
+		return err
+	}
+	if item.Path != "" {
+		if err := backend.DeleteObject(item.Bucket, item.TrashPath()); err != nil {
+			return err
+		}
+		return h.trash.Remove(item.Owner, item.ID)
+	}
+	items, err := h.trash.Items(item.Owner)
+	if err != nil {
+		return err
+	}
+	for _, o := range items {
+		if o.Store == item.Store && o.Bucket == item.Bucket && o.Path != "" {
+			if err := backend.DeleteObject(o.Bucket, o.TrashPath()); err != nil {
+				return err
+			}
+		}
+	}
+	if err := backend.DeleteBucket(item.Bucket); err != nil {
+		return err
+	}
+	if err := h.quotas.Remove(item.Owner, item.Store, item.Bucket, ""); err != nil {
+		h.log.Error("failed to record quota usage", zap.Error(err))
+	}
+	if err := h.lifecycles.Forget(item.Owner, item.Store, item.Bucket, ""); err != nil {
+		h.log.Error("failed to remove lifecycle rules", zap.Error(err))
+	}
+	if err := h.retentions.Release(item.Owner, item.Store, item.Bucket, ""); err != nil {
+		h.log.Error("failed to remove retention", zap.Error(err))
+	}
+	return h.trash.Forget(item.Owner, item.Store, item.Bucket)
+}
+
+// trashedItem returns the item in owner's recycle bin an action purges, the bucket if path is empty
+func (h *StorageHandler) trashedItem(namespace, store, bucket, path string) (*auth.TrashItem, error) {
+	if path != "" {
+		return h.trash.Item(namespace, auth.TrashID(path))
+	}
+	item, err := h.trash.Bucket(namespace, store, bucket)
+	if err == nil && item == nil {
+		err = errors.Wrapf(auth.ErrTrashItemNotFound, "bucket %s", bucket)
+	}
+	return item, err
+}
+
+// forgetTrash removes the recycle bin of owner's store and the items in it, once the store is unregistered
+func (h *StorageHandler) forgetTrash(namespace, store string) error {
+	if err := h.trash.DelBin(namespace, store); err != nil {
+		return err
+	}
+	return h.trash.Forget(namespace, store, "")
+}
@@ -0,0 +1,348 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/iotexproject/phoenix/auth"
+	"github.com/iotexproject/phoenix/config"
+	"github.com/iotexproject/phoenix/json"
+)
+
+// events posted to owner's subscriptions
+const (
+	EventObjectCreated     = "object.created"
+	EventObjectDeleted     = "object.deleted"
+	EventBucketCreated     = "bucket.created"
+	EventBucketDeleted     = "bucket.deleted"
+	EventStoreRegistered   = "store.registered"
+	EventStoreUnregistered = "store.unregistered"
+	EventRequestDenied     = "request.denied"
+	EventAccessRequested   = "access.requested"
+	EventAccessApproved    = "access.approved"
+	EventAccessDenied      = "access.denied"
+	EventAccessRedeemed    = "access.redeemed"
+)
+
+var subscriptionEvents = []string{
+	EventObjectCreated,
+	EventObjectDeleted,
+	EventBucketCreated,
+	EventBucketDeleted,
+	EventStoreRegistered,
+	EventStoreUnregistered,
+	EventRequestDenied,
+	EventAccessRequested,
+	EventAccessApproved,
+	EventAccessDenied,
+	EventAccessRedeemed,
+}
+
+// storageEvent is the data of events of stores, buckets and objects
+type storageEvent struct {
+	Store   string `json:"store"`
+	Bucket  string `json:"bucket,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Size    int64  `json:"size,omitempty"`
+	Issuer  string `json:"issuer,omitempty"`
+	Subject string `json:"subject,omitempty"`
+}
+
+type webhookEvent struct {
+	Event string      `json:"event"`
+	Time  int64       `json:"time"`
+	Data  interface{} `json:"data"`
+}
+
+// newWebhookClient returns the client posting events, which refuses to connect to loopback, private and
+// link-local addresses unless they are allowed, so host names can't be resolved to internal services
+func newWebhookClient(cfg *config.Config) *http.Client {
+	timeout := webhookTimeout(cfg)
+	dialer := &net.Dialer{Timeout: timeout}
+	if !cfg.Webhook.AllowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !auth.PublicIP(ip) {
+				return errors.Wrapf(auth.ErrPrivateAddress, "address %s", host)
+			}
+			return nil
+		}
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext},
+	}
+}
+
+func webhookTimeout(cfg *config.Config) time.Duration {
+	if cfg.Webhook.Timeout > 0 {
+		return time.Duration(cfg.Webhook.Timeout) * time.Second
+	}
+	return 10 * time.Second
+}
+
+// notify posts the event to the webhook the operator configured in webhook.url in background, failed deliveries
+// are logged. The URL is set by the operator, so it may be of an internal service
+func (h *StorageHandler) notify(event string, data interface{}) {
+	url := h.cfg.Webhook.URL
+	if url == "" {
+		return
+	}
+	body, err := json.Marshal(&webhookEvent{Event: event, Time: time.Now().Unix(), Data: data})
+	if err != nil {
+		h.log.Error("failed to encode webhook event", zap.String("event", event), zap.Error(err))
+		return
+	}
+	go func() {
+		res, err := h.operatorHook.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			h.log.Error("failed to post webhook event", zap.String("event", event), zap.Error(err))
+			return
+		}
+		io.Copy(ioutil.Discard, res.Body)
+		res.Body.Close()
+		if res.StatusCode >= http.StatusMultipleChoices {
+			h.log.Error("webhook rejected event", zap.String("event", event), zap.Int("status", res.StatusCode))
+		}
+	}()
+}
+
+func deliveryRetention(cfg *config.Config) time.Duration {
+	if cfg.Webhook.Retention > 0 {
+		return time.Duration(cfg.Webhook.Retention) * time.Second
+	}
+	return 7 * 24 * time.Hour
+}
+
+// CreateSubscription subscribes a URL to owner's events, optionally of the store, bucket and objects under the
+// prefix only. The secret signing the events is returned once
+// example: curl -H 'Content-Type: application/json' -H "Authorization: Bearer session" -d '{"url": "https://hooks.example.com/phoenix", "events": ["object.created"], "bucket": "reports"}' http://localhost:8080/webhooks
+func (h *StorageHandler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	item := &subscriptionObject{}
+	if err := decodeAndCloseRequest(r, item); err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	s := &auth.Subscription{
+		URL:    item.URL,
+		Events: item.Events,
+		Store:  item.Store,
+		Bucket: item.Bucket,
+		Prefix: item.Prefix,
+	}
+	if err := s.Normalize(subscriptionEvents, h.cfg.Webhook.AllowPrivate); err != nil {
+		renderJSON(w, http.StatusBadRequest, H{"message": err.Error()})
+		return
+	}
+	if err := h.subscriptions.PutSubscription(claims.Namespace, s); err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	renderJSON(w, http.StatusOK, H{"message": "successful", "subscription": s})
+}
+
+// ListSubscriptions lists owner's subscriptions
+// example: curl -H "Authorization: Bearer session" http://localhost:8080/webhooks
+func (h *StorageHandler) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	list, err := h.subscriptions.ListSubscriptions(claims.Namespace)
+	if err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	renderJSON(w, http.StatusOK, H{"subscriptions": list})
+}
+
+// DeleteSubscription removes owner's subscription, its pending deliveries fail
+// example: curl -H "Authorization: Bearer session" -X DELETE http://localhost:8080/webhooks/3f2a...
+func (h *StorageHandler) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	switch err := h.subscriptions.DelSubscription(claims.Namespace, chi.URLParam(r, "id")); errors.Cause(err) {
+	case nil:
+		break
+	case auth.ErrSubscriptionNotFound:
+		renderJSON(w, http.StatusNotFound, H{"message": err.Error()})
+		return
+	default:
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	renderJSON(w, http.StatusOK, H{"message": "successful"})
+}
+
+// ListDeliveries returns the delivery log of owner's events, latest first, of the subscription and with the status
+// (pending, delivered or failed) if given
+// example: curl -H "Authorization: Bearer session" 'http://localhost:8080/webhooks/deliveries?status=failed'
+func (h *StorageHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.ownerClaims(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	list, err := h.deliveries.Deliveries(claims.Namespace, query.Get("subscription"), query.Get("status"))
+	if err != nil {
+		renderJSON(w, http.StatusInternalServerError, H{"message": err.Error()})
+		return
+	}
+	renderJSON(w, http.StatusOK, H{"deliveries": list})
+}
+
+// publish queues the event of owner's object, or bucket or store, for the subscriptions selecting it
+func (h *StorageHandler) publish(namespace, event, store, bucket, path string, data interface{}) {
+	subscribed, err := h.subscriptions.Subscribed(namespace, event, store, bucket, path)
+	if err != nil {
+		h.log.Error("failed to find subscriptions", zap.String("event", event), zap.Error(err))
+		return
+	}
+	if len(subscribed) == 0 {
+		return
+	}
+	payload, err := json.Marshal(&webhookEvent{Event: event, Time: time.Now().Unix(), Data: data})
+	if err != nil {
+		h.log.Error("failed to encode webhook event", zap.String("event", event), zap.Error(err))
+		return
+	}
+	for _, s := range subscribed {
+		if _, err := h.deliveries.Enqueue(namespace, s, event, payload); err != nil {
+			h.log.Error("failed to queue webhook event", zap.String("event", event), zap.Error(err))
+		}
+	}
+	select {
+	case h.wake <- struct{}{}:
+	default:
+	}
+}
+
+// DeliverWebhooks posts the queued events to subscriptions, a bounded number at once, until the context is done.
+// Failed deliveries are retried with exponential backoff, the queue is kept in db so deliveries survive restart
+func (h *StorageHandler) DeliverWebhooks(ctx context.Context) {
+	concurrency := 8
+	if h.cfg.Webhook.Concurrency > 0 {
+		concurrency = h.cfg.Webhook.Concurrency
+	}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-h.wake:
+		}
+		due, err := h.deliveries.Due(time.Now().Unix())
+		if err != nil {
+			h.log.Error("failed to read delivery queue", zap.Error(err))
+			continue
+		}
+		// deliveries are posted concurrently, each cycle waits for them so none is posted twice at once
+		sem := make(chan struct{}, concurrency)
+		var wg sync.WaitGroup
+		for _, d := range due {
+			if ctx.Err() != nil {
+				break
+			}
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(d *auth.Delivery) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				h.deliver(d)
+				if err := h.deliveries.Done(d); err != nil {
+					h.log.Error("failed to record delivery", zap.String("id", d.ID), zap.Error(err))
+				}
+			}(d)
+		}
+		wg.Wait()
+	}
+}
+
+// deliver attempts to post the event to its subscription once, and schedules the next attempt if it fails
+func (h *StorageHandler) deliver(d *auth.Delivery) {
+	d.Attempts++
+	s, err := h.subscriptions.GetSubscription(d.Owner, d.Subscription)
+	if err != nil {
+		d.Status, d.Error, d.NextAt = auth.DeliveryFailed, err.Error(), 0
+		return
+	}
+	d.Response, d.Error = 0, ""
+	if err = h.post(s, d); err == nil {
+		d.Status, d.DeliveredAt, d.NextAt = auth.DeliveryDelivered, time.Now().Unix(), 0
+		return
+	}
+	d.Error = err.Error()
+	maxAttempts, backoff := 8, 30
+	if h.cfg.Webhook.MaxAttempts > 0 {
+		maxAttempts = h.cfg.Webhook.MaxAttempts
+	}
+	if h.cfg.Webhook.Backoff > 0 {
+		backoff = h.cfg.Webhook.Backoff
+	}
+	if d.Attempts >= maxAttempts {
+		d.Status, d.NextAt = auth.DeliveryFailed, 0
+		h.log.Error("webhook delivery failed", zap.String("id", d.ID), zap.String("url", d.URL), zap.Error(err))
+		return
+	}
+	delay := time.Duration(backoff) * time.Second << uint(d.Attempts-1)
+	if delay > time.Hour {
+		delay = time.Hour
+	}
+	d.NextAt = time.Now().Add(delay).Unix()
+}
+
+// post posts the payload of the delivery to the subscription, signed in header X-Phoenix-Signature as
+// "t=<unix time>,v1=<signature>"
+func (h *StorageHandler) post(s *auth.Subscription, d *auth.Delivery) error {
+	req, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader([]byte(d.Payload)))
+	if err != nil {
+		return err
+	}
+	t := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Phoenix-Event", d.Event)
+	req.Header.Set("X-Phoenix-Delivery", d.ID)
+	req.Header.Set("X-Phoenix-Signature", fmt.Sprintf("t=%d,v1=%s", t, s.Sign(t, []byte(d.Payload))))
+	res, err := h.webhook.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+	d.Response = res.StatusCode
+	if res.StatusCode >= http.StatusMultipleChoices {
+		return errors.Errorf("subscriber responded %s", res.Status)
+	}
+	return nil
+}
@@ -55,7 +55,7 @@ type Stopper interface {
 }
 
 func handleShutdown(service ...Stopper) {
-	stop := make(chan os.Signal)
+	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, os.Kill)
 
 	// wait INT or KILL
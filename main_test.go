@@ -1,14 +1,19 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -17,9 +22,12 @@ import (
 	"github.com/johannesboyne/gofakes3"
 	"github.com/johannesboyne/gofakes3/backend/s3mem"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
 
+	"github.com/iotexproject/phoenix/auth"
 	"github.com/iotexproject/phoenix/config"
 	"github.com/iotexproject/phoenix/handler"
+	"github.com/iotexproject/phoenix/json"
 	"github.com/iotexproject/phoenix/log"
 	"github.com/iotexproject/phoenix/server"
 )
@@ -55,6 +63,28 @@ func Test_ServerWithS3Storage(t *testing.T) {
 	cfg, err := config.New("config.yaml")
 	r.NoError(err)
 	cfg.Server.DBPath = path
+	events := make(chan string, 16)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event := struct{ Event string }{}
+		json.NewDecoder(r.Body).Decode(&event)
+		events <- event.Event
+	}))
+	defer hook.Close()
+	operatorEvents := make(chan string, 16)
+	operatorHook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event := struct{ Event string }{}
+		json.NewDecoder(r.Body).Decode(&event)
+		operatorEvents <- event.Event
+	}))
+	defer operatorHook.Close()
+	cfg.Webhook.URL = operatorHook.URL
+	cfg.Webhook.Backoff = 1
+	cfg.Webhook.AllowPrivate = true
+	cfg.Lifecycle.Interval = 1
+	cfg.Server.TrustedProxies = []string{"127.0.0.1"}
+	operator, err := crypto.GenerateKey()
+	r.NoError(err)
+	cfg.Admin.Operators = []string{operator.PublicKey().Address().String()}
 	r.NoError(log.InitLoggers(cfg.Log, cfg.SubLogs))
 
 	ts := server.New(cfg)
@@ -192,6 +222,1537 @@ func Test_ServerWithS3Storage(t *testing.T) {
 		r.NoError(err)
 		r.Equal(res.StatusCode, http.StatusNoContent)
 	})
+	t.Run("with delegation", func(t *testing.T) {
+		owner, _ := crypto.HexStringToPrivateKey("bc145bb9f00d55a3571e22660ef5fd1bfa596e272b80add2919735b82c273004")
+		trustee, err := crypto.GenerateKey()
+		r.NoError(err)
+		issue := time.Now().Unix()
+		expire := time.Now().Add(time.Hour).Unix()
+		ownerToken, err := jwt.SignJWT(issue, expire, "s3", jwt.CREATE+","+jwt.UPDATE+","+jwt.DELETE, owner)
+		r.NoError(err)
+
+		registerData = bytes.NewReader([]byte(`{ "name": "s3", "region":"www", "endpoint":"` + s3Server.URL + `", "key":"yyy", "token":"zzz"}`))
+		res, body, err := testRequest("POST", Addr+"/register", "", ownerToken, registerData)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/pods", "", ownerToken, bytes.NewReader([]byte(`{ "name": "reports"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/pea/reports/q1.csv", "", ownerToken, bytes.NewReader([]byte(`q1`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+
+		// owner grants trustee read on bucket reports, trustee delegates q1.csv to a subcontractor
+		trusteeToken, err := auth.SignToken(issue, expire, "s3/reports", jwt.READ, auth.Extension{
+			Holder: trustee.PublicKey().HexString(),
+		}, owner)
+		r.NoError(err)
+		subToken, err := auth.SignToken(issue, expire, "s3/reports/q1.csv", jwt.READ, auth.Extension{
+			ParentToken: trusteeToken,
+		}, trustee)
+		r.NoError(err)
+		res, body, err = testRequest("GET", Addr+"/pea/reports/q1.csv", "", subToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		r.Contains(body, "q1")
+		res, body, err = testRequest("GET", Addr+"/pea/reports", "", subToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+		r.Contains(body, "is not within subject")
+
+		// introspection explains the denied request
+		res, body, err = testRequest("POST", Addr+"/token/introspect", "", "",
+			bytes.NewReader([]byte(`{"token": "`+subToken+`", "method": "GET", "path": "/pea/reports"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		introspection := struct {
+			Active    bool
+			Namespace string
+			Store     struct{ Registered bool }
+			Explain   struct {
+				Allowed bool
+				Rule    string
+			}
+		}{}
+		r.NoError(json.Unmarshal([]byte(body), &introspection))
+		r.True(introspection.Active)
+		r.Equal(owner.PublicKey().Address().Hex()[2:], introspection.Namespace)
+		r.True(introspection.Store.Registered)
+		r.False(introspection.Explain.Allowed)
+		r.Equal("subject", introspection.Explain.Rule)
+
+		// trustee's token is bound to its key, requests must be signed by trustee
+		res, body, err = testRequest("GET", Addr+"/pea/reports/q1.csv", "", trusteeToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusUnauthorized, res.StatusCode, body)
+		r.Contains(body, auth.ErrRequestSignature.Error())
+		req, err := http.NewRequest("GET", Addr+"/pea/reports/q1.csv", nil)
+		r.NoError(err)
+		req.Header.Set("Authorization", "Bearer "+trusteeToken)
+		r.NoError(auth.SignRequest(req, nil, trustee))
+		res, err = http.DefaultClient.Do(req)
+		r.NoError(err)
+		res.Body.Close()
+		r.Equal(http.StatusOK, res.StatusCode)
+		res, err = http.DefaultClient.Do(req)
+		r.NoError(err)
+		res.Body.Close()
+		r.Equal(http.StatusUnauthorized, res.StatusCode)
+
+		// partner's token is bound to its egress network, the client IP is the one forwarded by proxy
+		partnerToken, err := auth.SignToken(issue, expire, "s3/reports", jwt.READ, auth.Extension{
+			CIDRs: []string{"10.0.0.0/8"},
+		}, owner)
+		r.NoError(err)
+		res, body, err = testRequest("GET", Addr+"/pea/reports/q1.csv", "", partnerToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+		r.Contains(body, auth.ErrClientIP.Error())
+		req, err = http.NewRequest("GET", Addr+"/pea/reports/q1.csv", nil)
+		r.NoError(err)
+		req.Header.Set("Authorization", "Bearer "+partnerToken)
+		req.Header.Set("X-Forwarded-For", "10.1.2.3")
+		res, err = http.DefaultClient.Do(req)
+		r.NoError(err)
+		res.Body.Close()
+		r.Equal(http.StatusOK, res.StatusCode)
+		// clients other than trusted proxies can't pass for another IP
+		spoofing := &http.Client{Transport: &http.Transport{
+			DialContext: (&net.Dialer{LocalAddr: &net.TCPAddr{IP: net.ParseIP("127.0.0.2")}}).DialContext,
+		}}
+		for _, header := range []string{"X-Forwarded-For", "X-Real-IP"} {
+			req, err = http.NewRequest("GET", Addr+"/pea/reports/q1.csv", nil)
+			r.NoError(err)
+			req.Header.Set("Authorization", "Bearer "+partnerToken)
+			req.Header.Set(header, "10.1.2.3")
+			res, err = spoofing.Do(req)
+			r.NoError(err)
+			res.Body.Close()
+			r.Equal(http.StatusForbidden, res.StatusCode, header)
+		}
+		// links would be used from outside of it
+		for _, route := range []string{"/presign", "/share"} {
+			req, err = http.NewRequest("POST", Addr+route, strings.NewReader(`{"bucket": "reports", "path": "q1.csv"}`))
+			r.NoError(err)
+			req.Header.Set("Authorization", "Bearer "+partnerToken)
+			req.Header.Set("X-Forwarded-For", "10.1.2.3")
+			res, err = http.DefaultClient.Do(req)
+			r.NoError(err)
+			content, err := ioutil.ReadAll(res.Body)
+			r.NoError(err)
+			res.Body.Close()
+			r.Equal(http.StatusForbidden, res.StatusCode, route)
+			r.Contains(string(content), handler.ErrorLinkRestricted.Error())
+		}
+		laterToken, err := auth.SignToken(issue, expire, "s3/reports", jwt.READ, auth.Extension{
+			NotBefore: time.Now().Add(time.Hour).Unix(),
+		}, owner)
+		r.NoError(err)
+		res, body, err = testRequest("GET", Addr+"/pea/reports/q1.csv", "", laterToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusUnauthorized, res.StatusCode, body)
+		r.Contains(body, auth.ErrNotYetValid.Error())
+
+		// tokens the owner issued can't revoke the tokens of other trustees, the owner session can
+		res, body, err = testRequest("POST", Addr+"/revoke", "", ownerToken, bytes.NewReader([]byte(`{"token": "`+trusteeToken+`"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+
+		// owner revokes trustee's token, which cuts off the subcontractor
+		session, err := walletLogin(Addr, owner)
+		r.NoError(err)
+		res, body, err = testRequest("POST", Addr+"/revoke", "", session, bytes.NewReader([]byte(`{"token": "`+trusteeToken+`"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("GET", Addr+"/pea/reports/q1.csv", "", subToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusUnauthorized, res.StatusCode, body)
+		r.Contains(body, auth.ErrTokenRevoked.Error())
+		// the store of revoked tokens is no longer disclosed
+		res, body, err = testRequest("POST", Addr+"/token/introspect", "", "", bytes.NewReader([]byte(`{"token": "`+subToken+`"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		r.Contains(body, `"active":false`)
+		r.Contains(body, `"registered":true`)
+		r.NotContains(body, s3Server.URL)
+
+		res, body, err = testRequest("DELETE", Addr+"/register/s3", "", ownerToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+	})
+	t.Run("with wallet login", func(t *testing.T) {
+		owner, _ := crypto.HexStringToPrivateKey("bc145bb9f00d55a3571e22660ef5fd1bfa596e272b80add2919735b82c273004")
+		res, body, err := testRequest("POST", Addr+"/login/challenge", "", "",
+			bytes.NewReader([]byte(`{"address": "`+owner.PublicKey().Address().String()+`"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		challenge := &auth.Challenge{}
+		r.NoError(json.Unmarshal([]byte(body), challenge))
+
+		sig, err := owner.Sign(auth.PersonalMessageHash(challenge.Message))
+		r.NoError(err)
+		res, body, err = testRequest("POST", Addr+"/login", "", "",
+			bytes.NewReader([]byte(`{"nonce": "`+challenge.Nonce+`", "signature": "`+hex.EncodeToString(sig)+`"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		session := &auth.Session{}
+		r.NoError(json.Unmarshal([]byte(body), session))
+
+		// owner session is accepted by owner-only endpoints
+		registerData = bytes.NewReader([]byte(`{ "name": "s3", "region":"www", "endpoint":"` + s3Server.URL + `", "key":"yyy", "token":"zzz"}`))
+		res, body, err = testRequest("POST", Addr+"/register", "", session.ID, registerData)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("GET", Addr+"/pea/reports", "", session.ID, nil)
+		r.NoError(err)
+		r.Equal(http.StatusUnauthorized, res.StatusCode, body)
+
+		// owner's policy restricts trustees
+		res, body, err = testRequest("POST", Addr+"/policies", "", session.ID,
+			bytes.NewReader([]byte(`{"store": "s3", "bucket": "reports", "expression": "request.path.startsWith(\"public/\")"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/policies", "", session.ID,
+			bytes.NewReader([]byte(`{"store": "s3", "expression": "request.path +"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusBadRequest, res.StatusCode, body)
+		readToken, err := jwt.SignJWT(time.Now().Unix(), time.Now().Add(time.Hour).Unix(), "s3", jwt.READ, owner)
+		r.NoError(err)
+		res, body, err = testRequest("GET", Addr+"/pea/reports/q1.csv", "", readToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+		r.Contains(body, auth.ErrPolicyDenied.Error())
+		res, body, err = testRequest("DELETE", Addr+"/policies/s3/reports", "", session.ID, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("GET", Addr+"/pea/reports/q1.csv", "", readToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+
+		// a chunked upload of unknown length is held to the size of the policy once read
+		res, body, err = testRequest("POST", Addr+"/policies", "", session.ID,
+			bytes.NewReader([]byte(`{"store": "s3", "bucket": "reports", "expression": "request.op != \"Update\" || object.size <= 4"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		updateToken, err := jwt.SignJWT(time.Now().Unix(), time.Now().Add(time.Hour).Unix(), "s3", jwt.UPDATE, owner)
+		r.NoError(err)
+		res, body, err = testRequest("POST", Addr+"/pea/reports/q2.csv", "", updateToken, io.MultiReader(strings.NewReader("1,2,3,4,5")))
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+		r.Contains(body, auth.ErrPolicyDenied.Error())
+		res, body, err = testRequest("DELETE", Addr+"/policies/s3/reports", "", session.ID, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+
+		// owner has phoenix mint a token for a grant, and revokes it
+		res, body, err = testRequest("POST", Addr+"/grants", "", session.ID,
+			bytes.NewReader([]byte(`{"trustee": "alice", "subject": "s3/reports", "scope": "Read", "expiresAt": `+
+				fmt.Sprint(time.Now().Add(time.Hour).Unix())+`, "mint": true}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		minted := struct {
+			Token string
+			Grant auth.Grant
+		}{}
+		r.NoError(json.Unmarshal([]byte(body), &minted))
+		res, body, err = testRequest("GET", Addr+"/pea/reports/q1.csv", "", minted.Token, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("GET", Addr+"/grants?store=s3", "", session.ID, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		r.Contains(body, minted.Grant.ID)
+		// the key phoenix mints with can't be replaced or removed by the owner
+		res, body, err = testRequest("POST", Addr+"/keys", "", session.ID, bytes.NewReader([]byte(
+			`{"kid": "`+auth.MintKeyID+`", "type": "secp256k1", "publicKey": "`+owner.PublicKey().HexString()+`"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusBadRequest, res.StatusCode, body)
+		r.Contains(body, handler.ErrorKeyReserved.Error())
+		res, body, err = testRequest("DELETE", Addr+"/keys/"+auth.MintKeyID, "", session.ID, nil)
+		r.NoError(err)
+		r.Equal(http.StatusBadRequest, res.StatusCode, body)
+		res, body, err = testRequest("GET", Addr+"/pea/reports/q1.csv", "", minted.Token, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("DELETE", Addr+"/grants/"+minted.Grant.ID, "", session.ID, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("GET", Addr+"/pea/reports/q1.csv", "", minted.Token, nil)
+		r.NoError(err)
+		r.Equal(http.StatusUnauthorized, res.StatusCode, body)
+
+		// trustee requests access, owner approves it and trustee redeems the token
+		res, body, err = testRequest("POST", Addr+"/webhooks", "", session.ID, bytes.NewReader([]byte(`{"url": "`+hook.URL+
+			`", "events": ["access.requested", "access.approved", "access.redeemed"], "store": "s3"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/access-requests", "", "",
+			bytes.NewReader([]byte(`{"owner": "`+owner.PublicKey().Address().String()+
+				`", "trustee": "bob", "subject": "s3/reports", "scope": "Read", "duration": 3600, "justification": "Q1 audit"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		r.Equal(handler.EventAccessRequested, <-events)
+		r.Equal(handler.EventAccessRequested, <-operatorEvents)
+		filed := struct {
+			Request auth.AccessRequest
+			Secret  string
+		}{}
+		r.NoError(json.Unmarshal([]byte(body), &filed))
+		redeem := `{"owner": "` + owner.PublicKey().Address().String() + `", "secret": "` + filed.Secret + `"}`
+		res, body, err = testRequest("POST", Addr+"/access-requests/"+filed.Request.ID+"/redeem", "", "", bytes.NewReader([]byte(redeem)))
+		r.NoError(err)
+		r.Equal(http.StatusConflict, res.StatusCode, body)
+
+		// embargoed object is held from trustees until owner releases it
+		res, body, err = testRequest("POST", Addr+"/embargoes", "", session.ID,
+			bytes.NewReader([]byte(`{"store": "s3", "bucket": "reports", "path": "q1.csv"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("GET", Addr+"/pea/reports/q1.csv", "", readToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+		r.Contains(body, auth.ErrEmbargoed.Error())
+		res, body, err = testRequest("GET", Addr+"/pea/reports", "", readToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		r.NotContains(body, "q1.csv")
+		res, body, err = testRequest("GET", Addr+"/embargoes", "", session.ID, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		pending := struct{ Embargoes []struct{ Release string } }{}
+		r.NoError(json.Unmarshal([]byte(body), &pending))
+		r.Len(pending.Embargoes, 1)
+		sig, err = owner.Sign(auth.PersonalMessageHash(pending.Embargoes[0].Release))
+		r.NoError(err)
+		res, body, err = testRequest("POST", Addr+"/embargoes/release", "", "", bytes.NewReader([]byte(`{"owner": "`+
+			owner.PublicKey().Address().String()+`", "store": "s3", "bucket": "reports", "path": "q1.csv", "signature": "`+hex.EncodeToString(sig)+`"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("GET", Addr+"/pea/reports/q1.csv", "", readToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("GET", Addr+"/access-requests?state=pending", "", session.ID, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		r.Contains(body, filed.Request.ID)
+		res, body, err = testRequest("POST", Addr+"/access-requests/"+filed.Request.ID+"/approve", "", session.ID, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		r.Equal(handler.EventAccessApproved, <-events)
+		r.Equal(handler.EventAccessApproved, <-operatorEvents)
+		res, body, err = testRequest("POST", Addr+"/access-requests/"+filed.Request.ID+"/redeem", "", "",
+			bytes.NewReader([]byte(`{"owner": "`+owner.PublicKey().Address().String()+`", "secret": "wrong"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/access-requests/"+filed.Request.ID+"/redeem", "", "", bytes.NewReader([]byte(redeem)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		r.Equal(handler.EventAccessRedeemed, <-events)
+		r.Equal(handler.EventAccessRedeemed, <-operatorEvents)
+		r.NoError(json.Unmarshal([]byte(body), &minted))
+		res, body, err = testRequest("GET", Addr+"/pea/reports/q1.csv", "", minted.Token, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/access-requests/"+filed.Request.ID+"/redeem", "", "", bytes.NewReader([]byte(redeem)))
+		r.NoError(err)
+		r.Equal(http.StatusConflict, res.StatusCode, body)
+
+		// embargoed object is held from trustees until owner releases it
+		res, body, err = testRequest("POST", Addr+"/embargoes", "", session.ID,
+			bytes.NewReader([]byte(`{"store": "s3", "bucket": "reports", "path": "q1.csv"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("GET", Addr+"/pea/reports/q1.csv", "", readToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+		r.Contains(body, auth.ErrEmbargoed.Error())
+		res, body, err = testRequest("GET", Addr+"/pea/reports", "", readToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		r.NotContains(body, "q1.csv")
+		res, body, err = testRequest("GET", Addr+"/embargoes", "", session.ID, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		held := struct{ Embargoes []struct{ Release string } }{}
+		r.NoError(json.Unmarshal([]byte(body), &held))
+		r.Len(held.Embargoes, 1)
+		sig, err = owner.Sign(auth.PersonalMessageHash(held.Embargoes[0].Release))
+		r.NoError(err)
+		res, body, err = testRequest("POST", Addr+"/embargoes/release", "", "", bytes.NewReader([]byte(`{"owner": "`+
+			owner.PublicKey().Address().String()+`", "store": "s3", "bucket": "reports", "path": "q1.csv", "signature": "`+hex.EncodeToString(sig)+`"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("GET", Addr+"/pea/reports/q1.csv", "", readToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+
+		res, body, err = testRequest("DELETE", Addr+"/register/s3", "", session.ID, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+	})
+	t.Run("with co-owned store", func(t *testing.T) {
+		owner, _ := crypto.HexStringToPrivateKey("bc145bb9f00d55a3571e22660ef5fd1bfa596e272b80add2919735b82c273004")
+		coOwner, err := crypto.GenerateKey()
+		r.NoError(err)
+		stranger, err := crypto.GenerateKey()
+		r.NoError(err)
+		issue, expire := time.Now().Unix(), time.Now().Add(time.Hour).Unix()
+		createToken, err := jwt.SignJWT(issue, expire, "s3", jwt.CREATE, owner)
+		r.NoError(err)
+		updateToken, err := jwt.SignJWT(issue, expire, "s3", jwt.UPDATE, owner)
+		r.NoError(err)
+		readToken, err := jwt.SignJWT(issue, expire, "s3", jwt.READ, owner)
+		r.NoError(err)
+		delToken, err := jwt.SignJWT(issue, expire, "s3", jwt.DELETE, owner)
+		r.NoError(err)
+
+		res, body, err := testRequest("POST", Addr+"/register", "", createToken, bytes.NewReader([]byte(`{"name": "s3", "region": "www", "key": "yyy", "token": "zzz", "endpoint": "`+
+			s3Server.URL+`", "owners": ["`+owner.PublicKey().Address().String()+`", "`+coOwner.PublicKey().Address().String()+`"], "threshold": 2}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/register", "", createToken,
+			bytes.NewReader([]byte(`{"name": "s3", "region": "www", "key": "yyy", "token": "zzz", "endpoint": "`+s3Server.URL+`"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusConflict, res.StatusCode, body)
+		// not even with the same owners, which would move the store to another endpoint without their approval
+		res, body, err = testRequest("POST", Addr+"/register", "", createToken, bytes.NewReader([]byte(`{"name": "s3", "region": "www", "key": "yyy", "token": "zzz", "endpoint": "http://127.0.0.1:1", `+
+			`"owners": ["`+owner.PublicKey().Address().String()+`", "`+coOwner.PublicKey().Address().String()+`"], "threshold": 2}`)))
+		r.NoError(err)
+		r.Equal(http.StatusConflict, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/pods", "", createToken, bytes.NewReader([]byte(`{"name": "shared"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/pea/shared/data.csv", "", updateToken, bytes.NewReader([]byte(`a,b`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+
+		// TTLs and lifecycle rules would delete without co-owners' approval
+		res, body, err = testRequest("POST", Addr+"/pea/shared/temp.csv?ttl=60", "", updateToken, bytes.NewReader([]byte(`a,b`)))
+		r.NoError(err)
+		r.Equal(http.StatusConflict, res.StatusCode, body)
+		r.Contains(body, handler.ErrorCoOwnedExpiry.Error())
+		session, err := walletLogin(Addr, owner)
+		r.NoError(err)
+		res, body, err = testRequest("POST", Addr+"/lifecycle", "", session, bytes.NewReader([]byte(
+			`{"store": "s3", "bucket": "shared", "action": "delete", "days": 30}`)))
+		r.NoError(err)
+		r.Equal(http.StatusConflict, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/lifecycle", "", session, bytes.NewReader([]byte(
+			`{"store": "s3", "bucket": "shared", "action": "archive", "days": 30}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+
+		// deletion is pending until both owners approve
+		approve := func(id string, key crypto.PrivateKey) (*http.Response, string) {
+			res, body, err := testRequest("GET", Addr+"/actions/"+id, "", "", nil)
+			r.NoError(err)
+			r.Equal(http.StatusOK, res.StatusCode, body)
+			pending := struct{ Message string }{}
+			r.NoError(json.Unmarshal([]byte(body), &pending))
+			sig, err := key.Sign(auth.PersonalMessageHash(pending.Message))
+			r.NoError(err)
+			res, body, err = testRequest("POST", Addr+"/actions/"+id+"/approve", "", "", bytes.NewReader([]byte(
+				`{"owner": "`+key.PublicKey().Address().String()+`", "signature": "`+hex.EncodeToString(sig)+`"}`)))
+			r.NoError(err)
+			return res, body
+		}
+		action := struct{ Action auth.Action }{}
+		res, body, err = testRequest("DELETE", Addr+"/pea/shared/data.csv", "", delToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusAccepted, res.StatusCode, body)
+		r.NoError(json.Unmarshal([]byte(body), &action))
+		res, body = approve(action.Action.ID, stranger)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+		res, body = approve(action.Action.ID, coOwner)
+		r.Equal(http.StatusAccepted, res.StatusCode, body)
+		res, body, err = testRequest("GET", Addr+"/pea/shared", "", readToken, nil)
+		r.NoError(err)
+		r.Contains(body, "data.csv")
+		res, body = approve(action.Action.ID, owner)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		r.Contains(body, auth.ActionExecuted)
+		res, body, err = testRequest("GET", Addr+"/pea/shared", "", readToken, nil)
+		r.NoError(err)
+		r.NotContains(body, "data.csv")
+		res, body = approve(action.Action.ID, owner)
+		r.Equal(http.StatusConflict, res.StatusCode, body)
+
+		// so is unregistering the store
+		res, body, err = testRequest("DELETE", Addr+"/register/s3", "", delToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusAccepted, res.StatusCode, body)
+		r.NoError(json.Unmarshal([]byte(body), &action))
+		res, body = approve(action.Action.ID, owner)
+		r.Equal(http.StatusAccepted, res.StatusCode, body)
+		res, body = approve(action.Action.ID, coOwner)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/pods", "", createToken, bytes.NewReader([]byte(`{"name": "shared"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusNoContent, res.StatusCode, body)
+	})
+	t.Run("with access lists", func(t *testing.T) {
+		owner, _ := crypto.HexStringToPrivateKey("bc145bb9f00d55a3571e22660ef5fd1bfa596e272b80add2919735b82c273004")
+		ownerToken, err := jwt.SignJWT(time.Now().Unix(), time.Now().Add(time.Hour).Unix(), "s3", jwt.CREATE+","+jwt.READ+","+jwt.DELETE, owner)
+		r.NoError(err)
+		registerData = bytes.NewReader([]byte(`{ "name": "s3", "region":"www", "endpoint":"` + s3Server.URL + `", "key":"yyy", "token":"zzz"}`))
+		res, body, err := testRequest("POST", Addr+"/register", "", ownerToken, registerData)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+
+		// only operators manage access lists
+		session, err := walletLogin(Addr, owner)
+		r.NoError(err)
+		res, body, err = testRequest("GET", Addr+"/admin/access-lists", "", session, nil)
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+		res, body, err = testRequest("GET", Addr+"/admin/access-lists", "", ownerToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+		admin, err := walletLogin(Addr, operator)
+		r.NoError(err)
+		links := []string{}
+		for _, route := range []string{"/presign", "/share"} {
+			res, body, err = testRequest("POST", Addr+route, "", ownerToken, bytes.NewReader([]byte(`{"bucket": "reports", "path": "q1.csv", "proxy": true}`)))
+			r.NoError(err)
+			r.Equal(http.StatusOK, res.StatusCode, body)
+			link := struct{ URL string }{}
+			r.NoError(json.Unmarshal([]byte(body), &link))
+			links = append(links, link.URL)
+		}
+
+		// operator blocks the owner, which applies without restart, to the links shared before as well
+		res, body, err = testRequest("POST", Addr+"/admin/access-lists", "", admin, bytes.NewReader([]byte(
+			`{"list": "deny", "kind": "issuer", "value": "`+owner.PublicKey().Address().String()+`", "notes": "abuse"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("GET", Addr+"/pea/reports", "", ownerToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+		r.Contains(body, auth.ErrAccessListed.Error())
+		for _, link := range links {
+			res, body, err = testRequest("GET", link, "", "", nil)
+			r.NoError(err)
+			r.Equal(http.StatusForbidden, res.StatusCode, body)
+			r.Contains(body, auth.ErrAccessListed.Error())
+		}
+		res, body, err = testRequest("POST", Addr+"/access-requests", "", "",
+			bytes.NewReader([]byte(`{"owner": "`+owner.PublicKey().Address().String()+
+				`", "trustee": "bob", "subject": "s3/reports", "scope": "Read", "duration": 3600, "justification": "Q2 audit"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+		r.Contains(body, auth.ErrAccessListed.Error())
+		res, body, err = testRequest("DELETE", Addr+"/register/s3", "", session, nil)
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+		res, body, err = testRequest("DELETE", Addr+"/admin/access-lists?list=deny&kind=issuer&value="+owner.PublicKey().Address().String(), "", admin, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+
+		// only clients of the consortium network are allowed
+		res, body, err = testRequest("POST", Addr+"/admin/access-lists", "", admin,
+			bytes.NewReader([]byte(`{"list": "allow", "kind": "cidr", "value": "10.0.0.0/8"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("GET", Addr+"/admin/access-lists", "", admin, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		r.Contains(body, "10.0.0.0/8")
+		res, body, err = testRequest("DELETE", Addr+"/register/s3", "", ownerToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+		r.Contains(body, "is not allowed")
+		res, body, err = testRequest("DELETE", Addr+"/admin/access-lists?list=allow&kind=cidr&value=10.0.0.0/8", "", admin, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+
+		res, body, err = testRequest("DELETE", Addr+"/register/s3", "", ownerToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+	})
+	t.Run("with quotas", func(t *testing.T) {
+		owner, err := crypto.GenerateKey()
+		r.NoError(err)
+		ownerToken, err := jwt.SignJWT(time.Now().Unix(), time.Now().Add(time.Hour).Unix(), "s3", jwt.CREATE+","+jwt.READ+","+jwt.UPDATE+","+jwt.DELETE, owner)
+		r.NoError(err)
+		res, body, err := testRequest("POST", Addr+"/register", "", ownerToken, bytes.NewReader([]byte(`{"name": "s3", "region": "www", "endpoint": "`+
+			s3Server.URL+`", "key": "yyy", "token": "zzz", "quota": {"maxTransfer": 10}, "bucketQuota": {"maxObjects": 1}}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/pods", "", ownerToken, bytes.NewReader([]byte(`{"name": "limited"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+
+		// each bucket holds one object, and the store transfers 10 bytes a day
+		res, body, err = testRequest("POST", Addr+"/pea/limited/a.csv", "", ownerToken, bytes.NewReader([]byte(`abcdef`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/pea/limited/b.csv", "", ownerToken, bytes.NewReader([]byte(`b`)))
+		r.NoError(err)
+		r.Equal(http.StatusRequestEntityTooLarge, res.StatusCode, body)
+		r.Contains(body, "bucket s3/limited would store 2 of 1 objects")
+		res, body, err = testRequest("GET", Addr+"/pea/limited/a.csv", "", ownerToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusTooManyRequests, res.StatusCode, body)
+		r.Contains(body, auth.ErrQuotaTransfer.Error())
+		// the body of an upload is cut at the bytes left, even if its length is not told
+		res, body, err = testRequest("POST", Addr+"/pea/limited/a.csv", "", ownerToken, io.MultiReader(strings.NewReader("abcdefgh")))
+		r.NoError(err)
+		r.Equal(http.StatusTooManyRequests, res.StatusCode, body)
+		r.Contains(body, "would transfer 11 of 10 bytes")
+
+		// the owner finds out who accessed the bucket in the audit log
+		session, err := walletLogin(Addr, owner)
+		r.NoError(err)
+		res, body, err = testRequest("GET", Addr+"/audit?bucket=limited", "", ownerToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+		res, body, err = testRequest("GET", Addr+"/audit?bucket=limited&verify=true", "", session, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		audit := struct{ Records []*auth.AuditRecord }{}
+		r.NoError(json.Unmarshal([]byte(body), &audit))
+		r.Len(audit.Records, 5)
+		r.Equal(jwt.CREATE, audit.Records[0].Op)
+		r.Equal(jwt.UPDATE, audit.Records[1].Op)
+		r.Equal("a.csv", audit.Records[1].Path)
+		r.Equal(int64(6), audit.Records[1].Bytes)
+		r.Equal(auth.AuditSucceeded, audit.Records[1].Result)
+		r.Equal(http.StatusRequestEntityTooLarge, audit.Records[2].Status)
+		r.Equal("127.0.0.1", audit.Records[3].IP)
+		r.Equal(audit.Records[2].Hash, audit.Records[3].Prev)
+		res, body, err = testRequest("GET", Addr+"/audit?path=a.csv&format=csv", "", session, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		r.Equal("text/csv", res.Header.Get("Content-Type"))
+		lines := strings.Split(strings.TrimSpace(body), "\n")
+		r.Len(lines, 4)
+		r.True(strings.HasPrefix(lines[0], "seq,time,issuer,token"))
+
+		// requests are metered for the owner and for the token
+		res, body, err = testRequest("GET", Addr+"/metering?granularity=hour", "", session, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		metering := struct{ Meters []*auth.Meter }{}
+		r.NoError(json.Unmarshal([]byte(body), &metering))
+		r.Len(metering.Meters, 2)
+		r.Equal("", metering.Meters[0].Token)
+		r.Equal(audit.Records[0].Token, metering.Meters[1].Token)
+		r.Equal(int64(5), metering.Meters[0].Requests)
+		r.Equal(int64(len(`{"name": "limited"}`)+7+5), metering.Meters[0].BytesIn)
+		r.Equal(int64(3), metering.Meters[0].Ops[jwt.UPDATE])
+		res, body, err = testRequest("GET", Addr+"/metering?granularity=week", "", session, nil)
+		r.NoError(err)
+		r.Equal(http.StatusBadRequest, res.StatusCode, body)
+		res, body, err = testRequest("GET", Addr+"/admin/metering?owner="+owner.PublicKey().Address().String(), "", session, nil)
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+		admin, err := walletLogin(Addr, operator)
+		r.NoError(err)
+		res, body, err = testRequest("GET", Addr+"/admin/metering?format=csv&owner="+owner.PublicKey().Address().String(), "", admin, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		lines = strings.Split(strings.TrimSpace(body), "\n")
+		r.Len(lines, 3)
+		r.Equal("granularity,start,owner,token,requests,bytesIn,bytesOut,create,read,update,delete", lines[0])
+
+		res, body, err = testRequest("POST", Addr+"/register", "", ownerToken, bytes.NewReader([]byte(`{"name": "s3", "region": "www", "endpoint": "`+
+			s3Server.URL+`", "key": "yyy", "token": "zzz", "quota": {"maxBytes": -1}}`)))
+		r.NoError(err)
+		r.Equal(http.StatusBadRequest, res.StatusCode, body)
+		res, body, err = testRequest("DELETE", Addr+"/register/s3", "", ownerToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+	})
+	t.Run("with webhooks", func(t *testing.T) {
+		owner, err := crypto.GenerateKey()
+		r.NoError(err)
+		ownerToken, err := jwt.SignJWT(time.Now().Unix(), time.Now().Add(time.Hour).Unix(), "s3", jwt.CREATE+","+jwt.READ+","+jwt.UPDATE+","+jwt.DELETE, owner)
+		r.NoError(err)
+		session, err := walletLogin(Addr, owner)
+		r.NoError(err)
+
+		// the subscriber fails the first attempt
+		type posted struct {
+			event, delivery, signature string
+			payload                    []byte
+		}
+		received := make(chan *posted, 16)
+		attempts := 0
+		subscriber := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			if attempts == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			payload, _ := ioutil.ReadAll(r.Body)
+			received <- &posted{r.Header.Get("X-Phoenix-Event"), r.Header.Get("X-Phoenix-Delivery"), r.Header.Get("X-Phoenix-Signature"), payload}
+		}))
+		defer subscriber.Close()
+
+		res, body, err := testRequest("POST", Addr+"/webhooks", "", session, bytes.NewReader([]byte(`{"url": "`+subscriber.URL+
+			`", "events": ["object.created", "object.deleted"], "bucket": "hooked", "prefix": "in/"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		created := struct{ Subscription *auth.Subscription }{}
+		r.NoError(json.Unmarshal([]byte(body), &created))
+		r.Len(created.Subscription.Secret, 64)
+		res, body, err = testRequest("POST", Addr+"/webhooks", "", session, bytes.NewReader([]byte(`{"url": "`+subscriber.URL+`", "events": ["object.read"]}`)))
+		r.NoError(err)
+		r.Equal(http.StatusBadRequest, res.StatusCode, body)
+
+		res, body, err = testRequest("POST", Addr+"/register", "", ownerToken, bytes.NewReader([]byte(`{"name": "s3", "region": "www", "endpoint": "`+
+			s3Server.URL+`", "key": "yyy", "token": "zzz"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/pods", "", ownerToken, bytes.NewReader([]byte(`{"name": "hooked"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/pea/hooked/out/a.csv", "", ownerToken, bytes.NewReader([]byte(`skipped`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/pea/hooked/in/a.csv", "", ownerToken, bytes.NewReader([]byte(`abc`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+
+		// the event is retried after backoff, signed with the secret of the subscription
+		var p *posted
+		select {
+		case p = <-received:
+		case <-time.After(5 * time.Second):
+			r.FailNow("event not delivered")
+		}
+		r.Equal(handler.EventObjectCreated, p.event)
+		var signedAt int64
+		var signature string
+		_, err = fmt.Sscanf(p.signature, "t=%d,v1=%s", &signedAt, &signature)
+		r.NoError(err)
+		r.Equal(created.Subscription.Sign(signedAt, p.payload), signature)
+		event := struct {
+			Event string
+			Data  struct{ Bucket, Path string }
+		}{}
+		r.NoError(json.Unmarshal(p.payload, &event))
+		r.Equal("in/a.csv", event.Data.Path)
+
+		// the delivery is logged once the subscriber responds
+		log := struct{ Deliveries []*auth.Delivery }{}
+		for i := 0; i < 10; i++ {
+			res, body, err = testRequest("GET", Addr+"/webhooks/deliveries?subscription="+created.Subscription.ID, "", session, nil)
+			r.NoError(err)
+			r.Equal(http.StatusOK, res.StatusCode, body)
+			r.NoError(json.Unmarshal([]byte(body), &log))
+			r.Len(log.Deliveries, 1)
+			if log.Deliveries[0].Status != auth.DeliveryPending {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		r.Equal(p.delivery, log.Deliveries[0].ID)
+		r.Equal(2, log.Deliveries[0].Attempts)
+		r.Equal(auth.DeliveryDelivered, log.Deliveries[0].Status)
+
+		res, body, err = testRequest("GET", Addr+"/webhooks", "", session, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		r.NotContains(body, created.Subscription.Secret)
+		res, body, err = testRequest("DELETE", Addr+"/webhooks/"+created.Subscription.ID, "", session, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("DELETE", Addr+"/webhooks/"+created.Subscription.ID, "", session, nil)
+		r.NoError(err)
+		r.Equal(http.StatusNotFound, res.StatusCode, body)
+		res, body, err = testRequest("DELETE", Addr+"/register/s3", "", ownerToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+	})
+	t.Run("with change feed", func(t *testing.T) {
+		owner, err := crypto.GenerateKey()
+		r.NoError(err)
+		ownerToken, err := jwt.SignJWT(time.Now().Unix(), time.Now().Add(time.Hour).Unix(), "s3", jwt.CREATE+","+jwt.READ+","+jwt.UPDATE+","+jwt.DELETE, owner)
+		r.NoError(err)
+		res, body, err := testRequest("POST", Addr+"/register", "", ownerToken, bytes.NewReader([]byte(`{"name": "s3", "region": "www", "endpoint": "`+
+			s3Server.URL+`", "key": "yyy", "token": "zzz"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/pods", "", ownerToken, bytes.NewReader([]byte(`{"name": "feed"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+
+		// watch resuming after the bucket is created
+		watch := func(token, lastID string) (*http.Response, *bufio.Reader) {
+			req, err := http.NewRequest("GET", Addr+"/pea/feed?watch=true", nil)
+			r.NoError(err)
+			req.Header.Set("Authorization", "Bearer "+token)
+			req.Header.Set("Last-Event-ID", lastID)
+			res, err := http.DefaultClient.Do(req)
+			r.NoError(err)
+			r.Equal(http.StatusOK, res.StatusCode)
+			r.Equal("text/event-stream", res.Header.Get("Content-Type"))
+			return res, bufio.NewReader(res.Body)
+		}
+		stream, reader := watch(ownerToken, "1")
+		for _, content := range []string{`{"t": 1}`, `{"t": 2}`} {
+			res, body, err = testRequest("POST", Addr+"/pea/feed/reading.json", "", ownerToken, bytes.NewReader([]byte(content)))
+			r.NoError(err)
+			r.Equal(http.StatusOK, res.StatusCode, body)
+		}
+		res, body, err = testRequest("DELETE", Addr+"/pea/feed/reading.json", "", ownerToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		for _, expected := range []struct{ id, event string }{{"2", "created"}, {"3", "updated"}, {"4", "deleted"}} {
+			id, event, data, err := readEvent(reader)
+			r.NoError(err)
+			r.Equal(expected.id, id)
+			r.Equal(expected.event, event)
+			change := &auth.Change{}
+			r.NoError(json.Unmarshal([]byte(data), change))
+			r.Equal("reading.json", change.Path)
+		}
+		stream.Body.Close()
+
+		// changes missed are replayed
+		stream, reader = watch(ownerToken, "3")
+		id, event, _, err := readEvent(reader)
+		r.NoError(err)
+		r.Equal("4", id)
+		r.Equal("deleted", event)
+		stream.Body.Close()
+
+		// the watch ends once its token is revoked
+		watcherToken, err := jwt.SignJWT(time.Now().Unix(), time.Now().Add(time.Hour).Unix(), "s3", jwt.READ, owner)
+		r.NoError(err)
+		stream, reader = watch(watcherToken, "4")
+		session, err := walletLogin(Addr, owner)
+		r.NoError(err)
+		res, body, err = testRequest("POST", Addr+"/revoke", "", session, bytes.NewReader([]byte(`{"token": "`+watcherToken+`"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/pea/feed/revoked.json", "", ownerToken, bytes.NewReader([]byte(`{}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		_, _, _, err = readEvent(reader)
+		r.Error(err)
+		stream.Body.Close()
+
+		// or streamed over websocket
+		wsConfig, err := websocket.NewConfig("ws"+strings.TrimPrefix(Addr, "http")+"/pea/feed?watch=true&lastEventId=2", Addr)
+		r.NoError(err)
+		wsConfig.Header.Set("Authorization", "Bearer "+ownerToken)
+		ws, err := websocket.DialConfig(wsConfig)
+		r.NoError(err)
+		for _, expected := range []string{auth.ChangeUpdated, auth.ChangeDeleted} {
+			change := &auth.Change{}
+			r.NoError(websocket.JSON.Receive(ws, change))
+			r.Equal(expected, change.Type)
+		}
+		r.NoError(ws.Close())
+
+		res, body, err = testRequest("GET", Addr+"/pea/feed?watch=true", "", ownerToken+"x", nil)
+		r.NoError(err)
+		r.Equal(http.StatusUnauthorized, res.StatusCode, body)
+		res, body, err = testRequest("DELETE", Addr+"/register/s3", "", ownerToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+	})
+
+	t.Run("with presigned urls", func(t *testing.T) {
+		owner, err := crypto.GenerateKey()
+		r.NoError(err)
+		scope := jwt.CREATE + "," + jwt.READ + "," + jwt.UPDATE + "," + jwt.DELETE
+		ownerToken, err := jwt.SignJWT(time.Now().Unix(), time.Now().Add(time.Hour).Unix(), "s3", scope, owner)
+		r.NoError(err)
+		res, body, err := testRequest("POST", Addr+"/register", "", ownerToken, bytes.NewReader([]byte(`{"name": "s3", "region": "www", "endpoint": "`+
+			s3Server.URL+`", "key": "yyy", "token": "zzz"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/pods", "", ownerToken, bytes.NewReader([]byte(`{"name": "presign"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/pea/presign/doc.txt", "", ownerToken, bytes.NewReader([]byte("direct")))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+
+		// the token presigning expires in a minute, so do the URLs
+		expiry := time.Now().Add(time.Minute).Unix()
+		shortToken, err := jwt.SignJWT(time.Now().Unix(), expiry, "s3/presign", scope, owner)
+		r.NoError(err)
+		presign := func(request string) (string, bool) {
+			res, body, err := testRequest("POST", Addr+"/presign", "", shortToken, bytes.NewReader([]byte(request)))
+			r.NoError(err)
+			r.Equal(http.StatusOK, res.StatusCode, body)
+			presigned := struct {
+				URL       string
+				ExpiresAt int64
+				Direct    bool
+			}{}
+			r.NoError(json.Unmarshal([]byte(body), &presigned))
+			r.True(presigned.ExpiresAt <= expiry)
+			return presigned.URL, presigned.Direct
+		}
+
+		// transfers of URLs would not be counted against a one-time token
+		onceToken, err := auth.SignToken(time.Now().Unix(), expiry, "s3/presign", jwt.READ, auth.Extension{MaxUses: 1}, owner)
+		r.NoError(err)
+		res, body, err = testRequest("POST", Addr+"/presign", "", onceToken, bytes.NewReader([]byte(`{"bucket": "presign", "path": "doc.txt", "proxy": true}`)))
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+		r.Contains(body, handler.ErrorPresignLimited.Error())
+
+		// s3 presigns downloads from itself
+		url, direct := presign(`{"bucket": "presign", "path": "doc.txt", "method": "GET", "expiresIn": 3600}`)
+		r.True(direct)
+		r.True(strings.HasPrefix(url, s3Server.URL))
+		res, body, err = testRequest("GET", url, "", "", nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		r.Equal("direct", body)
+
+		// or phoenix signs transfers through itself
+		url, direct = presign(`{"bucket": "presign", "path": "dir/proxy.txt", "method": "PUT", "proxy": true}`)
+		r.False(direct)
+		r.True(strings.HasPrefix(url, Addr+"/presigned/presign/dir/proxy.txt?"))
+		res, body, err = testRequest("PUT", url, "", "", bytes.NewReader([]byte("proxied")))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("GET", strings.Replace(url, "proxy.txt", "other.txt", 1), "", "", bytes.NewReader([]byte("tampered")))
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+		url, _ = presign(`{"bucket": "presign", "path": "dir/proxy.txt", "proxy": true}`)
+		res, body, err = testRequest("GET", url, "", "", nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		r.Equal("proxied", body)
+
+		// policies of the bucket are evaluated when the object is transferred, so the URL is signed by phoenix
+		session, err := walletLogin(Addr, owner)
+		r.NoError(err)
+		res, body, err = testRequest("POST", Addr+"/policies", "", session, bytes.NewReader([]byte(`{"store": "s3", "bucket": "presign", `+
+			`"expression": "request.op != \"Update\" || (object.size <= 4 && object.contentType != \"application/json\")"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		policed, direct := presign(`{"bucket": "presign", "path": "small.txt", "method": "PUT"}`)
+		r.False(direct)
+		for _, upload := range []struct{ contentType, content string }{{"text/plain", "too large"}, {"application/json", "{}"}} {
+			res, body, err = testRequest("PUT", policed, upload.contentType, "", bytes.NewReader([]byte(upload.content)))
+			r.NoError(err)
+			r.Equal(http.StatusForbidden, res.StatusCode, body)
+			r.Contains(body, auth.ErrPolicyDenied.Error())
+		}
+		res, body, err = testRequest("PUT", policed, "text/plain", "", bytes.NewReader([]byte("tiny")))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+
+		// embargoes set after the URL is presigned hold its downloads
+		held, direct := presign(`{"bucket": "presign", "path": "small.txt"}`)
+		r.False(direct)
+		res, body, err = testRequest("POST", Addr+"/embargoes", "", session,
+			bytes.NewReader([]byte(`{"store": "s3", "bucket": "presign", "path": "small.txt"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("GET", held, "", "", nil)
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+		r.Contains(body, auth.ErrEmbargoed.Error())
+		res, body, err = testRequest("DELETE", Addr+"/policies/s3/presign", "", session, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+
+		// outside the subject of the token
+		res, body, err = testRequest("POST", Addr+"/presign", "", shortToken, bytes.NewReader([]byte(`{"bucket": "other", "path": "doc.txt"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+
+		// revoking the token cuts off its URLs
+		res, body, err = testRequest("POST", Addr+"/revoke", "", session, bytes.NewReader([]byte(`{"token": "`+shortToken+`"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("GET", url, "", "", nil)
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+
+		res, body, err = testRequest("DELETE", Addr+"/register/s3", "", ownerToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+	})
+
+	t.Run("with share links", func(t *testing.T) {
+		owner, err := crypto.GenerateKey()
+		r.NoError(err)
+		scope := jwt.CREATE + "," + jwt.READ + "," + jwt.UPDATE + "," + jwt.DELETE
+		ownerToken, err := jwt.SignJWT(time.Now().Unix(), time.Now().Add(time.Hour).Unix(), "s3", scope, owner)
+		r.NoError(err)
+		res, body, err := testRequest("POST", Addr+"/register", "", ownerToken, bytes.NewReader([]byte(`{"name": "s3", "region": "www", "endpoint": "`+
+			s3Server.URL+`", "key": "yyy", "token": "zzz"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/pods", "", ownerToken, bytes.NewReader([]byte(`{"name": "share"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/pea/share/photo.txt", "", ownerToken, bytes.NewReader([]byte("shared")))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+
+		// trustee with read scope shares links
+		expiry := time.Now().Add(time.Minute).Unix()
+		trusteeToken, err := jwt.SignJWT(time.Now().Unix(), expiry, "s3/share", jwt.READ, owner)
+		r.NoError(err)
+		share := func(request string) string {
+			res, body, err := testRequest("POST", Addr+"/share", "", trusteeToken, bytes.NewReader([]byte(request)))
+			r.NoError(err)
+			r.Equal(http.StatusOK, res.StatusCode, body)
+			link := struct {
+				URL       string
+				ExpiresAt int64
+			}{}
+			r.NoError(json.Unmarshal([]byte(body), &link))
+			r.True(link.ExpiresAt <= expiry)
+			r.True(strings.HasPrefix(link.URL, Addr+"/shared/share/photo.txt?"))
+			return link.URL
+		}
+
+		// links are downloaded without a bearer header
+		url := share(`{"bucket": "share", "path": "photo.txt", "expiresIn": 86400}`)
+		for i := 0; i < 2; i++ {
+			res, err := http.Get(url)
+			r.NoError(err)
+			content, err := ioutil.ReadAll(res.Body)
+			r.NoError(err)
+			res.Body.Close()
+			r.Equal(http.StatusOK, res.StatusCode)
+			r.Equal("shared", string(content))
+		}
+		res, body, err = testRequest("GET", strings.Replace(url, "photo.txt", "other.txt", 1), "", "", nil)
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+
+		// single-use links are used up
+		once := share(`{"bucket": "share", "path": "photo.txt", "singleUse": true}`)
+		res, body, err = testRequest("GET", once, "", "", nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("GET", once, "", "", nil)
+		r.NoError(err)
+		r.Equal(http.StatusGone, res.StatusCode, body)
+
+		// sharing requires read scope of the object
+		res, body, err = testRequest("POST", Addr+"/share", "", trusteeToken, bytes.NewReader([]byte(`{"bucket": "other", "path": "photo.txt"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+
+		// downloads are audited as requests of the token sharing the link
+		session, err := walletLogin(Addr, owner)
+		r.NoError(err)
+		res, body, err = testRequest("GET", Addr+"/audit?path=photo.txt", "", session, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		audit := struct{ Records []*auth.AuditRecord }{}
+		r.NoError(json.Unmarshal([]byte(body), &audit))
+		trustee, err := auth.NewClaims(trusteeToken)
+		r.NoError(err)
+		shared := 0
+		for _, rec := range audit.Records {
+			if rec.Token == trustee.Hash() && rec.Subject == "s3/share/photo.txt" {
+				shared++
+			}
+		}
+		r.Equal(4, shared)
+
+		// links used up are refused before the object is fetched, so replaying them costs the owner no transfer
+		res, body, err = testRequest("POST", Addr+"/register", "", ownerToken, bytes.NewReader([]byte(`{"name": "s3", "region": "www", "endpoint": "`+
+			s3Server.URL+`", "key": "yyy", "token": "zzz", "quota": {"maxTransfer": 30}}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		for i := 0; i < 3; i++ {
+			res, body, err = testRequest("GET", once, "", "", nil)
+			r.NoError(err)
+			r.Equal(http.StatusGone, res.StatusCode, body)
+		}
+		res, body, err = testRequest("GET", url, "", "", nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+
+		// policies apply to the token sharing the link when it is downloaded
+		res, body, err = testRequest("POST", Addr+"/policies", "", session, bytes.NewReader([]byte(`{"store": "s3", "bucket": "share", "expression": "request.op != \"Read\""}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("GET", url, "", "", nil)
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+		r.Contains(body, auth.ErrPolicyDenied.Error())
+
+		// revoking the token cuts off its links
+		res, body, err = testRequest("POST", Addr+"/revoke", "", session, bytes.NewReader([]byte(`{"token": "`+trusteeToken+`"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("GET", url, "", "", nil)
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+
+		res, body, err = testRequest("DELETE", Addr+"/register/s3", "", ownerToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+	})
+
+	t.Run("with lifecycle", func(t *testing.T) {
+		owner, err := crypto.GenerateKey()
+		r.NoError(err)
+		scope := jwt.CREATE + "," + jwt.READ + "," + jwt.UPDATE + "," + jwt.DELETE
+		ownerToken, err := jwt.SignJWT(time.Now().Unix(), time.Now().Add(time.Hour).Unix(), "s3", scope, owner)
+		r.NoError(err)
+		session, err := walletLogin(Addr, owner)
+		r.NoError(err)
+		res, body, err := testRequest("POST", Addr+"/register", "", ownerToken, bytes.NewReader([]byte(`{"name": "s3", "region": "www", "endpoint": "`+
+			s3Server.URL+`", "key": "yyy", "token": "zzz"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/pods", "", ownerToken, bytes.NewReader([]byte(`{"name": "telemetry"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		for _, path := range []string{"raw/1.json", "raw/2.json", "summary.json"} {
+			res, body, err = testRequest("POST", Addr+"/pea/telemetry/"+path, "", ownerToken, bytes.NewReader([]byte(`{}`)))
+			r.NoError(err)
+			r.Equal(http.StatusOK, res.StatusCode, body)
+		}
+
+		// rules are enforced by phoenix if the backend has no lifecycle configuration
+		res, body, err = testRequest("POST", Addr+"/lifecycle", "", session, bytes.NewReader([]byte(
+			`{"store": "s3", "bucket": "telemetry", "prefix": "raw/", "action": "delete", "days": 30}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		rule := struct{ Rule *auth.LifecycleRule }{}
+		r.NoError(json.Unmarshal([]byte(body), &rule))
+		r.Equal("raw", rule.Rule.Prefix)
+		r.False(rule.Rule.Native)
+		res, body, err = testRequest("POST", Addr+"/lifecycle", "", session, bytes.NewReader([]byte(
+			`{"store": "s3", "bucket": "telemetry", "action": "delete", "days": 0}`)))
+		r.NoError(err)
+		r.Equal(http.StatusBadRequest, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/lifecycle", "", ownerToken, bytes.NewReader([]byte(
+			`{"store": "s3", "bucket": "telemetry", "action": "delete", "days": 1}`)))
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+
+		// dry run reports objects due by then, without deleting them
+		report := func(at time.Time) []string {
+			res, body, err := testRequest("GET", Addr+"/lifecycle/report?at="+strconv.FormatInt(at.Unix(), 10), "", session, nil)
+			r.NoError(err)
+			r.Equal(http.StatusOK, res.StatusCode, body)
+			report := struct {
+				DryRun  bool
+				Actions []struct{ Action, Path, Error string }
+			}{}
+			r.NoError(json.Unmarshal([]byte(body), &report))
+			r.True(report.DryRun)
+			paths := []string{}
+			for _, a := range report.Actions {
+				r.Empty(a.Error)
+				r.Equal(auth.LifecycleDelete, a.Action)
+				paths = append(paths, a.Path)
+			}
+			return paths
+		}
+		r.Empty(report(time.Now()))
+		r.Equal([]string{"raw/1.json", "raw/2.json"}, report(time.Now().Add(31*24*time.Hour)))
+		res, body, err = testRequest("GET", Addr+"/pea/telemetry/raw/1.json", "", ownerToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+
+		// objects uploaded with TTL are swept once expired
+		req, err := http.NewRequest("POST", Addr+"/pea/telemetry/live.json", bytes.NewReader([]byte(`{"t": 1}`)))
+		r.NoError(err)
+		req.Header.Set("Authorization", "Bearer "+ownerToken)
+		req.Header.Set("X-Phoenix-TTL", "1")
+		res, err = http.DefaultClient.Do(req)
+		r.NoError(err)
+		res.Body.Close()
+		r.Equal(http.StatusOK, res.StatusCode)
+		r.Equal([]string{"live.json"}, report(time.Now().Add(2*time.Second)))
+		res, body, err = testRequest("POST", Addr+"/pea/telemetry/bad.json?ttl=soon", "", ownerToken, bytes.NewReader([]byte(`{}`)))
+		r.NoError(err)
+		r.Equal(http.StatusBadRequest, res.StatusCode, body)
+		swept := false
+		for i := 0; i < 40 && !swept; i++ {
+			time.Sleep(100 * time.Millisecond)
+			res, body, err = testRequest("GET", Addr+"/pea/telemetry", "", ownerToken, nil)
+			r.NoError(err)
+			r.Equal(http.StatusOK, res.StatusCode, body)
+			swept = !strings.Contains(body, "live.json")
+		}
+		r.True(swept)
+		r.Empty(report(time.Now().Add(2 * time.Second)))
+
+		res, body, err = testRequest("GET", Addr+"/lifecycle", "", session, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		r.Contains(body, rule.Rule.ID)
+		res, body, err = testRequest("DELETE", Addr+"/lifecycle/"+rule.Rule.ID, "", session, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("DELETE", Addr+"/lifecycle/"+rule.Rule.ID, "", session, nil)
+		r.NoError(err)
+		r.Equal(http.StatusNotFound, res.StatusCode, body)
+		r.Empty(report(time.Now().Add(31 * 24 * time.Hour)))
+
+		res, body, err = testRequest("DELETE", Addr+"/register/s3", "", ownerToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+	})
+
+	t.Run("with recycle bin", func(t *testing.T) {
+		owner, err := crypto.GenerateKey()
+		r.NoError(err)
+		scope := jwt.CREATE + "," + jwt.READ + "," + jwt.UPDATE + "," + jwt.DELETE
+		ownerToken, err := jwt.SignJWT(time.Now().Unix(), time.Now().Add(time.Hour).Unix(), "s3", scope, owner)
+		r.NoError(err)
+		session, err := walletLogin(Addr, owner)
+		r.NoError(err)
+		registration := `{"name": "s3", "region": "www", "endpoint": "` + s3Server.URL + `", "key": "yyy", "token": "zzz", "recycleBin": {"days": %d}}`
+		res, body, err := testRequest("POST", Addr+"/register", "", ownerToken, bytes.NewReader([]byte(fmt.Sprintf(registration, 0))))
+		r.NoError(err)
+		r.Equal(http.StatusBadRequest, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/register", "", ownerToken, bytes.NewReader([]byte(fmt.Sprintf(registration, 7))))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/pods", "", ownerToken, bytes.NewReader([]byte(`{"name": "archive"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		for _, path := range []string{"a.txt", "b.txt"} {
+			res, body, err = testRequest("POST", Addr+"/pea/archive/"+path, "", ownerToken, bytes.NewReader([]byte(path)))
+			r.NoError(err)
+			r.Equal(http.StatusOK, res.StatusCode, body)
+		}
+		trash := func(method, url string) string {
+			res, body, err := testRequest(method, url, "", ownerToken, nil)
+			r.NoError(err)
+			r.Equal(http.StatusOK, res.StatusCode, body)
+			deleted := struct{ Trash string }{}
+			r.NoError(json.Unmarshal([]byte(body), &deleted))
+			r.Len(deleted.Trash, 32)
+			return deleted.Trash
+		}
+
+		// deleted objects are moved to the hidden folder of the recycle bin
+		id := trash("DELETE", Addr+"/pea/archive/a.txt")
+		res, body, err = testRequest("GET", Addr+"/pea/archive/a.txt", "", ownerToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusBadRequest, res.StatusCode, body)
+		res, body, err = testRequest("GET", Addr+"/pea/archive", "", ownerToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		r.NotContains(body, "a.txt")
+		r.NotContains(body, auth.TrashPrefix)
+		res, body, err = testRequest("GET", Addr+"/pea/archive/"+auth.TrashPrefix+"/"+id, "", ownerToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+		res, body, err = testRequest("GET", Addr+"/trash?store=s3&bucket=archive", "", ownerToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+		res, body, err = testRequest("GET", Addr+"/trash?store=s3&bucket=archive", "", session, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		items := struct{ Items []*auth.TrashItem }{}
+		r.NoError(json.Unmarshal([]byte(body), &items))
+		r.Len(items.Items, 1)
+		r.Equal("a.txt", items.Items[0].Path)
+		r.Equal(int64(5), items.Items[0].Size)
+
+		res, body, err = testRequest("POST", Addr+"/trash/"+id+"/restore", "", session, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("GET", Addr+"/pea/archive/a.txt", "", ownerToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		r.Contains(body, "a.txt")
+
+		// an object uploaded since is not overwritten, and purging takes the owner session
+		id = trash("DELETE", Addr+"/pea/archive/a.txt")
+		res, body, err = testRequest("POST", Addr+"/pea/archive/a.txt", "", ownerToken, bytes.NewReader([]byte("new")))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/trash/"+id+"/restore", "", session, nil)
+		r.NoError(err)
+		r.Equal(http.StatusConflict, res.StatusCode, body)
+		res, body, err = testRequest("DELETE", Addr+"/trash/"+id, "", ownerToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+		res, body, err = testRequest("DELETE", Addr+"/trash/"+id, "", session, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/trash/"+id+"/restore", "", session, nil)
+		r.NoError(err)
+		r.Equal(http.StatusNotFound, res.StatusCode, body)
+
+		// deleted buckets are gone until restored
+		id = trash("DELETE", Addr+"/pea/archive/a.txt")
+		trash("DELETE", Addr+"/pea/archive/b.txt")
+		bucketID := trash("DELETE", Addr+"/pods/archive")
+		res, body, err = testRequest("GET", Addr+"/pea/archive", "", ownerToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusNotFound, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/pods", "", ownerToken, bytes.NewReader([]byte(`{"name": "archive"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusConflict, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/trash/"+id+"/restore", "", session, nil)
+		r.NoError(err)
+		r.Equal(http.StatusConflict, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/trash/"+bucketID+"/restore", "", session, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/trash/"+id+"/restore", "", session, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+
+		// the sweeper purges what has been in the recycle bin longer than its days
+		id = trash("DELETE", Addr+"/pea/archive/a.txt")
+		bucketID = trash("DELETE", Addr+"/pods/archive")
+		res, body, err = testRequest("GET", Addr+"/lifecycle/report?at="+strconv.FormatInt(time.Now().Add(8*24*time.Hour).Unix(), 10), "", session, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		report := struct {
+			Actions []struct{ Trash, Path, Error string }
+		}{}
+		r.NoError(json.Unmarshal([]byte(body), &report))
+		purged := map[string]string{}
+		for _, a := range report.Actions {
+			r.Empty(a.Error)
+			purged[a.Trash] = a.Path
+		}
+		r.Equal("a.txt", purged[id])
+		r.Equal("", purged[bucketID])
+		r.Len(purged, 3)
+		res, body, err = testRequest("DELETE", Addr+"/trash/"+bucketID, "", session, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("GET", Addr+"/trash", "", session, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		r.NoError(json.Unmarshal([]byte(body), &items))
+		r.Empty(items.Items)
+		res, body, err = testRequest("POST", Addr+"/pods", "", ownerToken, bytes.NewReader([]byte(`{"name": "archive"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+
+		res, body, err = testRequest("DELETE", Addr+"/register/s3", "", ownerToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+	})
+
+	t.Run("with retention", func(t *testing.T) {
+		owner, err := crypto.GenerateKey()
+		r.NoError(err)
+		scope := jwt.CREATE + "," + jwt.READ + "," + jwt.UPDATE + "," + jwt.DELETE
+		ownerToken, err := jwt.SignJWT(time.Now().Unix(), time.Now().Add(time.Hour).Unix(), "s3", scope, owner)
+		r.NoError(err)
+		session, err := walletLogin(Addr, owner)
+		r.NoError(err)
+		res, body, err := testRequest("POST", Addr+"/register", "", ownerToken, bytes.NewReader([]byte(`{"name": "s3", "region": "www", "endpoint": "`+
+			s3Server.URL+`", "key": "yyy", "token": "zzz", "recycleBin": {"days": 7}}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/pods", "", ownerToken, bytes.NewReader([]byte(`{"name": "ledger"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/pea/ledger/draft.csv", "", ownerToken, bytes.NewReader([]byte("draft")))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+
+		res, body, err = testRequest("POST", Addr+"/retention", "", session, bytes.NewReader([]byte(`{"store": "s3", "bucket": "ledger", "days": 0}`)))
+		r.NoError(err)
+		r.Equal(http.StatusBadRequest, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/retention", "", ownerToken, bytes.NewReader([]byte(`{"store": "s3", "bucket": "ledger", "days": 1}`)))
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/retention", "", session, bytes.NewReader([]byte(`{"store": "s3", "bucket": "ledger", "days": 1}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+
+		// objects uploaded since can't be changed or deleted, even with Update and Delete scope
+		res, body, err = testRequest("POST", Addr+"/pea/ledger/2020.csv", "", ownerToken, bytes.NewReader([]byte("2020")))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/pea/ledger/2020.csv", "", ownerToken, bytes.NewReader([]byte("changed")))
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+		res, body, err = testRequest("DELETE", Addr+"/pea/ledger/2020.csv", "", ownerToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+		res, body, err = testRequest("DELETE", Addr+"/pods/ledger", "", ownerToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/token/introspect", "", "",
+			bytes.NewReader([]byte(`{"token": "`+ownerToken+`", "method": "DELETE", "path": "/pea/ledger/2020.csv"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		r.Contains(body, `"rule":"lock"`)
+		res, body, err = testRequest("GET", Addr+"/pea/ledger/2020.csv", "", ownerToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		r.Contains(body, `"2020"`)
+		res, body, err = testRequest("GET", Addr+"/retention?store=s3&bucket=ledger", "", session, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		listed := struct {
+			Policies []*auth.RetentionPolicy
+			Locks    []*auth.ObjectLock
+		}{}
+		r.NoError(json.Unmarshal([]byte(body), &listed))
+		r.Len(listed.Policies, 1)
+		r.Len(listed.Locks, 1)
+		r.Equal("2020.csv", listed.Locks[0].Path)
+		retainUntil := listed.Locks[0].RetainUntil
+		r.True(retainUntil > time.Now().Add(23*time.Hour).Unix())
+
+		// retention is extended but not shortened
+		lock := func(request string) (*http.Response, string) {
+			res, body, err := testRequest("POST", Addr+"/retention/objects", "", session, bytes.NewReader([]byte(request)))
+			r.NoError(err)
+			return res, body
+		}
+		res, body = lock(`{"store": "s3", "bucket": "ledger", "path": "2020.csv", "retainUntil": ` + strconv.FormatInt(retainUntil-60, 10) + `}`)
+		r.Equal(http.StatusConflict, res.StatusCode, body)
+		res, body = lock(`{"store": "s3", "bucket": "ledger", "path": "2020.csv", "retainUntil": ` + strconv.FormatInt(retainUntil+60, 10) + `}`)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body = lock(`{"store": "s3", "bucket": "ledger", "path": "missing.csv", "legalHold": true}`)
+		r.Equal(http.StatusNotFound, res.StatusCode, body)
+		res, body = lock(`{"store": "s3", "bucket": "ledger", "path": "draft.csv"}`)
+		r.Equal(http.StatusBadRequest, res.StatusCode, body)
+
+		// objects under legal hold are kept until it is removed
+		res, body = lock(`{"store": "s3", "bucket": "ledger", "path": "draft.csv", "legalHold": true}`)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("DELETE", Addr+"/pea/ledger/draft.csv", "", ownerToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+		r.Contains(body, "legal hold")
+		res, body = lock(`{"store": "s3", "bucket": "ledger", "path": "draft.csv", "legalHold": false}`)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("DELETE", Addr+"/pea/ledger/draft.csv", "", ownerToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+
+		// uploads of presigned URLs pass through phoenix to be retained
+		res, body, err = testRequest("POST", Addr+"/presign", "", ownerToken, bytes.NewReader([]byte(`{"bucket": "ledger", "path": "2020.csv", "method": "PUT"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/presign", "", ownerToken, bytes.NewReader([]byte(`{"bucket": "ledger", "path": "2021.csv", "method": "PUT"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		presigned := struct {
+			URL    string
+			Direct bool
+		}{}
+		r.NoError(json.Unmarshal([]byte(body), &presigned))
+		r.False(presigned.Direct)
+		res, body, err = testRequest("PUT", presigned.URL, "", "", bytes.NewReader([]byte("2021")))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("PUT", presigned.URL, "", "", bytes.NewReader([]byte("changed")))
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+
+		// the sweeper doesn't delete objects locked when they expire
+		req, err := http.NewRequest("POST", Addr+"/pea/ledger/live.csv", bytes.NewReader([]byte("live")))
+		r.NoError(err)
+		req.Header.Set("Authorization", "Bearer "+ownerToken)
+		req.Header.Set("X-Phoenix-TTL", "1")
+		res, err = http.DefaultClient.Do(req)
+		r.NoError(err)
+		res.Body.Close()
+		r.Equal(http.StatusOK, res.StatusCode)
+		res, body, err = testRequest("GET", Addr+"/lifecycle/report?at="+strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10), "", session, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		report := struct {
+			Actions []struct{ Path, Trash, Error string }
+		}{}
+		r.NoError(json.Unmarshal([]byte(body), &report))
+		retained := false
+		for _, a := range report.Actions {
+			if a.Path == "live.csv" && a.Trash == "" {
+				retained = strings.Contains(a.Error, auth.ErrObjectLocked.Error())
+			}
+		}
+		r.True(retained)
+		time.Sleep(2 * time.Second)
+		res, body, err = testRequest("GET", Addr+"/pea/ledger/live.csv", "", ownerToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+
+		// objects uploaded before the policy is removed stay retained
+		res, body, err = testRequest("DELETE", Addr+"/retention/s3/ledger", "", session, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("DELETE", Addr+"/retention/s3/ledger", "", session, nil)
+		r.NoError(err)
+		r.Equal(http.StatusNotFound, res.StatusCode, body)
+		res, body, err = testRequest("DELETE", Addr+"/pea/ledger/2021.csv", "", ownerToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusForbidden, res.StatusCode, body)
+		res, body, err = testRequest("POST", Addr+"/pea/ledger/2022.csv", "", ownerToken, bytes.NewReader([]byte("2022")))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		res, body, err = testRequest("DELETE", Addr+"/pea/ledger/2022.csv", "", ownerToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		// nor are they overwritten with presigned URLs, which pass through phoenix while it alone locks objects
+		res, body, err = testRequest("POST", Addr+"/presign", "", ownerToken, bytes.NewReader([]byte(`{"bucket": "ledger", "path": "2022.csv", "method": "PUT"}`)))
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+		r.NoError(json.Unmarshal([]byte(body), &presigned))
+		r.False(presigned.Direct)
+
+		res, body, err = testRequest("DELETE", Addr+"/register/s3", "", ownerToken, nil)
+		r.NoError(err)
+		r.Equal(http.StatusOK, res.StatusCode, body)
+	})
+}
+
+// readEvent reads the next server-sent event, skipping comments
+func readEvent(reader *bufio.Reader) (id, event, data string, err error) {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return "", "", "", err
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && id != "":
+			return id, event, data, nil
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+// walletLogin signs the login challenge with the key, and returns the owner session
+func walletLogin(addr string, key crypto.PrivateKey) (string, error) {
+	_, body, err := testRequest("POST", addr+"/login/challenge", "", "",
+		bytes.NewReader([]byte(`{"address": "`+key.PublicKey().Address().String()+`"}`)))
+	if err != nil {
+		return "", err
+	}
+	challenge := &auth.Challenge{}
+	if err := json.Unmarshal([]byte(body), challenge); err != nil {
+		return "", err
+	}
+	sig, err := key.Sign(auth.PersonalMessageHash(challenge.Message))
+	if err != nil {
+		return "", err
+	}
+	_, body, err = testRequest("POST", addr+"/login", "", "",
+		bytes.NewReader([]byte(`{"nonce": "`+challenge.Nonce+`", "signature": "`+hex.EncodeToString(sig)+`"}`)))
+	if err != nil {
+		return "", err
+	}
+	session := &auth.Session{}
+	if err := json.Unmarshal([]byte(body), session); err != nil {
+		return "", err
+	}
+	return session.ID, nil
 }
 
 func testRequest(
@@ -10,16 +10,17 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"sync"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/cors"
 	"go.uber.org/zap"
 
-	"github.com/iotexproject/phoenix/auth"
 	"github.com/iotexproject/phoenix/config"
 	"github.com/iotexproject/phoenix/db"
 	"github.com/iotexproject/phoenix/handler"
+	"github.com/iotexproject/phoenix/handler/midware"
 	"github.com/iotexproject/phoenix/log"
 )
 
@@ -29,6 +30,10 @@ type Server struct {
 	cfg    *config.Config
 	log    *zap.Logger
 	userDB db.KVStore
+
+	// cancel stops background jobs, done is closed once they return
+	cancel context.CancelFunc
+	done   chan struct{}
 }
 
 // New return new Server instance
@@ -45,7 +50,8 @@ func (srv *Server) Start() error {
 	r := chi.NewRouter()
 	// middleware
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	// client IPs forwarded by other peers than trusted proxies are ignored
+	r.Use(midware.RealIP(srv.cfg.Server.TrustedProxies))
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	srv.log.Info("RateLimit",
@@ -78,11 +84,27 @@ func (srv *Server) Start() error {
 	}
 
 	endpoint := fmt.Sprintf(":%s", srv.cfg.Server.Port)
-	h := handler.NewStorageHandler(srv.cfg, auth.NewCredential(srv.userDB))
+	h := handler.NewStorageHandler(srv.cfg, srv.userDB)
+	ctx, cancel := context.WithCancel(context.Background())
+	srv.cancel, srv.done = cancel, make(chan struct{})
+	go func() {
+		var wg sync.WaitGroup
+		for _, job := range []func(context.Context){h.DeliverWebhooks, h.SweepLifecycle} {
+			wg.Add(1)
+			go func(job func(context.Context)) {
+				defer wg.Done()
+				job(ctx)
+			}(job)
+		}
+		wg.Wait()
+		close(srv.done)
+	}()
 	srv.Server = &http.Server{
 		Handler: h.ServerMux(r),
 		Addr:    endpoint,
 	}
+	// change feeds stream until the client leaves, they are ended for shutdown not to wait for them
+	srv.RegisterOnShutdown(h.CloseWatches)
 	srv.log.Info("starting server", zap.String("endpoint", endpoint))
 	return srv.ListenAndServe()
 }
@@ -91,5 +113,9 @@ func (srv *Server) Stop(ctx context.Context) error {
 	if err := srv.Shutdown(ctx); err != nil {
 		srv.log.Error("shutdown server", zap.Error(err))
 	}
+	if srv.cancel != nil {
+		srv.cancel()
+		<-srv.done
+	}
 	return srv.userDB.Stop(ctx)
 }
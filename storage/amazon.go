@@ -10,16 +10,25 @@ import (
 	"bytes"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	pathutil "path"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+
+	"github.com/iotexproject/phoenix/auth"
 )
 
+// lifecycleRulePrefix prefixes the IDs of lifecycle rules phoenix configures, to tell them from rules configured
+// outside phoenix
+const lifecycleRulePrefix = "phoenix-"
+
 // AmazonS3Backend is a storage backend for Amazon S3
 type AmazonS3Backend struct {
 	Client     *s3.S3
@@ -152,3 +161,149 @@ func (b AmazonS3Backend) DeleteObject(bucket, path string) error {
 	_, err := b.Client.DeleteObject(s3Input)
 	return err
 }
+
+// PresignGetObject returns the URL to download an object from Amazon S3 bucket, at prefix, until it expires
+func (b AmazonS3Backend) PresignGetObject(bucket, path string, expires time.Duration) (string, error) {
+	req, _ := b.Client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(pathutil.Join(b.Prefix, path)),
+	})
+	return req.Presign(expires)
+}
+
+// PresignPutObject returns the URL to upload an object to Amazon S3 bucket, at prefix, until it expires
+func (b AmazonS3Backend) PresignPutObject(bucket, path string, expires time.Duration) (string, error) {
+	s3Input := &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(pathutil.Join(b.Prefix, path)),
+	}
+	if b.SSE != "" {
+		s3Input.ServerSideEncryption = aws.String(b.SSE)
+	}
+	req, _ := b.Client.PutObjectRequest(s3Input)
+	return req.Presign(expires)
+}
+
+// PutBucketLifecycle replaces the rules phoenix configured in the lifecycle configuration of Amazon S3 bucket with
+// the rules, at prefix. Rules configured outside phoenix are kept
+func (b AmazonS3Backend) PutBucketLifecycle(bucket string, rules []*auth.LifecycleRule) error {
+	configuration := &s3.BucketLifecycleConfiguration{}
+	current, err := b.Client.GetBucketLifecycleConfiguration(&s3.GetBucketLifecycleConfigurationInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != "NoSuchLifecycleConfiguration" {
+			return err
+		}
+	} else {
+		for _, rule := range current.Rules {
+			if !strings.HasPrefix(aws.StringValue(rule.ID), lifecycleRulePrefix) {
+				configuration.Rules = append(configuration.Rules, rule)
+			}
+		}
+	}
+	for _, rule := range rules {
+		// rules apply to the objects in the folder
+		prefix := pathutil.Join(b.Prefix, rule.Prefix)
+		if prefix != "" {
+			prefix += "/"
+		}
+		s3Rule := &s3.LifecycleRule{
+			ID:     aws.String(lifecycleRulePrefix + rule.ID),
+			Status: aws.String(s3.ExpirationStatusEnabled),
+			Filter: &s3.LifecycleRuleFilter{Prefix: aws.String(prefix)},
+		}
+		if rule.Action == auth.LifecycleArchive {
+			s3Rule.Transitions = []*s3.Transition{{
+				Days:         aws.Int64(int64(rule.Days)),
+				StorageClass: aws.String(rule.StorageClass),
+			}}
+		} else {
+			s3Rule.Expiration = &s3.LifecycleExpiration{Days: aws.Int64(int64(rule.Days))}
+		}
+		configuration.Rules = append(configuration.Rules, s3Rule)
+	}
+	if len(configuration.Rules) == 0 {
+		_, err := b.Client.DeleteBucketLifecycle(&s3.DeleteBucketLifecycleInput{
+			Bucket: aws.String(bucket),
+		})
+		return err
+	}
+	_, err = b.Client.PutBucketLifecycleConfiguration(&s3.PutBucketLifecycleConfigurationInput{
+		Bucket:                 aws.String(bucket),
+		LifecycleConfiguration: configuration,
+	})
+	return err
+}
+
+// ArchiveObject copies an object of Amazon S3 bucket onto itself in the storage class, unless it is in the storage
+// class already, at prefix
+func (b AmazonS3Backend) ArchiveObject(bucket, path, storageClass string) error {
+	key := pathutil.Join(b.Prefix, path)
+	head, err := b.Client.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return err
+	}
+	if aws.StringValue(head.StorageClass) == storageClass {
+		return nil
+	}
+	s3Input := &s3.CopyObjectInput{
+		Bucket:            aws.String(bucket),
+		Key:               aws.String(key),
+		CopySource:        aws.String((&url.URL{Path: bucket + "/" + key}).EscapedPath()),
+		MetadataDirective: aws.String(s3.MetadataDirectiveCopy),
+		StorageClass:      aws.String(storageClass),
+	}
+	if b.SSE != "" {
+		s3Input.ServerSideEncryption = aws.String(b.SSE)
+	}
+	_, err = b.Client.CopyObject(s3Input)
+	return err
+}
+
+// PutBucketRetention sets the default retention of objects uploaded to Amazon S3 bucket in compliance mode, or
+// removes it if days is zero. The bucket must have been created with object lock enabled
+func (b AmazonS3Backend) PutBucketRetention(bucket string, days int) error {
+	configuration := &s3.ObjectLockConfiguration{ObjectLockEnabled: aws.String(s3.ObjectLockEnabledEnabled)}
+	if days > 0 {
+		configuration.Rule = &s3.ObjectLockRule{DefaultRetention: &s3.DefaultRetention{
+			Mode: aws.String(s3.ObjectLockRetentionModeCompliance),
+			Days: aws.Int64(int64(days)),
+		}}
+	}
+	_, err := b.Client.PutObjectLockConfiguration(&s3.PutObjectLockConfigurationInput{
+		Bucket:                  aws.String(bucket),
+		ObjectLockConfiguration: configuration,
+	})
+	return err
+}
+
+// PutObjectRetention retains an object of Amazon S3 bucket in compliance mode until the time, at prefix
+func (b AmazonS3Backend) PutObjectRetention(bucket, path string, until time.Time) error {
+	_, err := b.Client.PutObjectRetention(&s3.PutObjectRetentionInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(pathutil.Join(b.Prefix, path)),
+		Retention: &s3.ObjectLockRetention{
+			Mode:            aws.String(s3.ObjectLockRetentionModeCompliance),
+			RetainUntilDate: aws.Time(until),
+		},
+	})
+	return err
+}
+
+// PutObjectLegalHold places or removes the legal hold of an object of Amazon S3 bucket, at prefix
+func (b AmazonS3Backend) PutObjectLegalHold(bucket, path string, on bool) error {
+	status := s3.ObjectLockLegalHoldStatusOff
+	if on {
+		status = s3.ObjectLockLegalHoldStatusOn
+	}
+	_, err := b.Client.PutObjectLegalHold(&s3.PutObjectLegalHoldInput{
+		Bucket:    aws.String(bucket),
+		Key:       aws.String(pathutil.Join(b.Prefix, path)),
+		LegalHold: &s3.ObjectLockLegalHold{Status: aws.String(status)},
+	})
+	return err
+}
@@ -41,6 +41,32 @@ type (
 		PutObject(bucket, path string, content []byte) error
 		DeleteObject(bucket, path string) error
 	}
+
+	// Presigner is implemented by backends that can hand out URLs to transfer an object directly, without the
+	// content passing through phoenix
+	Presigner interface {
+		PresignGetObject(bucket, path string, expires time.Duration) (string, error)
+		PresignPutObject(bucket, path string, expires time.Duration) (string, error)
+	}
+
+	// Lifecycler is implemented by backends that enforce lifecycle rules of a bucket by their own lifecycle
+	// configuration, the rules replace those put before and rules configured outside phoenix are kept
+	Lifecycler interface {
+		PutBucketLifecycle(bucket string, rules []*auth.LifecycleRule) error
+	}
+
+	// Archiver is implemented by backends that can move an object to a colder storage class
+	Archiver interface {
+		ArchiveObject(bucket, path, storageClass string) error
+	}
+
+	// ObjectLocker is implemented by backends that retain objects against changes and deletion themselves, for
+	// buckets they enabled it in
+	ObjectLocker interface {
+		PutBucketRetention(bucket string, days int) error
+		PutObjectRetention(bucket, path string, until time.Time) error
+		PutObjectLegalHold(bucket, path string, on bool) error
+	}
 )
 
 // HasExtension determines whether or not an object contains a file extension
@@ -0,0 +1,111 @@
+// Copyright (c) 2020 IoTeX
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+// meter exports usage meters of phoenix as csv or json for a time range, of the owner of the session, or of all
+// owners with -all and an operator session
+//
+//	meter -session $SESSION -granularity hour -from 2020-12-01 -to 2020-12-31 -format csv -o december.csv
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func main() {
+	endpoint := flag.String("url", "http://localhost:8080", "url of phoenix")
+	session := flag.String("session", os.Getenv("PHOENIX_SESSION"), "owner session, defaults to $PHOENIX_SESSION")
+	all := flag.Bool("all", false, "export meters of all owners, requires session of an operator")
+	owner := flag.String("owner", "", "export meters of the owner, requires session of an operator")
+	token := flag.String("token", "", "export meters of the token hash only")
+	from := flag.String("from", "", "start of time range, as date, RFC3339 or unix time")
+	to := flag.String("to", "", "end of time range, as date, RFC3339 or unix time")
+	granularity := flag.String("granularity", "day", "hour or day")
+	format := flag.String("format", "csv", "csv or json")
+	output := flag.String("o", "", "file to write, defaults to stdout")
+	flag.Parse()
+
+	if *session == "" {
+		exit(fmt.Errorf("session is required"))
+	}
+	query := url.Values{}
+	for name, v := range map[string]string{"from": *from, "to": *to} {
+		if v == "" {
+			continue
+		}
+		t, err := parseTime(v)
+		if err != nil {
+			exit(fmt.Errorf("%s: %v", name, err))
+		}
+		query.Set(name, strconv.FormatInt(t, 10))
+	}
+	query.Set("granularity", *granularity)
+	query.Set("format", *format)
+	if *token != "" {
+		query.Set("token", *token)
+	}
+	path := "/metering"
+	if *all || *owner != "" {
+		path = "/admin/metering"
+		if *owner != "" {
+			query.Set("owner", *owner)
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(*endpoint, "/")+path+"?"+query.Encode(), nil)
+	if err != nil {
+		exit(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+*session)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		exit(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		exit(fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body))))
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			exit(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		exit(err)
+	}
+}
+
+// parseTime parses date, RFC3339 time or unix time into unix time
+func parseTime(v string) (int64, error) {
+	if t, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02", time.RFC3339} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t.Unix(), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid time %s", v)
+}
+
+func exit(err error) {
+	fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+	os.Exit(1)
+}